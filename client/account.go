@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// CreateAccount creates a main account with its wallets and access tokens, it needs no token
+func (c *Client) CreateAccount(ctx context.Context, req *requests.CreateAccountRequest) (*responses.Response[*responses.CreateAccountResponseData], error) {
+	return call[*responses.Response[*responses.CreateAccountResponseData]](ctx, c, http.MethodPost, "/api/v1/accounts", req)
+}
+
+// UpdateWebhookURL sets the callback url and key webhook events are delivered with
+func (c *Client) UpdateWebhookURL(ctx context.Context, req *requests.UpdateWebhookURLRequest) error {
+	_, err := call[any](ctx, c, http.MethodPut, "/api/v1/accounts", req)
+	return err
+}
+
+// CreateSubAccount creates a sub-account
+func (c *Client) CreateSubAccount(ctx context.Context, req *requests.CreateSubAccountRequest) (*responses.Response[*models.Account], error) {
+	return call[*responses.Response[*models.Account]](ctx, c, http.MethodPost, "/api/v1/users", req)
+}
+
+// FetchAllSubAccounts lists a page of the sub-accounts
+func (c *Client) FetchAllSubAccounts(ctx context.Context, req *requests.FetchAllSubAccountsRequest) (*responses.Response[[]*models.Account], error) {
+	return call[*responses.Response[[]*models.Account]](ctx, c, http.MethodGet, "/api/v1/users", req)
+}
+
+// FetchAllSubAccountsIter walks every page of FetchAllSubAccounts, starting from the page of the request
+func (c *Client) FetchAllSubAccountsIter(req *requests.FetchAllSubAccountsRequest) *Iterator[*models.Account] {
+	r := *req
+	return newIterator(r.Pagination, func(ctx context.Context, page requests.Pagination) (*responses.Response[[]*models.Account], error) {
+		r.Pagination = page
+		return c.FetchAllSubAccounts(ctx, &r)
+	})
+}
+
+// EditSubAccountDetails edits a sub-account's details
+func (c *Client) EditSubAccountDetails(ctx context.Context, req *requests.EditSubAccountDetailsRequest) (*responses.Response[*models.Account], error) {
+	return call[*responses.Response[*models.Account]](ctx, c, http.MethodPut, "/api/v1/users/{user_id}", req)
+}
+
+// FetchAccountDetails fetches an account, `me` is the main account
+func (c *Client) FetchAccountDetails(ctx context.Context, req *requests.FetchAccountDetailsRequest) (*responses.Response[*models.Account], error) {
+	return call[*responses.Response[*models.Account]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}", req)
+}
+
+// FreezeSubAccount freezes a sub-account
+func (c *Client) FreezeSubAccount(ctx context.Context, req *requests.FreezeSubAccountRequest) (*responses.Response[*models.Account], error) {
+	return call[*responses.Response[*models.Account]](ctx, c, http.MethodPost, "/api/v1/users/{user_id}/freeze", req)
+}
+
+// UnfreezeSubAccount unfreezes a sub-account
+func (c *Client) UnfreezeSubAccount(ctx context.Context, req *requests.FreezeSubAccountRequest) (*responses.Response[*models.Account], error) {
+	return call[*responses.Response[*models.Account]](ctx, c, http.MethodPost, "/api/v1/users/{user_id}/unfreeze", req)
+}
+
+// FetchUserLimits lists an account's transaction limits and their usage
+func (c *Client) FetchUserLimits(ctx context.Context, req *requests.FetchUserLimitsRequest) (*responses.Response[[]*responses.UserLimitResponseData], error) {
+	return call[*responses.Response[[]*responses.UserLimitResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/limits", req)
+}
+
+// SetSubAccountLimit sets the limit a main account's sub-accounts of a kyc tier get in place of the tier's default
+func (c *Client) SetSubAccountLimit(ctx context.Context, req *requests.SetSubAccountLimitRequest) (*responses.Response[*responses.SubAccountLimitResponseData], error) {
+	return call[*responses.Response[*responses.SubAccountLimitResponseData]](ctx, c, http.MethodPut, "/api/v1/users/{user_id}/sub_account_limits", req)
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// StartReconciliation starts a reconciliation of the data database against the ledger
+func (c *Client) StartReconciliation(ctx context.Context) (*responses.Response[*models.ReconciliationReport], error) {
+	return call[*responses.Response[*models.ReconciliationReport]](ctx, c, http.MethodPost, "/api/v1/admin/reconciliations", nil)
+}
+
+// FetchReconciliationReports lists a page of the reconciliation reports
+func (c *Client) FetchReconciliationReports(ctx context.Context, req *requests.FetchReconciliationReportsRequest) (*responses.Response[[]*models.ReconciliationReport], error) {
+	return call[*responses.Response[[]*models.ReconciliationReport]](ctx, c, http.MethodGet, "/api/v1/admin/reconciliations", req)
+}
+
+// FetchReconciliationReportsIter walks every page of FetchReconciliationReports, starting from the page of the request
+func (c *Client) FetchReconciliationReportsIter(req *requests.FetchReconciliationReportsRequest) *Iterator[*models.ReconciliationReport] {
+	r := *req
+	return newIterator(r.Pagination, func(ctx context.Context, page requests.Pagination) (*responses.Response[[]*models.ReconciliationReport], error) {
+		r.Pagination = page
+		return c.FetchReconciliationReports(ctx, &r)
+	})
+}
+
+// FetchReconciliationReport fetches a reconciliation report
+func (c *Client) FetchReconciliationReport(ctx context.Context, req *requests.FetchReconciliationReportRequest) (*responses.Response[*models.ReconciliationReport], error) {
+	return call[*responses.Response[*models.ReconciliationReport]](ctx, c, http.MethodGet, "/api/v1/admin/reconciliations/{reconciliation_id}", req)
+}
+
+// FetchLedgerPositions lists the house's position on every ledger
+func (c *Client) FetchLedgerPositions(ctx context.Context, req *requests.FetchLedgerPositionsRequest) (*responses.Response[[]*responses.LedgerPositionResponseData], error) {
+	return call[*responses.Response[[]*responses.LedgerPositionResponseData]](ctx, c, http.MethodGet, "/api/v1/admin/ledgers", req)
+}
+
+// StartLiabilitySnapshot starts a liability snapshot of an environment
+func (c *Client) StartLiabilitySnapshot(ctx context.Context, req *requests.StartLiabilitySnapshotRequest) (*responses.Response[*models.LiabilitySnapshot], error) {
+	return call[*responses.Response[*models.LiabilitySnapshot]](ctx, c, http.MethodPost, "/api/v1/admin/proofs", req)
+}
+
+// AdminFetchLiabilitySnapshot fetches a liability snapshot, including running and failed ones
+func (c *Client) AdminFetchLiabilitySnapshot(ctx context.Context, req *requests.FetchLiabilitySnapshotRequest) (*responses.Response[*models.LiabilitySnapshot], error) {
+	return call[*responses.Response[*models.LiabilitySnapshot]](ctx, c, http.MethodGet, "/api/v1/admin/proofs/{snapshot_id}", req)
+}
+
+// SetTokenRateLimit sets an access token's rate limit for a route group in place of the group's default
+func (c *Client) SetTokenRateLimit(ctx context.Context, req *requests.SetTokenRateLimitRequest) (*responses.Response[*models.RateLimit], error) {
+	return call[*responses.Response[*models.RateLimit]](ctx, c, http.MethodPut, "/api/v1/admin/tokens/{token_id}/rate_limits", req)
+}
@@ -0,0 +1,327 @@
+// Package client calls the quidax-go api from Go services. Every route the handlers register has a typed method
+// taking the route's request type from types/requests and returning its response type from types/responses.
+// Requests are retried on network errors, rate limits and server errors. Requests other than GETs are sent with
+// an Idempotency-Key header, routes authenticated with a secret key answer a retry with the response of the first
+// request instead of applying it again. Failed calls return the errors.AppError the api responded with
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	mrand "math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+)
+
+const (
+	DefaultMaxRetries = 3
+	DefaultMinBackoff = 250 * time.Millisecond
+	DefaultMaxBackoff = 10 * time.Second
+)
+
+// Client calls the api with a secret key, or the admin token for the admin routes. It is safe for concurrent use
+type Client struct {
+	base       string
+	token      string
+	http       *http.Client
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with the http client instead of http.DefaultClient
+func WithHTTPClient(http *http.Client) Option {
+	return func(c *Client) {
+		c.http = http
+	}
+}
+
+// WithMaxRetries sets how many times a failed request is retried, zero disables retries
+func WithMaxRetries(n int) Option {
+	return func(c *Client) {
+		c.maxRetries = max(n, 0)
+	}
+}
+
+// WithBackoff sets the wait before the first retry, which doubles on every retry up to the max. A Retry-After
+// header sent by the api takes precedence
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff, c.maxBackoff = min, max
+	}
+}
+
+// New returns a client for the api served at the base url, such as `https://api.example.com`, authenticating
+// with the token
+func New(baseURL string, token string, opts ...Option) *Client {
+	c := &Client{
+		base:       strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		http:       http.DefaultClient,
+		maxRetries: DefaultMaxRetries,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithToken returns a copy of the client authenticating with the token
+func (c *Client) WithToken(token string) *Client {
+	clone := *c
+	clone.token = token
+	return &clone
+}
+
+type idempotencyKey struct{}
+
+// ContextWithIdempotencyKey makes the call made with the returned context send the key instead of a generated
+// one, for callers that store the key to retry the call after a restart. The api keeps keys for 24 hours
+func ContextWithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKey{}, key)
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// call sends the request of the route and decodes the response into a T, its path wildcards and query parameters
+// are read from the request's `uri` and `query` fields and its other fields make up the JSON body
+func call[T any](ctx context.Context, c *Client, method, path string, req any) (T, error) {
+	var out T
+	res, err := c.do(ctx, method, path, req)
+	if err != nil {
+		return out, err
+	}
+	defer res.Body.Close()
+
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		return out, err
+	}
+	if len(content) == 0 {
+		return out, nil
+	}
+	if err = json.Unmarshal(content, &out); err != nil {
+		return out, fmt.Errorf("%s %s: decoding response: %w", method, path, err)
+	}
+	return out, nil
+}
+
+// Download is a file the api responded with, the caller must close its body
+type Download struct {
+	ContentType string
+	// name the api suggests saving the file as, empty when it does not suggest one
+	Filename string
+	Body     io.ReadCloser
+}
+
+func newDownload(res *http.Response) *Download {
+	download := &Download{ContentType: res.Header.Get("content-type"), Body: res.Body}
+	if _, params, err := mime.ParseMediaType(res.Header.Get("content-disposition")); err == nil {
+		download.Filename = params["filename"]
+	}
+	return download
+}
+
+// download sends the request of a route responding with a file
+func (c *Client) download(ctx context.Context, method, path string, req any) (*Download, error) {
+	res, err := c.do(ctx, method, path, req)
+	if err != nil {
+		return nil, err
+	}
+	return newDownload(res), nil
+}
+
+// do sends the request of the route, retrying it while it fails with an error worth retrying. The caller must
+// close the body of the response, which has a 2xx or 3xx status
+func (c *Client) do(ctx context.Context, method, path string, req any) (*http.Response, error) {
+	target, body, err := encode(path, req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, path, err)
+	}
+
+	var key string
+	if method != http.MethodGet && method != http.MethodHead {
+		if key, _ = ctx.Value(idempotencyKey{}).(string); key == "" {
+			key = newIdempotencyKey()
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		var payload io.Reader
+		if body != nil {
+			payload = bytes.NewReader(body)
+		}
+		r, err := http.NewRequestWithContext(ctx, method, c.base+target, payload)
+		if err != nil {
+			return nil, err
+		}
+		r.Header.Set("accept", "application/json")
+		if body != nil {
+			r.Header.Set("content-type", "application/json")
+		}
+		if c.token != "" {
+			r.Header.Set("authorization", "Bearer "+c.token)
+		}
+		if key != "" {
+			r.Header.Set("idempotency-key", key)
+		}
+
+		res, err := c.http.Do(r)
+		if err == nil && res.StatusCode < 400 {
+			return res, nil
+		}
+		if err == nil {
+			err = decodeError(res)
+		}
+		if attempt >= c.maxRetries || !retryable(ctx, res) {
+			return nil, err
+		}
+
+		timer := time.NewTimer(c.backoff(attempt, res))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// decodeError reads the AppError in the body of a failed response and closes it. Bodies that are not an
+// AppError, such as ones from a proxy, are kept in the error's internal message
+func decodeError(res *http.Response) error {
+	defer res.Body.Close()
+	content, _ := io.ReadAll(res.Body)
+
+	appErr := errors.AppError{Code: res.StatusCode}
+	if err := json.Unmarshal(content, &appErr); err != nil || appErr.Type == "" {
+		return errors.AppError{
+			Code:     res.StatusCode,
+			Type:     errors.ErrFailedDependency,
+			Message:  http.StatusText(res.StatusCode),
+			Internal: string(content),
+		}
+	}
+	return appErr
+}
+
+// retryable reports whether a request that failed with the response, or without one when it is nil, may succeed
+// when it is sent again
+func retryable(ctx context.Context, res *http.Response) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if res == nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		// the api asks for a retry when a request with the same idempotency key is still being handled
+		return res.Header.Get("retry-after") != ""
+	default:
+		return false
+	}
+}
+
+// backoff returns how long to wait before retrying the request for the attempt, with jitter so clients do not
+// retry in step
+func (c *Client) backoff(attempt int, res *http.Response) time.Duration {
+	if res != nil {
+		if seconds, err := strconv.Atoi(res.Header.Get("retry-after")); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	wait := c.minBackoff << attempt
+	if wait <= 0 || wait > c.maxBackoff {
+		wait = c.maxBackoff
+	}
+	return wait/2 + mrand.N(wait/2+1)
+}
+
+// encode returns the path with its wildcards replaced by the request's `uri` fields and its `query` fields as
+// the query string, along with a JSON body of its other fields. The body is nil when the request has none
+func encode(path string, req any) (string, []byte, error) {
+	query := url.Values{}
+	fields := make(map[string]any)
+
+	if v := reflect.Indirect(reflect.ValueOf(req)); v.IsValid() {
+		if v.Kind() != reflect.Struct {
+			return "", nil, fmt.Errorf("request of type %s is not a struct", v.Type())
+		}
+		for _, field := range reflect.VisibleFields(v.Type()) {
+			if !field.IsExported() || field.Anonymous {
+				continue
+			}
+			value := v.FieldByIndex(field.Index)
+			uri, inPath := field.Tag.Lookup("uri")
+			if inPath && strings.Contains(path, "{"+uri+"}") {
+				if value.String() == "" {
+					return "", nil, fmt.Errorf("request is missing a value for %s", uri)
+				}
+				path = strings.ReplaceAll(path, "{"+uri+"}", url.PathEscape(value.String()))
+			}
+			name, inQuery := field.Tag.Lookup("query")
+			if inQuery {
+				if s, ok := queryValue(value); ok {
+					query.Set(name, s)
+				}
+			}
+			if name, ok := field.Tag.Lookup("json"); ok && !inPath && !inQuery {
+				if name, _, _ = strings.Cut(name, ","); name != "-" && name != "" {
+					fields[name] = value.Interface()
+				}
+			}
+		}
+	}
+	if strings.Contains(path, "{") {
+		return "", nil, fmt.Errorf("request is missing a value for a wildcard of %s", path)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	if len(fields) == 0 {
+		return path, nil, nil
+	}
+	body, err := json.Marshal(fields)
+	return path, body, err
+}
+
+// queryValue formats a query field the way the api parses it, enums by their name. Zero values are left out so the
+// api applies its defaults
+func queryValue(v reflect.Value) (string, bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", false
+		}
+		v = v.Elem()
+	} else if v.IsZero() {
+		return "", false
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String(), true
+	}
+	return fmt.Sprint(v.Interface()), true
+}
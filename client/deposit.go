@@ -0,0 +1,47 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// DepositAmount deposits an amount into a wallet, in the test environment only
+func (c *Client) DepositAmount(ctx context.Context, req *requests.DepositAmountRequest) (*responses.Response[*responses.DepositResponseData], error) {
+	return call[*responses.Response[*responses.DepositResponseData]](ctx, c, http.MethodPost, "/api/v1/users/{user_id}/deposits/{currency}", req)
+}
+
+// FetchDeposits lists a page of an account's deposits
+func (c *Client) FetchDeposits(ctx context.Context, req *requests.FetchDepositsRequest) (*responses.Response[[]*responses.DepositResponseData], error) {
+	return call[*responses.Response[[]*responses.DepositResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/deposits", req)
+}
+
+// FetchDepositsIter walks every page of FetchDeposits, starting from the page of the request
+func (c *Client) FetchDepositsIter(req *requests.FetchDepositsRequest) *Iterator[*responses.DepositResponseData] {
+	r := *req
+	return newIterator(r.Pagination, func(ctx context.Context, page requests.Pagination) (*responses.Response[[]*responses.DepositResponseData], error) {
+		r.Pagination = page
+		return c.FetchDeposits(ctx, &r)
+	})
+}
+
+// FetchCurrencyDeposits lists a page of an account's deposits in a currency
+func (c *Client) FetchCurrencyDeposits(ctx context.Context, req *requests.FetchDepositsRequest) (*responses.Response[[]*responses.DepositResponseData], error) {
+	return call[*responses.Response[[]*responses.DepositResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/deposits/currency/{currency}", req)
+}
+
+// FetchCurrencyDepositsIter walks every page of FetchCurrencyDeposits, starting from the page of the request
+func (c *Client) FetchCurrencyDepositsIter(req *requests.FetchDepositsRequest) *Iterator[*responses.DepositResponseData] {
+	r := *req
+	return newIterator(r.Pagination, func(ctx context.Context, page requests.Pagination) (*responses.Response[[]*responses.DepositResponseData], error) {
+		r.Pagination = page
+		return c.FetchCurrencyDeposits(ctx, &r)
+	})
+}
+
+// FetchDeposit fetches a deposit
+func (c *Client) FetchDeposit(ctx context.Context, req *requests.FetchDepositRequest) (*responses.Response[*responses.DepositResponseData], error) {
+	return call[*responses.Response[*responses.DepositResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/deposits/{transaction_id}", req)
+}
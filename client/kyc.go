@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// SubmitKYC submits a sub-account's kyc details for a tier
+func (c *Client) SubmitKYC(ctx context.Context, req *requests.SubmitKYCRequest) (*responses.Response[*models.KYCSubmission], error) {
+	return call[*responses.Response[*models.KYCSubmission]](ctx, c, http.MethodPost, "/api/v1/users/{user_id}/kyc", req)
+}
+
+// FetchKYCSubmissions lists a page of a sub-account's kyc submissions
+func (c *Client) FetchKYCSubmissions(ctx context.Context, req *requests.FetchKYCSubmissionsRequest) (*responses.Response[[]*models.KYCSubmission], error) {
+	return call[*responses.Response[[]*models.KYCSubmission]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/kyc", req)
+}
+
+// FetchKYCSubmissionsIter walks every page of FetchKYCSubmissions, starting from the page of the request
+func (c *Client) FetchKYCSubmissionsIter(req *requests.FetchKYCSubmissionsRequest) *Iterator[*models.KYCSubmission] {
+	r := *req
+	return newIterator(r.Pagination, func(ctx context.Context, page requests.Pagination) (*responses.Response[[]*models.KYCSubmission], error) {
+		r.Pagination = page
+		return c.FetchKYCSubmissions(ctx, &r)
+	})
+}
+
+// ReviewKYCSubmission approves or rejects a pending kyc submission
+func (c *Client) ReviewKYCSubmission(ctx context.Context, req *requests.ReviewKYCSubmissionRequest) (*responses.Response[*models.KYCSubmission], error) {
+	return call[*responses.Response[*models.KYCSubmission]](ctx, c, http.MethodPost, "/api/v1/users/{user_id}/kyc/{submission_id}/review", req)
+}
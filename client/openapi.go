@@ -0,0 +1,18 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/2HgO/quidax-go/openapi"
+)
+
+// FetchOpenAPIDocument fetches the OpenAPI document of the api, it needs no token
+func (c *Client) FetchOpenAPIDocument(ctx context.Context) (*openapi.Document, error) {
+	return call[*openapi.Document](ctx, c, http.MethodGet, "/api/v1/openapi.json", nil)
+}
+
+// FetchDocs downloads the html page browsing the OpenAPI document, it needs no token
+func (c *Client) FetchDocs(ctx context.Context) (*Download, error) {
+	return c.download(ctx, http.MethodGet, "/api/v1/docs", nil)
+}
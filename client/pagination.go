@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// Iterator walks the items of every page of a list route, fetching the next page once the items of the current
+// one have been read. Pages after the first are fetched with the cursor the api returns when it has one
+//
+//	it := c.FetchWithdrawalsIter(&requests.FetchWithdrawalsRequest{UserID: "me"})
+//	for it.Next(ctx) {
+//		process(it.Item())
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+type Iterator[T any] struct {
+	fetch func(context.Context, requests.Pagination) (*responses.Response[[]T], error)
+	page  requests.Pagination
+	items []T
+	item  T
+	last  bool
+	err   error
+	// pagination of the last page fetched, nil until the first page has been fetched
+	pagination *responses.Pagination
+}
+
+func newIterator[T any](page requests.Pagination, fetch func(context.Context, requests.Pagination) (*responses.Response[[]T], error)) *Iterator[T] {
+	return &Iterator[T]{fetch: fetch, page: page}
+}
+
+// Next moves to the next item, fetching the next page when the items of the current one have been read. It
+// returns false once every item has been read or a page could not be fetched
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	for len(it.items) == 0 {
+		if it.last || it.err != nil {
+			return false
+		}
+		res, err := it.fetch(ctx, it.page)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.items, it.pagination = res.Data, res.Pagination
+		it.last = !it.advance()
+	}
+
+	it.item, it.items = it.items[0], it.items[1:]
+	return true
+}
+
+// advance moves the page to the one after the page fetched, it returns false when that was the last page
+func (it *Iterator[T]) advance() bool {
+	p := it.pagination
+	switch {
+	case p == nil || len(it.items) == 0:
+		return false
+	case p.NextCursor != "":
+		it.page.Cursor = p.NextCursor
+		return true
+	case it.page.Cursor == "" && p.Page*p.PerPage < p.Total:
+		it.page.Page = p.Page + 1
+		return true
+	default:
+		return false
+	}
+}
+
+// Item returns the item Next moved to
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+// Err returns the error the last page failed with, nil when every page was fetched
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Pagination returns the pagination of the page fetched last, nil before the first page has been fetched
+func (it *Iterator[T]) Pagination() *responses.Pagination {
+	return it.pagination
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// FetchLiabilitySnapshots lists a page of the published liability snapshots, it needs no token
+func (c *Client) FetchLiabilitySnapshots(ctx context.Context, req *requests.FetchLiabilitySnapshotsRequest) (*responses.Response[[]*models.LiabilitySnapshot], error) {
+	return call[*responses.Response[[]*models.LiabilitySnapshot]](ctx, c, http.MethodGet, "/api/v1/proofs", req)
+}
+
+// FetchLiabilitySnapshotsIter walks every page of FetchLiabilitySnapshots, starting from the page of the request
+func (c *Client) FetchLiabilitySnapshotsIter(req *requests.FetchLiabilitySnapshotsRequest) *Iterator[*models.LiabilitySnapshot] {
+	r := *req
+	return newIterator(r.Pagination, func(ctx context.Context, page requests.Pagination) (*responses.Response[[]*models.LiabilitySnapshot], error) {
+		r.Pagination = page
+		return c.FetchLiabilitySnapshots(ctx, &r)
+	})
+}
+
+// FetchLiabilitySnapshot fetches a published liability snapshot, it needs no token
+func (c *Client) FetchLiabilitySnapshot(ctx context.Context, req *requests.FetchLiabilitySnapshotRequest) (*responses.Response[*models.LiabilitySnapshot], error) {
+	return call[*responses.Response[*models.LiabilitySnapshot]](ctx, c, http.MethodGet, "/api/v1/proofs/{snapshot_id}", req)
+}
+
+// FetchLiabilityProof fetches the inclusion proofs of an account's balances in a snapshot
+func (c *Client) FetchLiabilityProof(ctx context.Context, req *requests.FetchLiabilityProofRequest) (*responses.Response[*responses.LiabilityProofResponseData], error) {
+	return call[*responses.Response[*responses.LiabilityProofResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/proofs/{snapshot_id}", req)
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// TemporaryInstantSwapQuotation quotes a swap without holding funds
+func (c *Client) TemporaryInstantSwapQuotation(ctx context.Context, req *requests.CreateInstantSwapRequest) (*responses.Response[*responses.QuoteInstantSwapResponseData], error) {
+	return call[*responses.Response[*responses.QuoteInstantSwapResponseData]](ctx, c, http.MethodPost, "/api/v1/users/{user_id}/temporary_swap_quotation", req)
+}
+
+// CreateInstantSwap quotes a swap, holding the amount until the quotation is confirmed or expires
+func (c *Client) CreateInstantSwap(ctx context.Context, req *requests.CreateInstantSwapRequest) (*responses.Response[*responses.InstantSwapQuotationResponseData], error) {
+	return call[*responses.Response[*responses.InstantSwapQuotationResponseData]](ctx, c, http.MethodPost, "/api/v1/users/{user_id}/swap_quotation", req)
+}
+
+// ConfirmInstantSwap confirms a quotation, the swap is processed in the background
+func (c *Client) ConfirmInstantSwap(ctx context.Context, req *requests.ConfirmInstanSwapRequest) (*responses.Response[*responses.InstantSwapResponseData], error) {
+	return call[*responses.Response[*responses.InstantSwapResponseData]](ctx, c, http.MethodPost, "/api/v1/users/{user_id}/swap_quotation/{quotation_id}/confirm", req)
+}
+
+// FetchInstantSwapTransaction fetches a swap
+func (c *Client) FetchInstantSwapTransaction(ctx context.Context, req *requests.FetchInstantSwapTransactionRequest) (*responses.Response[*responses.InstantSwapResponseData], error) {
+	return call[*responses.Response[*responses.InstantSwapResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/swap_transactions/{swap_transaction_id}", req)
+}
+
+// GetInstantSwapTransactions lists a page of an account's swaps
+func (c *Client) GetInstantSwapTransactions(ctx context.Context, req *requests.GetInstantSwapTransactionsRequest) (*responses.Response[[]*responses.InstantSwapResponseData], error) {
+	return call[*responses.Response[[]*responses.InstantSwapResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/swap_transactions", req)
+}
+
+// GetInstantSwapTransactionsIter walks every page of GetInstantSwapTransactions, starting from the page of the request
+func (c *Client) GetInstantSwapTransactionsIter(req *requests.GetInstantSwapTransactionsRequest) *Iterator[*responses.InstantSwapResponseData] {
+	r := *req
+	return newIterator(r.Pagination, func(ctx context.Context, page requests.Pagination) (*responses.Response[[]*responses.InstantSwapResponseData], error) {
+		r.Pagination = page
+		return c.GetInstantSwapTransactions(ctx, &r)
+	})
+}
+
+// FetchMarketTicker fetches the ticker of a market such as `usdtngn`
+func (c *Client) FetchMarketTicker(ctx context.Context, market string) (map[string]any, error) {
+	req := struct {
+		Market string `uri:"market"`
+	}{market}
+	return call[map[string]any](ctx, c, http.MethodGet, "/api/v1/markets/tickers/{market}", &req)
+}
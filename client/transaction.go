@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// FetchTransactions lists a page of the ledger transfers of an account's wallets, newest first
+func (c *Client) FetchTransactions(ctx context.Context, req *requests.FetchTransactionsRequest) (*responses.Response[[]*responses.TransactionResponseData], error) {
+	return call[*responses.Response[[]*responses.TransactionResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/transactions", req)
+}
+
+// FetchTransactionsIter walks every page of FetchTransactions, starting from the page of the request
+func (c *Client) FetchTransactionsIter(req *requests.FetchTransactionsRequest) *Iterator[*responses.TransactionResponseData] {
+	r := *req
+	return newIterator(r.Pagination, func(ctx context.Context, page requests.Pagination) (*responses.Response[[]*responses.TransactionResponseData], error) {
+		r.Pagination = page
+		return c.FetchTransactions(ctx, &r)
+	})
+}
+
+// ExportStatement exports a statement. Small statements are streamed back as a download, large or async ones are
+// exported in the background and the export is returned instead, to be fetched until it has completed
+func (c *Client) ExportStatement(ctx context.Context, req *requests.ExportStatementRequest) (*responses.Response[*models.StatementExport], *Download, error) {
+	const path = "/api/v1/users/{user_id}/statements"
+	res, err := c.do(ctx, http.MethodGet, path, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if res.StatusCode != http.StatusAccepted {
+		return nil, newDownload(res), nil
+	}
+	defer res.Body.Close()
+
+	export := new(responses.Response[*models.StatementExport])
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = json.Unmarshal(content, export); err != nil {
+		return nil, nil, fmt.Errorf("%s %s: decoding response: %w", http.MethodGet, path, err)
+	}
+	return export, nil, nil
+}
+
+// FetchStatementExport fetches a statement export
+func (c *Client) FetchStatementExport(ctx context.Context, req *requests.FetchStatementExportRequest) (*responses.Response[*models.StatementExport], error) {
+	return call[*responses.Response[*models.StatementExport]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/statements/{export_id}", req)
+}
+
+// DownloadStatementExport downloads a completed statement export
+func (c *Client) DownloadStatementExport(ctx context.Context, req *requests.FetchStatementExportRequest) (*Download, error) {
+	return c.download(ctx, http.MethodGet, "/api/v1/users/{user_id}/statements/{export_id}/download", req)
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// FetchUserWallets lists an account's wallets
+func (c *Client) FetchUserWallets(ctx context.Context, req *requests.FetchUserWalletsRequest) (*responses.Response[[]*responses.UserWalletResponseData], error) {
+	return call[*responses.Response[[]*responses.UserWalletResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/wallets", req)
+}
+
+// FetchUserWallet fetches an account's wallet
+func (c *Client) FetchUserWallet(ctx context.Context, req *requests.FetchUserWalletRequest) (*responses.Response[*responses.UserWalletResponseData], error) {
+	return call[*responses.Response[*responses.UserWalletResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/wallets/{currency}", req)
+}
+
+// FetchPaymentAddress fetches a wallet's deposit address, deposits to addresses are not supported
+func (c *Client) FetchPaymentAddress(ctx context.Context, req *requests.FetchUserWalletRequest) (*responses.Response[map[string]any], error) {
+	return call[*responses.Response[map[string]any]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/wallets/{currency}/address", req)
+}
+
+// FetchPaymentAddresses lists a wallet's deposit addresses, deposits to addresses are not supported
+func (c *Client) FetchPaymentAddresses(ctx context.Context, req *requests.FetchUserWalletRequest) (*responses.Response[[]map[string]any], error) {
+	return call[*responses.Response[[]map[string]any]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/wallets/{currency}/addresses", req)
+}
+
+// FreezeUserWallet freezes a wallet
+func (c *Client) FreezeUserWallet(ctx context.Context, req *requests.FreezeUserWalletRequest) (*responses.Response[*responses.UserWalletResponseData], error) {
+	return call[*responses.Response[*responses.UserWalletResponseData]](ctx, c, http.MethodPost, "/api/v1/users/{user_id}/wallets/{currency}/freeze", req)
+}
+
+// UnfreezeUserWallet unfreezes a wallet
+func (c *Client) UnfreezeUserWallet(ctx context.Context, req *requests.FreezeUserWalletRequest) (*responses.Response[*responses.UserWalletResponseData], error) {
+	return call[*responses.Response[*responses.UserWalletResponseData]](ctx, c, http.MethodPost, "/api/v1/users/{user_id}/wallets/{currency}/unfreeze", req)
+}
+
+// FetchWalletBalance fetches a wallet's balance at a time or before a transaction
+func (c *Client) FetchWalletBalance(ctx context.Context, req *requests.FetchWalletBalanceRequest) (*responses.Response[*responses.WalletBalanceResponseData], error) {
+	return call[*responses.Response[*responses.WalletBalanceResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/wallets/{currency}/balances", req)
+}
+
+// FetchWalletBalanceHistory lists a page of a wallet's balances after each of its transfers, newest first
+func (c *Client) FetchWalletBalanceHistory(ctx context.Context, req *requests.FetchWalletBalanceHistoryRequest) (*responses.Response[[]*responses.WalletBalanceResponseData], error) {
+	return call[*responses.Response[[]*responses.WalletBalanceResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/wallets/{currency}/balances/history", req)
+}
+
+// FetchWalletBalanceHistoryIter walks every page of FetchWalletBalanceHistory, starting from the page of the request
+func (c *Client) FetchWalletBalanceHistoryIter(req *requests.FetchWalletBalanceHistoryRequest) *Iterator[*responses.WalletBalanceResponseData] {
+	r := *req
+	return newIterator(r.Pagination, func(ctx context.Context, page requests.Pagination) (*responses.Response[[]*responses.WalletBalanceResponseData], error) {
+		r.Pagination = page
+		return c.FetchWalletBalanceHistory(ctx, &r)
+	})
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader is the header webhook deliveries are signed in
+const SignatureHeader = "quidax-signature"
+
+// WebhookEvent is the body of a webhook delivery, data is decoded by the receiver according to the event
+type WebhookEvent struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// WebhookVerifier checks the signature of webhook deliveries made with the webhook key of an account
+type WebhookVerifier struct {
+	key string
+	// deliveries signed longer ago than this are rejected, zero accepts any age
+	tolerance time.Duration
+}
+
+// NewWebhookVerifier returns a verifier for the webhook key set with UpdateWebhookURL. Deliveries signed more than
+// the tolerance ago, or that far in the future, are rejected so a captured delivery can not be replayed later.
+// A zero tolerance accepts any age
+func NewWebhookVerifier(key string, tolerance time.Duration) *WebhookVerifier {
+	return &WebhookVerifier{key: key, tolerance: tolerance}
+}
+
+// Verify checks a `ts=<unix seconds>,sig=<hex hmac>` signature of the body, the hmac is a sha256 one of the
+// timestamp and the body with its slashes escaped joined by a dot
+func (v *WebhookVerifier) Verify(header string, body []byte) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "ts":
+			ts = value
+		case "sig":
+			sig = value
+		}
+	}
+	if ts == "" || sig == "" {
+		return fmt.Errorf("malformed webhook signature %q", header)
+	}
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed webhook signature timestamp %q", ts)
+	}
+
+	mac := hmac.New(sha256.New, []byte(v.key))
+	mac.Write([]byte(ts + "." + strings.ReplaceAll(string(body), "/", "\\/")))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(strings.ToLower(sig))) {
+		return fmt.Errorf("webhook signature does not match the body")
+	}
+
+	if age := time.Since(time.Unix(seconds, 0)); v.tolerance > 0 && (age > v.tolerance || age < -v.tolerance) {
+		return fmt.Errorf("webhook was signed %s ago, outside the tolerance of %s", age.Round(time.Second), v.tolerance)
+	}
+	return nil
+}
+
+// Event verifies the signature of the delivery and decodes its body
+func (v *WebhookVerifier) Event(header string, body []byte) (*WebhookEvent, error) {
+	if err := v.Verify(header, body); err != nil {
+		return nil, err
+	}
+	event := new(WebhookEvent)
+	if err := json.Unmarshal(body, event); err != nil {
+		return nil, fmt.Errorf("decoding webhook event: %w", err)
+	}
+	return event, nil
+}
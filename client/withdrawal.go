@@ -0,0 +1,38 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// CreateWithdrawal sends funds to another account
+func (c *Client) CreateWithdrawal(ctx context.Context, req *requests.CreateWithdrawalRequest) (*responses.Response[*responses.WithdrawalResponseData], error) {
+	return call[*responses.Response[*responses.WithdrawalResponseData]](ctx, c, http.MethodPost, "/api/v1/users/{user_id}/withdraws", req)
+}
+
+// FetchWithdrawals lists a page of an account's withdrawals
+func (c *Client) FetchWithdrawals(ctx context.Context, req *requests.FetchWithdrawalsRequest) (*responses.Response[[]*responses.WithdrawalResponseData], error) {
+	return call[*responses.Response[[]*responses.WithdrawalResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/withdraws", req)
+}
+
+// FetchWithdrawalsIter walks every page of FetchWithdrawals, starting from the page of the request
+func (c *Client) FetchWithdrawalsIter(req *requests.FetchWithdrawalsRequest) *Iterator[*responses.WithdrawalResponseData] {
+	r := *req
+	return newIterator(r.Pagination, func(ctx context.Context, page requests.Pagination) (*responses.Response[[]*responses.WithdrawalResponseData], error) {
+		r.Pagination = page
+		return c.FetchWithdrawals(ctx, &r)
+	})
+}
+
+// FetchWithdrawalByRef fetches a withdrawal by its reference
+func (c *Client) FetchWithdrawalByRef(ctx context.Context, req *requests.FetchWithdrawalRequest) (*responses.Response[*responses.WithdrawalResponseData], error) {
+	return call[*responses.Response[*responses.WithdrawalResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/withdraws/reference/{reference}", req)
+}
+
+// FetchWithdrawal fetches a withdrawal
+func (c *Client) FetchWithdrawal(ctx context.Context, req *requests.FetchWithdrawalRequest) (*responses.Response[*responses.WithdrawalResponseData], error) {
+	return call[*responses.Response[*responses.WithdrawalResponseData]](ctx, c, http.MethodGet, "/api/v1/users/{user_id}/withdraws/{withdrawal_id}", req)
+}
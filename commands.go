@@ -0,0 +1,611 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/2HgO/quidax-go/config"
+	"github.com/2HgO/quidax-go/db/migrations"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/fixtures"
+	"github.com/2HgO/quidax-go/handlers"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/services"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/utils"
+	"go.uber.org/fx"
+)
+
+// command is a subcommand of the binary, its name is one or two words such as `tokens issue`
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(app fx.Option, args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "", "start the http server, the default when no command is given", serve},
+		{"migrate", "up | down [steps] | status", "apply, revert or list the data database's schema migrations", migrate},
+		{"seed", "<file>", "apply a fixture file of accounts, balances and history, steps seeded before are skipped", seed},
+		{"reconcile", "", "run a reconciliation and print its report", reconcile},
+		{"config", "", "print the effective config with secrets redacted", printConfig},
+		{"openapi", "", "print the openapi document of the api", printOpenAPI},
+		{"accounts create", "-email <email> -password <password> -first-name <name> -last-name <name> -display-name <name>", "create a main account with its wallets and access tokens", createAccount},
+		{"tokens issue", "-user <user_id> [-environment test|live] -name <name> [-description <description>] [-rate-limit <route_group>:<rate>:<burst>]...", "issue an access token to a main account, rate limits replace the route group's default for the token", issueToken},
+		{"tokens revoke", "<token_id>", "revoke an access token", revokeToken},
+		{"limits set", "-user <user_id> -tier 1|2 -currency <currency> -operation withdrawal|swap [-single <amount>] [-daily <amount>] [-monthly <amount>]", "set the limit a main account's sub accounts of a kyc tier get, unset caps fall back to the tier's default", setSubAccountLimit},
+		{"webhooks replay", "[-environment test|live] <user_id> swap|withdrawal|deposit <id>", "deliver the event for a swap, withdrawal or deposit again", replayWebhook},
+		{"ledger inspect", "[-transfers <n>] <id>", "print a ledger account with its latest transfers, or a transfer", inspectLedger},
+	}
+}
+
+// run dispatches the arguments to their command, the server is started when no command is given
+func run(app fx.Option, args []string) int {
+	if len(args) == 0 {
+		return serve(app, nil)
+	}
+	for _, c := range commands {
+		words := strings.Fields(c.name)
+		if len(args) >= len(words) && slices.Equal(args[:len(words)], words) {
+			return c.run(app, args[len(words):])
+		}
+	}
+
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(os.Stdout)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", strings.Join(args, " "))
+	usage(os.Stderr)
+	return 1
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: quidax-go <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.name, c.summary)
+	}
+	tw.Flush()
+}
+
+// badUsage prints the command's usage and returns the exit code for invalid arguments
+func badUsage(name string) int {
+	for _, c := range commands {
+		if c.name == name {
+			fmt.Fprintln(os.Stderr, strings.TrimSpace("usage: quidax-go "+c.name+" "+c.args))
+		}
+	}
+	return 1
+}
+
+// flags returns the command's flag set, parse errors are reported with the command's usage
+func flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		badUsage(name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// start builds the application's services into the targets and starts the application, stop stops it again
+func start(app fx.Option, targets ...any) (stop func(), err error) {
+	cmd := fx.New(app, fx.NopLogger, fx.Populate(targets...))
+	if err = cmd.Err(); err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(context.Background()); err != nil {
+		return nil, err
+	}
+	return func() { cmd.Stop(context.Background()) }, nil
+}
+
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, err)
+	return 1
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// operator returns a context acting as an admin in the environment, commands are run by operators
+func operator(env models.Environment) context.Context {
+	principal := models.NewAdminPrincipal()
+	principal.Environment = env
+	return models.ContextWithPrincipal(context.Background(), principal)
+}
+
+func parseEnvironment(value string) (models.Environment, error) {
+	var env models.Environment
+	err := env.UnmarshalJSON([]byte(value))
+	return env, err
+}
+
+// validate checks a request built from command arguments the way bound http requests are checked
+func validate(req any) error {
+	if err := utils.Validator.Validate(req); err != nil {
+		return errors.HandleBindError(err)
+	}
+	return nil
+}
+
+func serve(app fx.Option, args []string) int {
+	if len(args) > 0 {
+		return badUsage("serve")
+	}
+	fx.New(
+		app,
+		fx.Invoke(AutoMigrate),
+		fx.Invoke(RecoverSagas),
+		fx.Invoke(RescheduleSwapReversals),
+		fx.Invoke(ResumeStatementExports),
+		// scheduled jobs are registered when their services are built
+		fx.Invoke(func(*http.Server, services.ReconciliationService, services.ProofService) {}),
+	).Run()
+	return 0
+}
+
+// reconcile runs a reconciliation with the application's services and prints the report. The exit code is 1
+// when the reconciliation could not run or failed and 2 when it found issues
+func reconcile(app fx.Option, args []string) int {
+	if len(args) > 0 {
+		return badUsage("reconcile")
+	}
+
+	var reconciliationService services.ReconciliationService
+	stop, err := start(app, &reconciliationService)
+	if err != nil {
+		return fail(err)
+	}
+	defer stop()
+
+	report, err := reconciliationService.Reconcile(context.Background(), models.Command_ReconciliationTrigger)
+	if err != nil {
+		return fail(err)
+	}
+	if err = printJSON(report); err != nil {
+		return fail(err)
+	}
+
+	switch {
+	case report.Status == models.Failed_ReconciliationStatus:
+		return 1
+	case report.IssueCount > 0:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// migrate applies, reverts or lists the data database's schema migrations. down reverts one migration unless
+// given the number to revert
+func migrate(app fx.Option, args []string) int {
+	if len(args) == 0 || !slices.Contains([]string{"up", "down", "status"}, args[0]) || len(args) > 2 || (len(args) == 2 && args[0] != "down") {
+		return badUsage("migrate")
+	}
+	steps := 1
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			return badUsage("migrate")
+		}
+		steps = n
+	}
+
+	var migrator *migrations.Migrator
+	cmd := fx.New(app, fx.NopLogger, fx.Populate(&migrator))
+	if err := cmd.Err(); err != nil {
+		return fail(err)
+	}
+
+	ctx := context.Background()
+	var err error
+	switch args[0] {
+	case "up":
+		var applied []migrations.Migration
+		applied, err = migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Println("applied", m)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		var reverted []migrations.Migration
+		reverted, err = migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			fmt.Println("reverted", m)
+		}
+		if err == nil && len(reverted) == 0 {
+			fmt.Println("no applied migrations")
+		}
+	case "status":
+		var status []migrations.Status
+		if status, err = migrator.Status(ctx); err == nil {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "MIGRATION\tAPPLIED AT")
+			for _, s := range status {
+				appliedAt := "pending"
+				if s.AppliedAt != nil {
+					appliedAt = s.AppliedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%s\n", s.Migration, appliedAt)
+			}
+			err = w.Flush()
+		}
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// seed applies a fixture file through the application's services and prints the steps it applied. The file is
+// checked before the application is started
+func seed(app fx.Option, args []string) int {
+	if len(args) != 1 {
+		return badUsage("seed")
+	}
+	file, err := fixtures.Load(args[0])
+	if err != nil {
+		return fail(err)
+	}
+
+	var seeder *fixtures.Seeder
+	stop, err := start(app, &seeder)
+	if err != nil {
+		return fail(err)
+	}
+	defer stop()
+
+	applied, err := seeder.Seed(context.Background(), file)
+	for _, step := range applied {
+		fmt.Println("seeded", step)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if len(applied) == 0 {
+		fmt.Println("nothing to seed")
+	}
+	return 0
+}
+
+// printConfig prints the effective config as YAML with secrets redacted, the exit code is 1 when it is invalid
+func printConfig(app fx.Option, args []string) int {
+	if len(args) > 0 {
+		return badUsage("config")
+	}
+
+	var cfg *config.Config
+	cmd := fx.New(app, fx.NopLogger, fx.Populate(&cfg))
+	if err := cmd.Err(); err != nil {
+		return fail(err)
+	}
+
+	out, err := cfg.YAML()
+	if err != nil {
+		return fail(err)
+	}
+	os.Stdout.Write(out)
+	return 0
+}
+
+// printOpenAPI prints the document served at /api/v1/openapi.json, it needs no config or databases
+func printOpenAPI(_ fx.Option, args []string) int {
+	if len(args) > 0 {
+		return badUsage("openapi")
+	}
+
+	doc, err := handlers.OpenAPIDocument()
+	if err != nil {
+		return fail(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(doc); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+func createAccount(app fx.Option, args []string) int {
+	req := &requests.CreateAccountRequest{}
+	fs := flags("accounts create")
+	fs.StringVar(&req.Email, "email", "", "email of the account")
+	fs.StringVar(&req.Password, "password", "", "password of the account")
+	fs.StringVar(&req.FirstName, "first-name", "", "first name of the account holder")
+	fs.StringVar(&req.LastName, "last-name", "", "last name of the account holder")
+	fs.StringVar(&req.DisplayName, "display-name", "", "display name of the account")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return badUsage("accounts create")
+	}
+	if err := validate(req); err != nil {
+		return fail(err)
+	}
+
+	var accountService services.AccountService
+	stop, err := start(app, &accountService)
+	if err != nil {
+		return fail(err)
+	}
+	defer stop()
+
+	res, err := accountService.CreateAccount(operator(models.Test_Environment), req)
+	if err != nil {
+		return fail(err)
+	}
+	if err = printJSON(res); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+func issueToken(app fx.Option, args []string) int {
+	req := &requests.IssueTokenRequest{}
+	var environment string
+	fs := flags("tokens issue")
+	fs.StringVar(&req.UserID, "user", "", "id of the main account")
+	fs.StringVar(&environment, "environment", "test", "environment the token authenticates against")
+	fs.StringVar(&req.Name, "name", "", "name of the token")
+	fs.StringVar(&req.Description, "description", "", "description of the token")
+	fs.Func("rate-limit", "requests per second and burst of a route group, or of every other group with `*`, as group:rate:burst, may be repeated", func(s string) error {
+		limit, err := parseRateLimit(s)
+		if err != nil {
+			return err
+		}
+		req.RateLimits = append(req.RateLimits, limit)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return badUsage("tokens issue")
+	}
+	env, err := parseEnvironment(environment)
+	if err != nil {
+		return fail(err)
+	}
+	req.Environment = &env
+	if err = validate(req); err != nil {
+		return fail(err)
+	}
+
+	var accountService services.AccountService
+	stop, err := start(app, &accountService)
+	if err != nil {
+		return fail(err)
+	}
+	defer stop()
+
+	res, err := accountService.IssueToken(operator(env), req)
+	if err != nil {
+		return fail(err)
+	}
+	if err = printJSON(res); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// parseRateLimit parses a group:rate:burst rate limit
+func parseRateLimit(s string) (requests.TokenRateLimit, error) {
+	group, rest, ok := strings.Cut(s, ":")
+	rate, burst, ok2 := strings.Cut(rest, ":")
+	if !ok || !ok2 {
+		return requests.TokenRateLimit{}, fmt.Errorf("rate limit %q is not group:rate:burst", s)
+	}
+	limit := requests.TokenRateLimit{RouteGroup: group}
+	var err error
+	if limit.Rate, err = strconv.ParseFloat(rate, 64); err != nil {
+		return limit, err
+	}
+	if limit.Burst, err = strconv.Atoi(burst); err != nil {
+		return limit, err
+	}
+	return limit, nil
+}
+
+func revokeToken(app fx.Option, args []string) int {
+	if len(args) != 1 {
+		return badUsage("tokens revoke")
+	}
+	req := &requests.RevokeTokenRequest{TokenID: args[0]}
+
+	var accountService services.AccountService
+	stop, err := start(app, &accountService)
+	if err != nil {
+		return fail(err)
+	}
+	defer stop()
+
+	if err = accountService.RevokeToken(operator(models.Test_Environment), req); err != nil {
+		return fail(err)
+	}
+	fmt.Println("revoked", req.TokenID)
+	return 0
+}
+
+// optionalFloat is a flag whose value is left nil when it is not given
+func optionalFloat(fs *flag.FlagSet, name string, usage string) **float64 {
+	value := new(*float64)
+	fs.Func(name, usage, func(s string) error {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		*value = &f
+		return nil
+	})
+	return value
+}
+
+func setSubAccountLimit(app fx.Option, args []string) int {
+	req := &requests.SetSubAccountLimitRequest{}
+	var tier uint
+	var operation string
+	fs := flags("limits set")
+	fs.StringVar(&req.UserID, "user", "", "id of the main account")
+	fs.UintVar(&tier, "tier", 0, "kyc tier of the sub accounts")
+	fs.StringVar(&req.Currency, "currency", "", "currency the limit applies to")
+	fs.StringVar(&operation, "operation", "", "operation the limit applies to")
+	single := optionalFloat(fs, "single", "cap of a single transaction")
+	daily := optionalFloat(fs, "daily", "cap of the transactions of a day")
+	monthly := optionalFloat(fs, "monthly", "cap of the transactions of a month")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return badUsage("limits set")
+	}
+	req.Tier, req.Single, req.Daily, req.Monthly = models.KYCTier(tier), *single, *daily, *monthly
+	req.Operation = new(models.LimitOperation)
+	if err := req.Operation.UnmarshalJSON([]byte(operation)); err != nil {
+		return fail(err)
+	}
+	if err := validate(req); err != nil {
+		return fail(err)
+	}
+
+	var limitService services.LimitService
+	stop, err := start(app, &limitService)
+	if err != nil {
+		return fail(err)
+	}
+	defer stop()
+
+	res, err := limitService.SetSubAccountLimit(operator(models.Test_Environment), req)
+	if err != nil {
+		return fail(err)
+	}
+	if err = printJSON(res); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// replayWebhook delivers the event for the current state of a swap, withdrawal or deposit to its user's
+// webhook url. Pending swaps and withdrawals have no event to replay
+func replayWebhook(app fx.Option, args []string) int {
+	var environment string
+	fs := flags("webhooks replay")
+	fs.StringVar(&environment, "environment", "test", "environment of the swap, withdrawal or deposit")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 3 {
+		return badUsage("webhooks replay")
+	}
+	userID, kind, id := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+	if !slices.Contains([]string{"swap", "withdrawal", "deposit"}, kind) {
+		return badUsage("webhooks replay")
+	}
+	env, err := parseEnvironment(environment)
+	if err != nil {
+		return fail(err)
+	}
+
+	var (
+		swapService       services.InstantSwapService
+		withdrawalService services.WithdrawalService
+		depositService    services.DepositService
+		webhookService    services.WebhookService
+	)
+	stop, err := start(app, &swapService, &withdrawalService, &depositService, &webhookService)
+	if err != nil {
+		return fail(err)
+	}
+	defer stop()
+
+	ctx := operator(env)
+	var (
+		event models.WebhookEvent
+		data  any
+		user  *models.Account
+	)
+	switch kind {
+	case "swap":
+		res, err := swapService.FetchInstantSwapTransaction(ctx, &requests.FetchInstantSwapTransactionRequest{UserID: userID, SwapTransactionID: id})
+		if err != nil {
+			return fail(err)
+		}
+		switch res.Data.Status {
+		case "confirmed":
+			event = models.SwapTransactionCompleted_WebhookEvent
+		case "reversed":
+			event = models.SwapTransactionReversed_WebhookEvent
+		case "failed":
+			event = models.SwapTransactionFailed_WebhookEvent
+		default:
+			return fail(fmt.Errorf("swap %s is %s, there is no event to replay", id, res.Data.Status))
+		}
+		data, user = res.Data, res.Data.User
+	case "withdrawal":
+		res, err := withdrawalService.FetchWithdrawal(ctx, &requests.FetchWithdrawalRequest{UserID: userID, WithdrawalID: id})
+		if err != nil {
+			return fail(err)
+		}
+		switch res.Data.Status {
+		case models.Completed_WithdrawalStatus:
+			event = models.WithdrawalSuccessful_WebhookEvent
+		case models.Failed_WithdrawalStatus:
+			event = models.WithdrawalRejected_WebhookEvent
+		default:
+			return fail(fmt.Errorf("withdrawal %s is %s, there is no event to replay", id, res.Data.Status))
+		}
+		data, user = res.Data, res.Data.User
+	case "deposit":
+		res, err := depositService.FetchDeposit(ctx, &requests.FetchDepositRequest{UserID: userID, TransactionID: id})
+		if err != nil {
+			return fail(err)
+		}
+		event, data, user = models.DepositSuccessful_WebhookEvent, res.Data, res.Data.User
+	}
+
+	if err = webhookService.Deliver(user.WebhookDetails, event, data); err != nil {
+		return fail(err)
+	}
+	fmt.Println("delivered", event)
+	return 0
+}
+
+func inspectLedger(app fx.Option, args []string) int {
+	req := &requests.InspectLedgerRecordRequest{}
+	fs := flags("ledger inspect")
+	transfers := fs.Uint("transfers", 10, "number of an account's latest transfers to print")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return badUsage("ledger inspect")
+	}
+	req.ID, req.Transfers = fs.Arg(0), uint32(*transfers)
+	if err := validate(req); err != nil {
+		return fail(err)
+	}
+
+	var ledgerService services.LedgerService
+	stop, err := start(app, &ledgerService)
+	if err != nil {
+		return fail(err)
+	}
+	defer stop()
+
+	res, err := ledgerService.InspectLedgerRecord(operator(models.Test_Environment), req)
+	if err != nil {
+		return fail(err)
+	}
+	if err = printJSON(res); err != nil {
+		return fail(err)
+	}
+	return 0
+}
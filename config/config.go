@@ -0,0 +1,268 @@
+// Package config loads the application's configuration. Defaults are overridden by the YAML or TOML file named
+// by CONFIG_FILE, which is in turn overridden by environment variables
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/creasty/defaults"
+	"github.com/go-playground/validator/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces the value of secret fields when the config is printed
+const redacted = "[redacted]"
+
+type Config struct {
+	HTTP           HTTP           `yaml:"http" toml:"http"`
+	DataDB         DataDB         `yaml:"data_db" toml:"data_db"`
+	TxDB           TxDB           `yaml:"tx_db" toml:"tx_db"`
+	Swaps          Swaps          `yaml:"swaps" toml:"swaps"`
+	Webhooks       Webhooks       `yaml:"webhooks" toml:"webhooks"`
+	Statements     Statements     `yaml:"statements" toml:"statements"`
+	Admin          Admin          `yaml:"admin" toml:"admin"`
+	Reconciliation Reconciliation `yaml:"reconciliation" toml:"reconciliation"`
+	Proofs         Proofs         `yaml:"proofs" toml:"proofs"`
+	KYC            KYC            `yaml:"kyc" toml:"kyc"`
+	Limits         Limits         `yaml:"limits" toml:"limits"`
+	RateLimits     RateLimits     `yaml:"rate_limits" toml:"rate_limits"`
+}
+
+type HTTP struct {
+	Address      string        `yaml:"address" toml:"address" env:"HTTP_ADDRESS" default:":55059" validate:"required"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"HTTP_READ_TIMEOUT" default:"15s" validate:"gt=0"`
+	WriteTimeout time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" default:"15s" validate:"gt=0"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" default:"60s" validate:"gt=0"`
+	// origins allowed to make cross origin requests, comma separated in the environment
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins" env:"HTTP_CORS_ORIGINS" default:"[\"*\"]" validate:"required,dive,required"`
+}
+
+type DataDB struct {
+	Driver string `yaml:"driver" toml:"driver" env:"DATA_DB_DRIVER" default:"mysql" validate:"oneof=mysql postgres sqlite memory"`
+	// address of the database, the driver's local default when empty. The database file for sqlite, which
+	// defaults to the name with a .db extension
+	URL      string `yaml:"url" toml:"url" env:"DATA_DB_URL"`
+	User     string `yaml:"user" toml:"user" env:"DATA_DB_USER" default:"root"`
+	Password string `yaml:"password" toml:"password" env:"DATA_DB_PASSWORD" secret:"true"`
+	Name     string `yaml:"name" toml:"name" env:"DATA_DB_NAME" default:"quidax-go" validate:"required"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode" env:"DATA_DB_SSLMODE" default:"disable"`
+	// applies pending schema migrations when the server starts
+	AutoMigrate bool `yaml:"auto_migrate" toml:"auto_migrate" env:"DATA_DB_AUTO_MIGRATE"`
+}
+
+type TxDB struct {
+	// addresses of the cluster's replicas, comma separated in the environment
+	Addresses []string `yaml:"addresses" toml:"addresses" env:"TX_DB_URL" validate:"dive,required"`
+	ClusterID uint64   `yaml:"cluster_id" toml:"cluster_id" env:"TX_DB_CLUSTER_ID"`
+}
+
+type Swaps struct {
+	// how long a quotation holds the funds being swapped before it is reversed
+	QuoteTTL time.Duration `yaml:"quote_ttl" toml:"quote_ttl" env:"SWAP_QUOTE_TTL" default:"12s" validate:"gt=0"`
+}
+
+type Webhooks struct {
+	// wait before each event is delivered
+	Delay time.Duration `yaml:"delay" toml:"delay" env:"WEBHOOK_DELAY" default:"5s" validate:"gte=0"`
+}
+
+type Statements struct {
+	// directory statement exports are written to, defaults to a directory in the os temp dir
+	Dir string `yaml:"dir" toml:"dir" env:"STATEMENTS_DIR"`
+	// how long the files of completed exports are kept before they are deleted, 0 keeps them
+	Retention time.Duration `yaml:"retention" toml:"retention" env:"STATEMENTS_RETENTION" default:"168h" validate:"gte=0"`
+}
+
+type Admin struct {
+	// bearer token for the admin api, the admin api is disabled when empty
+	Token string `yaml:"token" toml:"token" env:"ADMIN_TOKEN" secret:"true"`
+}
+
+type Reconciliation struct {
+	// interval between scheduled reconciliations, 0 disables them
+	Interval time.Duration `yaml:"interval" toml:"interval" env:"RECONCILIATION_INTERVAL" default:"24h" validate:"gte=0"`
+}
+
+type Proofs struct {
+	// interval between scheduled liability snapshots, 0 disables them
+	SnapshotInterval time.Duration `yaml:"snapshot_interval" toml:"snapshot_interval" env:"LIABILITY_SNAPSHOT_INTERVAL" default:"24h" validate:"gte=0"`
+}
+
+type KYC struct {
+	// how the local verifier decides submissions: `rules` approves adults from the supported countries and rejects
+	// everyone else, `manual` leaves every submission pending for review
+	Verifier string `yaml:"verifier" toml:"verifier" env:"KYC_VERIFIER" default:"rules" validate:"oneof=rules manual"`
+	// countries, as iso 3166 alpha-2 codes, the rules verifier approves submissions from
+	Countries []string `yaml:"countries" toml:"countries" env:"KYC_COUNTRIES" default:"[\"NG\"]" validate:"dive,iso3166_1_alpha2"`
+	// youngest age the rules verifier approves
+	MinimumAge int `yaml:"minimum_age" toml:"minimum_age" env:"KYC_MINIMUM_AGE" default:"18" validate:"gte=0"`
+}
+
+type Limits struct {
+	// caps of sub account withdrawals and swaps by kyc tier, currency and operation (`withdrawal` or `swap`),
+	// main accounts can set their own in their place. A tier set in the file replaces its defaults, currencies
+	// missing for a tier are not limited and kyc decides whether they can be used
+	Tiers map[string]TierLimits `yaml:"tiers" toml:"tiers" validate:"dive,keys,oneof=0 1 2,endkeys,dive,dive,keys,oneof=withdrawal swap,endkeys,required"`
+}
+
+// TierLimits are the limits of a kyc tier by currency and operation
+type TierLimits map[string]map[string]TransactionLimit
+
+// TransactionLimit caps the amount moved by a single transaction and by the transactions of a day and of a month,
+// an unset cap is unlimited
+type TransactionLimit struct {
+	Single  *float64 `yaml:"single" toml:"single" validate:"omitnil,gt=0"`
+	Daily   *float64 `yaml:"daily" toml:"daily" validate:"omitnil,gt=0"`
+	Monthly *float64 `yaml:"monthly" toml:"monthly" validate:"omitnil,gt=0"`
+}
+
+// Limit returns the default limit of the tier for the operation on the currency
+func (l Limits) Limit(tier int, currency string, op string) TransactionLimit {
+	return l.Tiers[strconv.Itoa(tier)][currency][op]
+}
+
+// SetDefaults sets the tier limits when they are not set, it is called when the config's defaults are set
+func (l *Limits) SetDefaults() {
+	if l.Tiers != nil {
+		return
+	}
+	limit := func(single, daily, monthly float64) map[string]TransactionLimit {
+		caps := TransactionLimit{Single: &single, Daily: &daily, Monthly: &monthly}
+		return map[string]TransactionLimit{"withdrawal": caps, "swap": caps}
+	}
+	l.Tiers = map[string]TierLimits{
+		"1": {
+			"ngn":  limit(1_000_000, 5_000_000, 50_000_000),
+			"usdt": limit(1_000, 5_000, 50_000),
+			"usdc": limit(1_000, 5_000, 50_000),
+		},
+		"2": {
+			"ngn":  limit(10_000_000, 50_000_000, 500_000_000),
+			"usdt": limit(10_000, 50_000, 500_000),
+			"usdc": limit(10_000, 50_000, 500_000),
+			"eth":  limit(5, 25, 250),
+			"bnb":  limit(20, 100, 1_000),
+			"sol":  limit(100, 500, 5_000),
+			"btc":  limit(0.5, 2.5, 25),
+		},
+	}
+}
+
+type RateLimits struct {
+	// token buckets of each route group for tokens without their own limits, and per client address for account
+	// creation, published proof roots and the admin api. A group set in the file replaces its default
+	Groups map[string]RateLimit `yaml:"groups" toml:"groups" validate:"dive,keys,oneof=accounts wallets swaps withdrawals deposits markets transactions proofs admin,endkeys,required"`
+}
+
+// RateLimit is a token bucket refilled with Rate requests per second up to Burst requests
+type RateLimit struct {
+	Rate  float64 `yaml:"rate" toml:"rate" validate:"gt=0"`
+	Burst int     `yaml:"burst" toml:"burst" validate:"gt=0"`
+}
+
+// SetDefaults sets the route group limits when they are not set, it is called when the config's defaults are set
+func (r *RateLimits) SetDefaults() {
+	if r.Groups != nil {
+		return
+	}
+	r.Groups = map[string]RateLimit{
+		"accounts":     {Rate: 2, Burst: 10},
+		"wallets":      {Rate: 5, Burst: 20},
+		"swaps":        {Rate: 2, Burst: 10},
+		"withdrawals":  {Rate: 2, Burst: 10},
+		"deposits":     {Rate: 5, Burst: 20},
+		"markets":      {Rate: 10, Burst: 50},
+		"transactions": {Rate: 1, Burst: 5},
+		"proofs":       {Rate: 1, Burst: 5},
+		"admin":        {Rate: 1, Burst: 5},
+	}
+}
+
+// Load builds the config from its defaults, the file named by CONFIG_FILE and the environment, and validates it
+func Load() (*Config, error) {
+	cfg := new(Config)
+	if err := defaults.Set(cfg); err != nil {
+		return nil, err
+	}
+	if file := os.Getenv("CONFIG_FILE"); file != "" {
+		if err := decodeFile(file, cfg); err != nil {
+			return nil, fmt.Errorf("reading config file %s: %w", file, err)
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// decodeFile reads a YAML or TOML file into the config, chosen by the file's extension. Keys the config does
+// not have are rejected so misspelt settings are not silently ignored
+func decodeFile(file string, cfg *Config) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		// an empty file leaves the defaults as they are
+		if err = dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		return nil
+	case ".toml":
+		md, err := toml.Decode(string(content), cfg)
+		if err != nil {
+			return err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown setting %s", undecoded[0])
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported config format %q, use .yaml, .yml or .toml", filepath.Ext(file))
+	}
+}
+
+// YAML returns the config as YAML with the values of secret fields redacted
+func (c Config) YAML() ([]byte, error) {
+	redact(reflect.ValueOf(&c).Elem())
+	return yaml.Marshal(c)
+}
+
+func redact(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch {
+		case field.Kind() == reflect.Struct:
+			redact(field)
+		case v.Type().Field(i).Tag.Get("secret") == "true" && field.String() != "":
+			field.SetString(redacted)
+		}
+	}
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// errors name settings the way they are written in config files
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		return fld.Tag.Get("yaml")
+	})
+	return v
+}
@@ -0,0 +1,337 @@
+// Package migrations applies the versioned schema migrations of the data database. Every dialect has its own
+// migrations in a directory named after it, a migration is a pair of NNNN_name.up.sql and NNNN_name.down.sql
+// files. Applied versions are recorded in the schema_migrations table
+package migrations
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/errors"
+	sq "github.com/Masterminds/squirrel"
+	"go.uber.org/zap"
+)
+
+//go:embed mysql postgres sqlite
+var files embed.FS
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// the lock held while migrating, so two processes starting together do not both migrate. mysql locks are
+// named, postgres advisory locks are keyed by a number
+const (
+	lockName = "quidax-go.schema_migrations"
+	lockID   = 7_215_338_401
+)
+
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Status is a migration with the time it was applied, AppliedAt is nil for pending migrations
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load returns the dialect's migrations ordered by version
+func Load(dialect db.Dialect) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, string(dialect))
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %w", dialect, err)
+	}
+
+	byVersion := make(map[uint64]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(files, path.Join(string(dialect), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// Migrator applies and reverts the migrations of the data database's dialect
+type Migrator struct {
+	db         *sql.DB
+	dialect    db.Dialect
+	migrations []Migration
+	log        *zap.Logger
+}
+
+func NewMigrator(dataDatabase *sql.DB, log *zap.Logger) (*Migrator, error) {
+	if dataDatabase == nil {
+		return nil, fmt.Errorf("the %s data database has no schema to migrate", db.Memory)
+	}
+	dialect := db.DialectOf(dataDatabase)
+	migrations, err := Load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: dataDatabase, dialect: dialect, migrations: migrations, log: log}, nil
+}
+
+// Up applies every pending migration in order and returns the ones it applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied := make([]Migration, 0)
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if err = m.apply(ctx, conn, migration, true); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns the ones it reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	reverted := make([]Migration, 0, steps)
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		versions, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if err = m.apply(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status returns every migration with the time it was applied, oldest first
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer conn.Close()
+
+	if err = m.createTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	versions, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		s := Status{Migration: migration}
+		if appliedAt, ok := versions[migration.Version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// apply runs a migration's up or down statements and records the change in schema_migrations. mysql
+// commits schema changes as they are made, so a migration that fails part way there has to be fixed by hand
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	script, direction := migration.Up, "up"
+	if !up {
+		script, direction = migration.Down, "down"
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range statements(script) {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migrating %s %s: %w", migration, direction, err)
+		}
+	}
+
+	builder := m.dialect.Builder()
+	if up {
+		_, err = builder.
+			Insert("schema_migrations").
+			Columns("version", "name", "applied_at").
+			Values(migration.Version, migration.Name, time.Now()).
+			RunWith(tx).
+			ExecContext(ctx)
+	} else {
+		_, err = builder.
+			Delete("schema_migrations").
+			Where(sq.Eq{"version": migration.Version}).
+			RunWith(tx).
+			ExecContext(ctx)
+	}
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.HandleDataDBError(err)
+	}
+
+	m.log.Info("migrated data database", zap.String("migration", migration.String()), zap.String("direction", direction))
+	return nil
+}
+
+// applied returns the applied versions with the time they were applied
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[uint64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	versions := make(map[uint64]time.Time)
+	for rows.Next() {
+		var version uint64
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		versions[version] = appliedAt
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	return versions, nil
+}
+
+func (m *Migrator) createTable(ctx context.Context, conn *sql.Conn) error {
+	timestamp := map[db.Dialect]string{db.MySQL: "datetime(6)", db.Postgres: "timestamptz", db.SQLite: "datetime"}[m.dialect]
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`create table if not exists schema_migrations (
+  version bigint not null,
+  name varchar(255) not null,
+  applied_at %s not null,
+
+  primary key (version)
+)`, timestamp))
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+// locked runs fn on a connection holding the migration lock. sqlite transactions already lock the whole
+// database, so no lock is taken there
+func (m *Migrator) locked(ctx context.Context, fn func(*sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	defer conn.Close()
+
+	switch m.dialect {
+	case db.MySQL:
+		var acquired sql.NullInt64
+		if err = conn.QueryRowContext(ctx, "select get_lock(?, 60)", lockName).Scan(&acquired); err != nil {
+			return errors.HandleDataDBError(err)
+		}
+		if acquired.Int64 != 1 {
+			return fmt.Errorf("timed out waiting for the migration lock")
+		}
+		defer conn.ExecContext(context.Background(), "select release_lock(?)", lockName)
+	case db.Postgres:
+		if _, err = conn.ExecContext(ctx, "select pg_advisory_lock($1)", lockID); err != nil {
+			return errors.HandleDataDBError(err)
+		}
+		defer conn.ExecContext(context.Background(), "select pg_advisory_unlock($1)", lockID)
+	}
+
+	if err = m.createTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// statements splits a migration into its statements, which end with a semicolon at the end of a line.
+// Comment lines are dropped
+func statements(script string) []string {
+	stmts := make([]string, 0)
+	var current strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(script))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
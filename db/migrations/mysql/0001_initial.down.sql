@@ -0,0 +1,7 @@
+drop table if exists instant_swaps;
+drop table if exists withdrawals;
+drop table if exists wallets;
+drop table if exists access_tokens;
+drop table if exists webhook_details;
+drop table if exists credentials;
+drop table if exists accounts;
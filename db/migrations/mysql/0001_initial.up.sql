@@ -1,5 +1,5 @@
-create database if not exists `quidax-go`;
-use `quidax-go`;
+-- the schema before migrations were tracked. Tables are only created when missing, so databases set up
+-- before then take this migration as applied and gain the later columns from the migrations after it
 
 create table if not exists accounts (
   id varchar(255) not null,
@@ -0,0 +1,21 @@
+alter table instant_swaps
+  drop column created_at;
+
+alter table withdrawals
+  drop column created_at,
+  drop column amount;
+
+alter table wallets
+  drop column frozen,
+  drop column environment;
+
+alter table access_tokens
+  drop column environment;
+
+alter table accounts
+  drop column kyc_tier,
+  drop column country,
+  drop column date_of_birth,
+  drop column phone_number,
+  drop column frozen,
+  drop column environment;
@@ -0,0 +1,24 @@
+-- columns added to the initial tables for environments, frozen accounts and wallets, kyc profiles, and the
+-- amounts and creation times withdrawals and swaps are listed by
+
+alter table accounts
+  add column environment tinyint unsigned not null default 0,
+  add column frozen boolean not null default False,
+  add column phone_number varchar(255),
+  add column date_of_birth date,
+  add column country varchar(2),
+  add column kyc_tier tinyint unsigned not null default 0;
+
+alter table access_tokens
+  add column environment tinyint unsigned not null default 0;
+
+alter table wallets
+  add column environment tinyint unsigned not null default 0,
+  add column frozen boolean not null default False;
+
+alter table withdrawals
+  add column amount decimal(36,18) not null default 0,
+  add column created_at datetime(6) not null default current_timestamp(6);
+
+alter table instant_swaps
+  add column created_at datetime(6) not null default current_timestamp(6);
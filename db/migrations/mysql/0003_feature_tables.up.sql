@@ -0,0 +1,138 @@
+-- tables for kyc submissions, transaction limits, statement exports, rate limits, reconciliations, sagas and
+-- liability snapshots
+
+create table if not exists kyc_submissions (
+  id varchar(255) not null,
+  account_id varchar(255) not null,
+  tier tinyint unsigned not null,
+  phone_number varchar(255) not null,
+  date_of_birth date not null,
+  country varchar(2) not null,
+  id_type varchar(255),
+  id_number varchar(255),
+  status tinyint unsigned not null,
+  reason varchar(255),
+  created_at datetime not null,
+  updated_at datetime not null,
+
+  primary key (id),
+  foreign key (account_id) references accounts(id)
+);
+
+create table if not exists transaction_limits (
+  account_id varchar(255) not null,
+  kyc_tier tinyint unsigned not null,
+  currency varchar(255) not null,
+  operation tinyint unsigned not null,
+  single_limit decimal(36,18),
+  daily_limit decimal(36,18),
+  monthly_limit decimal(36,18),
+
+  primary key (account_id, kyc_tier, currency, operation),
+  foreign key (account_id) references accounts(id)
+);
+
+create table if not exists statement_exports (
+  id varchar(255) not null,
+  account_id varchar(255) not null,
+  format tinyint unsigned not null,
+  currency varchar(255),
+  period_from datetime(6) not null,
+  period_to datetime(6) not null,
+  status tinyint unsigned not null,
+  reason varchar(255),
+  created_at datetime not null,
+  completed_at datetime,
+
+  primary key (id),
+  foreign key (account_id) references accounts(id)
+);
+
+create table if not exists rate_limits (
+  access_token_id varchar(255) not null,
+  route_group varchar(255) not null,
+  rate double not null,
+  burst int unsigned not null,
+
+  primary key (access_token_id, route_group),
+  foreign key (access_token_id) references access_tokens(id)
+);
+
+create table if not exists reconciliation_reports (
+  id varchar(255) not null,
+  trigger_source tinyint unsigned not null,
+  status tinyint unsigned not null,
+  reason varchar(1024),
+  wallets_checked int unsigned not null default 0,
+  withdrawals_checked int unsigned not null default 0,
+  swaps_checked int unsigned not null default 0,
+  issue_count int unsigned not null default 0,
+  started_at datetime(6) not null,
+  completed_at datetime(6),
+
+  primary key (id)
+);
+
+create table if not exists reconciliation_issues (
+  report_id varchar(255) not null,
+  seq int unsigned not null,
+  type tinyint unsigned not null,
+  resource varchar(255) not null,
+  resource_id varchar(255) not null,
+  environment tinyint unsigned not null,
+  detail varchar(1024) not null,
+
+  primary key (report_id, seq),
+  foreign key (report_id) references reconciliation_reports(id)
+);
+
+create table if not exists sagas (
+  id varchar(255) not null,
+  kind tinyint unsigned not null,
+  payload json not null,
+  state tinyint unsigned not null,
+  step int unsigned not null,
+  reason varchar(1024),
+  created_at datetime(6) not null,
+  updated_at datetime(6) not null,
+
+  primary key (id),
+  index (state)
+);
+
+create table if not exists liability_snapshots (
+  id varchar(255) not null,
+  environment tinyint unsigned not null,
+  status tinyint unsigned not null,
+  reason varchar(1024),
+  ledger_timestamp bigint unsigned not null,
+  created_at datetime(6) not null,
+  completed_at datetime(6),
+
+  primary key (id)
+);
+
+create table if not exists liability_roots (
+  snapshot_id varchar(255) not null,
+  currency varchar(255) not null,
+  root_hash char(64) not null,
+  total decimal(39, 0) not null,
+  leaf_count int unsigned not null,
+
+  primary key (snapshot_id, currency),
+  foreign key (snapshot_id) references liability_snapshots(id)
+);
+
+create table if not exists liability_leaves (
+  snapshot_id varchar(255) not null,
+  currency varchar(255) not null,
+  leaf_index int unsigned not null,
+  user_id varchar(255) not null,
+  wallet_id varchar(255) not null,
+  nonce char(32) not null,
+  balance decimal(39, 0) not null,
+
+  primary key (snapshot_id, currency, leaf_index),
+  index (snapshot_id, user_id),
+  foreign key (snapshot_id) references liability_snapshots(id)
+);
@@ -0,0 +1 @@
+drop table if exists limit_usage;
@@ -0,0 +1 @@
+alter table idempotency_keys drop column lease_expires_at;
@@ -0,0 +1,4 @@
+-- until when the request holding an idempotency key is handled, a retry takes over the key of a request whose
+-- lease has run out, such as one left by a process that stopped while handling it
+
+alter table idempotency_keys add column lease_expires_at datetime(6);
@@ -0,0 +1,10 @@
+drop table if exists liability_leaves;
+drop table if exists liability_roots;
+drop table if exists liability_snapshots;
+drop table if exists sagas;
+drop table if exists reconciliation_issues;
+drop table if exists reconciliation_reports;
+drop table if exists rate_limits;
+drop table if exists statement_exports;
+drop table if exists transaction_limits;
+drop table if exists kyc_submissions;
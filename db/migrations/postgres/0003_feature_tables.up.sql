@@ -0,0 +1,136 @@
+-- tables for kyc submissions, transaction limits, statement exports, rate limits, reconciliations, sagas and
+-- liability snapshots
+
+create table if not exists kyc_submissions (
+  id varchar(255) not null,
+  account_id varchar(255) not null,
+  tier smallint not null,
+  phone_number varchar(255) not null,
+  date_of_birth date not null,
+  country varchar(2) not null,
+  id_type varchar(255),
+  id_number varchar(255),
+  status smallint not null,
+  reason varchar(255),
+  created_at timestamptz not null,
+  updated_at timestamptz not null,
+
+  primary key (id),
+  foreign key (account_id) references accounts(id)
+);
+
+create table if not exists transaction_limits (
+  account_id varchar(255) not null,
+  kyc_tier smallint not null,
+  currency varchar(255) not null,
+  operation smallint not null,
+  single_limit numeric(36,18),
+  daily_limit numeric(36,18),
+  monthly_limit numeric(36,18),
+
+  primary key (account_id, kyc_tier, currency, operation),
+  foreign key (account_id) references accounts(id)
+);
+
+create table if not exists statement_exports (
+  id varchar(255) not null,
+  account_id varchar(255) not null,
+  format smallint not null,
+  currency varchar(255),
+  period_from timestamptz(6) not null,
+  period_to timestamptz(6) not null,
+  status smallint not null,
+  reason varchar(255),
+  created_at timestamptz not null,
+  completed_at timestamptz,
+
+  primary key (id),
+  foreign key (account_id) references accounts(id)
+);
+
+create table if not exists rate_limits (
+  access_token_id varchar(255) not null,
+  route_group varchar(255) not null,
+  rate double precision not null,
+  burst integer not null,
+
+  primary key (access_token_id, route_group),
+  foreign key (access_token_id) references access_tokens(id)
+);
+
+create table if not exists reconciliation_reports (
+  id varchar(255) not null,
+  trigger_source smallint not null,
+  status smallint not null,
+  reason varchar(1024),
+  wallets_checked integer not null default 0,
+  withdrawals_checked integer not null default 0,
+  swaps_checked integer not null default 0,
+  issue_count integer not null default 0,
+  started_at timestamptz(6) not null,
+  completed_at timestamptz(6),
+
+  primary key (id)
+);
+
+create table if not exists reconciliation_issues (
+  report_id varchar(255) not null,
+  seq integer not null,
+  type smallint not null,
+  resource varchar(255) not null,
+  resource_id varchar(255) not null,
+  environment smallint not null,
+  detail varchar(1024) not null,
+
+  primary key (report_id, seq),
+  foreign key (report_id) references reconciliation_reports(id)
+);
+
+create table if not exists sagas (
+  id varchar(255) not null,
+  kind smallint not null,
+  payload bytea not null,
+  state smallint not null,
+  step integer not null,
+  reason varchar(1024),
+  created_at timestamptz(6) not null,
+  updated_at timestamptz(6) not null,
+
+  primary key (id)
+);
+
+create table if not exists liability_snapshots (
+  id varchar(255) not null,
+  environment smallint not null,
+  status smallint not null,
+  reason varchar(1024),
+  ledger_timestamp bigint not null,
+  created_at timestamptz(6) not null,
+  completed_at timestamptz(6),
+
+  primary key (id)
+);
+
+create table if not exists liability_roots (
+  snapshot_id varchar(255) not null,
+  currency varchar(255) not null,
+  root_hash char(64) not null,
+  total numeric(39, 0) not null,
+  leaf_count integer not null,
+
+  primary key (snapshot_id, currency),
+  foreign key (snapshot_id) references liability_snapshots(id)
+);
+
+create table if not exists liability_leaves (
+  snapshot_id varchar(255) not null,
+  currency varchar(255) not null,
+  leaf_index integer not null,
+  user_id varchar(255) not null,
+  wallet_id varchar(255) not null,
+  nonce char(32) not null,
+  balance numeric(39, 0) not null,
+
+  primary key (snapshot_id, currency, leaf_index),
+  foreign key (snapshot_id) references liability_snapshots(id)
+);
@@ -0,0 +1,9 @@
+-- steps of fixture files applied by the seed command, keyed by the step so seeding again skips them
+
+create table if not exists seeded_fixtures (
+  id varchar(255) not null,
+  ref varchar(255) not null,
+  applied_at timestamptz not null,
+
+  primary key (id)
+);
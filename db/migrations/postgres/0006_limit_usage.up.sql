@@ -0,0 +1,11 @@
+-- amounts moved out of sub account wallets with each limited operation, per day (yyyy-mm-dd) and month (yyyy-mm).
+-- usage is reserved with a conditional update so requests made together can not exceed a limit between them
+
+create table if not exists limit_usage (
+  wallet_id varchar(255) not null,
+  operation smallint not null,
+  period varchar(16) not null,
+  used numeric(36,18) not null,
+
+  primary key (wallet_id, operation, period)
+);
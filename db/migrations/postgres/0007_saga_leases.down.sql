@@ -0,0 +1,3 @@
+alter table sagas
+  drop column lease_expires_at,
+  drop column owner;
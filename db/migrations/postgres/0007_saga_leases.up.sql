@@ -0,0 +1,6 @@
+-- the process running a saga and until when it holds it, sagas whose lease has run out are recovered by
+-- another process
+
+alter table sagas
+  add column owner varchar(255),
+  add column lease_expires_at timestamptz(6);
@@ -0,0 +1,2 @@
+alter table instant_swaps
+  drop column settled_at;
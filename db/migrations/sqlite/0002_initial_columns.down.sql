@@ -0,0 +1,12 @@
+alter table instant_swaps drop column created_at;
+alter table withdrawals drop column created_at;
+alter table withdrawals drop column amount;
+alter table wallets drop column frozen;
+alter table wallets drop column environment;
+alter table access_tokens drop column environment;
+alter table accounts drop column kyc_tier;
+alter table accounts drop column country;
+alter table accounts drop column date_of_birth;
+alter table accounts drop column phone_number;
+alter table accounts drop column frozen;
+alter table accounts drop column environment;
@@ -0,0 +1,16 @@
+-- columns added to the initial tables for environments, frozen accounts and wallets, kyc profiles, and the
+-- amounts and creation times withdrawals and swaps are listed by
+-- sqlite only adds columns with constant defaults, rows are always written with their created_at
+
+alter table accounts add column environment integer not null default 0;
+alter table accounts add column frozen boolean not null default False;
+alter table accounts add column phone_number varchar(255);
+alter table accounts add column date_of_birth date;
+alter table accounts add column country varchar(2);
+alter table accounts add column kyc_tier integer not null default 0;
+alter table access_tokens add column environment integer not null default 0;
+alter table wallets add column environment integer not null default 0;
+alter table wallets add column frozen boolean not null default False;
+alter table withdrawals add column amount numeric not null default 0;
+alter table withdrawals add column created_at datetime not null default '1970-01-01 00:00:00';
+alter table instant_swaps add column created_at datetime not null default '1970-01-01 00:00:00';
@@ -0,0 +1,12 @@
+-- requests made with an Idempotency-Key header, their responses are replayed when they are retried with the key
+
+create table if not exists idempotency_keys (
+  account_id varchar(255) not null,
+  idempotency_key varchar(255) not null,
+  request varchar(1024) not null,
+  status_code int,
+  response blob,
+  created_at datetime not null,
+
+  primary key (account_id, idempotency_key)
+);
@@ -0,0 +1,2 @@
+alter table sagas drop column lease_expires_at;
+alter table sagas drop column owner;
@@ -0,0 +1,5 @@
+-- the process running a saga and until when it holds it, sagas whose lease has run out are recovered by
+-- another process
+
+alter table sagas add column owner varchar(255);
+alter table sagas add column lease_expires_at datetime;
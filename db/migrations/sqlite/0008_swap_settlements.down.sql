@@ -0,0 +1 @@
+alter table instant_swaps drop column settled_at;
@@ -0,0 +1,5 @@
+-- when a swap was confirmed or its quotation reversed, the reversals of unsettled swaps are scheduled again when
+-- the app starts. Swaps made before this migration were settled long ago
+
+alter table instant_swaps add column settled_at datetime;
+update instant_swaps set settled_at = created_at;
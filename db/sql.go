@@ -2,16 +2,68 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
 	"sync"
 
 	"github.com/2HgO/quidax-go/config"
+	sq "github.com/Masterminds/squirrel"
 	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
 	"go.uber.org/zap"
 )
 
 var dataDb *sql.DB
 var dataDBOnce = &sync.Once{}
 
+// Dialect is the sql dialect of the data database, selected with the data database's driver setting
+type Dialect string
+
+const (
+	MySQL    Dialect = "mysql"
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+	// Memory keeps every row in the process with the in-memory repositories, there is no database to connect to
+	Memory Dialect = "memory"
+)
+
+// DialectOf returns the dialect of the driver the database was opened with
+func DialectOf(database *sql.DB) Dialect {
+	switch database.Driver().(type) {
+	case *pq.Driver:
+		return Postgres
+	case *sqlite3.SQLiteDriver:
+		return SQLite
+	}
+	return MySQL
+}
+
+// Builder returns a statement builder with the dialect's placeholders, postgres numbers them
+func (d Dialect) Builder() sq.StatementBuilderType {
+	if d == Postgres {
+		return sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	}
+	return sq.StatementBuilder
+}
+
+// Upsert makes the insert update the given columns of the row when one with the same key exists
+func (d Dialect) Upsert(stmt sq.InsertBuilder, key string, columns ...string) sq.InsertBuilder {
+	updates := make([]string, 0, len(columns))
+	if d == MySQL {
+		for _, column := range columns {
+			updates = append(updates, fmt.Sprintf("%s = values(%s)", column, column))
+		}
+		return stmt.Suffix("on duplicate key update " + strings.Join(updates, ", "))
+	}
+
+	for _, column := range columns {
+		updates = append(updates, fmt.Sprintf("%s = excluded.%s", column, column))
+	}
+	return stmt.Suffix(fmt.Sprintf("on conflict (%s) do update set %s", key, strings.Join(updates, ", ")))
+}
+
 type dbLogger struct {
 	log *zap.Logger
 }
@@ -20,29 +72,68 @@ func (d *dbLogger) Print(v ...any) {
 	d.log.Sugar().Info(v...)
 }
 
-func GetDataDBConnection(log *zap.Logger) *sql.DB {
+// GetDataDBConnection returns the data database's connection pool, nil for the memory driver
+func GetDataDBConnection(cfg *config.Config, log *zap.Logger) *sql.DB {
 	log.Sugar().Info()
+	if Dialect(cfg.DataDB.Driver) == Memory {
+		return nil
+	}
 	dataDBOnce.Do(func() {
+		var err error
+		if dataDb, err = OpenDataDB(cfg, log); err != nil {
+			log.Sugar().Fatalln(err)
+		}
+	})
+
+	return dataDb
+}
+
+// OpenDataDB connects to the data database the config describes. Unlike GetDataDBConnection every call opens
+// a new connection pool, tests use it to give each application its own database
+func OpenDataDB(cfg *config.Config, log *zap.Logger) (*sql.DB, error) {
+	dialect, user, name := Dialect(cfg.DataDB.Driver), cfg.DataDB.User, cfg.DataDB.Name
+	var driver, dsn string
+	switch dialect {
+	case MySQL:
 		cfg := mysql.Config{
-			User:      "root",
+			User:      user,
+			Passwd:    cfg.DataDB.Password,
 			Net:       "tcp",
-			Addr:      config.DATA_DB_URL, //"127.0.0.1:3306"
-			DBName:    "quidax-go",
+			Addr:      cfg.DataDB.URL, //"127.0.0.1:3306"
+			DBName:    name,
 			ParseTime: true,
 			Logger:    &dbLogger{log: log},
 		}
-		// Get a database handle.
-		var err error
-		dataDb, err = sql.Open("mysql", cfg.FormatDSN())
-		if err != nil {
-			log.Sugar().Fatalln(err)
+		driver, dsn = "mysql", cfg.FormatDSN()
+	case Postgres:
+		u := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(user, cfg.DataDB.Password),
+			Host:     cfg.DataDB.URL, //"127.0.0.1:5432"
+			Path:     name,
+			RawQuery: url.Values{"sslmode": {cfg.DataDB.SSLMode}}.Encode(),
 		}
-
-		pingErr := dataDb.Ping()
-		if pingErr != nil {
-			log.Sugar().Fatalln(pingErr)
+		driver, dsn = "postgres", u.String()
+	case SQLite:
+		path := cfg.DataDB.URL
+		if path == "" {
+			path = name + ".db"
 		}
-	})
+		// writers wait on each other instead of failing while another transaction holds the database
+		params := url.Values{"_foreign_keys": {"on"}, "_journal_mode": {"WAL"}, "_busy_timeout": {"5000"}, "_txlock": {"immediate"}}
+		driver, dsn = "sqlite3", "file:"+path+"?"+params.Encode()
+	default:
+		return nil, fmt.Errorf("unsupported data database driver %q", dialect)
+	}
 
-	return dataDb
+	// Get a database handle.
+	database, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err = database.Ping(); err != nil {
+		database.Close()
+		return nil, err
+	}
+	return database, nil
 }
@@ -0,0 +1,114 @@
+package tbfake
+
+import (
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+)
+
+const (
+	accountLinked uint16 = 1 << iota
+	accountDebitsMustNotExceedCredits
+	accountCreditsMustNotExceedDebits
+	accountHistory
+	accountImported
+	accountClosed
+
+	accountFlagsMask = accountClosed<<1 - 1
+)
+
+func (c *Client) CreateAccounts(accounts []tdb_types.Account) ([]tdb_types.AccountEventResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.begin(len(accounts)); err != nil {
+		return nil, err
+	}
+
+	first := c.reserve(len(accounts))
+	results := process(c, len(accounts),
+		func(i int) bool { return accounts[i].Flags&accountLinked != 0 },
+		func(i int) tdb_types.CreateAccountResult { return c.createAccount(accounts[i], first+uint64(i)) },
+		tdb_types.AccountLinkedEventFailed,
+		tdb_types.AccountLinkedEventChainOpen,
+	)
+
+	res := make([]tdb_types.AccountEventResult, 0)
+	for i, result := range results {
+		if result != tdb_types.AccountOK {
+			res = append(res, tdb_types.AccountEventResult{Index: uint32(i), Result: result})
+		}
+	}
+	return res, nil
+}
+
+func (c *Client) createAccount(a tdb_types.Account, timestamp uint64) tdb_types.CreateAccountResult {
+	if a.Flags&accountImported != 0 {
+		return tdb_types.AccountImportedEventNotExpected
+	}
+	if a.Timestamp != 0 {
+		return tdb_types.AccountTimestampMustBeZero
+	}
+	if a.Reserved != 0 {
+		return tdb_types.AccountReservedField
+	}
+	if a.Flags&^accountFlagsMask != 0 {
+		return tdb_types.AccountReservedFlag
+	}
+	if a.ID == zero {
+		return tdb_types.AccountIDMustNotBeZero
+	}
+	if a.ID == maxU128 {
+		return tdb_types.AccountIDMustNotBeIntMax
+	}
+	if e, ok := c.accounts[a.ID]; ok {
+		return accountExists(a, e.Account)
+	}
+	if a.Flags&accountDebitsMustNotExceedCredits != 0 && a.Flags&accountCreditsMustNotExceedDebits != 0 {
+		return tdb_types.AccountFlagsAreMutuallyExclusive
+	}
+	if a.DebitsPending != zero {
+		return tdb_types.AccountDebitsPendingMustBeZero
+	}
+	if a.DebitsPosted != zero {
+		return tdb_types.AccountDebitsPostedMustBeZero
+	}
+	if a.CreditsPending != zero {
+		return tdb_types.AccountCreditsPendingMustBeZero
+	}
+	if a.CreditsPosted != zero {
+		return tdb_types.AccountCreditsPostedMustBeZero
+	}
+	if a.Ledger == 0 {
+		return tdb_types.AccountLedgerMustNotBeZero
+	}
+	if a.Code == 0 {
+		return tdb_types.AccountCodeMustNotBeZero
+	}
+
+	a.Timestamp = timestamp
+	created := &account{Account: a}
+	c.write(func() {
+		c.accounts[a.ID] = created
+		c.accountOrder = append(c.accountOrder, created)
+	}, func() {
+		delete(c.accounts, a.ID)
+		c.accountOrder = c.accountOrder[:len(c.accountOrder)-1]
+	})
+	return tdb_types.AccountOK
+}
+
+func accountExists(a tdb_types.Account, e tdb_types.Account) tdb_types.CreateAccountResult {
+	switch {
+	case a.Flags != e.Flags:
+		return tdb_types.AccountExistsWithDifferentFlags
+	case a.UserData128 != e.UserData128:
+		return tdb_types.AccountExistsWithDifferentUserData128
+	case a.UserData64 != e.UserData64:
+		return tdb_types.AccountExistsWithDifferentUserData64
+	case a.UserData32 != e.UserData32:
+		return tdb_types.AccountExistsWithDifferentUserData32
+	case a.Ledger != e.Ledger:
+		return tdb_types.AccountExistsWithDifferentLedger
+	case a.Code != e.Code:
+		return tdb_types.AccountExistsWithDifferentCode
+	}
+	return tdb_types.AccountExists
+}
@@ -0,0 +1,578 @@
+package tbfake_test
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/2HgO/quidax-go/db/tbfake"
+	tdb "github.com/tigerbeetle/tigerbeetle-go"
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+)
+
+// The suite runs against the fake, set TB_CONFORMANCE_ADDRESS (and TB_CONFORMANCE_CLUSTER_ID) to run it
+// against a real cluster. Every test writes to its own accounts on a ledger the services do not use, so it
+// can be run against a cluster that holds other data
+const ledger = 9001
+
+type backend struct {
+	client tdb.Client
+	// advance moves the clock timeouts are checked against, a real cluster waits
+	advance func(time.Duration)
+	// user tags the accounts of a single test so queries only see them
+	user tdb_types.Uint128
+}
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newBackend(t *testing.T) *backend {
+	t.Helper()
+	if addr := os.Getenv("TB_CONFORMANCE_ADDRESS"); addr != "" {
+		var clusterID uint64
+		if id := os.Getenv("TB_CONFORMANCE_CLUSTER_ID"); id != "" {
+			var err error
+			if clusterID, err = strconv.ParseUint(id, 10, 64); err != nil {
+				t.Fatal(err)
+			}
+		}
+		client, err := tdb.NewClient(tdb_types.ToUint128(clusterID), strings.Split(addr, ","))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(client.Close)
+		return &backend{client: client, advance: time.Sleep, user: tdb_types.ID()}
+	}
+
+	c := &clock{now: time.Now()}
+	client := tbfake.New(tbfake.WithClock(c.Now))
+	t.Cleanup(client.Close)
+	return &backend{client: client, advance: c.Advance, user: tdb_types.ID()}
+}
+
+func (b *backend) account(flags tdb_types.AccountFlags) tdb_types.Account {
+	return tdb_types.Account{
+		ID:          tdb_types.ID(),
+		UserData128: b.user,
+		Ledger:      ledger,
+		Code:        1,
+		Flags:       flags.ToUint16(),
+	}
+}
+
+// accounts creates n accounts with the given flags
+func (b *backend) accounts(t *testing.T, n int, flags tdb_types.AccountFlags) []tdb_types.Account {
+	t.Helper()
+	accounts := make([]tdb_types.Account, n)
+	for i := range accounts {
+		accounts[i] = b.account(flags)
+	}
+	res, err := b.client.CreateAccounts(accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) > 0 {
+		t.Fatalf("creating accounts: %v", res)
+	}
+	return accounts
+}
+
+func (b *backend) lookup(t *testing.T, id tdb_types.Uint128) tdb_types.Account {
+	t.Helper()
+	res, err := b.client.LookupAccounts([]tdb_types.Uint128{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("account %s not found", id)
+	}
+	return res[0]
+}
+
+func (b *backend) transfer(t *testing.T, transfers ...tdb_types.Transfer) []tdb_types.TransferEventResult {
+	t.Helper()
+	res, err := b.client.CreateTransfers(transfers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func transfer(debit tdb_types.Account, credit tdb_types.Account, amount uint64, flags tdb_types.TransferFlags) tdb_types.Transfer {
+	return tdb_types.Transfer{
+		ID:              tdb_types.ID(),
+		DebitAccountID:  debit.ID,
+		CreditAccountID: credit.ID,
+		Amount:          tdb_types.ToUint128(amount),
+		Ledger:          ledger,
+		Code:            1,
+		Flags:           flags.ToUint16(),
+	}
+}
+
+func settle(pending tdb_types.Transfer, amount uint64, flags tdb_types.TransferFlags) tdb_types.Transfer {
+	return tdb_types.Transfer{
+		ID:        tdb_types.ID(),
+		PendingID: pending.ID,
+		Amount:    tdb_types.ToUint128(amount),
+		Flags:     flags.ToUint16(),
+	}
+}
+
+type balances struct {
+	debitsPending, debitsPosted, creditsPending, creditsPosted uint64
+}
+
+func expectBalances(t *testing.T, account tdb_types.Account, want balances) {
+	t.Helper()
+	got := balances{
+		debitsPending:  amount(account.DebitsPending),
+		debitsPosted:   amount(account.DebitsPosted),
+		creditsPending: amount(account.CreditsPending),
+		creditsPosted:  amount(account.CreditsPosted),
+	}
+	if got != want {
+		t.Fatalf("account %s balances are %+v, want %+v", account.ID, got, want)
+	}
+}
+
+func amount(v tdb_types.Uint128) uint64 {
+	n := v.BigInt()
+	return n.Uint64()
+}
+
+func expectTransferResults(t *testing.T, got []tdb_types.TransferEventResult, want ...tdb_types.TransferEventResult) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Fatalf("results are %v, want %v", got, want)
+	}
+}
+
+func expectAccountResults(t *testing.T, got []tdb_types.AccountEventResult, want ...tdb_types.AccountEventResult) {
+	t.Helper()
+	if !slices.Equal(got, want) {
+		t.Fatalf("results are %v, want %v", got, want)
+	}
+}
+
+func TestCreateAccounts(t *testing.T) {
+	b := newBackend(t)
+	existing := b.accounts(t, 1, tdb_types.AccountFlags{})[0]
+
+	differentCode := existing
+	differentCode.Code = 2
+	noLedger := b.account(tdb_types.AccountFlags{})
+	noLedger.Ledger = 0
+	noCode := b.account(tdb_types.AccountFlags{})
+	noCode.Code = 0
+	timestamped := b.account(tdb_types.AccountFlags{})
+	timestamped.Timestamp = 1
+	withBalance := b.account(tdb_types.AccountFlags{})
+	withBalance.CreditsPosted = tdb_types.ToUint128(1)
+
+	res, err := b.client.CreateAccounts([]tdb_types.Account{
+		b.account(tdb_types.AccountFlags{}),
+		existing,
+		differentCode,
+		{Ledger: ledger, Code: 1},
+		noLedger,
+		noCode,
+		timestamped,
+		b.account(tdb_types.AccountFlags{DebitsMustNotExceedCredits: true, CreditsMustNotExceedDebits: true}),
+		withBalance,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAccountResults(t, res,
+		tdb_types.AccountEventResult{Index: 1, Result: tdb_types.AccountExists},
+		tdb_types.AccountEventResult{Index: 2, Result: tdb_types.AccountExistsWithDifferentCode},
+		tdb_types.AccountEventResult{Index: 3, Result: tdb_types.AccountIDMustNotBeZero},
+		tdb_types.AccountEventResult{Index: 4, Result: tdb_types.AccountLedgerMustNotBeZero},
+		tdb_types.AccountEventResult{Index: 5, Result: tdb_types.AccountCodeMustNotBeZero},
+		tdb_types.AccountEventResult{Index: 6, Result: tdb_types.AccountTimestampMustBeZero},
+		tdb_types.AccountEventResult{Index: 7, Result: tdb_types.AccountFlagsAreMutuallyExclusive},
+		tdb_types.AccountEventResult{Index: 8, Result: tdb_types.AccountCreditsPostedMustBeZero},
+	)
+
+	created := b.lookup(t, existing.ID)
+	if created.Timestamp == 0 || created.UserData128 != b.user {
+		t.Fatalf("account was stored as %+v", created)
+	}
+}
+
+func TestLinkedAccounts(t *testing.T) {
+	b := newBackend(t)
+	linked := tdb_types.AccountFlags{Linked: true}
+
+	first, second, invalid, after := b.account(linked), b.account(linked), b.account(tdb_types.AccountFlags{}), b.account(tdb_types.AccountFlags{})
+	invalid.Code = 0
+	res, err := b.client.CreateAccounts([]tdb_types.Account{first, second, invalid, after})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAccountResults(t, res,
+		tdb_types.AccountEventResult{Index: 0, Result: tdb_types.AccountLinkedEventFailed},
+		tdb_types.AccountEventResult{Index: 1, Result: tdb_types.AccountLinkedEventFailed},
+		tdb_types.AccountEventResult{Index: 2, Result: tdb_types.AccountCodeMustNotBeZero},
+	)
+
+	found, err := b.client.LookupAccounts([]tdb_types.Uint128{first.ID, second.ID, after.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != after.ID {
+		t.Fatalf("a failed chain left accounts behind: %v", found)
+	}
+
+	res, err = b.client.CreateAccounts([]tdb_types.Account{b.account(tdb_types.AccountFlags{}), b.account(linked)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectAccountResults(t, res, tdb_types.AccountEventResult{Index: 1, Result: tdb_types.AccountLinkedEventChainOpen})
+}
+
+func TestTransfers(t *testing.T) {
+	b := newBackend(t)
+	system := b.accounts(t, 1, tdb_types.AccountFlags{})[0]
+	wallets := b.accounts(t, 2, tdb_types.AccountFlags{DebitsMustNotExceedCredits: true})
+
+	deposit := transfer(system, wallets[0], 100, tdb_types.TransferFlags{})
+	expectTransferResults(t, b.transfer(t, deposit))
+	expectTransferResults(t, b.transfer(t, transfer(wallets[0], wallets[1], 40, tdb_types.TransferFlags{})))
+
+	expectBalances(t, b.lookup(t, system.ID), balances{debitsPosted: 100})
+	expectBalances(t, b.lookup(t, wallets[0].ID), balances{debitsPosted: 40, creditsPosted: 100})
+	expectBalances(t, b.lookup(t, wallets[1].ID), balances{creditsPosted: 40})
+
+	overdraft := transfer(wallets[1], wallets[0], 41, tdb_types.TransferFlags{})
+	otherLedger := transfer(system, wallets[0], 1, tdb_types.TransferFlags{})
+	otherLedger.Ledger = ledger + 1
+	differentAmount := deposit
+	differentAmount.Amount = tdb_types.ToUint128(1)
+	expectTransferResults(t, b.transfer(t,
+		overdraft,
+		transfer(wallets[0], wallets[0], 1, tdb_types.TransferFlags{}),
+		transfer(b.account(tdb_types.AccountFlags{}), wallets[0], 1, tdb_types.TransferFlags{}),
+		otherLedger,
+		deposit,
+		differentAmount,
+		transfer(system, wallets[0], 1, tdb_types.TransferFlags{PostPendingTransfer: true}),
+	),
+		tdb_types.TransferEventResult{Index: 0, Result: tdb_types.TransferExceedsCredits},
+		tdb_types.TransferEventResult{Index: 1, Result: tdb_types.TransferAccountsMustBeDifferent},
+		tdb_types.TransferEventResult{Index: 2, Result: tdb_types.TransferDebitAccountNotFound},
+		tdb_types.TransferEventResult{Index: 3, Result: tdb_types.TransferTransferMustHaveTheSameLedgerAsAccounts},
+		tdb_types.TransferEventResult{Index: 4, Result: tdb_types.TransferExists},
+		tdb_types.TransferEventResult{Index: 5, Result: tdb_types.TransferExistsWithDifferentAmount},
+		tdb_types.TransferEventResult{Index: 6, Result: tdb_types.TransferPendingIDMustNotBeZero},
+	)
+
+	// an id that failed because of the ledger's state can not be used again
+	overdraft.Amount = tdb_types.ToUint128(40)
+	expectTransferResults(t, b.transfer(t, overdraft),
+		tdb_types.TransferEventResult{Index: 0, Result: tdb_types.TransferIDAlreadyFailed},
+	)
+	expectBalances(t, b.lookup(t, wallets[1].ID), balances{creditsPosted: 40})
+}
+
+func TestLinkedTransfers(t *testing.T) {
+	b := newBackend(t)
+	system := b.accounts(t, 1, tdb_types.AccountFlags{})[0]
+	wallets := b.accounts(t, 2, tdb_types.AccountFlags{DebitsMustNotExceedCredits: true})
+	linked := tdb_types.TransferFlags{Linked: true}
+
+	// the swap leg fails, so the deposit linked to it is rolled back
+	expectTransferResults(t, b.transfer(t,
+		transfer(system, wallets[0], 10, linked),
+		transfer(wallets[0], wallets[1], 11, tdb_types.TransferFlags{}),
+		transfer(system, wallets[1], 5, tdb_types.TransferFlags{}),
+	),
+		tdb_types.TransferEventResult{Index: 0, Result: tdb_types.TransferLinkedEventFailed},
+		tdb_types.TransferEventResult{Index: 1, Result: tdb_types.TransferExceedsCredits},
+	)
+	expectBalances(t, b.lookup(t, system.ID), balances{debitsPosted: 5})
+	expectBalances(t, b.lookup(t, wallets[0].ID), balances{})
+	expectBalances(t, b.lookup(t, wallets[1].ID), balances{creditsPosted: 5})
+
+	expectTransferResults(t, b.transfer(t,
+		transfer(system, wallets[0], 10, linked),
+		transfer(wallets[0], wallets[1], 10, tdb_types.TransferFlags{}),
+	))
+	expectBalances(t, b.lookup(t, wallets[1].ID), balances{creditsPosted: 15})
+
+	expectTransferResults(t, b.transfer(t, transfer(system, wallets[0], 1, linked)),
+		tdb_types.TransferEventResult{Index: 0, Result: tdb_types.TransferLinkedEventChainOpen},
+	)
+}
+
+func TestPendingTransfers(t *testing.T) {
+	b := newBackend(t)
+	system := b.accounts(t, 1, tdb_types.AccountFlags{})[0]
+	wallet := b.accounts(t, 1, tdb_types.AccountFlags{DebitsMustNotExceedCredits: true})[0]
+	expectTransferResults(t, b.transfer(t, transfer(system, wallet, 100, tdb_types.TransferFlags{})))
+
+	pending := transfer(wallet, system, 60, tdb_types.TransferFlags{Pending: true})
+	expectTransferResults(t, b.transfer(t, pending))
+	expectBalances(t, b.lookup(t, wallet.ID), balances{debitsPending: 60, creditsPosted: 100})
+
+	// held funds count against the balance
+	expectTransferResults(t, b.transfer(t, transfer(wallet, system, 41, tdb_types.TransferFlags{Pending: true})),
+		tdb_types.TransferEventResult{Index: 0, Result: tdb_types.TransferExceedsCredits},
+	)
+
+	expectTransferResults(t, b.transfer(t,
+		settle(pending, 61, tdb_types.TransferFlags{PostPendingTransfer: true}),
+		settle(pending, 59, tdb_types.TransferFlags{VoidPendingTransfer: true}),
+		settle(tdb_types.Transfer{ID: tdb_types.ID()}, 60, tdb_types.TransferFlags{PostPendingTransfer: true}),
+		settle(pending, 60, tdb_types.TransferFlags{PostPendingTransfer: true, VoidPendingTransfer: true}),
+	),
+		tdb_types.TransferEventResult{Index: 0, Result: tdb_types.TransferExceedsPendingTransferAmount},
+		tdb_types.TransferEventResult{Index: 1, Result: tdb_types.TransferPendingTransferHasDifferentAmount},
+		tdb_types.TransferEventResult{Index: 2, Result: tdb_types.TransferPendingTransferNotFound},
+		tdb_types.TransferEventResult{Index: 3, Result: tdb_types.TransferFlagsAreMutuallyExclusive},
+	)
+
+	post := settle(pending, 60, tdb_types.TransferFlags{PostPendingTransfer: true})
+	expectTransferResults(t, b.transfer(t, post))
+	expectBalances(t, b.lookup(t, wallet.ID), balances{debitsPosted: 60, creditsPosted: 100})
+	expectBalances(t, b.lookup(t, system.ID), balances{debitsPosted: 100, creditsPosted: 60})
+
+	posted, err := b.client.LookupTransfers([]tdb_types.Uint128{post.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posted) != 1 || posted[0].DebitAccountID != wallet.ID || posted[0].Ledger != ledger || posted[0].Code != 1 {
+		t.Fatalf("post was stored as %+v", posted)
+	}
+
+	expectTransferResults(t, b.transfer(t,
+		settle(pending, 0, tdb_types.TransferFlags{VoidPendingTransfer: true}),
+		transfer(system, wallet, 1, tdb_types.TransferFlags{PostPendingTransfer: true}),
+	),
+		tdb_types.TransferEventResult{Index: 0, Result: tdb_types.TransferPendingTransferAlreadyPosted},
+		tdb_types.TransferEventResult{Index: 1, Result: tdb_types.TransferPendingIDMustNotBeZero},
+	)
+
+	// a void releases the hold, leaving out the amount voids all of it
+	hold := transfer(wallet, system, 40, tdb_types.TransferFlags{Pending: true})
+	expectTransferResults(t, b.transfer(t, hold))
+	expectTransferResults(t, b.transfer(t, settle(hold, 0, tdb_types.TransferFlags{VoidPendingTransfer: true})))
+	expectBalances(t, b.lookup(t, wallet.ID), balances{debitsPosted: 60, creditsPosted: 100})
+	expectTransferResults(t, b.transfer(t, settle(hold, 40, tdb_types.TransferFlags{PostPendingTransfer: true})),
+		tdb_types.TransferEventResult{Index: 0, Result: tdb_types.TransferPendingTransferAlreadyVoided},
+	)
+
+	// posting part of a hold releases the rest
+	hold = transfer(wallet, system, 40, tdb_types.TransferFlags{Pending: true})
+	expectTransferResults(t, b.transfer(t, hold))
+	expectTransferResults(t, b.transfer(t, settle(hold, 15, tdb_types.TransferFlags{PostPendingTransfer: true})))
+	expectBalances(t, b.lookup(t, wallet.ID), balances{debitsPosted: 75, creditsPosted: 100})
+}
+
+func TestPendingTransferTimeout(t *testing.T) {
+	b := newBackend(t)
+	system := b.accounts(t, 1, tdb_types.AccountFlags{})[0]
+	wallet := b.accounts(t, 1, tdb_types.AccountFlags{DebitsMustNotExceedCredits: true})[0]
+	expectTransferResults(t, b.transfer(t, transfer(system, wallet, 100, tdb_types.TransferFlags{})))
+
+	timeout := transfer(system, wallet, 1, tdb_types.TransferFlags{})
+	timeout.Timeout = 1
+	expectTransferResults(t, b.transfer(t, timeout),
+		tdb_types.TransferEventResult{Index: 0, Result: tdb_types.TransferTimeoutReservedForPendingTransfer},
+	)
+
+	pending := transfer(wallet, system, 60, tdb_types.TransferFlags{Pending: true})
+	pending.Timeout = 1
+	expectTransferResults(t, b.transfer(t, pending))
+	expectBalances(t, b.lookup(t, wallet.ID), balances{debitsPending: 60, creditsPosted: 100})
+
+	b.advance(2 * time.Second)
+	expectTransferResults(t, b.transfer(t, settle(pending, 60, tdb_types.TransferFlags{PostPendingTransfer: true})),
+		tdb_types.TransferEventResult{Index: 0, Result: tdb_types.TransferPendingTransferExpired},
+	)
+
+	// a cluster releases expired transfers in the background, so give it a moment
+	for i := 0; ; i++ {
+		account := b.lookup(t, wallet.ID)
+		if amount(account.DebitsPending) == 0 || i == 20 {
+			expectBalances(t, account, balances{creditsPosted: 100})
+			break
+		}
+		b.advance(100 * time.Millisecond)
+	}
+}
+
+func TestLookupTransfers(t *testing.T) {
+	b := newBackend(t)
+	accounts := b.accounts(t, 2, tdb_types.AccountFlags{})
+	first, second := transfer(accounts[0], accounts[1], 1, tdb_types.TransferFlags{}), transfer(accounts[1], accounts[0], 2, tdb_types.TransferFlags{})
+	expectTransferResults(t, b.transfer(t, first, second))
+
+	res, err := b.client.LookupTransfers([]tdb_types.Uint128{second.ID, tdb_types.ID(), first.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].ID != second.ID || res[1].ID != first.ID {
+		t.Fatalf("lookup returned %v", res)
+	}
+	if res[1].Timestamp == 0 || res[1].Timestamp >= res[0].Timestamp {
+		t.Fatalf("transfers in a batch were not given increasing timestamps: %d, %d", res[1].Timestamp, res[0].Timestamp)
+	}
+}
+
+func TestGetAccountTransfersAndBalances(t *testing.T) {
+	b := newBackend(t)
+	system := b.accounts(t, 1, tdb_types.AccountFlags{})[0]
+	wallet := b.accounts(t, 1, tdb_types.AccountFlags{History: true})[0]
+	other := b.accounts(t, 1, tdb_types.AccountFlags{})[0]
+
+	transfers := []tdb_types.Transfer{
+		transfer(system, wallet, 100, tdb_types.TransferFlags{}),
+		transfer(wallet, other, 30, tdb_types.TransferFlags{}),
+		transfer(system, other, 5, tdb_types.TransferFlags{}),
+		transfer(wallet, system, 20, tdb_types.TransferFlags{Pending: true}),
+	}
+	transfers[1].Code = 2
+	expectTransferResults(t, b.transfer(t, transfers...))
+
+	ids := func(transfers []tdb_types.Transfer) []tdb_types.Uint128 {
+		res := make([]tdb_types.Uint128, 0, len(transfers))
+		for _, t := range transfers {
+			res = append(res, t.ID)
+		}
+		return res
+	}
+	filter := func(flags tdb_types.AccountFilterFlags) tdb_types.AccountFilter {
+		return tdb_types.AccountFilter{AccountID: wallet.ID, Limit: 10, Flags: flags.ToUint32()}
+	}
+
+	cases := []struct {
+		name   string
+		filter tdb_types.AccountFilter
+		want   []tdb_types.Uint128
+	}{
+		{"both sides", filter(tdb_types.AccountFilterFlags{Debits: true, Credits: true}), ids([]tdb_types.Transfer{transfers[0], transfers[1], transfers[3]})},
+		{"credits", filter(tdb_types.AccountFilterFlags{Credits: true}), ids(transfers[:1])},
+		{"debits reversed", filter(tdb_types.AccountFilterFlags{Debits: true, Reversed: true}), ids([]tdb_types.Transfer{transfers[3], transfers[1]})},
+		{"limit", func() tdb_types.AccountFilter {
+			f := filter(tdb_types.AccountFilterFlags{Debits: true, Credits: true})
+			f.Limit = 1
+			return f
+		}(), ids(transfers[:1])},
+		{"code", func() tdb_types.AccountFilter {
+			f := filter(tdb_types.AccountFilterFlags{Debits: true, Credits: true})
+			f.Code = 2
+			return f
+		}(), ids(transfers[1:2])},
+		{"no side", filter(tdb_types.AccountFilterFlags{}), ids(nil)},
+		{"no limit", func() tdb_types.AccountFilter {
+			f := filter(tdb_types.AccountFilterFlags{Debits: true, Credits: true})
+			f.Limit = 0
+			return f
+		}(), ids(nil)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := b.client.GetAccountTransfers(c.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(res); !slices.Equal(got, c.want) {
+				t.Fatalf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+
+	stored, err := b.client.LookupTransfers(ids(transfers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranged := filter(tdb_types.AccountFilterFlags{Debits: true, Credits: true})
+	ranged.TimestampMin, ranged.TimestampMax = stored[1].Timestamp, stored[2].Timestamp
+	res, err := b.client.GetAccountTransfers(ranged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(res); !slices.Equal(got, ids(transfers[1:2])) {
+		t.Fatalf("timestamp range returned %v", got)
+	}
+
+	history, err := b.client.GetAccountBalances(filter(tdb_types.AccountFilterFlags{Debits: true, Credits: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("got %d balances, want 3", len(history))
+	}
+	want := []balances{{creditsPosted: 100}, {debitsPosted: 30, creditsPosted: 100}, {debitsPending: 20, debitsPosted: 30, creditsPosted: 100}}
+	for i, balance := range history {
+		got := balances{amount(balance.DebitsPending), amount(balance.DebitsPosted), amount(balance.CreditsPending), amount(balance.CreditsPosted)}
+		if got != want[i] || balance.Timestamp != stored[[]int{0, 1, 3}[i]].Timestamp {
+			t.Fatalf("balance %d is %+v at %d", i, got, balance.Timestamp)
+		}
+	}
+
+	// balances are only kept for accounts with the history flag
+	history, err = b.client.GetAccountBalances(tdb_types.AccountFilter{AccountID: other.ID, Limit: 10, Flags: tdb_types.AccountFilterFlags{Debits: true, Credits: true}.ToUint32()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Fatalf("got balances for an account without history: %v", history)
+	}
+}
+
+func TestQueryAccounts(t *testing.T) {
+	b := newBackend(t)
+	accounts := b.accounts(t, 3, tdb_types.AccountFlags{})
+	system := b.account(tdb_types.AccountFlags{})
+	system.Code = 2
+	if res, err := b.client.CreateAccounts([]tdb_types.Account{system}); err != nil || len(res) > 0 {
+		t.Fatal(res, err)
+	}
+
+	query := func(filter tdb_types.QueryFilter) []tdb_types.Uint128 {
+		t.Helper()
+		res, err := b.client.QueryAccounts(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]tdb_types.Uint128, 0, len(res))
+		for _, account := range res {
+			ids = append(ids, account.ID)
+		}
+		return ids
+	}
+
+	if got := query(tdb_types.QueryFilter{UserData128: b.user, Ledger: ledger, Code: 1, Limit: 10}); !slices.Equal(got, []tdb_types.Uint128{accounts[0].ID, accounts[1].ID, accounts[2].ID}) {
+		t.Fatalf("query returned %v", got)
+	}
+	if got := query(tdb_types.QueryFilter{UserData128: b.user, Limit: 2, Flags: tdb_types.QueryFilterFlags{Reversed: true}.ToUint32()}); !slices.Equal(got, []tdb_types.Uint128{system.ID, accounts[2].ID}) {
+		t.Fatalf("reversed query returned %v", got)
+	}
+	if got := query(tdb_types.QueryFilter{UserData128: b.user, Code: 2, Limit: 10}); !slices.Equal(got, []tdb_types.Uint128{system.ID}) {
+		t.Fatalf("query by code returned %v", got)
+	}
+	if got := query(tdb_types.QueryFilter{UserData128: b.user}); len(got) != 0 {
+		t.Fatalf("query without a limit returned %v", got)
+	}
+}
@@ -0,0 +1,235 @@
+// Package tbfake is an in-process fake of the tigerbeetle client, so services can be run without a replica.
+// It follows tigerbeetle 0.16's rules for the requests the services make: linked chains, pending, posted and
+// voided transfers with timeouts, balance limits and balance history, with the same result codes, checked in
+// the same order. The conformance suite in this package runs against the fake, or against a real cluster
+// when TB_CONFORMANCE_ADDRESS is set.
+//
+// Imported events are not supported, they fail with ImportedEventNotExpected. Expired pending transfers are
+// released the next time the client is used after their timeout, and leave no balance history entry
+package tbfake
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	tdb "github.com/tigerbeetle/tigerbeetle-go"
+	tdb_errors "github.com/tigerbeetle/tigerbeetle-go/pkg/errors"
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+)
+
+// batchMax is the most events a request can carry, and the most results a query returns, with tigerbeetle's
+// default 1MiB messages
+const batchMax = 8190
+
+type Option func(*Client)
+
+// WithClock sets the clock timestamps and timeouts are read from, time.Now by default
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) {
+		c.now = now
+	}
+}
+
+type pendingState uint8
+
+const (
+	statePending pendingState = iota + 1
+	statePosted
+	stateVoided
+	stateExpired
+)
+
+type transfer struct {
+	tdb_types.Transfer
+	// state is only set on pending transfers
+	state     pendingState
+	expiresAt uint64
+}
+
+// entry is a transfer on an account, with the account's balance once it was applied
+type entry struct {
+	transfer *transfer
+	balance  tdb_types.AccountBalance
+}
+
+type account struct {
+	tdb_types.Account
+	entries []entry
+}
+
+// Client is an in-memory tdb.Client, it is safe for concurrent use
+type Client struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	closed bool
+	// timestamp is the last timestamp handed out, every event gets a unique and increasing one
+	timestamp uint64
+
+	accounts     map[tdb_types.Uint128]*account
+	accountOrder []*account
+	transfers    map[tdb_types.Uint128]*transfer
+	// transferOrder holds transfers by timestamp, timeouts holds pending transfers that can still expire
+	transferOrder []*transfer
+	timeouts      []*transfer
+	// failed holds the ids of transfers that failed with a transient result, they can not be retried
+	failed map[tdb_types.Uint128]tdb_types.CreateTransferResult
+
+	// undo reverts the writes of the linked chain being applied
+	undo []func()
+}
+
+var _ tdb.Client = (*Client)(nil)
+
+func New(opts ...Option) *Client {
+	c := &Client{
+		now:       time.Now,
+		accounts:  make(map[tdb_types.Uint128]*account),
+		transfers: make(map[tdb_types.Uint128]*transfer),
+		failed:    make(map[tdb_types.Uint128]tdb_types.CreateTransferResult),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Client) LookupAccounts(accountIDs []tdb_types.Uint128) ([]tdb_types.Account, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.begin(len(accountIDs)); err != nil {
+		return nil, err
+	}
+
+	res := make([]tdb_types.Account, 0, len(accountIDs))
+	for _, id := range accountIDs {
+		if a, ok := c.accounts[id]; ok {
+			res = append(res, a.Account)
+		}
+	}
+	return res, nil
+}
+
+func (c *Client) LookupTransfers(transferIDs []tdb_types.Uint128) ([]tdb_types.Transfer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.begin(len(transferIDs)); err != nil {
+		return nil, err
+	}
+
+	res := make([]tdb_types.Transfer, 0, len(transferIDs))
+	for _, id := range transferIDs {
+		if t, ok := c.transfers[id]; ok {
+			res = append(res, t.Transfer)
+		}
+	}
+	return res, nil
+}
+
+func (c *Client) Nop() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.begin(0)
+}
+
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+}
+
+// begin checks a request can be made and expires pending transfers whose timeout has passed
+func (c *Client) begin(events int) error {
+	if c.closed {
+		return tdb_errors.ErrClientClosed{}
+	}
+	if events > batchMax {
+		return tdb_errors.ErrMaximumBatchSizeExceeded{}
+	}
+	c.expire(max(c.clock(), c.timestamp))
+	c.commit()
+	return nil
+}
+
+func (c *Client) clock() uint64 {
+	return uint64(c.now().UnixNano())
+}
+
+// reserve hands out timestamps for a batch of events and returns the first one. Like tigerbeetle, every
+// event is given a timestamp whether it succeeds or not
+func (c *Client) reserve(events int) uint64 {
+	last := max(c.clock(), c.timestamp+uint64(events))
+	c.timestamp = last
+	return last - uint64(events) + 1
+}
+
+func (c *Client) expire(now uint64) {
+	c.timeouts = slices.DeleteFunc(c.timeouts, func(t *transfer) bool {
+		if t.state != statePending {
+			return true
+		}
+		if t.expiresAt > now {
+			return false
+		}
+
+		t.state = stateExpired
+		dr, cr := c.accounts[t.DebitAccountID], c.accounts[t.CreditAccountID]
+		dr.DebitsPending = sub(dr.DebitsPending, t.Amount)
+		cr.CreditsPending = sub(cr.CreditsPending, t.Amount)
+		c.reopen(t, dr, cr)
+		return true
+	})
+}
+
+// write applies a change that is reverted if the linked chain it is part of fails
+func (c *Client) write(apply func(), revert func()) {
+	apply()
+	c.undo = append(c.undo, revert)
+}
+
+func (c *Client) commit() {
+	c.undo = c.undo[:0]
+}
+
+func (c *Client) rollback() {
+	for i := len(c.undo) - 1; i >= 0; i-- {
+		c.undo[i]()
+	}
+	c.undo = c.undo[:0]
+}
+
+// process runs create on every event of a batch, linked chains are applied in full or not at all. It returns
+// the result of every event, ok results included
+func process[R ~uint32](c *Client, events int, linked func(int) bool, create func(int) R, failed R, open R) []R {
+	results := make([]R, events)
+	chain, broken := -1, false
+	for i := 0; i < events; i++ {
+		if linked(i) && chain < 0 {
+			chain = i
+		}
+
+		var result R
+		switch {
+		case broken:
+			result = failed
+		case linked(i) && i == events-1:
+			result = open
+		default:
+			result = create(i)
+		}
+		if result != 0 && chain >= 0 && !broken {
+			broken = true
+			c.rollback()
+			for j := chain; j < i; j++ {
+				results[j] = failed
+			}
+		}
+		results[i] = result
+
+		if chain < 0 || !linked(i) || result == open {
+			c.commit()
+			chain, broken = -1, false
+		}
+	}
+	return results
+}
@@ -0,0 +1,176 @@
+package tbfake
+
+import (
+	"math"
+
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+)
+
+const (
+	filterDebits uint32 = 1 << iota
+	filterCredits
+	filterReversed
+
+	accountFilterFlagsMask = filterReversed<<1 - 1
+	queryFilterReversed    = uint32(1)
+)
+
+func (c *Client) GetAccountTransfers(filter tdb_types.AccountFilter) ([]tdb_types.Transfer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.begin(0); err != nil {
+		return nil, err
+	}
+
+	entries := c.accountEntries(filter)
+	res := make([]tdb_types.Transfer, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.transfer.Transfer)
+	}
+	return res, nil
+}
+
+// GetAccountBalances returns the account's balance after each transfer the filter matches, only accounts
+// created with the history flag keep their balances
+func (c *Client) GetAccountBalances(filter tdb_types.AccountFilter) ([]tdb_types.AccountBalance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.begin(0); err != nil {
+		return nil, err
+	}
+
+	res := make([]tdb_types.AccountBalance, 0)
+	if a, ok := c.accounts[filter.AccountID]; !ok || a.Flags&accountHistory == 0 {
+		return res, nil
+	}
+	for _, e := range c.accountEntries(filter) {
+		res = append(res, e.balance)
+	}
+	return res, nil
+}
+
+func (c *Client) QueryAccounts(filter tdb_types.QueryFilter) ([]tdb_types.Account, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.begin(0); err != nil {
+		return nil, err
+	}
+
+	res := make([]tdb_types.Account, 0)
+	if !validQueryFilter(filter) {
+		return res, nil
+	}
+	limit := int(min(filter.Limit, batchMax))
+	for i := range c.accountOrder {
+		if filter.Flags&queryFilterReversed != 0 {
+			i = len(c.accountOrder) - 1 - i
+		}
+		a := c.accountOrder[i].Account
+		if matchQuery(filter, a.UserData128, a.UserData64, a.UserData32, a.Ledger, a.Code, a.Timestamp) {
+			res = append(res, a)
+			if len(res) == limit {
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+func (c *Client) QueryTransfers(filter tdb_types.QueryFilter) ([]tdb_types.Transfer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.begin(0); err != nil {
+		return nil, err
+	}
+
+	res := make([]tdb_types.Transfer, 0)
+	if !validQueryFilter(filter) {
+		return res, nil
+	}
+	limit := int(min(filter.Limit, batchMax))
+	for i := range c.transferOrder {
+		if filter.Flags&queryFilterReversed != 0 {
+			i = len(c.transferOrder) - 1 - i
+		}
+		t := c.transferOrder[i].Transfer
+		if matchQuery(filter, t.UserData128, t.UserData64, t.UserData32, t.Ledger, t.Code, t.Timestamp) {
+			res = append(res, t)
+			if len(res) == limit {
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+// accountEntries returns the account's transfers the filter matches, an invalid filter matches nothing
+func (c *Client) accountEntries(filter tdb_types.AccountFilter) []entry {
+	if !validAccountFilter(filter) {
+		return nil
+	}
+	a, ok := c.accounts[filter.AccountID]
+	if !ok {
+		return nil
+	}
+
+	limit := int(min(filter.Limit, batchMax))
+	res := make([]entry, 0)
+	for i := range a.entries {
+		if filter.Flags&filterReversed != 0 {
+			i = len(a.entries) - 1 - i
+		}
+		e := a.entries[i]
+		t := e.transfer
+		if !(filter.Flags&filterDebits != 0 && t.DebitAccountID == a.ID) && !(filter.Flags&filterCredits != 0 && t.CreditAccountID == a.ID) {
+			continue
+		}
+		if !inRange(t.Timestamp, filter.TimestampMin, filter.TimestampMax) {
+			continue
+		}
+		if (filter.UserData128 != zero && filter.UserData128 != t.UserData128) ||
+			(filter.UserData64 != 0 && filter.UserData64 != t.UserData64) ||
+			(filter.UserData32 != 0 && filter.UserData32 != t.UserData32) ||
+			(filter.Code != 0 && filter.Code != t.Code) {
+			continue
+		}
+		res = append(res, e)
+		if len(res) == limit {
+			break
+		}
+	}
+	return res
+}
+
+func validAccountFilter(filter tdb_types.AccountFilter) bool {
+	return filter.AccountID != zero && filter.AccountID != maxU128 &&
+		validTimestamps(filter.TimestampMin, filter.TimestampMax) &&
+		filter.Limit != 0 &&
+		filter.Flags&(filterDebits|filterCredits) != 0 &&
+		filter.Flags&^accountFilterFlagsMask == 0 &&
+		filter.Reserved == [58]uint8{}
+}
+
+func validQueryFilter(filter tdb_types.QueryFilter) bool {
+	return validTimestamps(filter.TimestampMin, filter.TimestampMax) &&
+		filter.Limit != 0 &&
+		filter.Flags&^queryFilterReversed == 0 &&
+		filter.Reserved == [6]uint8{}
+}
+
+// validTimestamps checks a filter's timestamp range, a zero bound leaves that side open
+func validTimestamps(from uint64, to uint64) bool {
+	return from != math.MaxUint64 && to != math.MaxUint64 && (to == 0 || from <= to)
+}
+
+func inRange(timestamp uint64, from uint64, to uint64) bool {
+	return timestamp >= from && (to == 0 || timestamp <= to)
+}
+
+func matchQuery(filter tdb_types.QueryFilter, userData128 tdb_types.Uint128, userData64 uint64, userData32 uint32, ledger uint32, code uint16, timestamp uint64) bool {
+	return (filter.UserData128 == zero || filter.UserData128 == userData128) &&
+		(filter.UserData64 == 0 || filter.UserData64 == userData64) &&
+		(filter.UserData32 == 0 || filter.UserData32 == userData32) &&
+		(filter.Ledger == 0 || filter.Ledger == ledger) &&
+		(filter.Code == 0 || filter.Code == code) &&
+		inRange(timestamp, filter.TimestampMin, filter.TimestampMax)
+}
@@ -0,0 +1,452 @@
+package tbfake
+
+import (
+	"math"
+	"math/bits"
+
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+)
+
+const (
+	transferLinked uint16 = 1 << iota
+	transferPending
+	transferPost
+	transferVoid
+	transferBalancingDebit
+	transferBalancingCredit
+	transferClosingDebit
+	transferClosingCredit
+	transferImported
+
+	transferFlagsMask = transferImported<<1 - 1
+)
+
+func (c *Client) CreateTransfers(transfers []tdb_types.Transfer) ([]tdb_types.TransferEventResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.begin(len(transfers)); err != nil {
+		return nil, err
+	}
+
+	first := c.reserve(len(transfers))
+	results := process(c, len(transfers),
+		func(i int) bool { return transfers[i].Flags&transferLinked != 0 },
+		func(i int) tdb_types.CreateTransferResult { return c.createTransfer(transfers[i], first+uint64(i)) },
+		tdb_types.TransferLinkedEventFailed,
+		tdb_types.TransferLinkedEventChainOpen,
+	)
+
+	res := make([]tdb_types.TransferEventResult, 0)
+	for i, result := range results {
+		if result != tdb_types.TransferOK {
+			res = append(res, tdb_types.TransferEventResult{Index: uint32(i), Result: result})
+		}
+	}
+	return res, nil
+}
+
+func (c *Client) createTransfer(t tdb_types.Transfer, timestamp uint64) tdb_types.CreateTransferResult {
+	if t.Flags&transferImported != 0 {
+		return tdb_types.TransferImportedEventNotExpected
+	}
+	if t.Timestamp != 0 {
+		return tdb_types.TransferTimestampMustBeZero
+	}
+	if t.Flags&^transferFlagsMask != 0 {
+		return tdb_types.TransferReservedFlag
+	}
+	if t.ID == zero {
+		return tdb_types.TransferIDMustNotBeZero
+	}
+	if t.ID == maxU128 {
+		return tdb_types.TransferIDMustNotBeIntMax
+	}
+	if e, ok := c.transfers[t.ID]; ok {
+		return transferExists(t, e.Transfer)
+	}
+	if _, ok := c.failed[t.ID]; ok {
+		return tdb_types.TransferIDAlreadyFailed
+	}
+
+	var result tdb_types.CreateTransferResult
+	if t.Flags&(transferPost|transferVoid) != 0 {
+		result = c.postOrVoidTransfer(t, timestamp)
+	} else {
+		result = c.createSingleTransfer(t, timestamp)
+	}
+	if transient(result) {
+		c.failed[t.ID] = result
+	}
+	return result
+}
+
+func (c *Client) createSingleTransfer(t tdb_types.Transfer, timestamp uint64) tdb_types.CreateTransferResult {
+	if t.DebitAccountID == zero {
+		return tdb_types.TransferDebitAccountIDMustNotBeZero
+	}
+	if t.DebitAccountID == maxU128 {
+		return tdb_types.TransferDebitAccountIDMustNotBeIntMax
+	}
+	if t.CreditAccountID == zero {
+		return tdb_types.TransferCreditAccountIDMustNotBeZero
+	}
+	if t.CreditAccountID == maxU128 {
+		return tdb_types.TransferCreditAccountIDMustNotBeIntMax
+	}
+	if t.DebitAccountID == t.CreditAccountID {
+		return tdb_types.TransferAccountsMustBeDifferent
+	}
+	if t.PendingID != zero {
+		return tdb_types.TransferPendingIDMustBeZero
+	}
+	pending := t.Flags&transferPending != 0
+	if !pending {
+		if t.Timeout != 0 {
+			return tdb_types.TransferTimeoutReservedForPendingTransfer
+		}
+		if t.Flags&(transferClosingDebit|transferClosingCredit) != 0 {
+			return tdb_types.TransferClosingTransferMustBePending
+		}
+	}
+	if t.Ledger == 0 {
+		return tdb_types.TransferLedgerMustNotBeZero
+	}
+	if t.Code == 0 {
+		return tdb_types.TransferCodeMustNotBeZero
+	}
+
+	dr, ok := c.accounts[t.DebitAccountID]
+	if !ok {
+		return tdb_types.TransferDebitAccountNotFound
+	}
+	cr, ok := c.accounts[t.CreditAccountID]
+	if !ok {
+		return tdb_types.TransferCreditAccountNotFound
+	}
+	if dr.Ledger != cr.Ledger {
+		return tdb_types.TransferAccountsMustHaveTheSameLedger
+	}
+	if t.Ledger != dr.Ledger {
+		return tdb_types.TransferTransferMustHaveTheSameLedgerAsAccounts
+	}
+	if dr.Flags&accountClosed != 0 {
+		return tdb_types.TransferDebitAccountAlreadyClosed
+	}
+	if cr.Flags&accountClosed != 0 {
+		return tdb_types.TransferCreditAccountAlreadyClosed
+	}
+
+	amount := t.Amount
+	if t.Flags&transferBalancingDebit != 0 {
+		amount = minimum(amount, sub(dr.CreditsPosted, saturate(add(dr.DebitsPosted, dr.DebitsPending))))
+	}
+	if t.Flags&transferBalancingCredit != 0 {
+		amount = minimum(amount, sub(cr.DebitsPosted, saturate(add(cr.CreditsPosted, cr.CreditsPending))))
+	}
+
+	if pending {
+		if _, overflow := add(dr.DebitsPending, amount); overflow {
+			return tdb_types.TransferOverflowsDebitsPending
+		}
+		if _, overflow := add(cr.CreditsPending, amount); overflow {
+			return tdb_types.TransferOverflowsCreditsPending
+		}
+	} else {
+		if _, overflow := add(dr.DebitsPosted, amount); overflow {
+			return tdb_types.TransferOverflowsDebitsPosted
+		}
+		if _, overflow := add(cr.CreditsPosted, amount); overflow {
+			return tdb_types.TransferOverflowsCreditsPosted
+		}
+	}
+	debits, overflow := add(dr.DebitsPending, dr.DebitsPosted)
+	if debits, overflow = add(debits, amount); overflow {
+		return tdb_types.TransferOverflowsDebits
+	}
+	credits, overflow := add(cr.CreditsPending, cr.CreditsPosted)
+	if credits, overflow = add(credits, amount); overflow {
+		return tdb_types.TransferOverflowsCredits
+	}
+	var expiresAt uint64
+	if pending && t.Timeout > 0 {
+		hi, lo := bits.Mul64(uint64(t.Timeout), 1e9)
+		sum, carry := bits.Add64(timestamp, lo, 0)
+		if hi != 0 || carry != 0 || sum == math.MaxUint64 {
+			return tdb_types.TransferOverflowsTimeout
+		}
+		expiresAt = sum
+	}
+	if dr.Flags&accountDebitsMustNotExceedCredits != 0 && less(dr.CreditsPosted, debits) {
+		return tdb_types.TransferExceedsCredits
+	}
+	if cr.Flags&accountCreditsMustNotExceedDebits != 0 && less(cr.DebitsPosted, credits) {
+		return tdb_types.TransferExceedsDebits
+	}
+
+	t.Amount = amount
+	t.Timestamp = timestamp
+	created := &transfer{Transfer: t, expiresAt: expiresAt}
+	if pending {
+		created.state = statePending
+	}
+	c.update(dr, cr, func(dr *tdb_types.Account, cr *tdb_types.Account) {
+		if pending {
+			dr.DebitsPending, _ = add(dr.DebitsPending, amount)
+			cr.CreditsPending, _ = add(cr.CreditsPending, amount)
+		} else {
+			dr.DebitsPosted, _ = add(dr.DebitsPosted, amount)
+			cr.CreditsPosted, _ = add(cr.CreditsPosted, amount)
+		}
+		if t.Flags&transferClosingDebit != 0 {
+			dr.Flags |= accountClosed
+		}
+		if t.Flags&transferClosingCredit != 0 {
+			cr.Flags |= accountClosed
+		}
+	})
+	c.insert(created, dr, cr)
+	return tdb_types.TransferOK
+}
+
+func (c *Client) postOrVoidTransfer(t tdb_types.Transfer, timestamp uint64) tdb_types.CreateTransferResult {
+	post := t.Flags&transferPost != 0
+	if post && t.Flags&transferVoid != 0 {
+		return tdb_types.TransferFlagsAreMutuallyExclusive
+	}
+	if t.Flags&(transferPending|transferBalancingDebit|transferBalancingCredit|transferClosingDebit|transferClosingCredit) != 0 {
+		return tdb_types.TransferFlagsAreMutuallyExclusive
+	}
+	if t.PendingID == zero {
+		return tdb_types.TransferPendingIDMustNotBeZero
+	}
+	if t.PendingID == maxU128 {
+		return tdb_types.TransferPendingIDMustNotBeIntMax
+	}
+	if t.PendingID == t.ID {
+		return tdb_types.TransferPendingIDMustBeDifferent
+	}
+	if t.Timeout != 0 {
+		return tdb_types.TransferTimeoutReservedForPendingTransfer
+	}
+
+	p, ok := c.transfers[t.PendingID]
+	if !ok {
+		return tdb_types.TransferPendingTransferNotFound
+	}
+	if p.Flags&transferPending == 0 {
+		return tdb_types.TransferPendingTransferNotPending
+	}
+	if t.DebitAccountID != zero && t.DebitAccountID != p.DebitAccountID {
+		return tdb_types.TransferPendingTransferHasDifferentDebitAccountID
+	}
+	if t.CreditAccountID != zero && t.CreditAccountID != p.CreditAccountID {
+		return tdb_types.TransferPendingTransferHasDifferentCreditAccountID
+	}
+	if t.Ledger != 0 && t.Ledger != p.Ledger {
+		return tdb_types.TransferPendingTransferHasDifferentLedger
+	}
+	if t.Code != 0 && t.Code != p.Code {
+		return tdb_types.TransferPendingTransferHasDifferentCode
+	}
+
+	// posting AMOUNT_MAX posts the pending amount, a void is for the pending amount and may leave it out
+	amount := p.Amount
+	if post {
+		if t.Amount != maxU128 {
+			if less(p.Amount, t.Amount) {
+				return tdb_types.TransferExceedsPendingTransferAmount
+			}
+			amount = t.Amount
+		}
+	} else if t.Amount != zero && t.Amount != maxU128 && t.Amount != p.Amount {
+		return tdb_types.TransferPendingTransferHasDifferentAmount
+	}
+
+	switch p.state {
+	case statePosted:
+		return tdb_types.TransferPendingTransferAlreadyPosted
+	case stateVoided:
+		return tdb_types.TransferPendingTransferAlreadyVoided
+	case stateExpired:
+		return tdb_types.TransferPendingTransferExpired
+	}
+	if p.Timeout > 0 && timestamp >= p.expiresAt {
+		return tdb_types.TransferPendingTransferExpired
+	}
+
+	dr, cr := c.accounts[p.DebitAccountID], c.accounts[p.CreditAccountID]
+	if dr.Flags&accountClosed != 0 && p.Flags&transferClosingDebit == 0 {
+		return tdb_types.TransferDebitAccountAlreadyClosed
+	}
+	if cr.Flags&accountClosed != 0 && p.Flags&transferClosingCredit == 0 {
+		return tdb_types.TransferCreditAccountAlreadyClosed
+	}
+
+	t.DebitAccountID, t.CreditAccountID = p.DebitAccountID, p.CreditAccountID
+	t.Ledger, t.Code = p.Ledger, p.Code
+	if t.UserData128 == zero {
+		t.UserData128 = p.UserData128
+	}
+	if t.UserData64 == 0 {
+		t.UserData64 = p.UserData64
+	}
+	if t.UserData32 == 0 {
+		t.UserData32 = p.UserData32
+	}
+	t.Amount = amount
+	t.Timestamp = timestamp
+
+	state := stateVoided
+	if post {
+		state = statePosted
+	}
+	c.write(func() { p.state = state }, func() { p.state = statePending })
+	c.update(dr, cr, func(dr *tdb_types.Account, cr *tdb_types.Account) {
+		dr.DebitsPending = sub(dr.DebitsPending, p.Amount)
+		cr.CreditsPending = sub(cr.CreditsPending, p.Amount)
+		if post {
+			dr.DebitsPosted, _ = add(dr.DebitsPosted, amount)
+			cr.CreditsPosted, _ = add(cr.CreditsPosted, amount)
+		}
+	})
+	if !post {
+		c.reopen(p, dr, cr)
+	}
+	c.insert(&transfer{Transfer: t}, dr, cr)
+	return tdb_types.TransferOK
+}
+
+// update changes the balances or flags of a transfer's accounts
+func (c *Client) update(dr *account, cr *account, change func(dr *tdb_types.Account, cr *tdb_types.Account)) {
+	drBefore, crBefore := dr.Account, cr.Account
+	c.write(func() {
+		change(&dr.Account, &cr.Account)
+	}, func() {
+		dr.Account, cr.Account = drBefore, crBefore
+	})
+}
+
+// reopen opens the accounts a voided or expired closing transfer closed
+func (c *Client) reopen(p *transfer, dr *account, cr *account) {
+	if p.Flags&(transferClosingDebit|transferClosingCredit) == 0 {
+		return
+	}
+	c.update(dr, cr, func(dr *tdb_types.Account, cr *tdb_types.Account) {
+		if p.Flags&transferClosingDebit != 0 {
+			dr.Flags &^= accountClosed
+		}
+		if p.Flags&transferClosingCredit != 0 {
+			cr.Flags &^= accountClosed
+		}
+	})
+}
+
+// insert stores a transfer along with the balances of its accounts once it was applied
+func (c *Client) insert(t *transfer, dr *account, cr *account) {
+	c.write(func() {
+		c.transfers[t.ID] = t
+		c.transferOrder = append(c.transferOrder, t)
+		dr.entries = append(dr.entries, entry{transfer: t, balance: balance(dr.Account, t.Timestamp)})
+		cr.entries = append(cr.entries, entry{transfer: t, balance: balance(cr.Account, t.Timestamp)})
+		if t.expiresAt > 0 {
+			c.timeouts = append(c.timeouts, t)
+		}
+	}, func() {
+		delete(c.transfers, t.ID)
+		c.transferOrder = c.transferOrder[:len(c.transferOrder)-1]
+		dr.entries = dr.entries[:len(dr.entries)-1]
+		cr.entries = cr.entries[:len(cr.entries)-1]
+		if t.expiresAt > 0 {
+			c.timeouts = c.timeouts[:len(c.timeouts)-1]
+		}
+	})
+}
+
+func balance(a tdb_types.Account, timestamp uint64) tdb_types.AccountBalance {
+	return tdb_types.AccountBalance{
+		DebitsPending:  a.DebitsPending,
+		DebitsPosted:   a.DebitsPosted,
+		CreditsPending: a.CreditsPending,
+		CreditsPosted:  a.CreditsPosted,
+		Timestamp:      timestamp,
+	}
+}
+
+func transferExists(t tdb_types.Transfer, e tdb_types.Transfer) tdb_types.CreateTransferResult {
+	if t.Flags != e.Flags {
+		return tdb_types.TransferExistsWithDifferentFlags
+	}
+	if t.PendingID != e.PendingID {
+		return tdb_types.TransferExistsWithDifferentPendingID
+	}
+	if t.Timeout != e.Timeout {
+		return tdb_types.TransferExistsWithDifferentTimeout
+	}
+
+	// a post or void takes the fields it leaves out from its pending transfer
+	postOrVoid := t.Flags&(transferPost|transferVoid) != 0
+	differs := func(field tdb_types.Uint128, existing tdb_types.Uint128) bool {
+		return field != existing && !(postOrVoid && field == zero)
+	}
+	switch {
+	case differs(t.DebitAccountID, e.DebitAccountID):
+		return tdb_types.TransferExistsWithDifferentDebitAccountID
+	case differs(t.CreditAccountID, e.CreditAccountID):
+		return tdb_types.TransferExistsWithDifferentCreditAccountID
+	case amountDiffers(t, e):
+		return tdb_types.TransferExistsWithDifferentAmount
+	case differs(t.UserData128, e.UserData128):
+		return tdb_types.TransferExistsWithDifferentUserData128
+	case t.UserData64 != e.UserData64 && !(postOrVoid && t.UserData64 == 0):
+		return tdb_types.TransferExistsWithDifferentUserData64
+	case t.UserData32 != e.UserData32 && !(postOrVoid && t.UserData32 == 0):
+		return tdb_types.TransferExistsWithDifferentUserData32
+	case t.Ledger != e.Ledger && !(postOrVoid && t.Ledger == 0):
+		return tdb_types.TransferExistsWithDifferentLedger
+	case t.Code != e.Code && !(postOrVoid && t.Code == 0):
+		return tdb_types.TransferExistsWithDifferentCode
+	}
+	return tdb_types.TransferExists
+}
+
+func amountDiffers(t tdb_types.Transfer, e tdb_types.Transfer) bool {
+	switch {
+	case t.Flags&transferPost != 0:
+		return t.Amount != maxU128 && t.Amount != e.Amount
+	case t.Flags&transferVoid != 0:
+		return t.Amount != zero && t.Amount != maxU128 && t.Amount != e.Amount
+	case t.Flags&(transferBalancingDebit|transferBalancingCredit) != 0:
+		// balancing transfers are stored with the amount they were cut down to
+		return less(t.Amount, e.Amount)
+	}
+	return t.Amount != e.Amount
+}
+
+// transient results depend on the state of the ledger when the transfer was made, tigerbeetle keeps the
+// transfer's id so it can not succeed on a retry
+func transient(result tdb_types.CreateTransferResult) bool {
+	switch result {
+	case tdb_types.TransferDebitAccountNotFound,
+		tdb_types.TransferCreditAccountNotFound,
+		tdb_types.TransferPendingTransferNotFound,
+		tdb_types.TransferExceedsCredits,
+		tdb_types.TransferExceedsDebits,
+		tdb_types.TransferDebitAccountAlreadyClosed,
+		tdb_types.TransferCreditAccountAlreadyClosed,
+		tdb_types.TransferOverflowsDebitsPending,
+		tdb_types.TransferOverflowsCreditsPending,
+		tdb_types.TransferOverflowsDebitsPosted,
+		tdb_types.TransferOverflowsCreditsPosted,
+		tdb_types.TransferOverflowsDebits,
+		tdb_types.TransferOverflowsCredits:
+		return true
+	}
+	return false
+}
+
+func saturate(sum tdb_types.Uint128, overflow bool) tdb_types.Uint128 {
+	if overflow {
+		return maxU128
+	}
+	return sum
+}
@@ -0,0 +1,61 @@
+package tbfake
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+)
+
+var (
+	zero    = tdb_types.Uint128{}
+	maxU128 = join(math.MaxUint64, math.MaxUint64)
+)
+
+// split returns the low and high halves of a little endian Uint128
+func split(v tdb_types.Uint128) (lo uint64, hi uint64) {
+	b := v.Bytes()
+	return binary.LittleEndian.Uint64(b[:8]), binary.LittleEndian.Uint64(b[8:])
+}
+
+func join(lo uint64, hi uint64) tdb_types.Uint128 {
+	var b [16]byte
+	binary.LittleEndian.PutUint64(b[:8], lo)
+	binary.LittleEndian.PutUint64(b[8:], hi)
+	return tdb_types.BytesToUint128(b)
+}
+
+// add returns a+b and whether the sum overflowed
+func add(a tdb_types.Uint128, b tdb_types.Uint128) (tdb_types.Uint128, bool) {
+	alo, ahi := split(a)
+	blo, bhi := split(b)
+	lo, carry := bits.Add64(alo, blo, 0)
+	hi, carry := bits.Add64(ahi, bhi, carry)
+	return join(lo, hi), carry != 0
+}
+
+// sub returns a-b, or zero when b is greater than a
+func sub(a tdb_types.Uint128, b tdb_types.Uint128) tdb_types.Uint128 {
+	alo, ahi := split(a)
+	blo, bhi := split(b)
+	lo, borrow := bits.Sub64(alo, blo, 0)
+	hi, borrow := bits.Sub64(ahi, bhi, borrow)
+	if borrow != 0 {
+		return zero
+	}
+	return join(lo, hi)
+}
+
+func less(a tdb_types.Uint128, b tdb_types.Uint128) bool {
+	alo, ahi := split(a)
+	blo, bhi := split(b)
+	return ahi < bhi || (ahi == bhi && alo < blo)
+}
+
+func minimum(a tdb_types.Uint128, b tdb_types.Uint128) tdb_types.Uint128 {
+	if less(b, a) {
+		return b
+	}
+	return a
+}
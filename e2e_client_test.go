@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// client calls the api of the harness' application. Calls fail with the AppError the api responded with
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// withToken returns a client authenticating with the token
+func (c *client) withToken(token string) *client {
+	return &client{base: c.base, token: token, http: c.http}
+}
+
+// do sends the body as JSON and returns the response as it is, nil bodies are not sent
+func (c *client) do(method, path string, body any) (*http.Response, error) {
+	var payload io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = bytes.NewReader(content)
+	}
+
+	req, err := http.NewRequest(method, c.base+path, payload)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/json")
+	if c.token != "" {
+		req.Header.Set("authorization", "Bearer "+c.token)
+	}
+	return c.http.Do(req)
+}
+
+// call sends the body as JSON and decodes the response into a T, nil bodies are not sent
+func call[T any](c *client, method, path string, body any) (T, error) {
+	var out T
+	res, err := c.do(method, path, body)
+	if err != nil {
+		return out, err
+	}
+	defer res.Body.Close()
+	content, err := io.ReadAll(res.Body)
+	if err != nil {
+		return out, err
+	}
+
+	if res.StatusCode >= 300 {
+		appErr := errors.AppError{Code: res.StatusCode}
+		if err = json.Unmarshal(content, &appErr); err != nil {
+			return out, fmt.Errorf("%s %s: %d %s", method, path, res.StatusCode, content)
+		}
+		return out, appErr
+	}
+	if len(content) == 0 {
+		return out, nil
+	}
+	if err = json.Unmarshal(content, &out); err != nil {
+		return out, fmt.Errorf("%s %s: decoding %s: %w", method, path, content, err)
+	}
+	return out, nil
+}
+
+func userPath(userID string, format string, args ...any) string {
+	return "/api/v1/users/" + url.PathEscape(userID) + fmt.Sprintf(format, args...)
+}
+
+func (c *client) CreateAccount(req *requests.CreateAccountRequest) (*responses.Response[*responses.CreateAccountResponseData], error) {
+	return call[*responses.Response[*responses.CreateAccountResponseData]](c, http.MethodPost, "/api/v1/accounts", req)
+}
+
+func (c *client) UpdateWebhookURL(req *requests.UpdateWebhookURLRequest) error {
+	_, err := call[any](c, http.MethodPut, "/api/v1/accounts", req)
+	return err
+}
+
+func (c *client) CreateSubAccount(req *requests.CreateSubAccountRequest) (*responses.Response[*models.Account], error) {
+	return call[*responses.Response[*models.Account]](c, http.MethodPost, "/api/v1/users", req)
+}
+
+func (c *client) FetchAccountDetails(userID string) (*responses.Response[*models.Account], error) {
+	return call[*responses.Response[*models.Account]](c, http.MethodGet, userPath(userID, ""), nil)
+}
+
+func (c *client) FetchAllSubAccounts() (*responses.Response[[]*models.Account], error) {
+	return call[*responses.Response[[]*models.Account]](c, http.MethodGet, "/api/v1/users", nil)
+}
+
+func (c *client) FetchUserLimits(userID string) (*responses.Response[[]*responses.UserLimitResponseData], error) {
+	return call[*responses.Response[[]*responses.UserLimitResponseData]](c, http.MethodGet, userPath(userID, "/limits"), nil)
+}
+
+func (c *client) SetSubAccountLimit(req *requests.SetSubAccountLimitRequest) (*responses.Response[*responses.SubAccountLimitResponseData], error) {
+	return call[*responses.Response[*responses.SubAccountLimitResponseData]](c, http.MethodPut, userPath(req.UserID, "/sub_account_limits"), req)
+}
+
+func (c *client) SubmitKYC(req *requests.SubmitKYCRequest) (*responses.Response[*models.KYCSubmission], error) {
+	return call[*responses.Response[*models.KYCSubmission]](c, http.MethodPost, userPath(req.UserID, "/kyc"), req)
+}
+
+func (c *client) ReviewKYCSubmission(req *requests.ReviewKYCSubmissionRequest) (*responses.Response[*models.KYCSubmission], error) {
+	return call[*responses.Response[*models.KYCSubmission]](c, http.MethodPost, userPath(req.UserID, "/kyc/%s/review", req.SubmissionID), req)
+}
+
+func (c *client) FetchUserWallets(userID string) (*responses.Response[[]*responses.UserWalletResponseData], error) {
+	return call[*responses.Response[[]*responses.UserWalletResponseData]](c, http.MethodGet, userPath(userID, "/wallets"), nil)
+}
+
+func (c *client) FetchUserWallet(userID, currency string) (*responses.Response[*responses.UserWalletResponseData], error) {
+	return call[*responses.Response[*responses.UserWalletResponseData]](c, http.MethodGet, userPath(userID, "/wallets/%s", currency), nil)
+}
+
+func (c *client) FreezeUserWallet(userID, currency string) (*responses.Response[*responses.UserWalletResponseData], error) {
+	return call[*responses.Response[*responses.UserWalletResponseData]](c, http.MethodPost, userPath(userID, "/wallets/%s/freeze", currency), nil)
+}
+
+func (c *client) UnfreezeUserWallet(userID, currency string) (*responses.Response[*responses.UserWalletResponseData], error) {
+	return call[*responses.Response[*responses.UserWalletResponseData]](c, http.MethodPost, userPath(userID, "/wallets/%s/unfreeze", currency), nil)
+}
+
+func (c *client) FetchWalletBalance(userID, currency string, at time.Time) (*responses.Response[*responses.WalletBalanceResponseData], error) {
+	return call[*responses.Response[*responses.WalletBalanceResponseData]](c, http.MethodGet, userPath(userID, "/wallets/%s/balances?at=%s", currency, url.QueryEscape(at.UTC().Format(time.RFC3339))), nil)
+}
+
+func (c *client) FetchWalletBalanceHistory(userID, currency string, from, to time.Time) (*responses.Response[[]*responses.WalletBalanceResponseData], error) {
+	query := url.Values{"from": {from.UTC().Format(time.RFC3339)}, "to": {to.UTC().Format(time.RFC3339)}}
+	return call[*responses.Response[[]*responses.WalletBalanceResponseData]](c, http.MethodGet, userPath(userID, "/wallets/%s/balances/history?%s", currency, query.Encode()), nil)
+}
+
+func (c *client) Deposit(req *requests.DepositAmountRequest) (*responses.Response[*responses.DepositResponseData], error) {
+	return call[*responses.Response[*responses.DepositResponseData]](c, http.MethodPost, userPath(req.UserID, "/deposits/%s", req.Currency), req)
+}
+
+func (c *client) FetchDeposit(userID, transactionID string) (*responses.Response[*responses.DepositResponseData], error) {
+	return call[*responses.Response[*responses.DepositResponseData]](c, http.MethodGet, userPath(userID, "/deposits/%s", transactionID), nil)
+}
+
+func (c *client) FetchDeposits(userID string) (*responses.Response[[]*responses.DepositResponseData], error) {
+	return call[*responses.Response[[]*responses.DepositResponseData]](c, http.MethodGet, userPath(userID, "/deposits"), nil)
+}
+
+func (c *client) QuoteInstantSwap(req *requests.CreateInstantSwapRequest) (*responses.Response[*responses.QuoteInstantSwapResponseData], error) {
+	return call[*responses.Response[*responses.QuoteInstantSwapResponseData]](c, http.MethodPost, userPath(req.UserID, "/temporary_swap_quotation"), req)
+}
+
+func (c *client) CreateInstantSwap(req *requests.CreateInstantSwapRequest) (*responses.Response[*responses.InstantSwapQuotationResponseData], error) {
+	return call[*responses.Response[*responses.InstantSwapQuotationResponseData]](c, http.MethodPost, userPath(req.UserID, "/swap_quotation"), req)
+}
+
+func (c *client) ConfirmInstantSwap(userID, quotationID string) (*responses.Response[*responses.InstantSwapResponseData], error) {
+	return call[*responses.Response[*responses.InstantSwapResponseData]](c, http.MethodPost, userPath(userID, "/swap_quotation/%s/confirm", quotationID), nil)
+}
+
+func (c *client) FetchInstantSwapTransaction(userID, swapID string) (*responses.Response[*responses.InstantSwapResponseData], error) {
+	return call[*responses.Response[*responses.InstantSwapResponseData]](c, http.MethodGet, userPath(userID, "/swap_transactions/%s", swapID), nil)
+}
+
+func (c *client) GetInstantSwapTransactions(userID string) (*responses.Response[[]*responses.InstantSwapResponseData], error) {
+	return call[*responses.Response[[]*responses.InstantSwapResponseData]](c, http.MethodGet, userPath(userID, "/swap_transactions"), nil)
+}
+
+func (c *client) CreateWithdrawal(req *requests.CreateWithdrawalRequest) (*responses.Response[*responses.WithdrawalResponseData], error) {
+	return call[*responses.Response[*responses.WithdrawalResponseData]](c, http.MethodPost, userPath(req.UserID, "/withdraws"), req)
+}
+
+func (c *client) FetchWithdrawal(userID, withdrawalID string) (*responses.Response[*responses.WithdrawalResponseData], error) {
+	return call[*responses.Response[*responses.WithdrawalResponseData]](c, http.MethodGet, userPath(userID, "/withdraws/%s", withdrawalID), nil)
+}
+
+func (c *client) FetchWithdrawals(userID string) (*responses.Response[[]*responses.WithdrawalResponseData], error) {
+	return call[*responses.Response[[]*responses.WithdrawalResponseData]](c, http.MethodGet, userPath(userID, "/withdraws"), nil)
+}
+
+func (c *client) StartReconciliation() (*responses.Response[*models.ReconciliationReport], error) {
+	return call[*responses.Response[*models.ReconciliationReport]](c, http.MethodPost, "/api/v1/admin/reconciliations", nil)
+}
+
+func (c *client) FetchReconciliationReports() (*responses.Response[[]*models.ReconciliationReport], error) {
+	return call[*responses.Response[[]*models.ReconciliationReport]](c, http.MethodGet, "/api/v1/admin/reconciliations", nil)
+}
+
+func (c *client) FetchReconciliationReport(reconciliationID string) (*responses.Response[*models.ReconciliationReport], error) {
+	return call[*responses.Response[*models.ReconciliationReport]](c, http.MethodGet, "/api/v1/admin/reconciliations/"+url.PathEscape(reconciliationID), nil)
+}
+
+func (c *client) FetchLiabilitySnapshot(snapshotID string) (*responses.Response[*models.LiabilitySnapshot], error) {
+	return call[*responses.Response[*models.LiabilitySnapshot]](c, http.MethodGet, "/api/v1/proofs/"+url.PathEscape(snapshotID), nil)
+}
+
+func (c *client) FetchLiabilityProof(userID, snapshotID string) (*responses.Response[*responses.LiabilityProofResponseData], error) {
+	return call[*responses.Response[*responses.LiabilityProofResponseData]](c, http.MethodGet, userPath(userID, "/proofs/%s", snapshotID), nil)
+}
+
+func (c *client) StartLiabilitySnapshot(req *requests.StartLiabilitySnapshotRequest) (*responses.Response[*models.LiabilitySnapshot], error) {
+	return call[*responses.Response[*models.LiabilitySnapshot]](c, http.MethodPost, "/api/v1/admin/proofs", req)
+}
+
+func (c *client) AdminFetchLiabilitySnapshot(snapshotID string) (*responses.Response[*models.LiabilitySnapshot], error) {
+	return call[*responses.Response[*models.LiabilitySnapshot]](c, http.MethodGet, "/api/v1/admin/proofs/"+url.PathEscape(snapshotID), nil)
+}
+
+func (c *client) SetTokenRateLimit(req *requests.SetTokenRateLimitRequest) (*responses.Response[*models.RateLimit], error) {
+	return call[*responses.Response[*models.RateLimit]](c, http.MethodPut, "/api/v1/admin/tokens/"+url.PathEscape(req.TokenID)+"/rate_limits", req)
+}
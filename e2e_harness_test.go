@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/2HgO/quidax-go/client"
+	"github.com/2HgO/quidax-go/config"
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/db/tbfake"
+	"github.com/2HgO/quidax-go/handlers"
+	"github.com/2HgO/quidax-go/services"
+	tdb "github.com/tigerbeetle/tigerbeetle-go"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// The harness runs the application built by newApp against backends chosen with the environment variables
+// below. The defaults need nothing installed, the other backends are configured with the application's own
+// DATA_DB_* and TX_DB_* settings
+const (
+	// sqlite, mysql, postgres or memory. mysql and postgres tests each get a database created for them
+	dataDBBackendEnv = "E2E_DATA_DB"
+	// fake or tigerbeetle. Only the fake's timestamps follow the harness clock
+	txDBBackendEnv = "E2E_TX_DB"
+
+	adminToken  = "e2e-admin-token"
+	webhookKey  = "e2e-webhook-key"
+	waitTimeout = 10 * time.Second
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the responses received")
+
+// harness is a running application with a client for its api, a receiver for its webhooks and a clock its
+// scheduled tasks run by
+type harness struct {
+	t        *testing.T
+	api      *client
+	admin    *client
+	webhooks *webhookReceiver
+	clock    *fakeClock
+	// configuration the app is started with
+	cfg *config.Config
+	// the ledger follows the clock, so swaps reversed by moving the clock forward are reported as reversed
+	fakeLedger bool
+	// placeholders replacing generated values in golden files, by value
+	placeholders map[string]string
+	// handlers the server's routes are registered by
+	routers []handlers.Handler
+	// for tests that leave behind what a stopped process would
+	idempotency services.IdempotencyService
+	// for tests that run the statement service's scheduled jobs themselves
+	statements services.StatementService
+	// the ledger the application writes to, for tests that write to it behind the application's back
+	ledger tdb.Client
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.HTTP.Address = "127.0.0.1:0"
+	cfg.DataDB.AutoMigrate = true
+	cfg.Admin.Token = adminToken
+	cfg.Webhooks.Delay = 0
+	cfg.Reconciliation.Interval = 0
+	cfg.Proofs.SnapshotInterval = 0
+	cfg.Statements.Dir = t.TempDir()
+	if os.Getenv("KYC_VERIFIER") == "" {
+		// flows review submissions by hand unless the test sets the verifier
+		cfg.KYC.Verifier = "manual"
+	}
+
+	log := zaptest.NewLogger(t, zaptest.Level(zap.WarnLevel))
+	clock := &fakeClock{}
+	h := &harness{t: t, clock: clock, cfg: cfg, placeholders: make(map[string]string)}
+
+	dataDB := openDataDB(t, cfg, log)
+	var txDB tdb.Client
+	switch backend := os.Getenv(txDBBackendEnv); backend {
+	case "", "fake":
+		txDB, h.fakeLedger = tbfake.New(tbfake.WithClock(clock.Now)), true
+	case "tigerbeetle":
+		txDB = db.GetTxDBConnection(cfg)
+		t.Cleanup(txDB.Close)
+	default:
+		t.Fatalf("unknown %s backend %q", txDBBackendEnv, backend)
+	}
+	h.ledger = txDB
+
+	var srv *http.Server
+	app := fx.New(
+		newApp(),
+		fx.NopLogger,
+		fx.Replace(cfg, dataDB, log),
+		fx.Replace(fx.Annotate(txDB, fx.As(new(tdb.Client)))),
+		fx.Replace(fx.Annotate(clock, fx.As(new(services.Clock)))),
+		fx.Invoke(AutoMigrate),
+		fx.Invoke(RecoverSagas),
+		fx.Invoke(RescheduleSwapReversals),
+		fx.Invoke(ResumeStatementExports),
+		fx.Invoke(fx.Annotate(func(routers []handlers.Handler) { h.routers = routers }, fx.ParamTags(`group:"handlers"`))),
+		fx.Populate(&srv, &h.idempotency, &h.statements),
+	)
+	if err = app.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { app.Stop(context.Background()) })
+
+	base := "http://" + srv.Addr
+	h.api = &client{base: base, http: &http.Client{Timeout: waitTimeout}}
+	h.admin = h.api.withToken(adminToken)
+	h.webhooks = newWebhookReceiver(t, webhookKey)
+	return h
+}
+
+// openDataDB opens the data database of the backend chosen for the test, which is removed when it ends. It is
+// nil for the memory backend
+func openDataDB(t *testing.T, cfg *config.Config, log *zap.Logger) *sql.DB {
+	t.Helper()
+
+	name := fmt.Sprintf("quidax_e2e_%d", time.Now().UnixNano())
+	switch backend := os.Getenv(dataDBBackendEnv); backend {
+	case "memory":
+		cfg.DataDB.Driver = string(db.Memory)
+		return nil
+	case "", "sqlite":
+		cfg.DataDB.Driver, cfg.DataDB.URL = string(db.SQLite), filepath.Join(t.TempDir(), "quidax-go.db")
+	case "mysql", "postgres":
+		cfg.DataDB.Driver = backend
+		server := *cfg
+		server.DataDB.Name = map[string]string{"mysql": "", "postgres": "postgres"}[backend]
+		admin, err := db.OpenDataDB(&server, log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		quoted := map[string]string{"mysql": "`" + name + "`", "postgres": `"` + name + `"`}[backend]
+		if _, err = admin.Exec("create database " + quoted); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			admin.Exec("drop database " + quoted)
+			admin.Close()
+		})
+		cfg.DataDB.Name = name
+	default:
+		t.Fatalf("unknown %s backend %q", dataDBBackendEnv, backend)
+	}
+
+	database, err := db.OpenDataDB(cfg, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+// requireFakeLedger skips tests whose results depend on ledger timestamps following the harness clock
+func (h *harness) requireFakeLedger() {
+	if !h.fakeLedger {
+		h.t.Skip("ledger timestamps do not follow the harness clock")
+	}
+}
+
+// eventually retries check until it succeeds or the wait times out, for results of work done in the background
+func (h *harness) eventually(what string, check func() bool) {
+	h.t.Helper()
+	for deadline := time.Now().Add(waitTimeout); !check(); time.Sleep(20 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// fakeClock runs at the speed of the system clock from the offset it has been moved forward by. Tasks run
+// when they come due in real time, or straight away when the clock is moved past them
+type fakeClock struct {
+	mu     sync.Mutex
+	offset time.Duration
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	at   time.Time
+	f    func()
+	once sync.Once
+}
+
+func (t *fakeTimer) fire() {
+	t.once.Do(t.f)
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Add(c.offset)
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) {
+	timer := &fakeTimer{at: c.Now().Add(d), f: f}
+	c.mu.Lock()
+	c.timers = append(c.timers, timer)
+	c.mu.Unlock()
+	time.AfterFunc(d, timer.fire)
+}
+
+// Advance moves the clock forward and runs the tasks that came due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.offset += d
+	now := time.Now().Add(c.offset)
+	pending := c.timers[:0]
+	due := make([]*fakeTimer, 0)
+	for _, timer := range c.timers {
+		if timer.at.After(now) {
+			pending = append(pending, timer)
+		} else {
+			due = append(due, timer)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	for _, timer := range due {
+		go timer.fire()
+	}
+}
+
+// webhookEvent is an event delivered to the receiver
+type webhookEvent struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// webhookReceiver is a callback url capturing the events delivered to it. Deliveries without a valid
+// quidax-signature header fail the test
+type webhookReceiver struct {
+	URL      string
+	verifier *sdk.WebhookVerifier
+	mu       sync.Mutex
+	events   []webhookEvent
+}
+
+func newWebhookReceiver(t *testing.T, key string) *webhookReceiver {
+	w := &webhookReceiver{verifier: sdk.NewWebhookVerifier(key, time.Minute)}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err = w.verifier.Verify(r.Header.Get(sdk.SignatureHeader), body); err != nil {
+			t.Errorf("webhook delivery: %v", err)
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var event webhookEvent
+		if err = json.Unmarshal(body, &event); err != nil {
+			t.Errorf("webhook delivery: %v", err)
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.mu.Lock()
+		w.events = append(w.events, event)
+		w.mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	w.URL = server.URL
+	return w
+}
+
+// waitForWebhook returns the first event of the type delivered since the last one returned, events are delivered in
+// the background
+func (h *harness) waitForWebhook(event string) webhookEvent {
+	h.t.Helper()
+	w := h.webhooks
+	var found webhookEvent
+	h.eventually("a "+event+" webhook", func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		for i, e := range w.events {
+			if e.Event == event {
+				found = e
+				w.events = append(w.events[:i], w.events[i+1:]...)
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
+
+// ledger ids are tigerbeetle ids in hex, which drops leading zeros
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	ledgerIDPattern = regexp.MustCompile(`^[0-9a-f]{24,32}$`)
+	tokenPattern    = regexp.MustCompile(`^sec_(test|live)_`)
+)
+
+// generatedKeys are fields whose values are generated and differ between runs, their values are replaced in
+// golden files whatever they look like
+var generatedKeys = map[string]string{
+	"sn":        "sn",
+	"reference": "reference",
+	"address":   "address",
+}
+
+// golden compares the value, as JSON, with the golden file of the step. Generated ids and tokens are
+// replaced with numbered placeholders, shared by the test's golden files so they show which values are the
+// same, and times are replaced outright. go test -run <test> -update rewrites the files
+func (h *harness) golden(step string, v any) {
+	h.t.Helper()
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	var doc any
+	if err = json.Unmarshal(raw, &doc); err != nil {
+		h.t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(h.normalize("", doc)); err != nil {
+		h.t.Fatal(err)
+	}
+	got := buf.Bytes()
+
+	// flows run again on the memory backend are compared with the golden files of their own test
+	file := filepath.Join("testdata", "golden", strings.TrimPrefix(h.t.Name(), "TestMemoryBackend/"), step+".json")
+	if *update {
+		if err = os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			h.t.Fatal(err)
+		}
+		if err = os.WriteFile(file, got, 0o644); err != nil {
+			h.t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		h.t.Fatalf("reading golden file, run with -update to create it: %v", err)
+	}
+	if string(want) != string(got) {
+		h.t.Errorf("%s does not match, run with -update to accept the change\ngot:\n%s\nwant:\n%s", file, got, want)
+	}
+}
+
+func (h *harness) normalize(key string, v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		// keys are visited in order so placeholders are numbered the same way on every run
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			v[k] = h.normalize(k, v[k])
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = h.normalize(key, value)
+		}
+		return v
+	case string:
+		if kind, ok := generatedKeys[key]; ok && v != "" {
+			return h.placeholder(kind, v)
+		}
+		switch {
+		case uuidPattern.MatchString(v):
+			return h.placeholder("id", v)
+		case ledgerIDPattern.MatchString(v):
+			return h.placeholder("ledger id", v)
+		case tokenPattern.MatchString(v):
+			return h.placeholder("token", v)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return "<time>"
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func (h *harness) placeholder(kind, value string) string {
+	if p, ok := h.placeholders[value]; ok {
+		return p
+	}
+	n := 1
+	for _, p := range h.placeholders {
+		if strings.HasPrefix(p, "<"+kind+" ") {
+			n++
+		}
+	}
+	p := fmt.Sprintf("<%s %d>", kind, n)
+	h.placeholders[value] = p
+	return p
+}
@@ -0,0 +1,907 @@
+package main
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"math/big"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/merkle"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/services"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/utils"
+	"github.com/google/uuid"
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+)
+
+// merchant is a main account with a client authenticated with its test token
+type merchant struct {
+	id  string
+	api *client
+	// id of the test token the client authenticates with
+	tokenID string
+}
+
+func (h *harness) createMerchant(email string) merchant {
+	h.t.Helper()
+	res, err := h.api.CreateAccount(&requests.CreateAccountRequest{
+		Email:       email,
+		Password:    "e2e-password",
+		FirstName:   "Ada",
+		LastName:    "Okafor",
+		DisplayName: "Acme",
+	})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return merchant{id: res.Data.User.ID, api: h.api.withToken(res.Data.Token.Token), tokenID: res.Data.Token.ID}
+}
+
+// createCustomer creates a sub-account of the merchant verified for the kyc tier
+func (h *harness) createCustomer(m merchant, email string, tier models.KYCTier) string {
+	h.t.Helper()
+	res, err := m.api.CreateSubAccount(&requests.CreateSubAccountRequest{Email: email, FirstName: "Tolu", LastName: "Adeyemi"})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if tier == models.Tier0_KYCTier {
+		return res.Data.ID
+	}
+
+	submission, err := m.api.SubmitKYC(&requests.SubmitKYCRequest{
+		UserID:      res.Data.ID,
+		Tier:        tier,
+		PhoneNumber: "+2348012345678",
+		DateOfBirth: "1994-03-12",
+		Country:     "NG",
+		IDType:      "national_id",
+		IDNumber:    "12345678901",
+	})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	_, err = m.api.ReviewKYCSubmission(&requests.ReviewKYCSubmissionRequest{
+		UserID:       res.Data.ID,
+		SubmissionID: submission.Data.ID,
+		Status:       models.Approved_KYCStatus,
+	})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return res.Data.ID
+}
+
+func (h *harness) subscribe(m merchant) {
+	h.t.Helper()
+	err := m.api.UpdateWebhookURL(&requests.UpdateWebhookURLRequest{CallbackURL: h.webhooks.URL, WebhookKey: utils.String(webhookKey)})
+	if err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+func (h *harness) deposit(m merchant, userID, currency string, amount float64) {
+	h.t.Helper()
+	if _, err := m.api.Deposit(&requests.DepositAmountRequest{UserID: userID, Currency: currency, Amount: models.Double(amount)}); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// goldenError compares the error the api responded with, with its status code, with the step's golden file
+func (h *harness) goldenError(step string, err error) {
+	h.t.Helper()
+	var appErr errors.AppError
+	if !stderrors.As(err, &appErr) {
+		h.t.Fatalf("expected an api error, got %v", err)
+	}
+	h.golden(step, map[string]any{"status": appErr.Code, "error": appErr})
+}
+
+func TestAccountFlow(t *testing.T) {
+	h := newHarness(t)
+
+	created, err := h.api.CreateAccount(&requests.CreateAccountRequest{
+		Email:       "ops@acme.test",
+		Password:    "e2e-password",
+		FirstName:   "Ada",
+		LastName:    "Okafor",
+		DisplayName: "Acme",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("create_account", created)
+	m := merchant{id: created.Data.User.ID, api: h.api.withToken(created.Data.Token.Token)}
+
+	sub, err := m.api.CreateSubAccount(&requests.CreateSubAccountRequest{Email: "tolu@acme.test", FirstName: "Tolu", LastName: "Adeyemi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("create_sub_account", sub)
+
+	submission, err := m.api.SubmitKYC(&requests.SubmitKYCRequest{
+		UserID:      sub.Data.ID,
+		Tier:        models.Tier1_KYCTier,
+		PhoneNumber: "+2348012345678",
+		DateOfBirth: "1994-03-12",
+		Country:     "NG",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("submit_kyc", submission)
+
+	reviewed, err := m.api.ReviewKYCSubmission(&requests.ReviewKYCSubmissionRequest{
+		UserID:       sub.Data.ID,
+		SubmissionID: submission.Data.ID,
+		Status:       models.Approved_KYCStatus,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("review_kyc", reviewed)
+
+	details, err := m.api.FetchAccountDetails(sub.Data.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("fetch_sub_account", details)
+
+	subs, err := m.api.FetchAllSubAccounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("fetch_sub_accounts", subs)
+
+	_, err = h.api.withToken("sec_test_unknown").FetchAccountDetails("me")
+	h.goldenError("fetch_account_unauthenticated", err)
+}
+
+func TestKYCRules(t *testing.T) {
+	t.Setenv("KYC_VERIFIER", "rules")
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+
+	adult, err := m.api.CreateSubAccount(&requests.CreateSubAccountRequest{Email: "tolu@acme.test", FirstName: "Tolu", LastName: "Adeyemi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	approved, err := m.api.SubmitKYC(&requests.SubmitKYCRequest{
+		UserID:      adult.Data.ID,
+		Tier:        models.Tier1_KYCTier,
+		PhoneNumber: "+2348012345678",
+		DateOfBirth: "1994-03-12",
+		Country:     "NG",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("submit_kyc_approved", approved)
+
+	minor, err := m.api.CreateSubAccount(&requests.CreateSubAccountRequest{Email: "bayo@acme.test", FirstName: "Bayo", LastName: "Adeyemi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejected, err := m.api.SubmitKYC(&requests.SubmitKYCRequest{
+		UserID:      minor.Data.ID,
+		Tier:        models.Tier1_KYCTier,
+		PhoneNumber: "+2348012345679",
+		DateOfBirth: "2015-06-01",
+		Country:     "NG",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("submit_kyc_rejected", rejected)
+
+	// a decided submission can not be reviewed again
+	_, err = m.api.ReviewKYCSubmission(&requests.ReviewKYCSubmissionRequest{
+		UserID:       minor.Data.ID,
+		SubmissionID: rejected.Data.ID,
+		Status:       models.Approved_KYCStatus,
+	})
+	h.goldenError("review_decided_kyc", err)
+}
+
+func TestKYCTierNotLowered(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	user, err := m.api.CreateSubAccount(&requests.CreateSubAccountRequest{Email: "tolu@acme.test", FirstName: "Tolu", LastName: "Adeyemi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	submit := func(tier models.KYCTier) string {
+		t.Helper()
+		submission, err := m.api.SubmitKYC(&requests.SubmitKYCRequest{
+			UserID:      user.Data.ID,
+			Tier:        tier,
+			PhoneNumber: "+2348012345678",
+			DateOfBirth: "1994-03-12",
+			Country:     "NG",
+			IDType:      "national_id",
+			IDNumber:    "12345678901",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return submission.Data.ID
+	}
+	approve := func(id string) *models.KYCSubmission {
+		t.Helper()
+		res, err := m.api.ReviewKYCSubmission(&requests.ReviewKYCSubmissionRequest{UserID: user.Data.ID, SubmissionID: id, Status: models.Approved_KYCStatus})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Data
+	}
+
+	// both are pending when the higher tier is approved first
+	lower, higher := submit(models.Tier1_KYCTier), submit(models.Tier2_KYCTier)
+	approve(higher)
+	if decided := approve(lower); decided.User.KYCTier != models.Tier2_KYCTier {
+		t.Errorf("approved submission reports tier %d, want %d", decided.User.KYCTier, models.Tier2_KYCTier)
+	}
+
+	account, err := m.api.FetchAccountDetails(user.Data.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Data.KYCTier != models.Tier2_KYCTier {
+		t.Errorf("user is at tier %d after a lower tier was approved, want %d", account.Data.KYCTier, models.Tier2_KYCTier)
+	}
+}
+
+func TestWalletFlow(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	customer := h.createCustomer(m, "tolu@acme.test", models.Tier0_KYCTier)
+
+	wallets, err := m.api.FetchUserWallets(customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("fetch_wallets", wallets)
+
+	frozen, err := m.api.FreezeUserWallet(customer, "ngn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("freeze_wallet", frozen)
+
+	h.deposit(m, m.id, "ngn", 5000)
+	transfer := &requests.CreateWithdrawalRequest{UserID: m.id, FundUid: customer, Currency: "ngn", Amount: 1000}
+	_, err = m.api.CreateWithdrawal(transfer)
+	h.goldenError("transfer_to_frozen_wallet", err)
+
+	if _, err = m.api.UnfreezeUserWallet(customer, "ngn"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.api.CreateWithdrawal(transfer); err != nil {
+		t.Fatal(err)
+	}
+	wallet, err := m.api.FetchUserWallet(customer, "ngn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("fetch_wallet", wallet)
+
+	_, err = m.api.Deposit(&requests.DepositAmountRequest{UserID: customer, Currency: "btc", Amount: 1})
+	h.goldenError("deposit_without_kyc", err)
+}
+
+func TestDepositFlow(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	h.subscribe(m)
+
+	deposit, err := m.api.Deposit(&requests.DepositAmountRequest{UserID: "me", Currency: "usdt", Amount: 250.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("deposit", deposit)
+	event := h.waitForWebhook(models.DepositSuccessful_WebhookEvent.String())
+	h.golden("deposit_webhook", event)
+
+	fetched, err := m.api.FetchDeposit(m.id, deposit.Data.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("fetch_deposit", fetched)
+
+	deposits, err := m.api.FetchDeposits(m.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("fetch_deposits", deposits)
+}
+
+func TestSwapFlow(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	h.subscribe(m)
+	h.deposit(m, m.id, "usdt", 500)
+
+	quotation, err := m.api.CreateInstantSwap(&requests.CreateInstantSwapRequest{UserID: m.id, FromCurrency: "usdt", ToCurrency: "ngn", FromAmount: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("create_quotation", quotation)
+
+	confirmed, err := m.api.ConfirmInstantSwap(m.id, quotation.Data.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("confirm_swap", confirmed)
+	h.golden("swap_completed_webhook", h.waitForWebhook(models.SwapTransactionCompleted_WebhookEvent.String()))
+
+	swap, err := m.api.FetchInstantSwapTransaction(m.id, confirmed.Data.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("fetch_swap", swap)
+
+	wallet, err := m.api.FetchUserWallet(m.id, "usdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("usdt_wallet_after_swap", wallet)
+}
+
+func TestPointInTimeBalances(t *testing.T) {
+	h := newHarness(t)
+	h.requireFakeLedger()
+	m := h.createMerchant("ops@acme.test")
+	h.subscribe(m)
+	customer := h.createCustomer(m, "tolu@acme.test", models.Tier1_KYCTier)
+	h.deposit(m, customer, "ngn", 1_000)
+
+	// balances are asked for by the second, the clock is moved past each step so they fall in different seconds
+	tick := func() time.Time {
+		h.clock.Advance(2 * time.Second)
+		return h.clock.Now().Truncate(time.Second)
+	}
+	beforeQuote := tick()
+	quotation, err := m.api.CreateInstantSwap(&requests.CreateInstantSwapRequest{UserID: customer, FromCurrency: "ngn", ToCurrency: "usdt", FromAmount: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	quoted := tick()
+	if _, err = m.api.ConfirmInstantSwap(customer, quotation.Data.ID); err != nil {
+		t.Fatal(err)
+	}
+	h.waitForWebhook(models.SwapTransactionCompleted_WebhookEvent.String())
+	afterSwap := tick()
+
+	type balances struct{ debitsPending, debitsPosted, creditsPending, creditsPosted float64 }
+	balanceAt := func(currency string, at time.Time) balances {
+		t.Helper()
+		res, err := m.api.FetchWalletBalance(customer, currency, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return balances{res.Data.DebitsPending, res.Data.DebitsPosted, res.Data.CreditsPending, res.Data.CreditsPosted}
+	}
+
+	// the quote holds the ngn being swapped and the usdt it buys until it is confirmed
+	received := quotation.Data.ToAmount
+	for _, tc := range []struct {
+		name     string
+		currency string
+		at       time.Time
+		want     balances
+	}{
+		{"ngn before the quote", "ngn", beforeQuote, balances{creditsPosted: 1_000}},
+		{"ngn once quoted", "ngn", quoted, balances{debitsPending: 50, creditsPosted: 1_000}},
+		{"ngn after the swap", "ngn", afterSwap, balances{debitsPosted: 50, creditsPosted: 1_000}},
+		{"usdt before the quote", "usdt", beforeQuote, balances{}},
+		{"usdt once quoted", "usdt", quoted, balances{creditsPending: received}},
+		{"usdt after the swap", "usdt", afterSwap, balances{creditsPosted: received}},
+	} {
+		if got := balanceAt(tc.currency, tc.at); got != tc.want {
+			t.Errorf("%s: balance is %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+
+	// the range lists the balance after the hold and after its posting, newest first
+	history, err := m.api.FetchWalletBalanceHistory(customer, "ngn", beforeQuote, afterSwap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]balances, 0, len(history.Data))
+	for _, b := range history.Data {
+		got = append(got, balances{b.DebitsPending, b.DebitsPosted, b.CreditsPending, b.CreditsPosted})
+	}
+	want := []balances{{debitsPosted: 50, creditsPosted: 1_000}, {debitsPending: 50, creditsPosted: 1_000}}
+	if !slices.Equal(got, want) {
+		t.Errorf("ngn balances between the quote and the swap are %+v, want %+v", got, want)
+	}
+}
+
+func TestSwapFrozenWallet(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	customer := h.createCustomer(m, "tolu@acme.test", models.Tier1_KYCTier)
+	h.deposit(m, customer, "usdt", 500)
+
+	swap := &requests.CreateInstantSwapRequest{UserID: customer, FromCurrency: "usdt", ToCurrency: "ngn", FromAmount: 50}
+	quotation, err := m.api.CreateInstantSwap(swap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.api.FreezeUserWallet(customer, "ngn"); err != nil {
+		t.Fatal(err)
+	}
+
+	// neither wallet of a swap may be frozen when it is quoted or confirmed
+	var appErr errors.AppError
+	_, err = m.api.QuoteInstantSwap(swap)
+	if !stderrors.As(err, &appErr) || appErr.Type != errors.ErrFrozen {
+		t.Errorf("quoting a swap into a frozen wallet failed with %v, want a frozen error", err)
+	}
+	_, err = m.api.ConfirmInstantSwap(customer, quotation.Data.ID)
+	if !stderrors.As(err, &appErr) || appErr.Type != errors.ErrFrozen {
+		t.Errorf("confirming a swap into a frozen wallet failed with %v, want a frozen error", err)
+	}
+
+	if _, err = m.api.UnfreezeUserWallet(customer, "ngn"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.api.FreezeUserWallet(customer, "usdt"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.api.QuoteInstantSwap(swap)
+	if !stderrors.As(err, &appErr) || appErr.Type != errors.ErrFrozen {
+		t.Errorf("quoting a swap from a frozen wallet failed with %v, want a frozen error", err)
+	}
+	_, err = m.api.ConfirmInstantSwap(customer, quotation.Data.ID)
+	if !stderrors.As(err, &appErr) || appErr.Type != errors.ErrFrozen {
+		t.Errorf("confirming a swap from a frozen wallet failed with %v, want a frozen error", err)
+	}
+
+	if _, err = m.api.UnfreezeUserWallet(customer, "usdt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.api.QuoteInstantSwap(swap); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.api.ConfirmInstantSwap(customer, quotation.Data.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSwapReversal(t *testing.T) {
+	h := newHarness(t)
+	h.requireFakeLedger()
+	m := h.createMerchant("ops@acme.test")
+	h.subscribe(m)
+	h.deposit(m, m.id, "usdt", 500)
+
+	quotation, err := m.api.CreateInstantSwap(&requests.CreateInstantSwapRequest{UserID: m.id, FromCurrency: "usdt", ToCurrency: "ngn", FromAmount: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the quotation is not confirmed, its holds are voided once it expires
+	h.clock.Advance(quotation.Data.ExpiresAt.Sub(h.clock.Now()) + time.Second)
+	event := h.waitForWebhook(models.SwapTransactionReversed_WebhookEvent.String())
+	h.golden("swap_reversed_webhook", event)
+
+	wallet, err := m.api.FetchUserWallet(m.id, "usdt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("usdt_wallet_after_reversal", wallet)
+
+	swaps, err := m.api.GetInstantSwapTransactions(m.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("swaps_after_reversal", swaps)
+}
+
+func TestWithdrawalFlow(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	h.subscribe(m)
+	customer := h.createCustomer(m, "tolu@acme.test", models.Tier1_KYCTier)
+	h.deposit(m, m.id, "ngn", 50000)
+
+	withdrawal, err := m.api.CreateWithdrawal(&requests.CreateWithdrawalRequest{
+		UserID:    m.id,
+		FundUid:   customer,
+		Currency:  "ngn",
+		Amount:    12500,
+		Narration: "refund",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("create_withdrawal", withdrawal)
+	h.golden("withdrawal_webhook", h.waitForWebhook(models.WithdrawalSuccessful_WebhookEvent.String()))
+
+	fetched, err := m.api.FetchWithdrawal(m.id, withdrawal.Data.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("fetch_withdrawal", fetched)
+
+	received, err := m.api.FetchUserWallet(customer, "ngn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("recipient_wallet", received)
+
+	_, err = m.api.CreateWithdrawal(&requests.CreateWithdrawalRequest{UserID: m.id, FundUid: customer, Currency: "ngn", Amount: 1_000_000})
+	h.goldenError("withdraw_more_than_balance", err)
+}
+
+// TestWithdrawalLimits withdraws more than the daily limit with requests made together, only those that fit
+// within the limit go through
+func TestWithdrawalLimits(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	customer := h.createCustomer(m, "tolu@acme.test", models.Tier1_KYCTier)
+	h.deposit(m, customer, "ngn", 10_000_000)
+
+	// tier 1 ngn withdrawals are limited to 1,000,000 at a time and 5,000,000 a day
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = m.api.CreateWithdrawal(&requests.CreateWithdrawalRequest{UserID: customer, FundUid: m.id, Currency: "ngn", Amount: 1_000_000})
+		}()
+	}
+	wg.Wait()
+
+	var completed int
+	for _, err := range errs {
+		var appErr errors.AppError
+		switch {
+		case err == nil:
+			completed++
+		case !stderrors.As(err, &appErr) || appErr.Type != errors.ErrLimitExceeded:
+			t.Errorf("withdrawal failed with %v, want a limit exceeded error", err)
+		}
+	}
+	if completed != 5 {
+		t.Errorf("%d withdrawals went through, want 5", completed)
+	}
+
+	limits, err := m.api.FetchUserLimits(customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("limits", limits)
+}
+
+func TestSubAccountLimits(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	customer := h.createCustomer(m, "tolu@acme.test", models.Tier1_KYCTier)
+	h.deposit(m, customer, "ngn", 10_000_000)
+
+	// the merchant lowers the tier 1 single withdrawal cap from 1,000,000, the daily cap stays the tier's default
+	withdrawal := models.Withdrawal_LimitOperation
+	set, err := m.api.SetSubAccountLimit(&requests.SetSubAccountLimitRequest{UserID: "me", Tier: models.Tier1_KYCTier, Currency: "ngn", Operation: &withdrawal, Single: utils.Float64(500_000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *set.Data.Single != 500_000 || *set.Data.Daily != 5_000_000 {
+		t.Errorf("set limit is %v a time and %v a day, want 500000 and 5000000", *set.Data.Single, *set.Data.Daily)
+	}
+
+	var appErr errors.AppError
+	_, err = m.api.CreateWithdrawal(&requests.CreateWithdrawalRequest{UserID: customer, FundUid: m.id, Currency: "ngn", Amount: 600_000})
+	if !stderrors.As(err, &appErr) || appErr.Type != errors.ErrLimitExceeded {
+		t.Errorf("withdrawal over the set limit failed with %v, want a limit exceeded error", err)
+	}
+	if _, err = m.api.CreateWithdrawal(&requests.CreateWithdrawalRequest{UserID: customer, FundUid: m.id, Currency: "ngn", Amount: 500_000}); err != nil {
+		t.Fatal(err)
+	}
+
+	// limits are set by main accounts, not for a single sub account
+	_, err = m.api.SetSubAccountLimit(&requests.SetSubAccountLimitRequest{UserID: customer, Tier: models.Tier1_KYCTier, Currency: "ngn", Operation: &withdrawal, Single: utils.Float64(1)})
+	if !stderrors.As(err, &appErr) || appErr.Type != errors.ErrValidation {
+		t.Errorf("setting a limit on a sub account failed with %v, want a validation error", err)
+	}
+
+	limits, err := m.api.FetchUserLimits(customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.golden("limits", limits)
+}
+
+func TestReconciliation(t *testing.T) {
+	h := newHarness(t)
+	h.requireFakeLedger()
+	m := h.createMerchant("ops@acme.test")
+	h.subscribe(m)
+	customer := h.createCustomer(m, "tolu@acme.test", models.Tier1_KYCTier)
+	h.deposit(m, customer, "ngn", 10_000)
+
+	// a withdrawal and a swap that agree with the ledger are not reported
+	if _, err := m.api.CreateWithdrawal(&requests.CreateWithdrawalRequest{UserID: customer, FundUid: m.id, Currency: "ngn", Amount: 1_000}); err != nil {
+		t.Fatal(err)
+	}
+	quotation, err := m.api.CreateInstantSwap(&requests.CreateInstantSwapRequest{UserID: customer, FromCurrency: "ngn", ToCurrency: "usdt", FromAmount: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.api.ConfirmInstantSwap(customer, quotation.Data.ID); err != nil {
+		t.Fatal(err)
+	}
+	h.waitForWebhook(models.SwapTransactionCompleted_WebhookEvent.String())
+
+	// records the application did not make are written to the ledger behind its back
+	from, err := m.api.FetchUserWallet(customer, "ngn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := m.api.FetchUserWallet(m.id, "ngn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromID, _ := tdb_types.HexStringToUint128(from.Data.ID)
+	toID, _ := tdb_types.HexStringToUint128(to.Data.ID)
+	ngn := services.LedgerIDs[models.Test_Environment]["ngn"]
+
+	orphanAccount := tdb_types.Account{ID: tdb_types.ID(), Ledger: ngn, Code: 1, UserData128: tdb_types.BytesToUint128(uuid.New())}
+	if res, err := h.ledger.CreateAccounts([]tdb_types.Account{orphanAccount}); err != nil || len(res) > 0 {
+		t.Fatalf("creating the orphan account: %v %v", res, err)
+	}
+	// codes 2 and 1 are the withdrawal and swap transfer codes
+	orphanWithdrawal := tdb_types.Transfer{ID: tdb_types.ID(), DebitAccountID: fromID, CreditAccountID: toID, Amount: utils.ToAmount(500), Ledger: ngn, Code: 2}
+	orphanHold := tdb_types.Transfer{ID: tdb_types.ID(), DebitAccountID: fromID, CreditAccountID: toID, Amount: utils.ToAmount(250), Ledger: ngn, Code: 1, Flags: tdb_types.TransferFlags{Pending: true}.ToUint16()}
+	if res, err := h.ledger.CreateTransfers([]tdb_types.Transfer{orphanWithdrawal, orphanHold}); err != nil || len(res) > 0 {
+		t.Fatalf("creating the orphan transfers: %v %v", res, err)
+	}
+
+	// records younger than a minute may belong to a saga that is still running and are not checked
+	h.clock.Advance(2 * time.Minute)
+	started, err := h.admin.StartReconciliation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report *models.ReconciliationReport
+	h.eventually("the reconciliation", func() bool {
+		res, err := h.admin.FetchReconciliationReport(started.Data.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report = res.Data
+		return report.Status != models.Running_ReconciliationStatus
+	})
+
+	if report.Status != models.Completed_ReconciliationStatus {
+		t.Fatalf("reconciliation is %s, want completed", report.Status)
+	}
+	if report.Trigger != models.Admin_ReconciliationTrigger || report.WithdrawalsChecked != 1 || report.SwapsChecked != 1 || report.WalletsChecked == 0 {
+		t.Errorf("reconciliation was %s and checked %d wallets, %d withdrawals and %d swaps, want an admin run over every wallet, 1 withdrawal and 1 swap",
+			report.Trigger, report.WalletsChecked, report.WithdrawalsChecked, report.SwapsChecked)
+	}
+
+	type issue struct {
+		issueType models.ReconciliationIssueType
+		resource  string
+		id        string
+	}
+	want := []issue{
+		{models.OrphanLedgerAccount_ReconciliationIssueType, "ledger_account", orphanAccount.ID.String()},
+		{models.OrphanLedgerTransfer_ReconciliationIssueType, "ledger_transfer", orphanWithdrawal.ID.String()},
+		{models.OrphanLedgerTransfer_ReconciliationIssueType, "ledger_transfer", orphanHold.ID.String()},
+	}
+	got := make([]issue, 0, len(report.Issues))
+	for _, i := range report.Issues {
+		got = append(got, issue{i.Type, i.Resource, i.ResourceID})
+		if i.Environment != models.Test_Environment {
+			t.Errorf("issue on %s %s is in the %s environment, want test", i.Resource, i.ResourceID, i.Environment)
+		}
+	}
+	if report.IssueCount != len(want) || !slices.Equal(got, want) {
+		t.Errorf("reconciliation found %d issues %+v, want %+v", report.IssueCount, got, want)
+	}
+
+	// the list leaves the issues out
+	reports, err := h.admin.FetchReconciliationReports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports.Data) != 1 || reports.Data[0].ID != report.ID || reports.Data[0].IssueCount != len(want) {
+		t.Errorf("reconciliation reports are %+v, want the completed run", reports.Data)
+	}
+
+	// only admins see reports
+	var appErr errors.AppError
+	_, err = m.api.FetchReconciliationReport(report.ID)
+	if !stderrors.As(err, &appErr) || appErr.Code != http.StatusUnauthorized {
+		t.Errorf("fetching a report with an access token failed with %v, want an unauthorized error", err)
+	}
+}
+
+func TestLiabilityProofFlow(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	customer := h.createCustomer(m, "tolu@acme.test", models.Tier1_KYCTier)
+	h.deposit(m, customer, "ngn", 5_000)
+	h.deposit(m, m.id, "ngn", 2_000)
+	h.deposit(m, m.id, "usdt", 250)
+	// snapshots are of the balances a minute before they are taken
+	h.clock.Advance(2 * time.Minute)
+
+	test := models.Test_Environment
+	started, err := h.admin.StartLiabilitySnapshot(&requests.StartLiabilitySnapshotRequest{Environment: &test})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshotID := started.Data.ID
+	h.eventually("the liability snapshot", func() bool {
+		snapshot, err := h.admin.AdminFetchLiabilitySnapshot(snapshotID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snapshot.Data.Status == models.Failed_LiabilitySnapshotStatus {
+			t.Fatalf("liability snapshot failed: %v", *snapshot.Data.Reason)
+		}
+		return snapshot.Data.Status == models.Completed_LiabilitySnapshotStatus
+	})
+
+	// proofs are checked against the roots published without a token
+	published, err := h.api.FetchLiabilitySnapshot(snapshotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := make(map[string]*models.LiabilityRoot)
+	for _, root := range published.Data.Roots {
+		roots[root.Currency] = root
+	}
+
+	proofs, err := m.api.FetchLiabilityProof(customer, snapshotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proofs.Data.Proofs) != 1 || proofs.Data.Proofs[0].Currency != "ngn" {
+		t.Fatalf("customer has proofs %+v, want one for ngn", proofs.Data.Proofs)
+	}
+	mine, err := m.api.FetchLiabilityProof("me", snapshotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mine.Data.Proofs) != 2 {
+		t.Fatalf("merchant has %d proofs, want one for ngn and one for usdt", len(mine.Data.Proofs))
+	}
+
+	for _, proof := range append(proofs.Data.Proofs, mine.Data.Proofs...) {
+		if err = merkle.Verify(proof.Proof); err != nil {
+			t.Errorf("%s proof of %s: %v", proof.Currency, proof.Proof.Leaf.UserID, err)
+		}
+		root, ok := roots[proof.Currency]
+		if !ok {
+			t.Errorf("snapshot has no published %s root", proof.Currency)
+			continue
+		}
+		if proof.Proof.Root.Hash != root.Hash || proof.Proof.Root.Sum.Cmp(root.Total) != 0 {
+			t.Errorf("%s proof has root %s of %s, want the published %s of %s", proof.Currency, proof.Proof.Root.Hash, proof.Proof.Root.Sum, root.Hash, root.Total)
+		}
+	}
+	if leaf := proofs.Data.Proofs[0].Proof.Leaf; leaf.UserID != customer || leaf.Balance.Sign() <= 0 {
+		t.Errorf("customer's proof is of %s with a balance of %s, want the customer's balance", leaf.UserID, leaf.Balance)
+	}
+	// both ngn balances are in the published total
+	if ngn := roots["ngn"]; ngn.Leaves < 2 || ngn.Total.Cmp(new(big.Int).Add(proofs.Data.Proofs[0].Proof.Leaf.Balance, mine.Data.Proofs[0].Proof.Leaf.Balance)) < 0 {
+		t.Errorf("ngn root has %d leaves totalling %s, want both balances in it", ngn.Leaves, ngn.Total)
+	}
+
+	// another main account can not fetch the customer's proofs
+	other := h.createMerchant("ops@other.test")
+	var appErr errors.AppError
+	_, err = other.api.FetchLiabilityProof(customer, snapshotID)
+	if !stderrors.As(err, &appErr) || appErr.Type != errors.ErrNotFound {
+		t.Errorf("fetching another account's proofs failed with %v, want a not found error", err)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+
+	if _, err := h.admin.SetTokenRateLimit(&requests.SetTokenRateLimitRequest{TokenID: m.tokenID, RouteGroup: "transactions", Rate: 0.01, Burst: 2}); err != nil {
+		t.Fatal(err)
+	}
+	var appErr errors.AppError
+	_, err := h.admin.SetTokenRateLimit(&requests.SetTokenRateLimitRequest{TokenID: "missing", RouteGroup: "*", Rate: 1, Burst: 1})
+	if !stderrors.As(err, &appErr) || appErr.Type != errors.ErrNotFound {
+		t.Errorf("setting the limit of a missing token failed with %v, want a not found error", err)
+	}
+
+	// the token's limit replaces the group's default of 5 requests
+	for i, remaining := range []string{"1", "0"} {
+		res, err := m.api.do(http.MethodGet, userPath("me", "/transactions"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("request %d responded with %d, want 200", i, res.StatusCode)
+		}
+		if limit := res.Header.Get("X-RateLimit-Limit"); limit != "2" {
+			t.Errorf("request %d has a limit of %q, want 2", i, limit)
+		}
+		if got := res.Header.Get("X-RateLimit-Remaining"); got != remaining {
+			t.Errorf("request %d has %q requests remaining, want %s", i, got, remaining)
+		}
+		if reset := res.Header.Get("X-RateLimit-Reset"); reset == "" {
+			t.Errorf("request %d has no reset header", i)
+		}
+	}
+
+	res, err := m.api.do(http.MethodGet, userPath("me", "/transactions"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("request over the limit responded with %d, want 429", res.StatusCode)
+	}
+	// a request is added back every 100 seconds
+	if retryAfter := res.Header.Get("Retry-After"); retryAfter != "100" {
+		t.Errorf("limited request has a Retry-After of %q, want 100", retryAfter)
+	}
+	if err = json.NewDecoder(res.Body).Decode(&appErr); err != nil {
+		t.Fatal(err)
+	}
+	if appErr.Type != errors.ErrRateLimited {
+		t.Errorf("limited request failed with %s, want %s", appErr.Type, errors.ErrRateLimited)
+	}
+
+	// other groups keep their configured defaults
+	res, err = m.api.do(http.MethodGet, userPath("me", "/wallets"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if limit := res.Header.Get("X-RateLimit-Limit"); limit != "20" {
+		t.Errorf("wallets request has a limit of %q, want the default of 20", limit)
+	}
+}
+
+// TestMemoryBackend runs the flows against the in-memory repositories, which must answer the way the sql ones do
+// so the flows are compared with their own golden files
+func TestMemoryBackend(t *testing.T) {
+	t.Setenv(dataDBBackendEnv, "memory")
+	for _, flow := range []struct {
+		name string
+		run  func(*testing.T)
+	}{
+		{"TestAccountFlow", TestAccountFlow},
+		{"TestKYCRules", TestKYCRules},
+		{"TestKYCTierNotLowered", TestKYCTierNotLowered},
+		{"TestWalletFlow", TestWalletFlow},
+		{"TestDepositFlow", TestDepositFlow},
+		{"TestSwapFlow", TestSwapFlow},
+		{"TestPointInTimeBalances", TestPointInTimeBalances},
+		{"TestSwapFrozenWallet", TestSwapFrozenWallet},
+		{"TestSwapReversal", TestSwapReversal},
+		{"TestWithdrawalFlow", TestWithdrawalFlow},
+		{"TestWithdrawalLimits", TestWithdrawalLimits},
+		{"TestSubAccountLimits", TestSubAccountLimits},
+		{"TestReconciliation", TestReconciliation},
+		{"TestLiabilityProofFlow", TestLiabilityProofFlow},
+		{"TestRateLimits", TestRateLimits},
+		{"TestClientIdempotentRetries", TestClientIdempotentRetries},
+		{"TestAbandonedIdempotencyKey", TestAbandonedIdempotencyKey},
+		{"TestStatementExportExpiry", TestStatementExportExpiry},
+		{"TestClientPagination", TestClientPagination},
+		{"TestLedgerPagination", TestLedgerPagination},
+	} {
+		t.Run(flow.name, flow.run)
+	}
+}
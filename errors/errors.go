@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"runtime/debug"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	_ "github.com/tigerbeetle/tigerbeetle-go/pkg/errors"
@@ -29,6 +30,11 @@ const (
 	ErrFailedDependency ErrorType = "FAILED_DEPENDENCY"
 	ErrFatal            ErrorType = "FATAL_ERROR"
 	ErrNotImplemented   ErrorType = "NOT_IMPLEMENTED_ERROR"
+	ErrRateLimited      ErrorType = "RATE_LIMITED"
+	ErrFrozen           ErrorType = "FROZEN_ERROR"
+	ErrKYCRequired      ErrorType = "KYC_REQUIRED_ERROR"
+	ErrLimitExceeded    ErrorType = "LIMIT_EXCEEDED_ERROR"
+	ErrConflict         ErrorType = "CONFLICT_ERROR"
 )
 
 type AppError struct {
@@ -36,6 +42,8 @@ type AppError struct {
 	Type     ErrorType `json:"type"`
 	Message  string    `json:"message"`
 	Internal string    `json:"internal,omitempty"`
+	// additional details for the client to act on
+	Data any `json:"data,omitempty"`
 }
 
 func (a AppError) Error() string {
@@ -56,6 +64,10 @@ func Is(err, target error) bool {
 	return errors.Is(err, target)
 }
 
+func As(err error, target any) bool {
+	return errors.As(err, target)
+}
+
 func HandleDataDBError(err error) AppError {
 	if Is(err, sql.ErrNoRows) {
 		return NewNotFoundError("resource not found")
@@ -135,6 +147,47 @@ func NewPermissionError(msg string) AppError {
 	}
 }
 
+func NewFrozenError(msg string) AppError {
+	return AppError{
+		Code:    http.StatusForbidden,
+		Type:    ErrFrozen,
+		Message: msg,
+	}
+}
+
+func NewKYCRequiredError(msg string) AppError {
+	return AppError{
+		Code:    http.StatusForbidden,
+		Type:    ErrKYCRequired,
+		Message: msg,
+	}
+}
+
+// LimitExceededDetails tells the client how much more it may move before the limit resets
+type LimitExceededDetails struct {
+	Currency  string  `json:"currency"`
+	Period    string  `json:"period"`
+	Limit     float64 `json:"limit"`
+	Remaining float64 `json:"remaining"`
+}
+
+func NewLimitExceededError(msg string, details LimitExceededDetails) AppError {
+	return AppError{
+		Code:    http.StatusForbidden,
+		Type:    ErrLimitExceeded,
+		Message: msg,
+		Data:    details,
+	}
+}
+
+func NewConflictError(msg string) AppError {
+	return AppError{
+		Code:    http.StatusConflict,
+		Type:    ErrConflict,
+		Message: msg,
+	}
+}
+
 func NewAuthenticationError(msg string) AppError {
 	return AppError{
 		Code:    http.StatusUnauthorized,
@@ -173,6 +226,14 @@ func NewFailedDependencyError(msg string) AppError {
 	}
 }
 
+func NewRateLimitedError(retryAfter time.Duration) AppError {
+	return AppError{
+		Code:    http.StatusTooManyRequests,
+		Type:    ErrRateLimited,
+		Message: fmt.Sprintf("Too many requests, retry in %s", retryAfter.Round(time.Second)),
+	}
+}
+
 func NewImplementationError() AppError {
 	return AppError{
 		Code:    http.StatusNotImplemented,
@@ -0,0 +1,175 @@
+// Package fixtures seeds the application from declarative YAML files describing main accounts, their
+// sub-accounts, access tokens, webhook urls, starting balances and a history of swaps and withdrawals.
+// Fixtures are applied through the services, so seeded accounts have the same wallets and ledger history as
+// ones made through the api
+package fixtures
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/2HgO/quidax-go/models"
+	"gopkg.in/yaml.v3"
+)
+
+// File is a fixture file. Accounts are created first, then the swaps and withdrawals in the order they are
+// listed. Every account, swap and withdrawal has a key naming it in the file and in the record of applied
+// steps, keys must be unique across every file seeded into a database
+type File struct {
+	Accounts    []Account    `yaml:"accounts"`
+	Swaps       []Swap       `yaml:"swaps"`
+	Withdrawals []Withdrawal `yaml:"withdrawals"`
+}
+
+// Account is a main account in the test environment
+type Account struct {
+	Key         string       `yaml:"key"`
+	Email       string       `yaml:"email"`
+	Password    string       `yaml:"password"`
+	FirstName   string       `yaml:"first_name"`
+	LastName    string       `yaml:"last_name"`
+	DisplayName string       `yaml:"display_name"`
+	Webhook     *Webhook     `yaml:"webhook"`
+	Tokens      []Token      `yaml:"tokens"`
+	Balances    Balances     `yaml:"balances"`
+	SubAccounts []SubAccount `yaml:"sub_accounts"`
+}
+
+type SubAccount struct {
+	Key       string   `yaml:"key"`
+	Email     string   `yaml:"email"`
+	FirstName string   `yaml:"first_name"`
+	LastName  string   `yaml:"last_name"`
+	KYC       *KYC     `yaml:"kyc"`
+	Balances  Balances `yaml:"balances"`
+}
+
+// KYC is a kyc submission made for a sub-account, it is approved once submitted
+type KYC struct {
+	Tier        models.KYCTier `yaml:"tier"`
+	PhoneNumber string         `yaml:"phone_number"`
+	DateOfBirth string         `yaml:"date_of_birth"`
+	Country     string         `yaml:"country"`
+	IDType      string         `yaml:"id_type"`
+	IDNumber    string         `yaml:"id_number"`
+}
+
+// Token is an access token issued to a main account in addition to the ones it is created with, tokens are
+// named uniquely within their account
+type Token struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	// environment the token authenticates against, test when empty
+	Environment string `yaml:"environment"`
+}
+
+// Webhook is the callback url of a main account. It is set on every run, after the other steps, so seeding
+// does not deliver events for the seeded history
+type Webhook struct {
+	URL string  `yaml:"url"`
+	Key *string `yaml:"key"`
+}
+
+// Balances are amounts deposited into an account's wallets, keyed by currency
+type Balances map[string]models.Double
+
+// Swap is an instant swap made by the account named by User and confirmed right away
+type Swap struct {
+	Key    string        `yaml:"key"`
+	User   string        `yaml:"user"`
+	From   string        `yaml:"from"`
+	To     string        `yaml:"to"`
+	Amount models.Double `yaml:"amount"`
+}
+
+// Withdrawal sends funds from the account named by User to the account named by To
+type Withdrawal struct {
+	Key       string        `yaml:"key"`
+	User      string        `yaml:"user"`
+	To        string        `yaml:"to"`
+	Currency  string        `yaml:"currency"`
+	Amount    models.Double `yaml:"amount"`
+	Narration string        `yaml:"narration"`
+	Note      string        `yaml:"note"`
+}
+
+// Load reads a fixture file and checks that its keys are unique and its references resolve. Keys the format
+// does not have are rejected so misspelt fields are not silently ignored
+func Load(file string) (*File, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	f := new(File)
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err = dec.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("reading fixtures %s: %w", file, err)
+	}
+	if err = f.check(); err != nil {
+		return nil, fmt.Errorf("invalid fixtures %s: %w", file, err)
+	}
+	return f, nil
+}
+
+func (f *File) check() error {
+	users := make(map[string]bool)
+	addUser := func(key string) error {
+		if key == "" {
+			return fmt.Errorf("every account needs a key")
+		}
+		if users[key] {
+			return fmt.Errorf("account key %q is used more than once", key)
+		}
+		users[key] = true
+		return nil
+	}
+
+	for _, account := range f.Accounts {
+		if err := addUser(account.Key); err != nil {
+			return err
+		}
+		tokens := make(map[string]bool)
+		for _, token := range account.Tokens {
+			if tokens[token.Name] {
+				return fmt.Errorf("account %q has more than one token named %q", account.Key, token.Name)
+			}
+			tokens[token.Name] = true
+		}
+		for _, sub := range account.SubAccounts {
+			if err := addUser(sub.Key); err != nil {
+				return err
+			}
+		}
+	}
+
+	swaps := make(map[string]bool)
+	for _, swap := range f.Swaps {
+		if swap.Key == "" || swaps[swap.Key] {
+			return fmt.Errorf("every swap needs a unique key, %q is missing or used more than once", swap.Key)
+		}
+		swaps[swap.Key] = true
+		if !users[swap.User] {
+			return fmt.Errorf("swap %q is made by unknown account %q", swap.Key, swap.User)
+		}
+	}
+
+	withdrawals := make(map[string]bool)
+	for _, withdrawal := range f.Withdrawals {
+		if withdrawal.Key == "" || withdrawals[withdrawal.Key] {
+			return fmt.Errorf("every withdrawal needs a unique key, %q is missing or used more than once", withdrawal.Key)
+		}
+		withdrawals[withdrawal.Key] = true
+		if !users[withdrawal.User] {
+			return fmt.Errorf("withdrawal %q is made by unknown account %q", withdrawal.Key, withdrawal.User)
+		}
+		if !users[withdrawal.To] {
+			return fmt.Errorf("withdrawal %q is sent to unknown account %q", withdrawal.Key, withdrawal.To)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,358 @@
+package fixtures
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
+	"github.com/2HgO/quidax-go/services"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/utils"
+	"go.uber.org/zap"
+)
+
+// how long a confirmed swap is waited on before seeding carries on with the next step
+const (
+	swapTimeout      = 30 * time.Second
+	swapPollInterval = 100 * time.Millisecond
+)
+
+// Seeder applies fixture files. Every step of a file, such as creating an account or making a deposit, is
+// recorded in the seeded_fixtures table with the id of what it made, so seeding a file again only applies the
+// steps added since. A step that fails after its service call succeeded is applied again on the next run
+type Seeder struct {
+	fixtureRepository repositories.FixtureRepository
+	accountService    services.AccountService
+	kycService        services.KYCService
+	depositService    services.DepositService
+	swapService       services.InstantSwapService
+	withdrawalService services.WithdrawalService
+	log               *zap.Logger
+}
+
+func NewSeeder(
+	fixtureRepository repositories.FixtureRepository,
+	accountService services.AccountService,
+	kycService services.KYCService,
+	depositService services.DepositService,
+	swapService services.InstantSwapService,
+	withdrawalService services.WithdrawalService,
+	log *zap.Logger,
+) *Seeder {
+	return &Seeder{
+		fixtureRepository: fixtureRepository,
+		accountService:    accountService,
+		kycService:        kycService,
+		depositService:    depositService,
+		swapService:       swapService,
+		withdrawalService: withdrawalService,
+		log:               log,
+	}
+}
+
+// seeding is the state of a single Seed call
+type seeding struct {
+	*Seeder
+	admin context.Context
+	// ids of the file's accounts and the contexts of the main accounts owning them, by key
+	users  map[string]string
+	owners map[string]context.Context
+	// steps applied by this call
+	applied []string
+}
+
+// Seed applies the steps of the file that were not applied before and returns the ones it applied
+func (s *Seeder) Seed(ctx context.Context, file *File) ([]string, error) {
+	r := &seeding{
+		Seeder: s,
+		admin:  models.ContextWithPrincipal(ctx, models.NewAdminPrincipal()),
+		users:  make(map[string]string),
+		owners: make(map[string]context.Context),
+	}
+
+	for _, account := range file.Accounts {
+		if err := r.account(ctx, account); err != nil {
+			return r.applied, err
+		}
+	}
+	for _, swap := range file.Swaps {
+		if err := r.swap(swap); err != nil {
+			return r.applied, err
+		}
+	}
+	for _, withdrawal := range file.Withdrawals {
+		if err := r.withdrawal(withdrawal); err != nil {
+			return r.applied, err
+		}
+	}
+	for _, account := range file.Accounts {
+		if err := r.webhook(account); err != nil {
+			return r.applied, err
+		}
+	}
+	return r.applied, nil
+}
+
+func (r *seeding) account(ctx context.Context, account Account) error {
+	id, err := r.step("account:"+account.Key, func() (string, error) {
+		req := &requests.CreateAccountRequest{
+			Email:       account.Email,
+			Password:    account.Password,
+			FirstName:   account.FirstName,
+			LastName:    account.LastName,
+			DisplayName: account.DisplayName,
+		}
+		if err := validate(req); err != nil {
+			return "", err
+		}
+		res, err := r.accountService.CreateAccount(r.admin, req)
+		if err != nil {
+			return "", err
+		}
+		return res.Data.User.ID, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the main account acts for itself and its sub-accounts the way it would with a test access token
+	res, err := r.accountService.FetchAccountDetails(r.admin, &requests.FetchAccountDetailsRequest{UserID: id})
+	if err != nil {
+		return fmt.Errorf("seeding account:%s: %w", account.Key, err)
+	}
+	parent := res.Data
+	parent.Environment = models.Test_Environment
+	owner := models.ContextWithPrincipal(ctx, models.NewAccountPrincipal(parent))
+	r.users[account.Key], r.owners[account.Key] = id, owner
+
+	for _, token := range account.Tokens {
+		if err = r.token(ctx, account.Key, id, token); err != nil {
+			return err
+		}
+	}
+	if err = r.balances(account.Key, account.Balances); err != nil {
+		return err
+	}
+
+	for _, sub := range account.SubAccounts {
+		id, err := r.step("sub_account:"+sub.Key, func() (string, error) {
+			req := &requests.CreateSubAccountRequest{Email: sub.Email, FirstName: sub.FirstName, LastName: sub.LastName}
+			if err := validate(req); err != nil {
+				return "", err
+			}
+			res, err := r.accountService.CreateSubAccount(owner, req)
+			if err != nil {
+				return "", err
+			}
+			return res.Data.ID, nil
+		})
+		if err != nil {
+			return err
+		}
+		r.users[sub.Key], r.owners[sub.Key] = id, owner
+
+		if sub.KYC != nil {
+			if err = r.kyc(sub.Key, *sub.KYC); err != nil {
+				return err
+			}
+		}
+		if err = r.balances(sub.Key, sub.Balances); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *seeding) token(ctx context.Context, key, userID string, token Token) error {
+	_, err := r.step(fmt.Sprintf("token:%s:%s", key, token.Name), func() (string, error) {
+		env := models.Test_Environment
+		if token.Environment != "" {
+			if err := env.UnmarshalJSON([]byte(token.Environment)); err != nil {
+				return "", errors.NewValidationError(fmt.Sprintf("invalid environment %q", token.Environment))
+			}
+		}
+		req := &requests.IssueTokenRequest{UserID: userID, Environment: &env, Name: token.Name, Description: token.Description}
+		if err := validate(req); err != nil {
+			return "", err
+		}
+
+		// tokens are issued by an admin acting in the token's environment
+		principal := models.NewAdminPrincipal()
+		principal.Environment = env
+		res, err := r.accountService.IssueToken(models.ContextWithPrincipal(ctx, principal), req)
+		if err != nil {
+			return "", err
+		}
+		return res.Data.ID, nil
+	})
+	return err
+}
+
+// kyc submits the sub-account's kyc details and approves the submission
+func (r *seeding) kyc(key string, kyc KYC) error {
+	_, err := r.step("kyc:"+key, func() (string, error) {
+		req := &requests.SubmitKYCRequest{
+			UserID:      r.users[key],
+			Tier:        kyc.Tier,
+			PhoneNumber: kyc.PhoneNumber,
+			DateOfBirth: kyc.DateOfBirth,
+			Country:     kyc.Country,
+			IDType:      kyc.IDType,
+			IDNumber:    kyc.IDNumber,
+		}
+		if err := validate(req); err != nil {
+			return "", err
+		}
+		res, err := r.kycService.SubmitKYC(r.owners[key], req)
+		if err != nil {
+			return "", err
+		}
+		if res.Data.Status != models.Pending_KYCStatus {
+			return res.Data.ID, nil
+		}
+
+		_, err = r.kycService.ReviewKYCSubmission(r.admin, &requests.ReviewKYCSubmissionRequest{
+			UserID:       r.users[key],
+			SubmissionID: res.Data.ID,
+			Status:       models.Approved_KYCStatus,
+		})
+		if err != nil {
+			return "", err
+		}
+		return res.Data.ID, nil
+	})
+	return err
+}
+
+// balances deposits the starting balances into the account's wallets, in currency order so every run
+// deposits in the same order
+func (r *seeding) balances(key string, balances Balances) error {
+	currencies := make([]string, 0, len(balances))
+	for currency := range balances {
+		currencies = append(currencies, currency)
+	}
+	slices.Sort(currencies)
+
+	for _, currency := range currencies {
+		_, err := r.step(fmt.Sprintf("balance:%s:%s", key, currency), func() (string, error) {
+			req := &requests.DepositAmountRequest{UserID: r.users[key], Currency: currency, Amount: balances[currency]}
+			if err := validate(req); err != nil {
+				return "", err
+			}
+			res, err := r.depositService.CreateDeposit(r.owners[key], req)
+			if err != nil {
+				return "", err
+			}
+			return res.Data.ID, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// swap quotes and confirms the swap, then waits for it to be processed so the steps after it see its
+// transfers
+func (r *seeding) swap(swap Swap) error {
+	_, err := r.step("swap:"+swap.Key, func() (string, error) {
+		ctx, userID := r.owners[swap.User], r.users[swap.User]
+		req := &requests.CreateInstantSwapRequest{UserID: userID, FromCurrency: swap.From, ToCurrency: swap.To, FromAmount: swap.Amount}
+		if err := validate(req); err != nil {
+			return "", err
+		}
+		quotation, err := r.swapService.CreateInstantSwap(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		confirmed, err := r.swapService.ConfirmInstantSwap(ctx, &requests.ConfirmInstanSwapRequest{UserID: userID, QuotationID: quotation.Data.ID})
+		if err != nil {
+			return "", err
+		}
+
+		fetch := &requests.FetchInstantSwapTransactionRequest{UserID: userID, SwapTransactionID: confirmed.Data.ID}
+		for deadline := time.Now().Add(swapTimeout); ; time.Sleep(swapPollInterval) {
+			// the swap is not found until its transfers are made
+			res, err := r.swapService.FetchInstantSwapTransaction(ctx, fetch)
+			switch {
+			case err == nil && res.Data.Status != "pending":
+				return res.Data.ID, nil
+			case err != nil && errors.AsAppError(err).Type != errors.ErrNotFound:
+				return "", err
+			case time.Now().After(deadline):
+				return "", fmt.Errorf("swap %s was not processed within %s", confirmed.Data.ID, swapTimeout)
+			}
+		}
+	})
+	return err
+}
+
+func (r *seeding) withdrawal(withdrawal Withdrawal) error {
+	_, err := r.step("withdrawal:"+withdrawal.Key, func() (string, error) {
+		req := &requests.CreateWithdrawalRequest{
+			UserID:          r.users[withdrawal.User],
+			FundUid:         r.users[withdrawal.To],
+			Currency:        withdrawal.Currency,
+			Amount:          withdrawal.Amount,
+			TransactionNote: withdrawal.Note,
+			Narration:       withdrawal.Narration,
+		}
+		if err := validate(req); err != nil {
+			return "", err
+		}
+		res, err := r.withdrawalService.CreateUserWithdrawal(r.owners[withdrawal.User], req)
+		if err != nil {
+			return "", err
+		}
+		return res.Data.ID, nil
+	})
+	return err
+}
+
+// webhook sets the account's callback url. It is not recorded as a step, so changes to the url in the file
+// take effect when it is seeded again
+func (r *seeding) webhook(account Account) error {
+	if account.Webhook == nil {
+		return nil
+	}
+	err := r.accountService.UpdateWebHookURL(r.owners[account.Key], &requests.UpdateWebhookURLRequest{
+		CallbackURL: account.Webhook.URL,
+		WebhookKey:  account.Webhook.Key,
+	})
+	if err != nil {
+		return fmt.Errorf("seeding webhook:%s: %w", account.Key, err)
+	}
+	return nil
+}
+
+// step returns the ref recorded for the step when it was applied before. Otherwise it applies the step and
+// records it with the ref apply returns, the id of what the step made
+func (r *seeding) step(id string, apply func() (string, error)) (string, error) {
+	ref, seeded, err := r.fixtureRepository.Find(r.admin, id)
+	if err != nil || seeded {
+		return ref, err
+	}
+
+	if ref, err = apply(); err != nil {
+		return "", fmt.Errorf("seeding %s: %w", id, err)
+	}
+	if err = r.fixtureRepository.Record(r.admin, id, ref, time.Now()); err != nil {
+		return "", err
+	}
+
+	r.log.Info("seeded fixture", zap.String("step", id), zap.String("ref", ref))
+	r.applied = append(r.applied, id)
+	return ref, nil
+}
+
+// validate checks a request built from a fixture the way bound http requests are checked
+func validate(req any) error {
+	if err := utils.Validator.Validate(req); err != nil {
+		return errors.HandleBindError(err)
+	}
+	return nil
+}
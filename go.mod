@@ -3,6 +3,7 @@ module github.com/2HgO/quidax-go
 go 1.22.4
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/MadAppGang/httplog v1.3.0
 	github.com/MadAppGang/httplog/zap v1.2.1
 	github.com/Masterminds/squirrel v1.5.4
@@ -12,13 +13,16 @@ require (
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/handlers v1.5.2
 	github.com/gorilla/schema v1.4.1
+	github.com/lib/pq v1.10.9
 	github.com/lucsky/cuid v1.2.1
 	github.com/madflojo/tasks v1.2.1
+	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/tigerbeetle/tigerbeetle-go v0.16.11
 	go.uber.org/fx v1.23.0
 	go.uber.org/zap v1.26.0
 	golang.org/x/crypto v0.28.0
 	golang.org/x/text v0.19.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -18,13 +18,17 @@ type AccountHandler interface {
 	CreateSubAccount(http.ResponseWriter, *http.Request)
 	EditSubAccountDetails(http.ResponseWriter, *http.Request)
 	FetchAllSubAccounts(http.ResponseWriter, *http.Request)
+	FreezeSubAccount(http.ResponseWriter, *http.Request)
+	UnfreezeSubAccount(http.ResponseWriter, *http.Request)
+	FetchUserLimits(http.ResponseWriter, *http.Request)
+	SetSubAccountLimit(http.ResponseWriter, *http.Request)
 
 	Handler
 }
 
-func NewAccountHandler(accountService services.AccountService, middlewares MiddleWareHandler, log *zap.Logger) AccountHandler {
+func NewAccountHandler(accountService services.AccountService, limitService services.LimitService, middlewares MiddleWareHandler, log *zap.Logger) AccountHandler {
 	return &accountHandler{
-		handler: handler{accountService: accountService, middlewares: middlewares, log: log},
+		handler: handler{accountService: accountService, limitService: limitService, middlewares: middlewares, log: log},
 	}
 }
 
@@ -32,15 +36,19 @@ type accountHandler struct {
 	handler
 }
 
-func (a *accountHandler) ServeHttp(mux *http.ServeMux) {
-	mux.HandleFunc("POST /api/v1/accounts", a.CreateAccount)
+func (a *accountHandler) ServeHttp(mux Router) {
+	mux.HandleFunc("POST /api/v1/accounts", a.middlewares.AttachRateLimit(AccountsRouteGroup, a.CreateAccount))
 
-	mux.HandleFunc("PUT /api/v1/accounts", a.middlewares.AttachValidateAccessToken(a.UpdateWebHookURL))
+	mux.HandleFunc("PUT /api/v1/accounts", a.middlewares.AttachValidateAccessToken(AccountsRouteGroup, a.UpdateWebHookURL))
 
-	mux.HandleFunc("POST /api/v1/users", a.middlewares.AttachValidateAccessToken(a.CreateSubAccount))
-	mux.HandleFunc("GET /api/v1/users", a.middlewares.AttachValidateAccessToken(a.FetchAllSubAccounts))
-	mux.HandleFunc("PUT /api/v1/users/{user_id}", a.middlewares.AttachValidateAccessToken(a.EditSubAccountDetails))
-	mux.HandleFunc("GET /api/v1/users/{user_id}", a.middlewares.AttachValidateAccessToken(a.FetchAccountDetails))
+	mux.HandleFunc("POST /api/v1/users", a.middlewares.AttachValidateAccessToken(AccountsRouteGroup, a.CreateSubAccount))
+	mux.HandleFunc("GET /api/v1/users", a.middlewares.AttachValidateAccessToken(AccountsRouteGroup, a.FetchAllSubAccounts))
+	mux.HandleFunc("PUT /api/v1/users/{user_id}", a.middlewares.AttachValidateAccessToken(AccountsRouteGroup, a.EditSubAccountDetails))
+	mux.HandleFunc("GET /api/v1/users/{user_id}", a.middlewares.AttachValidateAccessToken(AccountsRouteGroup, a.FetchAccountDetails))
+	mux.HandleFunc("POST /api/v1/users/{user_id}/freeze", a.middlewares.AttachValidateAccessToken(AccountsRouteGroup, a.FreezeSubAccount))
+	mux.HandleFunc("POST /api/v1/users/{user_id}/unfreeze", a.middlewares.AttachValidateAccessToken(AccountsRouteGroup, a.UnfreezeSubAccount))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/limits", a.middlewares.AttachValidateAccessToken(AccountsRouteGroup, a.FetchUserLimits))
+	mux.HandleFunc("PUT /api/v1/users/{user_id}/sub_account_limits", a.middlewares.AttachValidateAccessToken(AccountsRouteGroup, a.SetSubAccountLimit))
 }
 
 func (a *accountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
@@ -113,5 +121,54 @@ func (a *accountHandler) FetchAllSubAccounts(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	writePagination(w, res.Pagination)
+	utils.JSON(w, 200, res)
+}
+
+func (a *accountHandler) FreezeSubAccount(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FreezeSubAccountRequest](r)
+
+	res, err := a.accountService.FreezeSubAccount(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
+
+func (a *accountHandler) UnfreezeSubAccount(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FreezeSubAccountRequest](r)
+
+	res, err := a.accountService.UnfreezeSubAccount(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
+
+func (a *accountHandler) FetchUserLimits(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchUserLimitsRequest](r)
+
+	res, err := a.limitService.FetchUserLimits(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
+
+func (a *accountHandler) SetSubAccountLimit(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.SetSubAccountLimitRequest](r)
+
+	res, err := a.limitService.SetSubAccountLimit(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
 	utils.JSON(w, 200, res)
 }
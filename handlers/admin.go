@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/services"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/utils"
+	"go.uber.org/zap"
+)
+
+type AdminHandler interface {
+	StartReconciliation(http.ResponseWriter, *http.Request)
+	FetchReconciliationReports(http.ResponseWriter, *http.Request)
+	FetchReconciliationReport(http.ResponseWriter, *http.Request)
+	FetchLedgerPositions(http.ResponseWriter, *http.Request)
+	StartLiabilitySnapshot(http.ResponseWriter, *http.Request)
+	FetchLiabilitySnapshot(http.ResponseWriter, *http.Request)
+	SetTokenRateLimit(http.ResponseWriter, *http.Request)
+
+	Handler
+}
+
+func NewAdminHandler(reconciliationService services.ReconciliationService, ledgerService services.LedgerService, proofService services.ProofService, accountService services.AccountService, middlewares MiddleWareHandler, log *zap.Logger) AdminHandler {
+	return &adminHandler{
+		handler: handler{reconciliationService: reconciliationService, ledgerService: ledgerService, proofService: proofService, accountService: accountService, middlewares: middlewares, log: log},
+	}
+}
+
+type adminHandler struct {
+	handler
+}
+
+func (a *adminHandler) ServeHttp(mux Router) {
+	mux.HandleFunc("POST /api/v1/admin/reconciliations", a.middlewares.AttachValidateAdminToken(AdminRouteGroup, a.StartReconciliation))
+	mux.HandleFunc("GET /api/v1/admin/reconciliations", a.middlewares.AttachValidateAdminToken(AdminRouteGroup, a.FetchReconciliationReports))
+	mux.HandleFunc("GET /api/v1/admin/reconciliations/{reconciliation_id}", a.middlewares.AttachValidateAdminToken(AdminRouteGroup, a.FetchReconciliationReport))
+	mux.HandleFunc("GET /api/v1/admin/ledgers", a.middlewares.AttachValidateAdminToken(AdminRouteGroup, a.FetchLedgerPositions))
+	mux.HandleFunc("POST /api/v1/admin/proofs", a.middlewares.AttachValidateAdminToken(AdminRouteGroup, a.StartLiabilitySnapshot))
+	// unlike the public route, running and failed snapshots are served to admins
+	mux.HandleFunc("GET /api/v1/admin/proofs/{snapshot_id}", a.middlewares.AttachValidateAdminToken(AdminRouteGroup, a.FetchLiabilitySnapshot))
+	mux.HandleFunc("PUT /api/v1/admin/tokens/{token_id}/rate_limits", a.middlewares.AttachValidateAdminToken(AdminRouteGroup, a.SetTokenRateLimit))
+}
+
+func (a *adminHandler) StartReconciliation(w http.ResponseWriter, r *http.Request) {
+	res, err := a.reconciliationService.StartReconciliation(r.Context())
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 202, res)
+}
+
+func (a *adminHandler) FetchReconciliationReports(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchReconciliationReportsRequest](r)
+
+	res, err := a.reconciliationService.FetchReconciliationReports(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	writePagination(w, res.Pagination)
+	utils.JSON(w, 200, res)
+}
+
+func (a *adminHandler) FetchReconciliationReport(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchReconciliationReportRequest](r)
+
+	res, err := a.reconciliationService.FetchReconciliationReport(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
+
+func (a *adminHandler) FetchLedgerPositions(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchLedgerPositionsRequest](r)
+
+	res, err := a.ledgerService.FetchLedgerPositions(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
+
+func (a *adminHandler) StartLiabilitySnapshot(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.StartLiabilitySnapshotRequest](r)
+
+	res, err := a.proofService.StartLiabilitySnapshot(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 202, res)
+}
+
+func (a *adminHandler) FetchLiabilitySnapshot(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchLiabilitySnapshotRequest](r)
+
+	res, err := a.proofService.FetchLiabilitySnapshot(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
+
+func (a *adminHandler) SetTokenRateLimit(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.SetTokenRateLimitRequest](r)
+
+	res, err := a.accountService.SetTokenRateLimit(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
@@ -28,11 +28,11 @@ type depositHandler struct {
 	handler
 }
 
-func (d *depositHandler) ServeHttp(mux *http.ServeMux) {
-	mux.Handle("POST /api/v1/users/{user_id}/deposits/{currency}", d.middlewares.AttachValidateAccessToken(d.DepositAmount))
-	mux.Handle("GET /api/v1/users/{user_id}/deposits", d.middlewares.AttachValidateAccessToken(d.FetchDeposits))
-	mux.Handle("GET /api/v1/users/{user_id}/deposits/currency/{currency}", d.middlewares.AttachValidateAccessToken(d.FetchDeposits))
-	mux.Handle("GET /api/v1/users/{user_id}/deposits/{transaction_id}", d.middlewares.AttachValidateAccessToken(d.FetchDeposit))
+func (d *depositHandler) ServeHttp(mux Router) {
+	mux.Handle("POST /api/v1/users/{user_id}/deposits/{currency}", d.middlewares.AttachValidateAccessToken(DepositsRouteGroup, d.DepositAmount))
+	mux.Handle("GET /api/v1/users/{user_id}/deposits", d.middlewares.AttachValidateAccessToken(DepositsRouteGroup, d.FetchDeposits))
+	mux.Handle("GET /api/v1/users/{user_id}/deposits/currency/{currency}", d.middlewares.AttachValidateAccessToken(DepositsRouteGroup, d.FetchDeposits))
+	mux.Handle("GET /api/v1/users/{user_id}/deposits/{transaction_id}", d.middlewares.AttachValidateAccessToken(DepositsRouteGroup, d.FetchDeposit))
 }
 
 func (d *depositHandler) DepositAmount(w http.ResponseWriter, r *http.Request) {
@@ -68,5 +68,6 @@ func (d *depositHandler) FetchDeposits(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	writePagination(w, res.Pagination)
 	utils.JSON(w, 200, res)
 }
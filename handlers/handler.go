@@ -2,22 +2,69 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/2HgO/quidax-go/services"
+	"github.com/2HgO/quidax-go/types/responses"
 	"go.uber.org/zap"
 )
 
 type handler struct {
-	accountService    services.AccountService
-	walletService     services.WalletService
-	swapService       services.InstantSwapService
-	withdrawalService services.WithdrawalService
-	depositService    services.DepositService
-	middlewares       MiddleWareHandler
+	accountService        services.AccountService
+	walletService         services.WalletService
+	swapService           services.InstantSwapService
+	withdrawalService     services.WithdrawalService
+	depositService        services.DepositService
+	kycService            services.KYCService
+	limitService          services.LimitService
+	transactionService    services.TransactionService
+	statementService      services.StatementService
+	reconciliationService services.ReconciliationService
+	ledgerService         services.LedgerService
+	proofService          services.ProofService
+	middlewares           MiddleWareHandler
 
 	log *zap.Logger
 }
 
 type Handler interface {
-	ServeHttp(*http.ServeMux)
+	ServeHttp(Router)
+}
+
+// Router is the mux handlers register their routes on, *http.ServeMux in the server
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+// routeRecorder is a Router keeping the patterns registered on it
+type routeRecorder struct {
+	patterns []string
+}
+
+func (r *routeRecorder) Handle(pattern string, _ http.Handler) {
+	r.patterns = append(r.patterns, pattern)
+}
+
+func (r *routeRecorder) HandleFunc(pattern string, _ func(http.ResponseWriter, *http.Request)) {
+	r.patterns = append(r.patterns, pattern)
+}
+
+// Patterns lists the patterns of the routes the handlers register
+func Patterns(routers []Handler) []string {
+	recorder := new(routeRecorder)
+	for _, router := range routers {
+		router.ServeHttp(recorder)
+	}
+	return recorder.patterns
+}
+
+// writePagination sets the pagination headers exposed through cors for list responses
+func writePagination(w http.ResponseWriter, pagination *responses.Pagination) {
+	if pagination == nil {
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(pagination.Total))
+	w.Header().Set("X-Page-Number", strconv.Itoa(pagination.Page))
+	w.Header().Set("X-Per-Page", strconv.Itoa(pagination.PerPage))
 }
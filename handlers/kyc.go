@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/services"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/utils"
+	"go.uber.org/zap"
+)
+
+type KYCHandler interface {
+	SubmitKYC(http.ResponseWriter, *http.Request)
+	FetchKYCSubmissions(http.ResponseWriter, *http.Request)
+	ReviewKYCSubmission(http.ResponseWriter, *http.Request)
+
+	Handler
+}
+
+func NewKYCHandler(kycService services.KYCService, middlewares MiddleWareHandler, log *zap.Logger) KYCHandler {
+	return &kycHandler{
+		handler: handler{kycService: kycService, middlewares: middlewares, log: log},
+	}
+}
+
+type kycHandler struct {
+	handler
+}
+
+func (k *kycHandler) ServeHttp(mux Router) {
+	mux.HandleFunc("POST /api/v1/users/{user_id}/kyc", k.middlewares.AttachValidateAccessToken(AccountsRouteGroup, k.SubmitKYC))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/kyc", k.middlewares.AttachValidateAccessToken(AccountsRouteGroup, k.FetchKYCSubmissions))
+	mux.HandleFunc("POST /api/v1/users/{user_id}/kyc/{submission_id}/review", k.middlewares.AttachValidateAccessToken(AccountsRouteGroup, k.ReviewKYCSubmission))
+}
+
+func (k *kycHandler) SubmitKYC(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.SubmitKYCRequest](r)
+
+	res, err := k.kycService.SubmitKYC(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 201, res)
+}
+
+func (k *kycHandler) FetchKYCSubmissions(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchKYCSubmissionsRequest](r)
+
+	res, err := k.kycService.FetchKYCSubmissions(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	writePagination(w, res.Pagination)
+	utils.JSON(w, 200, res)
+}
+
+func (k *kycHandler) ReviewKYCSubmission(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.ReviewKYCSubmissionRequest](r)
+
+	res, err := k.kycService.ReviewKYCSubmission(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
@@ -1,31 +1,67 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
 	"net/http"
 	"strings"
 
+	"github.com/2HgO/quidax-go/config"
 	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
 	"github.com/2HgO/quidax-go/services"
 	"github.com/2HgO/quidax-go/utils"
 	"go.uber.org/zap"
 )
 
 type MiddleWareHandler interface {
-	AttachValidateAccessToken(http.HandlerFunc) http.HandlerFunc
+	AttachValidateAccessToken(RouteGroup, http.HandlerFunc) http.HandlerFunc
+	AttachRateLimit(RouteGroup, http.HandlerFunc) http.HandlerFunc
+	AttachValidateAdminToken(RouteGroup, http.HandlerFunc) http.HandlerFunc
 }
 
 type middlewareHandler struct {
-	accountService services.AccountService
-	log            *zap.Logger
+	accountService     services.AccountService
+	idempotencyService services.IdempotencyService
+	limiter            *rateLimiter
+	adminToken         string
+	log                *zap.Logger
 }
 
-func NewMiddlewareHandler(account services.AccountService, log *zap.Logger) MiddleWareHandler {
-	return &middlewareHandler{accountService: account, log: log}
+func NewMiddlewareHandler(account services.AccountService, idempotency services.IdempotencyService, cfg *config.Config, log *zap.Logger) MiddleWareHandler {
+	return &middlewareHandler{accountService: account, idempotencyService: idempotency, limiter: newRateLimiter(cfg.RateLimits, account.GetRateLimits), adminToken: cfg.Admin.Token, log: log}
 }
 
-func (m *middlewareHandler) AttachValidateAccessToken(h http.HandlerFunc) http.HandlerFunc {
-	return utils.Middleware(h, m.validateAccessToken)
+// AttachValidateAccessToken authenticates the request, applies the token's rate limits for the route group and
+// replays the responses of requests retried with an Idempotency-Key header
+func (m *middlewareHandler) AttachValidateAccessToken(group RouteGroup, h http.HandlerFunc) http.HandlerFunc {
+	return utils.Middleware(h, m.validateAccessToken, m.rateLimit(group), m.idempotent)
+}
+
+// AttachRateLimit applies the route group's default rate limits per client address to unauthenticated routes
+func (m *middlewareHandler) AttachRateLimit(group RouteGroup, h http.HandlerFunc) http.HandlerFunc {
+	return utils.Middleware(h, m.rateLimit(group))
+}
+
+// AttachValidateAdminToken authenticates operators with the admin token, admin routes are limited per client address
+func (m *middlewareHandler) AttachValidateAdminToken(group RouteGroup, h http.HandlerFunc) http.HandlerFunc {
+	return utils.Middleware(h, m.rateLimit(group), m.validateAdminToken)
+}
+
+func (m *middlewareHandler) validateAdminToken(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("authorization"), "Bearer ")
+		if m.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(m.adminToken)) != 1 {
+			errors.NewInvalidTokenError().Serialize(w)
+			return
+		}
+
+		h.ServeHTTP(w, r.WithContext(models.ContextWithPrincipal(r.Context(), models.NewAdminPrincipal())))
+	}
 }
 
 func (m *middlewareHandler) validateAccessToken(h http.HandlerFunc) http.HandlerFunc {
@@ -42,23 +78,125 @@ func (m *middlewareHandler) validateAccessToken(h http.HandlerFunc) http.Handler
 			return
 		}
 
-		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "user", res)))
+		h.ServeHTTP(w, r.WithContext(models.ContextWithPrincipal(r.Context(), models.NewAccountPrincipal(res))))
 	}
 }
 
+// maxIdempotentBody is the largest body read to fingerprint a request sent with an Idempotency-Key header
+const maxIdempotentBody = 1 << 20
+
+// idempotent answers requests retried with the Idempotency-Key header of a request that has been handled with its
+// stored response. Responses with a server error are only dropped when the request failed before changing
+// anything, the request can then be retried with the key
+func (m *middlewareHandler) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("idempotency-key")
+		if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				errors.NewValidationError("request body is too large").Serialize(w)
+				return
+			}
+			errors.NewValidationError("invalid request body received").Serialize(w)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(r.URL.RawQuery+"\n"), body...))
+
+		stored, err := m.idempotencyService.Begin(r.Context(), key, r.Method+" "+r.URL.Path+" "+hex.EncodeToString(sum[:]))
+		if err != nil {
+			appErr := errors.AsAppError(err)
+			if appErr.Type == errors.ErrConflict {
+				// the request with the key is still being handled, its response can be replayed shortly
+				w.Header().Set("Retry-After", "1")
+			}
+			appErr.Serialize(w)
+			return
+		}
+		if stored.StatusCode != nil {
+			w.Header().Set("Idempotent-Replayed", "true")
+			if len(stored.Response) > 0 {
+				w.Header().Set("Content-Type", "application/json")
+			}
+			w.WriteHeader(*stored.StatusCode)
+			w.Write(stored.Response)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		ctx, effects := models.ContextWithEffects(r.Context())
+		func() {
+			defer func() {
+				if err := recover(); err != nil {
+					serializeRecovered(rec, err)
+				}
+			}()
+			h.ServeHTTP(rec, r.WithContext(ctx))
+		}()
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		// the response has been written, the key is kept even when the client has gone away
+		// a request that failed after changing something is not handled again, a retry could repeat what it did
+		ctx = context.WithoutCancel(r.Context())
+		if rec.status >= http.StatusInternalServerError && !effects.Applied() {
+			err = m.idempotencyService.Abandon(ctx, stored)
+		} else {
+			err = m.idempotencyService.Complete(ctx, stored, rec.status, rec.body.Bytes())
+		}
+		if err != nil {
+			m.log.Error("storing idempotent response", zap.String("idempotency_key", key), zap.Error(err))
+		}
+	}
+}
+
+// responseRecorder keeps a copy of the response written through it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
 func RecoveryMW(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				switch rErr := err.(type) {
-				case error:
-					errors.AsAppError(rErr).Serialize(w)
-				default:
-					errors.NewUnknownError(err).Serialize(w)
-				}
+				serializeRecovered(w, err)
 			}
 		}()
 
 		h.ServeHTTP(w, r)
 	})
 }
+
+// serializeRecovered responds with the error a handler panicked with
+func serializeRecovered(w http.ResponseWriter, err any) {
+	switch rErr := err.(type) {
+	case error:
+		errors.AsAppError(rErr).Serialize(w)
+	default:
+		errors.NewUnknownError(err).Serialize(w)
+	}
+}
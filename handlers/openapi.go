@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// the docs page renders the document with redoc, openapi.json is resolved relative to the page
+const docsPage = `<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>quidax-go api</title>
+</head>
+<body>
+	<redoc spec-url="openapi.json"></redoc>
+	<script src="https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+type OpenAPIHandler interface {
+	FetchOpenAPIDocument(http.ResponseWriter, *http.Request)
+	FetchDocs(http.ResponseWriter, *http.Request)
+
+	Handler
+}
+
+// NewOpenAPIHandler generates the document once, the application does not start when the spec is invalid
+func NewOpenAPIHandler(log *zap.Logger) (OpenAPIHandler, error) {
+	doc, err := OpenAPIDocument()
+	if err != nil {
+		return nil, err
+	}
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &openAPIHandler{handler: handler{log: log}, document: content}, nil
+}
+
+type openAPIHandler struct {
+	handler
+	document []byte
+}
+
+func (o *openAPIHandler) ServeHttp(mux Router) {
+	// the document is public like the docs it is published in
+	mux.HandleFunc("GET /api/v1/openapi.json", o.FetchOpenAPIDocument)
+	mux.HandleFunc("GET /api/v1/docs", o.FetchDocs)
+}
+
+func (o *openAPIHandler) FetchOpenAPIDocument(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	if _, err := w.Write(o.document); err != nil {
+		o.log.Error("writing openapi document", zap.Error(err))
+	}
+}
+
+func (o *openAPIHandler) FetchDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(200)
+	if _, err := w.Write([]byte(docsPage)); err != nil {
+		o.log.Error("writing docs page", zap.Error(err))
+	}
+}
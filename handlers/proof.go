@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/services"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/utils"
+	"go.uber.org/zap"
+)
+
+type ProofHandler interface {
+	FetchLiabilitySnapshots(http.ResponseWriter, *http.Request)
+	FetchLiabilitySnapshot(http.ResponseWriter, *http.Request)
+	FetchLiabilityProof(http.ResponseWriter, *http.Request)
+
+	Handler
+}
+
+func NewProofHandler(proofService services.ProofService, middlewares MiddleWareHandler, log *zap.Logger) ProofHandler {
+	return &proofHandler{
+		handler: handler{proofService: proofService, middlewares: middlewares, log: log},
+	}
+}
+
+type proofHandler struct {
+	handler
+}
+
+func (p *proofHandler) ServeHttp(mux Router) {
+	// snapshot roots are published without authentication
+	mux.HandleFunc("GET /api/v1/proofs", p.middlewares.AttachRateLimit(ProofsRouteGroup, p.FetchLiabilitySnapshots))
+	mux.HandleFunc("GET /api/v1/proofs/{snapshot_id}", p.middlewares.AttachRateLimit(ProofsRouteGroup, p.FetchLiabilitySnapshot))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/proofs/{snapshot_id}", p.middlewares.AttachValidateAccessToken(ProofsRouteGroup, p.FetchLiabilityProof))
+}
+
+func (p *proofHandler) FetchLiabilitySnapshots(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchLiabilitySnapshotsRequest](r)
+
+	res, err := p.proofService.FetchLiabilitySnapshots(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	writePagination(w, res.Pagination)
+	utils.JSON(w, 200, res)
+}
+
+func (p *proofHandler) FetchLiabilitySnapshot(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchLiabilitySnapshotRequest](r)
+
+	res, err := p.proofService.FetchLiabilitySnapshot(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
+
+func (p *proofHandler) FetchLiabilityProof(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchLiabilityProofRequest](r)
+
+	res, err := p.proofService.FetchLiabilityProof(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/2HgO/quidax-go/config"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/utils"
+)
+
+type RouteGroup string
+
+const (
+	AccountsRouteGroup     RouteGroup = "accounts"
+	WalletsRouteGroup      RouteGroup = "wallets"
+	SwapsRouteGroup        RouteGroup = "swaps"
+	WithdrawalsRouteGroup  RouteGroup = "withdrawals"
+	DepositsRouteGroup     RouteGroup = "deposits"
+	MarketsRouteGroup      RouteGroup = "markets"
+	TransactionsRouteGroup RouteGroup = "transactions"
+	ProofsRouteGroup       RouteGroup = "proofs"
+	AdminRouteGroup        RouteGroup = "admin"
+)
+
+// idle buckets are dropped so that limit changes are picked up and memory is reclaimed
+const rateLimitSweepInterval = time.Minute
+
+type rateLimiter struct {
+	// limits applied to tokens without their own limits for the route group
+	defaults map[RouteGroup]models.RateLimit
+	limits   func(context.Context, string) ([]*models.RateLimit, error)
+
+	mu        sync.Mutex
+	buckets   map[string]*utils.TokenBucket
+	lastSweep time.Time
+}
+
+func newRateLimiter(cfg config.RateLimits, limits func(context.Context, string) ([]*models.RateLimit, error)) *rateLimiter {
+	defaults := make(map[RouteGroup]models.RateLimit, len(cfg.Groups))
+	for group, limit := range cfg.Groups {
+		defaults[RouteGroup(group)] = models.RateLimit{RouteGroup: group, Rate: limit.Rate, Burst: limit.Burst}
+	}
+	return &rateLimiter{
+		defaults:  defaults,
+		limits:    limits,
+		buckets:   map[string]*utils.TokenBucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	for key, bucket := range l.buckets {
+		if bucket.Idle(now) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// bucket returns the bucket for the client in the route group, token limits are looked up for
+// authenticated clients when the bucket is first created
+func (l *rateLimiter) bucket(ctx context.Context, client string, token string, group RouteGroup) (*utils.TokenBucket, error) {
+	key := client + "|" + string(group)
+
+	l.mu.Lock()
+	l.sweep(time.Now())
+	bucket, ok := l.buckets[key]
+	l.mu.Unlock()
+	if ok {
+		return bucket, nil
+	}
+
+	limit := l.defaults[group]
+	if token != "" {
+		limits, err := l.limits(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		for _, tokenLimit := range limits {
+			switch tokenLimit.RouteGroup {
+			case string(group):
+				limit = *tokenLimit
+			case "*":
+				if limit.AccessTokenID == "" {
+					limit = *tokenLimit
+				}
+			}
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if bucket, ok = l.buckets[key]; !ok {
+		bucket = utils.NewTokenBucket(limit.Rate, limit.Burst)
+		l.buckets[key] = bucket
+	}
+	return bucket, nil
+}
+
+func (m *middlewareHandler) rateLimit(group RouteGroup) utils.MW {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			token := strings.TrimPrefix(r.Header.Get("authorization"), "Bearer ")
+			client := token
+			if _, ok := models.PrincipalFromContext(r.Context()); !ok {
+				// unauthenticated routes are limited per client address
+				token = ""
+				client, _, _ = net.SplitHostPort(r.RemoteAddr)
+			}
+
+			bucket, err := m.limiter.bucket(r.Context(), client, token, group)
+			if err != nil {
+				errors.AsAppError(err).Serialize(w)
+				return
+			}
+
+			ok, state := bucket.Take(time.Now())
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(state.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(state.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(state.Reset.Seconds()))))
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(state.RetryAfter.Seconds()))))
+				errors.NewRateLimitedError(state.RetryAfter).Serialize(w)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/openapi"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// Spec has an entry for every route the handlers register, the openapi document is generated from it.
+// TestOpenAPISpec fails when a route has no entry or an entry has no route
+var Spec = []openapi.Route{
+	// accounts
+	{
+		Method: "POST", Path: "/api/v1/accounts", ID: "createAccount", Tag: "Accounts",
+		Summary:   "create a main account with its wallets and access tokens",
+		Request:   requests.CreateAccountRequest{},
+		Responses: map[int]any{201: responses.Response[*responses.CreateAccountResponseData]{}},
+	},
+	{
+		Method: "PUT", Path: "/api/v1/accounts", ID: "updateWebhookURL", Tag: "Accounts", Auth: openapi.AccessTokenAuth,
+		Summary:   "set the callback url and key webhook events are delivered with",
+		Request:   requests.UpdateWebhookURLRequest{},
+		Responses: map[int]any{204: nil},
+	},
+	{
+		Method: "POST", Path: "/api/v1/users", ID: "createSubAccount", Tag: "Accounts", Auth: openapi.AccessTokenAuth,
+		Summary:   "create a sub-account",
+		Request:   requests.CreateSubAccountRequest{},
+		Responses: map[int]any{201: responses.Response[*models.Account]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users", ID: "fetchAllSubAccounts", Tag: "Accounts", Auth: openapi.AccessTokenAuth,
+		Summary:   "list the sub-accounts",
+		Request:   requests.FetchAllSubAccountsRequest{},
+		Responses: map[int]any{200: responses.Response[[]*models.Account]{}},
+	},
+	{
+		Method: "PUT", Path: "/api/v1/users/{user_id}", ID: "editSubAccountDetails", Tag: "Accounts", Auth: openapi.AccessTokenAuth,
+		Summary:   "edit a sub-account's details",
+		Request:   requests.EditSubAccountDetailsRequest{},
+		Responses: map[int]any{200: responses.Response[*models.Account]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}", ID: "fetchAccountDetails", Tag: "Accounts", Auth: openapi.AccessTokenAuth,
+		Summary:   "fetch an account, `me` is the main account",
+		Request:   requests.FetchAccountDetailsRequest{},
+		Responses: map[int]any{200: responses.Response[*models.Account]{}},
+	},
+	{
+		Method: "POST", Path: "/api/v1/users/{user_id}/freeze", ID: "freezeSubAccount", Tag: "Accounts", Auth: openapi.AccessTokenAuth,
+		Summary:   "freeze a sub-account",
+		Request:   requests.FreezeSubAccountRequest{},
+		Responses: map[int]any{200: responses.Response[*models.Account]{}},
+	},
+	{
+		Method: "POST", Path: "/api/v1/users/{user_id}/unfreeze", ID: "unfreezeSubAccount", Tag: "Accounts", Auth: openapi.AccessTokenAuth,
+		Summary:   "unfreeze a sub-account",
+		Request:   requests.FreezeSubAccountRequest{},
+		Responses: map[int]any{200: responses.Response[*models.Account]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/limits", ID: "fetchUserLimits", Tag: "Accounts", Auth: openapi.AccessTokenAuth,
+		Summary:   "list an account's transaction limits and their usage",
+		Request:   requests.FetchUserLimitsRequest{},
+		Responses: map[int]any{200: responses.Response[[]*responses.UserLimitResponseData]{}},
+	},
+	{
+		Method: "PUT", Path: "/api/v1/users/{user_id}/sub_account_limits", ID: "setSubAccountLimit", Tag: "Accounts", Auth: openapi.AccessTokenAuth,
+		Summary:   "set the limit a main account's sub-accounts of a kyc tier get in place of the tier's default",
+		Request:   requests.SetSubAccountLimitRequest{},
+		Responses: map[int]any{200: responses.Response[*responses.SubAccountLimitResponseData]{}},
+	},
+
+	// kyc
+	{
+		Method: "POST", Path: "/api/v1/users/{user_id}/kyc", ID: "submitKYC", Tag: "KYC", Auth: openapi.AccessTokenAuth,
+		Summary:   "submit a sub-account's kyc details for a tier",
+		Request:   requests.SubmitKYCRequest{},
+		Responses: map[int]any{201: responses.Response[*models.KYCSubmission]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/kyc", ID: "fetchKYCSubmissions", Tag: "KYC", Auth: openapi.AccessTokenAuth,
+		Summary:   "list a sub-account's kyc submissions",
+		Request:   requests.FetchKYCSubmissionsRequest{},
+		Responses: map[int]any{200: responses.Response[[]*models.KYCSubmission]{}},
+	},
+	{
+		Method: "POST", Path: "/api/v1/users/{user_id}/kyc/{submission_id}/review", ID: "reviewKYCSubmission", Tag: "KYC", Auth: openapi.AccessTokenAuth,
+		Summary:   "approve or reject a pending kyc submission",
+		Request:   requests.ReviewKYCSubmissionRequest{},
+		Responses: map[int]any{200: responses.Response[*models.KYCSubmission]{}},
+	},
+
+	// wallets
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/wallets", ID: "fetchUserWallets", Tag: "Wallets", Auth: openapi.AccessTokenAuth,
+		Summary:   "list an account's wallets",
+		Request:   requests.FetchUserWalletsRequest{},
+		Responses: map[int]any{200: responses.Response[[]*responses.UserWalletResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/wallets/{currency}", ID: "fetchUserWallet", Tag: "Wallets", Auth: openapi.AccessTokenAuth,
+		Summary:   "fetch an account's wallet",
+		Request:   requests.FetchUserWalletRequest{},
+		Responses: map[int]any{200: responses.Response[*responses.UserWalletResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/wallets/{currency}/address", ID: "fetchPaymentAddress", Tag: "Wallets", Auth: openapi.AccessTokenAuth,
+		Summary:   "fetch a wallet's deposit address, deposits to addresses are not supported",
+		Request:   requests.FetchUserWalletRequest{},
+		Responses: map[int]any{200: responses.Response[map[string]any]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/wallets/{currency}/addresses", ID: "fetchPaymentAddresses", Tag: "Wallets", Auth: openapi.AccessTokenAuth,
+		Summary:   "list a wallet's deposit addresses, deposits to addresses are not supported",
+		Request:   requests.FetchUserWalletRequest{},
+		Responses: map[int]any{200: responses.Response[[]map[string]any]{}},
+	},
+	{
+		Method: "POST", Path: "/api/v1/users/{user_id}/wallets/{currency}/freeze", ID: "freezeUserWallet", Tag: "Wallets", Auth: openapi.AccessTokenAuth,
+		Summary:   "freeze a wallet",
+		Request:   requests.FreezeUserWalletRequest{},
+		Responses: map[int]any{200: responses.Response[*responses.UserWalletResponseData]{}},
+	},
+	{
+		Method: "POST", Path: "/api/v1/users/{user_id}/wallets/{currency}/unfreeze", ID: "unfreezeUserWallet", Tag: "Wallets", Auth: openapi.AccessTokenAuth,
+		Summary:   "unfreeze a wallet",
+		Request:   requests.FreezeUserWalletRequest{},
+		Responses: map[int]any{200: responses.Response[*responses.UserWalletResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/wallets/{currency}/balances", ID: "fetchWalletBalance", Tag: "Wallets", Auth: openapi.AccessTokenAuth,
+		Summary:   "fetch a wallet's balance at a time or before a transaction",
+		Request:   requests.FetchWalletBalanceRequest{},
+		Responses: map[int]any{200: responses.Response[*responses.WalletBalanceResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/wallets/{currency}/balances/history", ID: "fetchWalletBalanceHistory", Tag: "Wallets", Auth: openapi.AccessTokenAuth,
+		Summary:   "list a wallet's balances after each of its transfers, newest first",
+		Request:   requests.FetchWalletBalanceHistoryRequest{},
+		Responses: map[int]any{200: responses.Response[[]*responses.WalletBalanceResponseData]{}},
+	},
+
+	// deposits
+	{
+		Method: "POST", Path: "/api/v1/users/{user_id}/deposits/{currency}", ID: "depositAmount", Tag: "Deposits", Auth: openapi.AccessTokenAuth,
+		Summary:   "deposit an amount into a wallet, test environment only",
+		Request:   requests.DepositAmountRequest{},
+		Responses: map[int]any{201: responses.Response[*responses.DepositResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/deposits", ID: "fetchDeposits", Tag: "Deposits", Auth: openapi.AccessTokenAuth,
+		Summary:   "list an account's deposits",
+		Request:   requests.FetchDepositsRequest{},
+		Responses: map[int]any{200: responses.Response[[]*responses.DepositResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/deposits/currency/{currency}", ID: "fetchCurrencyDeposits", Tag: "Deposits", Auth: openapi.AccessTokenAuth,
+		Summary:   "list an account's deposits in a currency",
+		Request:   requests.FetchDepositsRequest{},
+		Responses: map[int]any{200: responses.Response[[]*responses.DepositResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/deposits/{transaction_id}", ID: "fetchDeposit", Tag: "Deposits", Auth: openapi.AccessTokenAuth,
+		Summary:   "fetch a deposit",
+		Request:   requests.FetchDepositRequest{},
+		Responses: map[int]any{200: responses.Response[*responses.DepositResponseData]{}},
+	},
+
+	// swaps
+	{
+		Method: "POST", Path: "/api/v1/users/{user_id}/temporary_swap_quotation", ID: "temporaryInstantSwapQuotation", Tag: "Swaps", Auth: openapi.AccessTokenAuth,
+		Summary:   "quote a swap without holding funds",
+		Request:   requests.CreateInstantSwapRequest{},
+		Responses: map[int]any{200: responses.Response[*responses.QuoteInstantSwapResponseData]{}},
+	},
+	{
+		Method: "POST", Path: "/api/v1/users/{user_id}/swap_quotation", ID: "createInstantSwap", Tag: "Swaps", Auth: openapi.AccessTokenAuth,
+		Summary:   "quote a swap, holding the amount until the quotation is confirmed or expires",
+		Request:   requests.CreateInstantSwapRequest{},
+		Responses: map[int]any{201: responses.Response[*responses.InstantSwapQuotationResponseData]{}},
+	},
+	{
+		Method: "POST", Path: "/api/v1/users/{user_id}/swap_quotation/{quotation_id}/confirm", ID: "confirmInstantSwap", Tag: "Swaps", Auth: openapi.AccessTokenAuth,
+		Summary:   "confirm a quotation, the swap is processed in the background",
+		Request:   requests.ConfirmInstanSwapRequest{},
+		Responses: map[int]any{200: responses.Response[*responses.InstantSwapResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/swap_transactions/{swap_transaction_id}", ID: "fetchInstantSwapTransaction", Tag: "Swaps", Auth: openapi.AccessTokenAuth,
+		Summary:   "fetch a swap",
+		Request:   requests.FetchInstantSwapTransactionRequest{},
+		Responses: map[int]any{200: responses.Response[*responses.InstantSwapResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/swap_transactions", ID: "getInstantSwapTransactions", Tag: "Swaps", Auth: openapi.AccessTokenAuth,
+		Summary:   "list an account's swaps",
+		Request:   requests.GetInstantSwapTransactionsRequest{},
+		Responses: map[int]any{200: responses.Response[[]*responses.InstantSwapResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/markets/tickers/{market}", ID: "fetchMarketTicker", Tag: "Markets", Auth: openapi.AccessTokenAuth,
+		Summary:   "fetch the ticker of a market such as `usdtngn`",
+		Responses: map[int]any{200: map[string]any{}},
+	},
+
+	// withdrawals
+	{
+		Method: "POST", Path: "/api/v1/users/{user_id}/withdraws", ID: "createWithdrawal", Tag: "Withdrawals", Auth: openapi.AccessTokenAuth,
+		Summary:   "send funds to another account",
+		Request:   requests.CreateWithdrawalRequest{},
+		Responses: map[int]any{201: responses.Response[*responses.WithdrawalResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/withdraws", ID: "fetchWithdrawals", Tag: "Withdrawals", Auth: openapi.AccessTokenAuth,
+		Summary:   "list an account's withdrawals",
+		Request:   requests.FetchWithdrawalsRequest{},
+		Responses: map[int]any{200: responses.Response[[]*responses.WithdrawalResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/withdraws/reference/{reference}", ID: "fetchWithdrawalByRef", Tag: "Withdrawals", Auth: openapi.AccessTokenAuth,
+		Summary:   "fetch a withdrawal by its reference",
+		Request:   requests.FetchWithdrawalRequest{},
+		Responses: map[int]any{200: responses.Response[*responses.WithdrawalResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/withdraws/{withdrawal_id}", ID: "fetchWithdrawal", Tag: "Withdrawals", Auth: openapi.AccessTokenAuth,
+		Summary:   "fetch a withdrawal",
+		Request:   requests.FetchWithdrawalRequest{},
+		Responses: map[int]any{200: responses.Response[*responses.WithdrawalResponseData]{}},
+	},
+
+	// transactions
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/transactions", ID: "fetchTransactions", Tag: "Transactions", Auth: openapi.AccessTokenAuth,
+		Summary:   "list the ledger transfers of an account's wallets, newest first",
+		Request:   requests.FetchTransactionsRequest{},
+		Responses: map[int]any{200: responses.Response[[]*responses.TransactionResponseData]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/statements", ID: "exportStatement", Tag: "Transactions", Auth: openapi.AccessTokenAuth,
+		Summary: "export a statement, large or async exports are made in the background",
+		Request: requests.ExportStatementRequest{},
+		Responses: map[int]any{
+			200: openapi.File{"text/csv", "application/jsonl"},
+			202: responses.Response[*models.StatementExport]{},
+		},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/statements/{export_id}", ID: "fetchStatementExport", Tag: "Transactions", Auth: openapi.AccessTokenAuth,
+		Summary:   "fetch a statement export",
+		Request:   requests.FetchStatementExportRequest{},
+		Responses: map[int]any{200: responses.Response[*models.StatementExport]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/statements/{export_id}/download", ID: "downloadStatementExport", Tag: "Transactions", Auth: openapi.AccessTokenAuth,
+		Summary:   "download a completed statement export",
+		Request:   requests.FetchStatementExportRequest{},
+		Responses: map[int]any{200: openapi.File{"text/csv", "application/jsonl"}},
+	},
+
+	// proofs
+	{
+		Method: "GET", Path: "/api/v1/proofs", ID: "fetchLiabilitySnapshots", Tag: "Proofs",
+		Summary:   "list the published liability snapshots",
+		Request:   requests.FetchLiabilitySnapshotsRequest{},
+		Responses: map[int]any{200: responses.Response[[]*models.LiabilitySnapshot]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/proofs/{snapshot_id}", ID: "fetchLiabilitySnapshot", Tag: "Proofs",
+		Summary:   "fetch a published liability snapshot",
+		Request:   requests.FetchLiabilitySnapshotRequest{},
+		Responses: map[int]any{200: responses.Response[*models.LiabilitySnapshot]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/users/{user_id}/proofs/{snapshot_id}", ID: "fetchLiabilityProof", Tag: "Proofs", Auth: openapi.AccessTokenAuth,
+		Summary:   "fetch the inclusion proofs of an account's balances in a snapshot",
+		Request:   requests.FetchLiabilityProofRequest{},
+		Responses: map[int]any{200: responses.Response[*responses.LiabilityProofResponseData]{}},
+	},
+
+	// admin
+	{
+		Method: "POST", Path: "/api/v1/admin/reconciliations", ID: "startReconciliation", Tag: "Admin", Auth: openapi.AdminTokenAuth,
+		Summary:   "start a reconciliation of the data database against the ledger",
+		Responses: map[int]any{202: responses.Response[*models.ReconciliationReport]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/admin/reconciliations", ID: "fetchReconciliationReports", Tag: "Admin", Auth: openapi.AdminTokenAuth,
+		Summary:   "list reconciliation reports",
+		Request:   requests.FetchReconciliationReportsRequest{},
+		Responses: map[int]any{200: responses.Response[[]*models.ReconciliationReport]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/admin/reconciliations/{reconciliation_id}", ID: "fetchReconciliationReport", Tag: "Admin", Auth: openapi.AdminTokenAuth,
+		Summary:   "fetch a reconciliation report",
+		Request:   requests.FetchReconciliationReportRequest{},
+		Responses: map[int]any{200: responses.Response[*models.ReconciliationReport]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/admin/ledgers", ID: "fetchLedgerPositions", Tag: "Admin", Auth: openapi.AdminTokenAuth,
+		Summary:   "list the house's position on every ledger",
+		Request:   requests.FetchLedgerPositionsRequest{},
+		Responses: map[int]any{200: responses.Response[[]*responses.LedgerPositionResponseData]{}},
+	},
+	{
+		Method: "POST", Path: "/api/v1/admin/proofs", ID: "startLiabilitySnapshot", Tag: "Admin", Auth: openapi.AdminTokenAuth,
+		Summary:   "start a liability snapshot of an environment",
+		Request:   requests.StartLiabilitySnapshotRequest{},
+		Responses: map[int]any{202: responses.Response[*models.LiabilitySnapshot]{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/admin/proofs/{snapshot_id}", ID: "adminFetchLiabilitySnapshot", Tag: "Admin", Auth: openapi.AdminTokenAuth,
+		Summary:   "fetch a liability snapshot, including running and failed ones",
+		Request:   requests.FetchLiabilitySnapshotRequest{},
+		Responses: map[int]any{200: responses.Response[*models.LiabilitySnapshot]{}},
+	},
+	{
+		Method: "PUT", Path: "/api/v1/admin/tokens/{token_id}/rate_limits", ID: "setTokenRateLimit", Tag: "Admin", Auth: openapi.AdminTokenAuth,
+		Summary:   "set an access token's rate limit for a route group in place of the group's default",
+		Request:   requests.SetTokenRateLimitRequest{},
+		Responses: map[int]any{200: responses.Response[*models.RateLimit]{}},
+	},
+
+	// docs
+	{
+		Method: "GET", Path: "/api/v1/openapi.json", ID: "fetchOpenAPIDocument", Tag: "Docs",
+		Summary:   "fetch this document",
+		Responses: map[int]any{200: map[string]any{}},
+	},
+	{
+		Method: "GET", Path: "/api/v1/docs", ID: "fetchDocs", Tag: "Docs",
+		Summary:   "browse this document",
+		Responses: map[int]any{200: openapi.File{"text/html"}},
+	},
+}
+
+// OpenAPIDocument generates the openapi document of the api from the spec
+func OpenAPIDocument() (*openapi.Document, error) {
+	return openapi.Build(openapi.Info{
+		Title:       "quidax-go",
+		Description: "A clone of the Quidax api for building and testing integrations against, amounts are decimal strings as they are on Quidax",
+		Version:     "v1",
+	}, Spec)
+}
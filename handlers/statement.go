@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/services"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/utils"
+	"go.uber.org/zap"
+)
+
+type StatementHandler interface {
+	ExportStatement(http.ResponseWriter, *http.Request)
+	FetchStatementExport(http.ResponseWriter, *http.Request)
+	DownloadStatementExport(http.ResponseWriter, *http.Request)
+
+	Handler
+}
+
+func NewStatementHandler(statementService services.StatementService, middlewares MiddleWareHandler, log *zap.Logger) StatementHandler {
+	return &statementHandler{
+		handler: handler{statementService: statementService, middlewares: middlewares, log: log},
+	}
+}
+
+type statementHandler struct {
+	handler
+}
+
+func (s *statementHandler) ServeHttp(mux Router) {
+	mux.HandleFunc("GET /api/v1/users/{user_id}/statements", s.middlewares.AttachValidateAccessToken(TransactionsRouteGroup, s.ExportStatement))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/statements/{export_id}", s.middlewares.AttachValidateAccessToken(TransactionsRouteGroup, s.FetchStatementExport))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/statements/{export_id}/download", s.middlewares.AttachValidateAccessToken(TransactionsRouteGroup, s.DownloadStatementExport))
+}
+
+func (s *statementHandler) ExportStatement(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.ExportStatementRequest](r)
+
+	res, write, err := s.statementService.ExportStatement(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+	if write == nil {
+		utils.JSON(w, 202, res)
+		return
+	}
+
+	w.Header().Set("Content-Type", req.Format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement.%s"`, req.Format))
+	w.WriteHeader(200)
+	if err = write(w); err != nil {
+		s.log.Error("writing statement", zap.Error(err))
+	}
+}
+
+func (s *statementHandler) FetchStatementExport(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchStatementExportRequest](r)
+
+	res, err := s.statementService.FetchStatementExport(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
+
+func (s *statementHandler) DownloadStatementExport(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchStatementExportRequest](r)
+
+	export, file, err := s.statementService.DownloadStatementExport(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", export.Format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%s.%s"`, export.ID, export.Format))
+	w.WriteHeader(200)
+	if _, err = io.Copy(w, file); err != nil {
+		s.log.Error("writing statement export", zap.Error(err))
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/services"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/utils"
+	"go.uber.org/zap"
+)
+
+type TransactionHandler interface {
+	FetchTransactions(http.ResponseWriter, *http.Request)
+
+	Handler
+}
+
+func NewTransactionHandler(transactionService services.TransactionService, middlewares MiddleWareHandler, log *zap.Logger) TransactionHandler {
+	return &transactionHandler{
+		handler: handler{transactionService: transactionService, middlewares: middlewares, log: log},
+	}
+}
+
+type transactionHandler struct {
+	handler
+}
+
+func (t *transactionHandler) ServeHttp(mux Router) {
+	mux.HandleFunc("GET /api/v1/users/{user_id}/transactions", t.middlewares.AttachValidateAccessToken(TransactionsRouteGroup, t.FetchTransactions))
+}
+
+func (t *transactionHandler) FetchTransactions(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchTransactionsRequest](r)
+
+	res, err := t.transactionService.FetchTransactions(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	writePagination(w, res.Pagination)
+	utils.JSON(w, 200, res)
+}
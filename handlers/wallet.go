@@ -14,6 +14,10 @@ import (
 type WalletHandler interface {
 	FetchUserWallet(http.ResponseWriter, *http.Request)
 	FetchUserWallets(http.ResponseWriter, *http.Request)
+	FreezeUserWallet(http.ResponseWriter, *http.Request)
+	UnfreezeUserWallet(http.ResponseWriter, *http.Request)
+	FetchWalletBalance(http.ResponseWriter, *http.Request)
+	FetchWalletBalanceHistory(http.ResponseWriter, *http.Request)
 
 	Handler
 }
@@ -28,11 +32,15 @@ type walletHandler struct {
 	handler
 }
 
-func (ws *walletHandler) ServeHttp(mux *http.ServeMux) {
-	mux.HandleFunc("GET /api/v1/users/{user_id}/wallets", ws.middlewares.AttachValidateAccessToken(ws.FetchUserWallets))
-	mux.HandleFunc("GET /api/v1/users/{user_id}/wallets/{currency}", ws.middlewares.AttachValidateAccessToken(ws.FetchUserWallet))
-	mux.HandleFunc("GET /api/v1/users/{user_id}/wallets/{currency}/address", ws.middlewares.AttachValidateAccessToken(ws.FetchPaymentAddress))
-	mux.HandleFunc("GET /api/v1/users/{user_id}/wallets/{currency}/addresses", ws.middlewares.AttachValidateAccessToken(ws.FetchPaymentAddresses))
+func (ws *walletHandler) ServeHttp(mux Router) {
+	mux.HandleFunc("GET /api/v1/users/{user_id}/wallets", ws.middlewares.AttachValidateAccessToken(WalletsRouteGroup, ws.FetchUserWallets))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/wallets/{currency}", ws.middlewares.AttachValidateAccessToken(WalletsRouteGroup, ws.FetchUserWallet))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/wallets/{currency}/address", ws.middlewares.AttachValidateAccessToken(WalletsRouteGroup, ws.FetchPaymentAddress))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/wallets/{currency}/addresses", ws.middlewares.AttachValidateAccessToken(WalletsRouteGroup, ws.FetchPaymentAddresses))
+	mux.HandleFunc("POST /api/v1/users/{user_id}/wallets/{currency}/freeze", ws.middlewares.AttachValidateAccessToken(WalletsRouteGroup, ws.FreezeUserWallet))
+	mux.HandleFunc("POST /api/v1/users/{user_id}/wallets/{currency}/unfreeze", ws.middlewares.AttachValidateAccessToken(WalletsRouteGroup, ws.UnfreezeUserWallet))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/wallets/{currency}/balances", ws.middlewares.AttachValidateAccessToken(WalletsRouteGroup, ws.FetchWalletBalance))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/wallets/{currency}/balances/history", ws.middlewares.AttachValidateAccessToken(WalletsRouteGroup, ws.FetchWalletBalanceHistory))
 }
 
 func (ws *walletHandler) FetchPaymentAddress(w http.ResponseWriter, r *http.Request) {
@@ -103,6 +111,30 @@ func (ws *walletHandler) FetchUserWallets(w http.ResponseWriter, r *http.Request
 	utils.JSON(w, 200, res)
 }
 
+func (ws *walletHandler) FreezeUserWallet(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FreezeUserWalletRequest](r)
+
+	res, err := ws.walletService.FreezeUserWallet(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
+
+func (ws *walletHandler) UnfreezeUserWallet(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FreezeUserWalletRequest](r)
+
+	res, err := ws.walletService.UnfreezeUserWallet(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
+
 func (ws *walletHandler) FetchWalletAddress(w http.ResponseWriter, r *http.Request) {
 	req := utils.Bind[requests.FetchUserWalletRequest](r)
 
@@ -125,3 +157,28 @@ func (ws *walletHandler) FetchWalletAddress(w http.ResponseWriter, r *http.Reque
 		},
 	})
 }
+
+func (ws *walletHandler) FetchWalletBalance(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchWalletBalanceRequest](r)
+
+	res, err := ws.walletService.FetchWalletBalance(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	utils.JSON(w, 200, res)
+}
+
+func (ws *walletHandler) FetchWalletBalanceHistory(w http.ResponseWriter, r *http.Request) {
+	req := utils.Bind[requests.FetchWalletBalanceHistoryRequest](r)
+
+	res, err := ws.walletService.FetchWalletBalanceHistory(r.Context(), req)
+	if err != nil {
+		errors.AsAppError(err).Serialize(w)
+		return
+	}
+
+	writePagination(w, res.Pagination)
+	utils.JSON(w, 200, res)
+}
@@ -29,11 +29,11 @@ type withdrawalHandler struct {
 	handler
 }
 
-func (wd *withdrawalHandler) ServeHttp(mux *http.ServeMux) {
-	mux.HandleFunc("POST /api/v1/users/{user_id}/withdraws", wd.middlewares.AttachValidateAccessToken(wd.CreateWithdrawal))
-	mux.HandleFunc("GET /api/v1/users/{user_id}/withdraws", wd.middlewares.AttachValidateAccessToken(wd.FetchWithdrawals))
-	mux.HandleFunc("GET /api/v1/users/{user_id}/withdraws/reference/{reference}", wd.middlewares.AttachValidateAccessToken(wd.FetchWithdrawalByRef))
-	mux.HandleFunc("GET /api/v1/users/{user_id}/withdraws/{withdrawal_id}", wd.middlewares.AttachValidateAccessToken(wd.FetchWithdrawal))
+func (wd *withdrawalHandler) ServeHttp(mux Router) {
+	mux.HandleFunc("POST /api/v1/users/{user_id}/withdraws", wd.middlewares.AttachValidateAccessToken(WithdrawalsRouteGroup, wd.CreateWithdrawal))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/withdraws", wd.middlewares.AttachValidateAccessToken(WithdrawalsRouteGroup, wd.FetchWithdrawals))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/withdraws/reference/{reference}", wd.middlewares.AttachValidateAccessToken(WithdrawalsRouteGroup, wd.FetchWithdrawalByRef))
+	mux.HandleFunc("GET /api/v1/users/{user_id}/withdraws/{withdrawal_id}", wd.middlewares.AttachValidateAccessToken(WithdrawalsRouteGroup, wd.FetchWithdrawal))
 }
 
 func (wd *withdrawalHandler) CreateWithdrawal(w http.ResponseWriter, r *http.Request) {
@@ -79,5 +79,6 @@ func (wd *withdrawalHandler) FetchWithdrawals(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	writePagination(w, res.Pagination)
 	utils.JSON(w, 200, res)
 }
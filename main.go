@@ -1,9 +1,12 @@
 package main
 
 import (
-	"net/http"
+	"os"
 
+	"github.com/2HgO/quidax-go/config"
 	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/db/migrations"
+	"github.com/2HgO/quidax-go/fixtures"
 	"github.com/2HgO/quidax-go/handlers"
 	"github.com/2HgO/quidax-go/services"
 	"github.com/madflojo/tasks"
@@ -12,7 +15,12 @@ import (
 )
 
 func main() {
-	fx.New(
+	os.Exit(run(newApp(), os.Args[1:]))
+}
+
+// newApp provides every component of the application, commands pick the ones they need
+func newApp() fx.Option {
+	return fx.Options(
 		fx.Provide(
 			NewHttpServer,
 			fx.Annotate(
@@ -44,6 +52,36 @@ func main() {
 				fx.As(new(handlers.Handler)),
 				fx.ResultTags(`group:"handlers"`),
 			),
+			fx.Annotate(
+				handlers.NewKYCHandler,
+				fx.As(new(handlers.Handler)),
+				fx.ResultTags(`group:"handlers"`),
+			),
+			fx.Annotate(
+				handlers.NewTransactionHandler,
+				fx.As(new(handlers.Handler)),
+				fx.ResultTags(`group:"handlers"`),
+			),
+			fx.Annotate(
+				handlers.NewProofHandler,
+				fx.As(new(handlers.Handler)),
+				fx.ResultTags(`group:"handlers"`),
+			),
+			fx.Annotate(
+				handlers.NewStatementHandler,
+				fx.As(new(handlers.Handler)),
+				fx.ResultTags(`group:"handlers"`),
+			),
+			fx.Annotate(
+				handlers.NewAdminHandler,
+				fx.As(new(handlers.Handler)),
+				fx.ResultTags(`group:"handlers"`),
+			),
+			fx.Annotate(
+				handlers.NewOpenAPIHandler,
+				fx.As(new(handlers.Handler)),
+				fx.ResultTags(`group:"handlers"`),
+			),
 			handlers.NewMiddlewareHandler,
 			services.NewInstantSwapService,
 			services.NewDepositService,
@@ -52,11 +90,26 @@ func main() {
 			services.NewWebhookService,
 			services.NewSchedulerService,
 			services.NewAccountService,
+			services.NewKYCService,
+			services.NewLimitService,
+			services.NewTransactionService,
+			services.NewStatementService,
+			services.NewReconciliationService,
+			services.NewLedgerService,
+			services.NewProofService,
+			services.NewIdempotencyService,
+			services.NewSagaService,
+			services.NewSystemClock,
+			services.NewLocalKYCVerifier,
+			services.NewAuthorizationService,
+			NewDataRepositories,
+			migrations.NewMigrator,
+			fixtures.NewSeeder,
 			db.GetDataDBConnection,
 			db.GetTxDBConnection,
 			tasks.New,
+			config.Load,
 			zap.NewProduction,
 		),
-		fx.Invoke(func(*http.Server) {}),
-	).Run()
+	)
 }
@@ -0,0 +1,229 @@
+// Package merkle builds merkle sum trees over wallet balances and verifies inclusion proofs against their
+// roots. It only depends on the standard library so proofs can be checked outside of this service
+package merkle
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+const (
+	leafPrefix byte = 0
+	nodePrefix byte = 1
+	// sums are hashed as 128 bit big endian integers, the size of a ledger amount
+	sumSize = 16
+)
+
+var maxSum = new(big.Int).Lsh(big.NewInt(1), sumSize*8)
+
+type Hash [sha256.Size]byte
+
+func (h Hash) String() string {
+	return hex.EncodeToString(h[:])
+}
+
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+func (h *Hash) UnmarshalText(input []byte) error {
+	decoded, err := hex.DecodeString(string(input))
+	if err != nil || len(decoded) != len(h) {
+		return fmt.Errorf("invalid hash %q", input)
+	}
+	copy(h[:], decoded)
+	return nil
+}
+
+// Node is a node of a merkle sum tree, Sum is the total balance of the leaves below it
+type Node struct {
+	Hash Hash     `json:"hash"`
+	Sum  *big.Int `json:"sum"`
+}
+
+// Leaf is a wallet's balance in a snapshot, the nonce keeps the leaf's hash from being matched with a guessed
+// user and balance
+type Leaf struct {
+	UserID   string   `json:"user_id"`
+	WalletID string   `json:"wallet_id"`
+	Nonce    string   `json:"nonce"`
+	Balance  *big.Int `json:"balance"`
+}
+
+// Node hashes the leaf, the balance must fit in 128 bits
+func (l Leaf) Node() (Node, error) {
+	nonce, err := hex.DecodeString(l.Nonce)
+	if err != nil {
+		return Node{}, fmt.Errorf("invalid nonce %q", l.Nonce)
+	}
+	balance, err := encodeSum(l.Balance)
+	if err != nil {
+		return Node{}, err
+	}
+
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	writeField(h, nonce)
+	writeField(h, []byte(l.UserID))
+	writeField(h, []byte(l.WalletID))
+	h.Write(balance)
+
+	node := Node{Sum: new(big.Int).Set(l.Balance)}
+	h.Sum(node.Hash[:0])
+	return node, nil
+}
+
+// Step is a sibling on the path from a leaf to the root, Left is set when the sibling is the left child
+type Step struct {
+	Node
+	Left bool `json:"left"`
+}
+
+// Proof shows that a leaf is included in the tree with the given root
+type Proof struct {
+	Leaf Leaf   `json:"leaf"`
+	Path []Step `json:"path"`
+	Root Node   `json:"root"`
+}
+
+// Tree holds every level of a merkle sum tree, the leaves first. A level with an odd number of nodes moves
+// its last node up unchanged
+type Tree struct {
+	leaves []Leaf
+	levels [][]Node
+}
+
+// Build hashes the leaves in order and builds the tree above them
+func Build(leaves []Leaf) (*Tree, error) {
+	if len(leaves) == 0 {
+		return nil, errors.New("no leaves")
+	}
+
+	level := make([]Node, 0, len(leaves))
+	for i, leaf := range leaves {
+		node, err := leaf.Node()
+		if err != nil {
+			return nil, fmt.Errorf("leaf %d: %w", i, err)
+		}
+		level = append(level, node)
+	}
+
+	tree := &Tree{leaves: leaves, levels: [][]Node{level}}
+	for len(level) > 1 {
+		next := make([]Node, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			node, err := parent(level[i], level[i+1])
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, node)
+		}
+		tree.levels = append(tree.levels, next)
+		level = next
+	}
+
+	return tree, nil
+}
+
+func (t *Tree) Root() Node {
+	return t.levels[len(t.levels)-1][0]
+}
+
+// Prove returns the inclusion proof of the leaf at index
+func (t *Tree) Prove(index int) (*Proof, error) {
+	if index < 0 || index >= len(t.leaves) {
+		return nil, fmt.Errorf("leaf %d out of range", index)
+	}
+
+	proof := &Proof{Leaf: t.leaves[index], Path: make([]Step, 0, len(t.levels)-1), Root: t.Root()}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		switch {
+		case index%2 == 1:
+			proof.Path = append(proof.Path, Step{Node: level[index-1], Left: true})
+		case index+1 < len(level):
+			proof.Path = append(proof.Path, Step{Node: level[index+1]})
+		}
+		index /= 2
+	}
+
+	return proof, nil
+}
+
+// Verify recomputes the root from the proof's leaf and path and checks it against the proof's root. Sums
+// are checked along with hashes, so a proof can not hide part of a balance or a negative sibling
+func Verify(proof *Proof) error {
+	node, err := proof.Leaf.Node()
+	if err != nil {
+		return err
+	}
+	for i, step := range proof.Path {
+		if step.Sum == nil || step.Sum.Sign() < 0 {
+			return fmt.Errorf("step %d: invalid sum", i)
+		}
+		if step.Left {
+			node, err = parent(step.Node, node)
+		} else {
+			node, err = parent(node, step.Node)
+		}
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i, err)
+		}
+	}
+
+	if node.Hash != proof.Root.Hash {
+		return errors.New("root hash does not match")
+	}
+	if proof.Root.Sum == nil || node.Sum.Cmp(proof.Root.Sum) != 0 {
+		return errors.New("root sum does not match")
+	}
+	return nil
+}
+
+func parent(left Node, right Node) (Node, error) {
+	leftSum, err := encodeSum(left.Sum)
+	if err != nil {
+		return Node{}, err
+	}
+	rightSum, err := encodeSum(right.Sum)
+	if err != nil {
+		return Node{}, err
+	}
+
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left.Hash[:])
+	h.Write(leftSum)
+	h.Write(right.Hash[:])
+	h.Write(rightSum)
+
+	node := Node{Sum: new(big.Int).Add(left.Sum, right.Sum)}
+	if _, err := encodeSum(node.Sum); err != nil {
+		return Node{}, err
+	}
+	h.Sum(node.Hash[:0])
+	return node, nil
+}
+
+func encodeSum(sum *big.Int) ([]byte, error) {
+	if sum == nil || sum.Sign() < 0 || sum.Cmp(maxSum) >= 0 {
+		return nil, errors.New("sum out of range")
+	}
+	return sum.FillBytes(make([]byte, sumSize)), nil
+}
+
+// writeField writes a length prefixed field so adjacent fields can not be shifted into one another
+func writeField(w io.Writer, field []byte) {
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(field)))
+	w.Write(size[:])
+	w.Write(field)
+}
@@ -0,0 +1,183 @@
+package merkle_test
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/2HgO/quidax-go/merkle"
+)
+
+func leaves(n int) []merkle.Leaf {
+	leaves := make([]merkle.Leaf, 0, n)
+	for i := range n {
+		leaves = append(leaves, merkle.Leaf{
+			UserID:   fmt.Sprintf("user-%d", i),
+			WalletID: fmt.Sprintf("wallet-%d", i),
+			Nonce:    fmt.Sprintf("%032x", i+1),
+			Balance:  big.NewInt(int64(100 * (i + 1))),
+		})
+	}
+	return leaves
+}
+
+func TestProofs(t *testing.T) {
+	// single leaves, even and odd counts, and counts whose odd node is carried up more than one level
+	for _, n := range []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 13} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			tree, err := merkle.Build(leaves(n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// balances are 100, 200, ... so the total is 100 * n(n+1)/2
+			if want := big.NewInt(int64(100 * n * (n + 1) / 2)); tree.Root().Sum.Cmp(want) != 0 {
+				t.Errorf("root sum is %s, want %s", tree.Root().Sum, want)
+			}
+
+			for i := range n {
+				proof, err := tree.Prove(i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if proof.Root != tree.Root() {
+					t.Errorf("proof of leaf %d has root %s, want %s", i, proof.Root.Hash, tree.Root().Hash)
+				}
+				if err = merkle.Verify(proof); err != nil {
+					t.Errorf("proof of leaf %d: %v", i, err)
+				}
+			}
+
+			if _, err = tree.Prove(n); err == nil {
+				t.Errorf("proving leaf %d of %d succeeded, want an error", n, n)
+			}
+		})
+	}
+}
+
+func TestSingleLeafProof(t *testing.T) {
+	tree, err := merkle.Build(leaves(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := leaves(1)[0].Node()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Root().Hash != leaf.Hash {
+		t.Errorf("root of a single leaf is %s, want the leaf's hash %s", tree.Root().Hash, leaf.Hash)
+	}
+
+	proof, err := tree.Prove(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proof.Path) != 0 {
+		t.Errorf("proof of a single leaf has %d steps, want none", len(proof.Path))
+	}
+}
+
+func TestVerifyRejectsTamperedProofs(t *testing.T) {
+	tree, err := merkle.Build(leaves(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		tamper func(*merkle.Proof)
+		err    string
+	}{
+		{
+			name:   "sibling sum raised",
+			tamper: func(p *merkle.Proof) { p.Path[0].Sum = new(big.Int).Add(p.Path[0].Sum, big.NewInt(1)) },
+			err:    "root hash does not match",
+		},
+		{
+			name:   "sibling hash changed",
+			tamper: func(p *merkle.Proof) { p.Path[1].Hash[0] ^= 0xff },
+			err:    "root hash does not match",
+		},
+		{
+			// a negative sibling would let the leaf's balance be left out of the root's sum
+			name:   "negative sibling",
+			tamper: func(p *merkle.Proof) { p.Path[0].Sum = new(big.Int).Neg(p.Path[0].Sum) },
+			err:    "step 0: invalid sum",
+		},
+		{
+			name:   "sibling without a sum",
+			tamper: func(p *merkle.Proof) { p.Path[1].Sum = nil },
+			err:    "step 1: invalid sum",
+		},
+		{
+			name:   "sibling side swapped",
+			tamper: func(p *merkle.Proof) { p.Path[0].Left = !p.Path[0].Left },
+			err:    "root hash does not match",
+		},
+		{
+			name:   "step dropped",
+			tamper: func(p *merkle.Proof) { p.Path = p.Path[:len(p.Path)-1] },
+			err:    "root hash does not match",
+		},
+		{
+			name:   "leaf balance lowered",
+			tamper: func(p *merkle.Proof) { p.Leaf.Balance = big.NewInt(1) },
+			err:    "root hash does not match",
+		},
+		{
+			name:   "leaf user changed",
+			tamper: func(p *merkle.Proof) { p.Leaf.UserID = "user-4" },
+			err:    "root hash does not match",
+		},
+		{
+			name:   "root sum changed",
+			tamper: func(p *merkle.Proof) { p.Root.Sum = new(big.Int).Sub(p.Root.Sum, big.NewInt(1)) },
+			err:    "root sum does not match",
+		},
+		{
+			name:   "invalid nonce",
+			tamper: func(p *merkle.Proof) { p.Leaf.Nonce = "not hex" },
+			err:    "invalid nonce",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			proof, err := tree.Prove(2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the path's nodes are shared with the tree, the proof is tampered with a copy of them
+			path := make([]merkle.Step, len(proof.Path))
+			for i, step := range proof.Path {
+				path[i] = merkle.Step{Node: merkle.Node{Hash: step.Hash, Sum: new(big.Int).Set(step.Sum)}, Left: step.Left}
+			}
+			proof.Path = path
+			proof.Root.Sum = new(big.Int).Set(proof.Root.Sum)
+
+			tc.tamper(proof)
+			err = merkle.Verify(proof)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("verifying the tampered proof failed with %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestBuildRejectsInvalidLeaves(t *testing.T) {
+	if _, err := merkle.Build(nil); err == nil {
+		t.Error("building a tree without leaves succeeded, want an error")
+	}
+
+	negative := leaves(3)
+	negative[1].Balance = big.NewInt(-1)
+	if _, err := merkle.Build(negative); err == nil {
+		t.Error("building a tree with a negative balance succeeded, want an error")
+	}
+
+	// balances and sums are hashed as 128 bit integers
+	overflow := leaves(2)
+	overflow[0].Balance = new(big.Int).Lsh(big.NewInt(1), 127)
+	overflow[1].Balance = new(big.Int).Lsh(big.NewInt(1), 127)
+	if _, err := merkle.Build(overflow); err == nil {
+		t.Error("building a tree whose sum overflows 128 bits succeeded, want an error")
+	}
+}
@@ -4,14 +4,20 @@ import "time"
 
 type Account struct {
 	// ? maybe change to uuid.UUID
-	ID          string     `json:"id"`
-	SN          string     `json:"sn,omitempty"`
-	DisplayName string     `json:"display_name"`
-	Email       string     `json:"email,omitempty"`
-	FirstName   string     `json:"first_name"`
-	LastName    string     `json:"last_name"`
-	CreatedAt   *time.Time `json:"created_at,omitempty"`
-	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	ID          string      `json:"id"`
+	SN          string      `json:"sn,omitempty"`
+	DisplayName string      `json:"display_name"`
+	Email       string      `json:"email,omitempty"`
+	FirstName   string      `json:"first_name"`
+	LastName    string      `json:"last_name"`
+	CreatedAt   *time.Time  `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time  `json:"updated_at,omitempty"`
+	Environment Environment `json:"environment"`
+	Frozen      bool        `json:"frozen"`
+	PhoneNumber *string     `json:"phone_number"`
+	DateOfBirth *time.Time  `json:"date_of_birth"`
+	Country     *string     `json:"country"`
+	KYCTier     KYCTier     `json:"kyc_tier"`
 
 	// internal fields
 	IsMainAccount bool    `json:"-"`
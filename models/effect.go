@@ -0,0 +1,33 @@
+package models
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Effects records whether the work done for a request has changed anything, a request that failed before it
+// did can safely be handled again
+type Effects struct {
+	applied atomic.Bool
+}
+
+// Applied reports whether anything has been changed for the request
+func (e *Effects) Applied() bool {
+	return e.applied.Load()
+}
+
+type effectsContextKey struct{}
+
+// ContextWithEffects returns a context that records the changes made with it in the returned effects
+func ContextWithEffects(ctx context.Context) (context.Context, *Effects) {
+	effects := &Effects{}
+	return context.WithValue(ctx, effectsContextKey{}, effects), effects
+}
+
+// RecordEffect marks the context's request as having changed something, it does nothing for a context
+// without effects
+func RecordEffect(ctx context.Context) {
+	if effects, ok := ctx.Value(effectsContextKey{}).(*Effects); ok {
+		effects.applied.Store(true)
+	}
+}
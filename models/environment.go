@@ -0,0 +1,49 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/2HgO/quidax-go/errors"
+)
+
+type Environment uint8
+
+const (
+	Test_Environment Environment = iota
+	Live_Environment
+)
+
+func (e Environment) String() string {
+	switch e {
+	case Test_Environment:
+		return "test"
+	case Live_Environment:
+		return "live"
+	default:
+		panic("unreachable")
+	}
+}
+
+// KeyPrefix is prepended to every secret key issued for the environment
+func (e Environment) KeyPrefix() string {
+	return "sec_" + e.String() + "_"
+}
+
+func (e *Environment) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "test":
+		*e = Test_Environment
+	case "live":
+		*e = Live_Environment
+	default:
+		return errors.NewValidationError("invalid environment")
+	}
+	return nil
+}
+
+func (e Environment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.String())
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// IdempotencyKey is a request an account made with an Idempotency-Key header, retries with the key are
+// answered with the stored response instead of being handled again
+type IdempotencyKey struct {
+	AccountID string
+	Key       string
+	// method, path and body hash of the request the key was first used with
+	Request string
+	// status and body of the response, both nil while the request is still being handled
+	StatusCode *int
+	Response   []byte
+	CreatedAt  time.Time
+	// until when the request handling the key holds it, a retry takes over a key whose lease has run out
+	// before it was completed
+	LeaseExpiresAt *time.Time
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type InstantSwap struct {
 	ID            string
 	QuotationID   string
@@ -11,4 +13,7 @@ type InstantSwap struct {
 	SwapTxID1     string
 	QuoteTxID0    string
 	QuoteTxID1    string
+	CreatedAt     time.Time
+	// SettledAt is when the swap was confirmed or its quotation reversed, nil while its holds are pending
+	SettledAt *time.Time
 }
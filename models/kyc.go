@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+)
+
+type KYCTier uint8
+
+const (
+	Tier0_KYCTier KYCTier = iota
+	Tier1_KYCTier
+	Tier2_KYCTier
+)
+
+type KYCStatus uint8
+
+const (
+	Pending_KYCStatus KYCStatus = iota
+	Approved_KYCStatus
+	Rejected_KYCStatus
+)
+
+func (k KYCStatus) String() string {
+	switch k {
+	case Pending_KYCStatus:
+		return "pending"
+	case Approved_KYCStatus:
+		return "approved"
+	case Rejected_KYCStatus:
+		return "rejected"
+	default:
+		panic("unreachable")
+	}
+}
+
+func (k *KYCStatus) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "pending":
+		*k = Pending_KYCStatus
+	case "approved":
+		*k = Approved_KYCStatus
+	case "rejected":
+		*k = Rejected_KYCStatus
+	default:
+		return errors.NewValidationError("invalid kyc status")
+	}
+	return nil
+}
+
+func (k KYCStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.String())
+}
+
+type KYCSubmission struct {
+	ID          string     `json:"id"`
+	AccountID   string     `json:"-"`
+	Tier        KYCTier    `json:"tier"`
+	PhoneNumber string     `json:"phone_number"`
+	DateOfBirth *time.Time `json:"date_of_birth"`
+	Country     string     `json:"country"`
+	IDType      *string    `json:"id_type"`
+	IDNumber    *string    `json:"id_number"`
+	Status      KYCStatus  `json:"status"`
+	Reason      *string    `json:"reason"`
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at"`
+
+	// populated data
+	User *Account `json:"user,omitempty"`
+}
+
+// KYCDecision is the outcome of reviewing a kyc submission
+type KYCDecision struct {
+	Status KYCStatus
+	Reason *string
+}
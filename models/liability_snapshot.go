@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/merkle"
+)
+
+type LiabilitySnapshotStatus uint8
+
+const (
+	Running_LiabilitySnapshotStatus LiabilitySnapshotStatus = iota
+	Completed_LiabilitySnapshotStatus
+	Failed_LiabilitySnapshotStatus
+)
+
+func (l LiabilitySnapshotStatus) String() string {
+	switch l {
+	case Running_LiabilitySnapshotStatus:
+		return "running"
+	case Completed_LiabilitySnapshotStatus:
+		return "completed"
+	case Failed_LiabilitySnapshotStatus:
+		return "failed"
+	default:
+		panic("unreachable")
+	}
+}
+
+func (l *LiabilitySnapshotStatus) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "running":
+		*l = Running_LiabilitySnapshotStatus
+	case "completed":
+		*l = Completed_LiabilitySnapshotStatus
+	case "failed":
+		*l = Failed_LiabilitySnapshotStatus
+	default:
+		return errors.NewValidationError("invalid liability snapshot status")
+	}
+	return nil
+}
+
+func (l LiabilitySnapshotStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.String())
+}
+
+// LiabilityRoot is the root of the merkle sum tree over every wallet balance of a currency, Total is the sum
+// of the balances in ledger units
+type LiabilityRoot struct {
+	Currency string      `json:"currency"`
+	Hash     merkle.Hash `json:"hash"`
+	Total    *big.Int    `json:"total"`
+	Leaves   int         `json:"leaves"`
+}
+
+// LiabilitySnapshot is the set of wallet balances of an environment at a ledger timestamp
+type LiabilitySnapshot struct {
+	ID              string                  `json:"id"`
+	Environment     Environment             `json:"environment"`
+	Status          LiabilitySnapshotStatus `json:"status"`
+	Reason          *string                 `json:"reason"`
+	LedgerTimestamp uint64                  `json:"ledger_timestamp"`
+	Roots           []*LiabilityRoot        `json:"roots"`
+	CreatedAt       time.Time               `json:"created_at"`
+	CompletedAt     *time.Time              `json:"completed_at"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import "context"
+
+type PrincipalType uint8
+
+const (
+	Account_PrincipalType PrincipalType = iota
+	System_PrincipalType
+	Admin_PrincipalType
+)
+
+func (p PrincipalType) String() string {
+	switch p {
+	case Account_PrincipalType:
+		return "account"
+	case System_PrincipalType:
+		return "system"
+	case Admin_PrincipalType:
+		return "admin"
+	default:
+		panic("unreachable")
+	}
+}
+
+// Principal is the authenticated caller a request or job acts as
+type Principal struct {
+	Type PrincipalType
+	// main account the principal acts for, nil for system and admin principals
+	Account     *Account
+	Environment Environment
+}
+
+func NewAccountPrincipal(account *Account) *Principal {
+	return &Principal{
+		Type:        Account_PrincipalType,
+		Account:     account,
+		Environment: account.Environment,
+	}
+}
+
+// NewSystemPrincipal returns a principal for internal jobs, system principals may act on any user
+func NewSystemPrincipal(env Environment) *Principal {
+	return &Principal{
+		Type:        System_PrincipalType,
+		Environment: env,
+	}
+}
+
+// NewAdminPrincipal returns a principal for operators authenticated with the admin token
+func NewAdminPrincipal() *Principal {
+	return &Principal{
+		Type:        Admin_PrincipalType,
+		Environment: Test_Environment,
+	}
+}
+
+// IsSystem reports whether the principal acts for the platform rather than a main account, system and
+// admin principals may act on any user
+func (p *Principal) IsSystem() bool {
+	return p.Type != Account_PrincipalType
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.Type == Admin_PrincipalType
+}
+
+type principalContextKey struct{}
+
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
@@ -0,0 +1,10 @@
+package models
+
+type RateLimit struct {
+	AccessTokenID string `json:"access_token_id"`
+	// route group the limit applies to, `*` applies to every group without its own limit
+	RouteGroup string `json:"route_group"`
+	// tokens added to the bucket per second
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
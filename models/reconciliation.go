@@ -0,0 +1,184 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+)
+
+type ReconciliationTrigger uint8
+
+const (
+	Scheduled_ReconciliationTrigger ReconciliationTrigger = iota
+	Command_ReconciliationTrigger
+	Admin_ReconciliationTrigger
+)
+
+func (r ReconciliationTrigger) String() string {
+	switch r {
+	case Scheduled_ReconciliationTrigger:
+		return "scheduled"
+	case Command_ReconciliationTrigger:
+		return "command"
+	case Admin_ReconciliationTrigger:
+		return "admin"
+	default:
+		panic("unreachable")
+	}
+}
+
+func (r *ReconciliationTrigger) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "scheduled":
+		*r = Scheduled_ReconciliationTrigger
+	case "command":
+		*r = Command_ReconciliationTrigger
+	case "admin":
+		*r = Admin_ReconciliationTrigger
+	default:
+		return errors.NewValidationError("invalid reconciliation trigger")
+	}
+	return nil
+}
+
+func (r ReconciliationTrigger) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+type ReconciliationStatus uint8
+
+const (
+	Running_ReconciliationStatus ReconciliationStatus = iota
+	Completed_ReconciliationStatus
+	Failed_ReconciliationStatus
+)
+
+func (r ReconciliationStatus) String() string {
+	switch r {
+	case Running_ReconciliationStatus:
+		return "running"
+	case Completed_ReconciliationStatus:
+		return "completed"
+	case Failed_ReconciliationStatus:
+		return "failed"
+	default:
+		panic("unreachable")
+	}
+}
+
+func (r *ReconciliationStatus) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "running":
+		*r = Running_ReconciliationStatus
+	case "completed":
+		*r = Completed_ReconciliationStatus
+	case "failed":
+		*r = Failed_ReconciliationStatus
+	default:
+		return errors.NewValidationError("invalid reconciliation status")
+	}
+	return nil
+}
+
+func (r ReconciliationStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+type ReconciliationIssueType uint8
+
+const (
+	// a wallet row has no ledger account
+	MissingLedgerAccount_ReconciliationIssueType ReconciliationIssueType = iota
+	// a ledger wallet account has no wallet row
+	OrphanLedgerAccount_ReconciliationIssueType
+	// a ledger account disagrees with its wallet row on ledger or owner
+	AccountMismatch_ReconciliationIssueType
+	// a withdrawal or swap row references a transfer missing from the ledger
+	MissingLedgerTransfer_ReconciliationIssueType
+	// a withdrawal or swap transfer has no withdrawal or swap row
+	OrphanLedgerTransfer_ReconciliationIssueType
+	// a transfer disagrees with its withdrawal or swap row
+	TransferMismatch_ReconciliationIssueType
+	// a swap hold was neither posted nor voided long after the quote expired
+	UnresolvedPendingTransfer_ReconciliationIssueType
+)
+
+func (r ReconciliationIssueType) String() string {
+	switch r {
+	case MissingLedgerAccount_ReconciliationIssueType:
+		return "missing_ledger_account"
+	case OrphanLedgerAccount_ReconciliationIssueType:
+		return "orphan_ledger_account"
+	case AccountMismatch_ReconciliationIssueType:
+		return "account_mismatch"
+	case MissingLedgerTransfer_ReconciliationIssueType:
+		return "missing_ledger_transfer"
+	case OrphanLedgerTransfer_ReconciliationIssueType:
+		return "orphan_ledger_transfer"
+	case TransferMismatch_ReconciliationIssueType:
+		return "transfer_mismatch"
+	case UnresolvedPendingTransfer_ReconciliationIssueType:
+		return "unresolved_pending_transfer"
+	default:
+		panic("unreachable")
+	}
+}
+
+func (r *ReconciliationIssueType) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "missing_ledger_account":
+		*r = MissingLedgerAccount_ReconciliationIssueType
+	case "orphan_ledger_account":
+		*r = OrphanLedgerAccount_ReconciliationIssueType
+	case "account_mismatch":
+		*r = AccountMismatch_ReconciliationIssueType
+	case "missing_ledger_transfer":
+		*r = MissingLedgerTransfer_ReconciliationIssueType
+	case "orphan_ledger_transfer":
+		*r = OrphanLedgerTransfer_ReconciliationIssueType
+	case "transfer_mismatch":
+		*r = TransferMismatch_ReconciliationIssueType
+	case "unresolved_pending_transfer":
+		*r = UnresolvedPendingTransfer_ReconciliationIssueType
+	default:
+		return errors.NewValidationError("invalid reconciliation issue type")
+	}
+	return nil
+}
+
+func (r ReconciliationIssueType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// ReconciliationIssue is a disagreement between the data database and the ledger found by a reconciliation
+type ReconciliationIssue struct {
+	Type ReconciliationIssueType `json:"type"`
+	// kind of record the issue was found on, one of wallet, withdrawal, instant_swap, ledger_account or ledger_transfer
+	Resource    string      `json:"resource"`
+	ResourceID  string      `json:"resource_id"`
+	Environment Environment `json:"environment"`
+	Detail      string      `json:"detail"`
+}
+
+// ReconciliationReport is the outcome of a cross check of wallets, withdrawals and swaps against the ledger
+type ReconciliationReport struct {
+	ID                 string                 `json:"id"`
+	Trigger            ReconciliationTrigger  `json:"trigger"`
+	Status             ReconciliationStatus   `json:"status"`
+	Reason             *string                `json:"reason"`
+	WalletsChecked     int                    `json:"wallets_checked"`
+	WithdrawalsChecked int                    `json:"withdrawals_checked"`
+	SwapsChecked       int                    `json:"swaps_checked"`
+	IssueCount         int                    `json:"issue_count"`
+	Issues             []*ReconciliationIssue `json:"issues,omitempty"`
+	StartedAt          time.Time              `json:"started_at"`
+	CompletedAt        *time.Time             `json:"completed_at"`
+}
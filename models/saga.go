@@ -0,0 +1,119 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+)
+
+type SagaKind uint8
+
+const (
+	CreateAccount_SagaKind SagaKind = iota
+	Withdrawal_SagaKind
+	InstantSwap_SagaKind
+	ConfirmInstantSwap_SagaKind
+)
+
+func (s SagaKind) String() string {
+	switch s {
+	case CreateAccount_SagaKind:
+		return "create_account"
+	case Withdrawal_SagaKind:
+		return "withdrawal"
+	case InstantSwap_SagaKind:
+		return "instant_swap"
+	case ConfirmInstantSwap_SagaKind:
+		return "confirm_instant_swap"
+	default:
+		panic("unreachable")
+	}
+}
+
+func (s *SagaKind) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "create_account":
+		*s = CreateAccount_SagaKind
+	case "withdrawal":
+		*s = Withdrawal_SagaKind
+	case "instant_swap":
+		*s = InstantSwap_SagaKind
+	case "confirm_instant_swap":
+		*s = ConfirmInstantSwap_SagaKind
+	default:
+		return errors.NewValidationError("invalid saga kind")
+	}
+	return nil
+}
+
+func (s SagaKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+type SagaState uint8
+
+const (
+	Running_SagaState SagaState = iota
+	Compensating_SagaState
+	Completed_SagaState
+	Compensated_SagaState
+)
+
+func (s SagaState) String() string {
+	switch s {
+	case Running_SagaState:
+		return "running"
+	case Compensating_SagaState:
+		return "compensating"
+	case Completed_SagaState:
+		return "completed"
+	case Compensated_SagaState:
+		return "compensated"
+	default:
+		panic("unreachable")
+	}
+}
+
+func (s *SagaState) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "running":
+		*s = Running_SagaState
+	case "compensating":
+		*s = Compensating_SagaState
+	case "completed":
+		*s = Completed_SagaState
+	case "compensated":
+		*s = Compensated_SagaState
+	default:
+		return errors.NewValidationError("invalid saga state")
+	}
+	return nil
+}
+
+func (s SagaState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Saga is an operation spanning the data database and the ledger, recorded so that it can be resumed or
+// compensated when the process stops part way through
+type Saga struct {
+	ID   string   `json:"id"`
+	Kind SagaKind `json:"kind"`
+	// intent of the saga, the ledger writes it makes and the records it touches
+	Payload json.RawMessage `json:"payload"`
+	State   SagaState       `json:"state"`
+	// number of steps that have taken effect and have not been compensated
+	Step      int       `json:"step"`
+	Reason    *string   `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// process running the saga, it holds the saga until its lease expires unless it renews it
+	Owner          *string    `json:"owner"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at"`
+}
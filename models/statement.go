@@ -0,0 +1,121 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+)
+
+type StatementFormat uint8
+
+const (
+	CSV_StatementFormat StatementFormat = iota
+	JSONL_StatementFormat
+)
+
+func (s StatementFormat) String() string {
+	switch s {
+	case CSV_StatementFormat:
+		return "csv"
+	case JSONL_StatementFormat:
+		return "jsonl"
+	default:
+		panic("unreachable")
+	}
+}
+
+func (s StatementFormat) ContentType() string {
+	switch s {
+	case CSV_StatementFormat:
+		return "text/csv"
+	case JSONL_StatementFormat:
+		return "application/jsonl"
+	default:
+		panic("unreachable")
+	}
+}
+
+func (s *StatementFormat) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "csv":
+		*s = CSV_StatementFormat
+	case "jsonl":
+		*s = JSONL_StatementFormat
+	default:
+		return errors.NewValidationError("invalid statement format")
+	}
+	return nil
+}
+
+func (s *StatementFormat) UnmarshalText(input []byte) error {
+	return s.UnmarshalJSON(input)
+}
+
+func (s StatementFormat) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+type StatementExportStatus uint8
+
+const (
+	Pending_StatementExportStatus StatementExportStatus = iota
+	Completed_StatementExportStatus
+	Failed_StatementExportStatus
+	// the export's file has been deleted once it was older than the retention
+	Expired_StatementExportStatus
+)
+
+func (s StatementExportStatus) String() string {
+	switch s {
+	case Pending_StatementExportStatus:
+		return "pending"
+	case Completed_StatementExportStatus:
+		return "completed"
+	case Failed_StatementExportStatus:
+		return "failed"
+	case Expired_StatementExportStatus:
+		return "expired"
+	default:
+		panic("unreachable")
+	}
+}
+
+func (s *StatementExportStatus) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "pending":
+		*s = Pending_StatementExportStatus
+	case "completed":
+		*s = Completed_StatementExportStatus
+	case "failed":
+		*s = Failed_StatementExportStatus
+	case "expired":
+		*s = Expired_StatementExportStatus
+	default:
+		return errors.NewValidationError("invalid statement export status")
+	}
+	return nil
+}
+
+func (s StatementExportStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// StatementExport is a statement written to a file by a background job
+type StatementExport struct {
+	ID          string                `json:"id"`
+	AccountID   string                `json:"-"`
+	Format      StatementFormat       `json:"format"`
+	Currency    *string               `json:"currency"`
+	From        time.Time             `json:"from"`
+	To          time.Time             `json:"to"`
+	Status      StatementExportStatus `json:"status"`
+	Reason      *string               `json:"reason"`
+	CreatedAt   time.Time             `json:"created_at"`
+	CompletedAt *time.Time            `json:"completed_at"`
+}
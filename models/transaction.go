@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/2HgO/quidax-go/errors"
+)
+
+type TransactionType uint8
+
+const (
+	Deposit_TransactionType TransactionType = iota
+	Withdrawal_TransactionType
+	Swap_TransactionType
+	Fee_TransactionType
+)
+
+func (t TransactionType) String() string {
+	switch t {
+	case Deposit_TransactionType:
+		return "deposit"
+	case Withdrawal_TransactionType:
+		return "withdrawal"
+	case Swap_TransactionType:
+		return "swap"
+	case Fee_TransactionType:
+		return "fee"
+	default:
+		panic("unreachable")
+	}
+}
+
+func (t *TransactionType) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "deposit":
+		*t = Deposit_TransactionType
+	case "withdrawal":
+		*t = Withdrawal_TransactionType
+	case "swap":
+		*t = Swap_TransactionType
+	case "fee":
+		*t = Fee_TransactionType
+	default:
+		return errors.NewValidationError("invalid transaction type")
+	}
+	return nil
+}
+
+func (t *TransactionType) UnmarshalText(input []byte) error {
+	return t.UnmarshalJSON(input)
+}
+
+func (t TransactionType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/2HgO/quidax-go/errors"
+)
+
+type LimitOperation uint8
+
+const (
+	Withdrawal_LimitOperation LimitOperation = iota
+	Swap_LimitOperation
+)
+
+func (l LimitOperation) String() string {
+	switch l {
+	case Withdrawal_LimitOperation:
+		return "withdrawal"
+	case Swap_LimitOperation:
+		return "swap"
+	default:
+		panic("unreachable")
+	}
+}
+
+func (l *LimitOperation) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "withdrawal":
+		*l = Withdrawal_LimitOperation
+	case "swap":
+		*l = Swap_LimitOperation
+	default:
+		return errors.NewValidationError("invalid limit operation")
+	}
+	return nil
+}
+
+func (l LimitOperation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.String())
+}
+
+// TransactionLimit caps the amount of a currency a sub account may move with an operation,
+// a nil cap is unlimited
+type TransactionLimit struct {
+	Single  *float64
+	Daily   *float64
+	Monthly *float64
+}
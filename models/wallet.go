@@ -3,6 +3,8 @@ package models
 type Wallet struct {
 	ID string `json:"id"`
 	// ? maybe change to uuid.UUID
-	AccountID string `json:"account_id"`
-	Token     string `json:"token"`
+	AccountID   string      `json:"account_id"`
+	Token       string      `json:"token"`
+	Environment Environment `json:"environment"`
+	Frozen      bool        `json:"frozen"`
 }
@@ -21,6 +21,13 @@ const (
 
 	DepositSuccessful_WebhookEvent
 	DepositConfirmation_WebhookEvent
+
+	UserFrozen_WebhookEvent
+	UserUnfrozen_WebhookEvent
+	WalletFrozen_WebhookEvent
+	WalletUnfrozen_WebhookEvent
+
+	KYCUpdated_WebhookEvent
 )
 
 func (w WebhookEvent) String() string {
@@ -41,6 +48,16 @@ func (w WebhookEvent) String() string {
 		return "deposit.successful"
 	case DepositConfirmation_WebhookEvent:
 		return "deposit.transaction.confirmation"
+	case UserFrozen_WebhookEvent:
+		return "user.frozen"
+	case UserUnfrozen_WebhookEvent:
+		return "user.unfrozen"
+	case WalletFrozen_WebhookEvent:
+		return "wallet.frozen"
+	case WalletUnfrozen_WebhookEvent:
+		return "wallet.unfrozen"
+	case KYCUpdated_WebhookEvent:
+		return "kyc.updated"
 	default:
 		panic("unreachable")
 	}
@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"strings"
+	"time"
 
 	"github.com/2HgO/quidax-go/errors"
 )
@@ -17,6 +18,9 @@ type Withdrawal struct {
 	Reason          *string
 	Status          WithdrawalStatus
 	Recipient       *Recipient
+	// zero for withdrawals made before amounts were recorded
+	Amount    float64
+	CreatedAt time.Time
 }
 
 type WithdrawalStatus uint8
@@ -59,6 +63,10 @@ func (w *WithdrawalStatus) UnmarshalJSON(input []byte) error {
 	return nil
 }
 
+func (w *WithdrawalStatus) UnmarshalText(input []byte) error {
+	return w.UnmarshalJSON(input)
+}
+
 func (w WithdrawalStatus) MarshalJSON() ([]byte, error) {
 	return json.Marshal(w.String())
 }
@@ -81,6 +89,20 @@ func (r RecipientType) String() string {
 	}
 }
 
+func (r *RecipientType) UnmarshalJSON(input []byte) error {
+	strInput := string(input)
+	strInput = strings.Trim(strInput, `"`)
+	switch strInput {
+	case "internal":
+		*r = Internal_RecipientType
+	case "coin_address":
+		*r = CoinAddress_RecipientType
+	default:
+		return errors.NewValidationError("invalid recipient type")
+	}
+	return nil
+}
+
 func (r RecipientType) MarshalJSON() ([]byte, error) {
 	return json.Marshal(r.String())
 }
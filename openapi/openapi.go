@@ -0,0 +1,265 @@
+// Package openapi generates an OpenAPI 3 document from the request and response types of the api's routes.
+// Parameters and request bodies are read from the `uri`, `query` and `json` tags of a route's request type and
+// constrained by its `validate` and `default` tags, so the document stays in step with how requests are bound
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/2HgO/quidax-go/errors"
+)
+
+const Version = "3.0.3"
+
+// Auth is how a route is authenticated
+type Auth string
+
+const (
+	NoAuth          Auth = ""
+	AccessTokenAuth Auth = "access_token"
+	AdminTokenAuth  Auth = "admin_token"
+)
+
+// File is a response downloaded as a file in one of the content types
+type File []string
+
+// Route is a spec entry for a route the api serves
+type Route struct {
+	Method string
+	// path as registered on the mux, with its wildcards in braces
+	Path    string
+	ID      string
+	Summary string
+	Tag     string
+	Auth    Auth
+	// value of the type bound from the request, nil when the route does not bind one
+	Request any
+	// values of the types responded with by status code. A nil value is a response without a body and a File
+	// value is a download
+	Responses map[int]any
+}
+
+// Pattern is the mux pattern the route is registered with
+func (r Route) Pattern() string {
+	return r.Method + " " + r.Path
+}
+
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Tags       []Tag                           `json:"tags,omitempty"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme"`
+	Description string `json:"description,omitempty"`
+}
+
+// Build generates the document of the routes. It fails when a route or operation id is listed twice, or a
+// route's request type has a field for a path wildcard that is not a string, which could not be bound
+func Build(info Info, routes []Route) (*Document, error) {
+	s := newSchemas()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]map[string]Operation),
+		Components: Components{
+			Schemas: s.components,
+			SecuritySchemes: map[string]SecurityScheme{
+				string(AccessTokenAuth): {Type: "http", Scheme: "bearer", Description: "secret key of a main account, `sec_test_` keys act in the test environment and `sec_live_` keys in the live one"},
+				string(AdminTokenAuth):  {Type: "http", Scheme: "bearer", Description: "admin token from the `admin.token` setting"},
+			},
+		},
+	}
+	// every route responds with an AppError when it fails
+	s.errorSchema = s.of(reflect.TypeFor[errors.AppError]())
+
+	tags, ids := make(map[string]bool), make(map[string]bool)
+	for _, route := range routes {
+		method := strings.ToLower(route.Method)
+		if _, ok := doc.Paths[route.Path][method]; ok {
+			return nil, fmt.Errorf("route %s is listed more than once", route.Pattern())
+		}
+		if route.ID == "" || ids[route.ID] {
+			return nil, fmt.Errorf("route %s needs a unique operation id, %q is missing or used more than once", route.Pattern(), route.ID)
+		}
+		ids[route.ID] = true
+		op, err := s.operation(route)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", route.Pattern(), err)
+		}
+		if doc.Paths[route.Path] == nil {
+			doc.Paths[route.Path] = make(map[string]Operation)
+		}
+		doc.Paths[route.Path][method] = op
+
+		if route.Tag != "" && !tags[route.Tag] {
+			tags[route.Tag] = true
+			doc.Tags = append(doc.Tags, Tag{Name: route.Tag})
+		}
+	}
+	return doc, nil
+}
+
+var wildcard = regexp.MustCompile(`\{(\w+)(?:\.\.\.)?\}`)
+
+func (s *schemas) operation(route Route) (Operation, error) {
+	op := Operation{
+		OperationID: route.ID,
+		Summary:     route.Summary,
+		Responses:   make(map[string]Response),
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+	if route.Auth != NoAuth {
+		op.Security = []map[string][]string{{string(route.Auth): {}}}
+	}
+
+	var fields []field
+	if route.Request != nil {
+		fields = s.fields(reflect.TypeOf(route.Request))
+	}
+
+	// wildcards are typed by the request's uri field of the same name, ones it does not bind are plain strings
+	inPath := make(map[string]bool)
+	for _, match := range wildcard.FindAllStringSubmatch(route.Path, -1) {
+		name := match[1]
+		inPath[name] = true
+		param := Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		for _, f := range fields {
+			if f.uri != name {
+				continue
+			}
+			if f.typ.Kind() != reflect.String {
+				return op, fmt.Errorf("field %s for wildcard %s is not a string", f.name, name)
+			}
+			param.Schema, param.Description = f.schema, f.description
+		}
+		op.Parameters = append(op.Parameters, param)
+	}
+
+	body := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range fields {
+		switch {
+		case f.query != "" && !inPath[f.query]:
+			op.Parameters = append(op.Parameters, Parameter{Name: f.query, In: "query", Description: f.description, Required: f.required, Schema: f.schema})
+		case f.query == "" && f.uri == "" && f.json != "":
+			body.Properties[f.json] = withDescription(f.schema, f.description)
+			if f.required {
+				body.Required = append(body.Required, f.json)
+			}
+		}
+	}
+	if len(body.Properties) > 0 {
+		op.RequestBody = &RequestBody{
+			Required: len(body.Required) > 0,
+			Content:  map[string]MediaType{"application/json": {Schema: body}},
+		}
+	}
+
+	for status, res := range route.Responses {
+		code := fmt.Sprint(status)
+		switch res := res.(type) {
+		case nil:
+			op.Responses[code] = Response{Description: statusDescription(status)}
+		case File:
+			content := make(map[string]MediaType)
+			for _, contentType := range res {
+				content[contentType] = MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+			}
+			op.Responses[code] = Response{Description: statusDescription(status), Content: content}
+		default:
+			op.Responses[code] = Response{
+				Description: statusDescription(status),
+				Content:     map[string]MediaType{"application/json": {Schema: s.of(reflect.TypeOf(res))}},
+			}
+		}
+	}
+	op.Responses["default"] = Response{
+		Description: "error",
+		Content:     map[string]MediaType{"application/json": {Schema: s.errorSchema}},
+	}
+	return op, nil
+}
+
+func statusDescription(status int) string {
+	switch status {
+	case 200:
+		return "successful"
+	case 201:
+		return "created"
+	case 202:
+		return "accepted, the work carries on in the background"
+	case 204:
+		return "no content"
+	default:
+		return fmt.Sprint(status)
+	}
+}
+
+// Patterns lists the mux patterns of the document's operations in order
+func (d *Document) Patterns() []string {
+	var patterns []string
+	for path, item := range d.Paths {
+		for method := range item {
+			patterns = append(patterns, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(patterns)
+	return patterns
+}
@@ -0,0 +1,426 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is the subset of OpenAPI 3.0 schema objects the generated types need
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	bigIntType        = reflect.TypeFor[big.Int]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+	stringerType      = reflect.TypeFor[fmt.Stringer]()
+)
+
+// schemas generates the schemas of go types. Named structs are added to the components and referenced,
+// instances of generic types such as responses.Response are inlined since their names are not usable
+type schemas struct {
+	components  map[string]*Schema
+	names       map[reflect.Type]string
+	errorSchema *Schema
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+func (s *schemas) of(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		schema := s.of(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == bigIntType:
+		return &Schema{Type: "integer"}
+	case isEnum(t):
+		return &Schema{Type: "string", Enum: enumValues(t)}
+	case t.Implements(jsonMarshalerType) && t.Kind() == reflect.Struct:
+		return &Schema{}
+	case t.Implements(textMarshalerType) && !t.Implements(jsonMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.of(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(t.Elem())}
+	case reflect.Struct:
+		return s.object(t)
+	default:
+		// interfaces hold any value
+		return &Schema{}
+	}
+}
+
+// object references the component of a named struct, adding it on first use
+func (s *schemas) object(t reflect.Type) *Schema {
+	if t.Name() == "" || strings.Contains(t.Name(), "[") {
+		return s.properties(t)
+	}
+
+	name, ok := s.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := s.components[name]; taken {
+			pkg := []rune(path.Base(t.PkgPath()))
+			name = string(unicode.ToUpper(pkg[0])) + string(pkg[1:]) + name
+		}
+		s.names[t] = name
+		// the component is reserved before its properties are generated so recursive types end
+		s.components[name] = nil
+		s.components[name] = s.properties(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// properties is the object of a struct's json fields. Fields always encoded, the ones without omitempty, are
+// listed as required
+func (s *schemas) properties(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	walk(t, func(sf reflect.StructField) {
+		name, opts := jsonName(sf)
+		if name == "" {
+			return
+		}
+		prop := s.of(sf.Type)
+		if opts.Contains("string") && prop.Ref == "" && (prop.Type == "number" || prop.Type == "integer") {
+			// quidax encodes amounts as decimal strings
+			prop.Type, prop.Format = "string", "decimal"
+		}
+		schema.Properties[name] = prop
+		if !opts.Contains("omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	})
+	return schema
+}
+
+// walk calls fn with the fields of the struct, the fields of embedded structs without a json name are
+// promoted the way encoding/json promotes them
+func walk(t reflect.Type, fn func(reflect.StructField)) {
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			embedded := sf.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); embedded.Kind() == reflect.Struct && name == "" {
+				walk(embedded, fn)
+				continue
+			}
+		}
+		if sf.IsExported() {
+			fn(sf)
+		}
+	}
+}
+
+type tagOptions string
+
+func (o tagOptions) Contains(option string) bool {
+	for _, opt := range strings.Split(string(o), ",") {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonName is the name the field is encoded with, empty when it is not encoded
+func jsonName(sf reflect.StructField) (string, tagOptions) {
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return "", ""
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = sf.Name
+	}
+	return name, tagOptions(opts)
+}
+
+// isEnum reports whether the type is one of the integer enums of the models, which are encoded as the name
+// their String method returns
+func isEnum(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return t.Implements(jsonMarshalerType) && t.Implements(stringerType)
+	default:
+		return false
+	}
+}
+
+// enumValues lists the names of an enum's values, which start at 0 and end before the first value String
+// panics on. The enums are uint8s, so no more values than one holds are tried
+func enumValues(t reflect.Type) []any {
+	var values []any
+	for n := range int64(math.MaxUint8 + 1) {
+		name, ok := enumName(t, n)
+		if !ok {
+			break
+		}
+		values = append(values, name)
+	}
+	return values
+}
+
+func enumName(t reflect.Type, n int64) (name string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			name, ok = "", false
+		}
+	}()
+	v := reflect.New(t).Elem()
+	if v.CanInt() {
+		v.SetInt(n)
+	} else {
+		v.SetUint(uint64(n))
+	}
+	return v.Interface().(fmt.Stringer).String(), true
+}
+
+// field is a field of a request type with the names it is bound from
+type field struct {
+	name        string
+	typ         reflect.Type
+	uri         string
+	query       string
+	json        string
+	required    bool
+	description string
+	schema      *Schema
+}
+
+// fields lists the bound fields of a request type, constrained by their validate and default tags
+func (s *schemas) fields(t reflect.Type) []field {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var fields []field
+	wire := make(map[string]string)
+	walk(t, func(sf reflect.StructField) {
+		f := field{name: sf.Name, typ: sf.Type}
+		f.uri, _, _ = strings.Cut(sf.Tag.Get("uri"), ",")
+		f.query, _, _ = strings.Cut(sf.Tag.Get("query"), ",")
+		f.json, _ = jsonName(sf)
+		for _, name := range []string{f.query, f.uri, f.json} {
+			if name != "" {
+				wire[sf.Name] = name
+				break
+			}
+		}
+		f.schema = s.of(sf.Type)
+		if f.schema.Ref == "" {
+			if value, ok := sf.Tag.Lookup("default"); ok {
+				f.schema.Default = parseValue(sf.Type, value)
+			}
+		}
+		fields = append(fields, f)
+	})
+
+	// constraints are applied once every field is named so the ones naming other fields can use their names
+	i := 0
+	walk(t, func(sf reflect.StructField) {
+		constrain(&fields[i], sf.Tag.Get("validate"), wire)
+		i++
+	})
+	return fields
+}
+
+var formats = map[string]*Schema{
+	"email":            {Format: "email"},
+	"url":              {Format: "uri"},
+	"uuid":             {Format: "uuid"},
+	"e164":             {Pattern: `^\+[1-9][0-9]{1,14}$`},
+	"iso3166_1_alpha2": {Pattern: `^[A-Z]{2}$`},
+}
+
+// constrain applies the validate tag's rules to the field's schema. Rules relating the field to other fields
+// cannot be expressed in the schema and are described instead
+func constrain(f *field, tag string, wire map[string]string) {
+	if tag == "" {
+		return
+	}
+	schema := f.schema
+	if schema.Ref != "" {
+		return
+	}
+	numeric := schema.Type == "number" || schema.Type == "integer"
+
+	var notes []string
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			f.required = true
+		case "oneof":
+			schema.Enum = nil
+			for _, value := range strings.Fields(param) {
+				if isEnum(f.elem()) {
+					n, _ := strconv.ParseInt(value, 10, 64)
+					name, _ := enumName(f.elem(), n)
+					schema.Enum = append(schema.Enum, name)
+					continue
+				}
+				schema.Enum = append(schema.Enum, parseValue(f.elem(), value))
+			}
+		case "min", "max", "gt", "gte", "lt", "lte":
+			bound, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			if !numeric {
+				length := int(bound)
+				switch name {
+				case "min":
+					schema.MinLength = &length
+				case "max":
+					schema.MaxLength = &length
+				}
+				continue
+			}
+			switch name {
+			case "min", "gte":
+				schema.Minimum = &bound
+			case "gt":
+				schema.Minimum, schema.ExclusiveMinimum = &bound, true
+			case "max", "lte":
+				schema.Maximum = &bound
+			case "lt":
+				schema.Maximum, schema.ExclusiveMaximum = &bound, true
+			}
+		case "datetime":
+			switch param {
+			case time.RFC3339:
+				schema.Format = "date-time"
+			case time.DateOnly:
+				schema.Format = "date"
+			default:
+				notes = append(notes, "formatted as "+param)
+			}
+		case "required_if":
+			other, value, _ := strings.Cut(param, " ")
+			notes = append(notes, fmt.Sprintf("required when %s is %s", wire[other], value))
+		case "required_with":
+			notes = append(notes, "required with "+wire[param])
+		case "required_without":
+			notes = append(notes, "required without "+wire[param])
+		case "excluded_with":
+			notes = append(notes, "not allowed with "+wire[param])
+		case "gtefield":
+			notes = append(notes, "at least "+wire[param])
+		default:
+			if format, ok := formats[name]; ok {
+				if format.Format != "" {
+					schema.Format = format.Format
+				}
+				if format.Pattern != "" {
+					schema.Pattern = format.Pattern
+				}
+			}
+		}
+	}
+	f.description = strings.Join(notes, ", ")
+}
+
+// elem is the field's type without pointers
+func (f *field) elem() reflect.Type {
+	t := f.typ
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// parseValue converts a tag's value to the json value of the type, values that do not parse are kept as
+// strings
+func parseValue(t reflect.Type, value string) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+// withDescription is the schema with the description, references are wrapped since their siblings are ignored
+func withDescription(schema *Schema, description string) *Schema {
+	switch {
+	case description == "":
+		return schema
+	case schema.Ref != "":
+		return &Schema{AllOf: []*Schema{schema}, Description: description}
+	default:
+		schema.Description = description
+		return schema
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/2HgO/quidax-go/handlers"
+	"github.com/2HgO/quidax-go/openapi"
+)
+
+// TestOpenAPISpec fails when a route the handlers register has no entry in handlers.Spec, or an entry has no
+// route. The served document is compared with its golden file so changes to the api show up in review
+func TestOpenAPISpec(t *testing.T) {
+	h := newHarness(t)
+
+	routes := handlers.Patterns(h.routers)
+	entries := make([]string, 0, len(handlers.Spec))
+	for _, route := range handlers.Spec {
+		entries = append(entries, route.Pattern())
+	}
+	for _, route := range routes {
+		if !slices.Contains(entries, route) {
+			t.Errorf("route %q has no entry in handlers.Spec", route)
+		}
+	}
+	for _, entry := range entries {
+		if !slices.Contains(routes, entry) {
+			t.Errorf("handlers.Spec entry %q has no route", entry)
+		}
+	}
+
+	doc, err := call[*openapi.Document](h.api, http.MethodGet, "/api/v1/openapi.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(routes)
+	if patterns := doc.Patterns(); !slices.Equal(patterns, routes) {
+		t.Errorf("document has operations for %v, want %v", patterns, routes)
+	}
+	h.golden("openapi", doc)
+
+	res, err := h.api.http.Get(h.api.base + "/api/v1/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	page, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != 200 || !strings.Contains(string(page), `spec-url="openapi.json"`) {
+		t.Errorf("docs page responded with %d:\n%s", res.StatusCode, page)
+	}
+}
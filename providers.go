@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net"
 	"net/http"
-	"time"
 
+	"github.com/2HgO/quidax-go/config"
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/db/migrations"
 	"github.com/2HgO/quidax-go/handlers"
+	"github.com/2HgO/quidax-go/repositories"
+	"github.com/2HgO/quidax-go/services"
 	"github.com/MadAppGang/httplog"
 	lzap "github.com/MadAppGang/httplog/zap"
 	gHandlers "github.com/gorilla/handlers"
@@ -15,25 +20,25 @@ import (
 	"go.uber.org/zap"
 )
 
-func NewHttpServer(lc fx.Lifecycle, mux *http.ServeMux, log *zap.Logger) *http.Server {
-	config := httplog.LoggerConfig{
+func NewHttpServer(lc fx.Lifecycle, mux *http.ServeMux, cfg *config.Config, log *zap.Logger) *http.Server {
+	logConfig := httplog.LoggerConfig{
 		Formatter: lzap.ZapLogger(log, zap.InfoLevel, "quidax-go"),
 	}
 	opts := []gHandlers.CORSOption{
 		gHandlers.AllowCredentials(),
-		gHandlers.AllowedHeaders([]string{"keep-alive", "user-agent", "cache-control", "authorization", "content-type", "content-transfer-encoding", "x-accept-content-transfer-encoding", "x-accept-response-streaming", "x-user-agent", "referer", "x-trace-id", "origin", "x-requested-with"}),
+		gHandlers.AllowedHeaders([]string{"keep-alive", "user-agent", "cache-control", "authorization", "content-type", "content-transfer-encoding", "x-accept-content-transfer-encoding", "x-accept-response-streaming", "x-user-agent", "referer", "x-trace-id", "origin", "x-requested-with", "idempotency-key"}),
 		gHandlers.AllowedMethods([]string{"GET", "PUT", "DELETE", "POST", "PATCH", "OPTIONS"}),
-		gHandlers.AllowedOrigins([]string{"*"}),
-		gHandlers.ExposedHeaders([]string{"x-envoy-upstream-service-time", "x-total-count", "x-page-number", "x-per-page"}),
+		gHandlers.AllowedOrigins(cfg.HTTP.CORSOrigins),
+		gHandlers.ExposedHeaders([]string{"x-envoy-upstream-service-time", "x-total-count", "x-page-number", "x-per-page", "x-ratelimit-limit", "x-ratelimit-remaining", "x-ratelimit-reset", "retry-after", "idempotent-replayed"}),
 		gHandlers.MaxAge(1728000),
 	}
 	srv := &http.Server{
-		Addr: ":55059",
+		Addr: cfg.HTTP.Address,
 		// todo: handler request logger manually
-		Handler:      gHandlers.CORS(opts...)(httplog.LoggerWithConfig(config)(handlers.RecoveryMW(mux))),
-		WriteTimeout: time.Second * 15,
-		ReadTimeout:  time.Second * 15,
-		IdleTimeout:  time.Second * 60,
+		Handler:      gHandlers.CORS(opts...)(httplog.LoggerWithConfig(logConfig)(handlers.RecoveryMW(mux))),
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
 	}
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -41,6 +46,8 @@ func NewHttpServer(lc fx.Lifecycle, mux *http.ServeMux, log *zap.Logger) *http.S
 			if err != nil {
 				return err
 			}
+			// a port of 0 listens on a free port, the address is updated to the one picked
+			srv.Addr = ln.Addr().String()
 			fmt.Println("Starting HTTP server at", srv.Addr)
 			go srv.Serve(ln)
 			return nil
@@ -60,3 +67,99 @@ func NewServeMux(routers []handlers.Handler) *http.ServeMux {
 	}
 	return mux
 }
+
+// DataRepositories are the repositories of the data database backend the `data_db.driver` setting selects
+type DataRepositories struct {
+	fx.Out
+
+	Transactor     repositories.Transactor
+	Accounts       repositories.AccountRepository
+	Wallets        repositories.WalletRepository
+	Tokens         repositories.TokenRepository
+	Withdrawals    repositories.WithdrawalRepository
+	Swaps          repositories.SwapRepository
+	Idempotency    repositories.IdempotencyRepository
+	Limits         repositories.LimitRepository
+	KYC            repositories.KYCRepository
+	Liabilities    repositories.LiabilityRepository
+	Reconciliation repositories.ReconciliationRepository
+	Sagas          repositories.SagaRepository
+	Statements     repositories.StatementExportRepository
+	Fixtures       repositories.FixtureRepository
+}
+
+// NewDataRepositories provides the sql repositories of the data database, or in-memory ones for the memory driver
+func NewDataRepositories(dataDB *sql.DB, cfg *config.Config) DataRepositories {
+	var r *repositories.Repositories
+	switch db.Dialect(cfg.DataDB.Driver) {
+	case db.Memory:
+		r = repositories.NewMemoryRepositories()
+	default:
+		r = repositories.NewSQLRepositories(dataDB)
+	}
+	return DataRepositories{
+		Transactor:     r.Transactor,
+		Accounts:       r.Accounts,
+		Wallets:        r.Wallets,
+		Tokens:         r.Tokens,
+		Withdrawals:    r.Withdrawals,
+		Swaps:          r.Swaps,
+		Idempotency:    r.Idempotency,
+		Limits:         r.Limits,
+		KYC:            r.KYC,
+		Liabilities:    r.Liabilities,
+		Reconciliation: r.Reconciliation,
+		Sagas:          r.Sagas,
+		Statements:     r.Statements,
+		Fixtures:       r.Fixtures,
+	}
+}
+
+// AutoMigrate applies pending schema migrations on start when auto migration is enabled, it must be invoked
+// before anything that reads the data database on start. The memory driver has no schema to migrate
+func AutoMigrate(lc fx.Lifecycle, dataDB *sql.DB, cfg *config.Config, log *zap.Logger) error {
+	if !cfg.DataDB.AutoMigrate || db.Dialect(cfg.DataDB.Driver) == db.Memory {
+		return nil
+	}
+	migrator, err := migrations.NewMigrator(dataDB, log)
+	if err != nil {
+		return err
+	}
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			_, err := migrator.Up(ctx)
+			return err
+		},
+	})
+	return nil
+}
+
+// RecoverSagas settles the unfinished sagas whose lease has run out before the server starts taking requests,
+// it must be invoked before the server so its hook runs first. Sagas of a process that stopped less than a lease
+// ago may still be held by it, the periodic recovery settles them once their lease runs out
+func RecoverSagas(lc fx.Lifecycle, sagas services.SagaService) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return sagas.Recover(ctx)
+		},
+	})
+}
+
+// RescheduleSwapReversals schedules the reversal of the swaps left pending by a previous process once the app
+// starts, it must be invoked after RecoverSagas
+func RescheduleSwapReversals(lc fx.Lifecycle, scheduler services.SchedulerService) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return scheduler.RescheduleInstantSwapReversals(ctx)
+		},
+	})
+}
+
+// ResumeStatementExports runs the statement exports left pending by a previous process again once the app starts
+func ResumeStatementExports(lc fx.Lifecycle, statements services.StatementService) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			return statements.ResumeExports(ctx)
+		},
+	})
+}
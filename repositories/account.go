@@ -0,0 +1,276 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	sq "github.com/Masterminds/squirrel"
+)
+
+type AccountRepository interface {
+	// Create stores a main account, or a sub account when its parent is set
+	Create(context.Context, *models.Account) error
+	SaveCredentials(context.Context, *models.Credentials) error
+	// SaveWebhookDetails replaces the account's webhook details
+	SaveWebhookDetails(context.Context, *models.WebhookDetails) error
+	// FindByID returns the account with the webhook details of the account, or of its parent for sub accounts
+	FindByID(context.Context, string) (*models.Account, error)
+	FindByIDs(context.Context, []string) ([]*models.Account, error)
+	// FindByAccessToken returns the account the token was issued to, in the token's environment
+	FindByAccessToken(context.Context, string) (*models.Account, error)
+	// ListSubAccounts returns the requested page of the parent's sub accounts in the environment, newest first
+	ListSubAccounts(context.Context, string, models.Environment, requests.Pagination) ([]*models.Account, *responses.Pagination, error)
+	// Update saves the account's names, phone number, date of birth and country
+	Update(context.Context, *models.Account) error
+	SetFrozen(context.Context, string, bool) error
+	SetKYCTier(context.Context, string, models.KYCTier) error
+	// Delete removes the account and its credentials
+	Delete(context.Context, string) error
+}
+
+func NewSQLAccountRepository(dataDatabase *sql.DB) AccountRepository {
+	return &sqlAccountRepository{db: dataDatabase, dialect: db.DialectOf(dataDatabase), builder: db.DialectOf(dataDatabase).Builder()}
+}
+
+type sqlAccountRepository struct {
+	db      *sql.DB
+	dialect db.Dialect
+	builder sq.StatementBuilderType
+}
+
+var accountColumns = []string{
+	"accounts.id", "sn", "display_name", "email", "first_name", "last_name", "created_at", "updated_at",
+	"accounts.environment", "accounts.frozen", "is_main_account", "parent_id", "callback_url", "webhook_key",
+	"phone_number", "date_of_birth", "country", "kyc_tier",
+}
+
+func scanAccount(row sq.RowScanner) (*models.Account, error) {
+	account := &models.Account{}
+	err := row.Scan(
+		&account.ID, &account.SN, &account.DisplayName, &account.Email, &account.FirstName, &account.LastName, &account.CreatedAt, &account.UpdatedAt,
+		&account.Environment, &account.Frozen, &account.IsMainAccount, &account.ParentID, &account.WebhookDetails.CallbackURL, &account.WebhookDetails.WebhookKey,
+		&account.PhoneNumber, &account.DateOfBirth, &account.Country, &account.KYCTier,
+	)
+	return account, err
+}
+
+func (m *sqlAccountRepository) selectAccounts() sq.SelectBuilder {
+	return m.builder.
+		Select(accountColumns...).
+		From("accounts").
+		LeftJoin("webhook_details on webhook_details.id = accounts.id OR webhook_details.id = accounts.parent_id")
+}
+
+func (m *sqlAccountRepository) Create(ctx context.Context, account *models.Account) error {
+	stmt := m.builder.
+		Insert("accounts").
+		Columns("id", "sn", "display_name", "email", "first_name", "last_name", "created_at", "updated_at", "is_main_account").
+		Values(account.ID, account.SN, account.DisplayName, account.Email, account.FirstName, account.LastName, account.CreatedAt, account.UpdatedAt, account.ParentID == nil)
+	if account.ParentID != nil {
+		stmt = m.builder.
+			Insert("accounts").
+			Columns("id", "sn", "display_name", "email", "first_name", "last_name", "created_at", "updated_at", "is_main_account", "parent_id", "environment").
+			Values(account.ID, account.SN, account.DisplayName, account.Email, account.FirstName, account.LastName, account.CreatedAt, account.UpdatedAt, false, *account.ParentID, account.Environment)
+	}
+
+	_, err := stmt.RunWith(runner(ctx, m.db)).ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlAccountRepository) SaveCredentials(ctx context.Context, credentials *models.Credentials) error {
+	_, err := m.builder.
+		Insert("credentials").
+		Columns("id", "password").
+		Values(credentials.ID, credentials.Password).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlAccountRepository) SaveWebhookDetails(ctx context.Context, details *models.WebhookDetails) error {
+	stmt := m.builder.
+		Insert("webhook_details").
+		Columns("id", "callback_url", "webhook_key").
+		Values(details.ID, details.CallbackURL, details.WebhookKey)
+	_, err := m.dialect.Upsert(stmt, "id", "callback_url", "webhook_key").
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlAccountRepository) FindByID(ctx context.Context, id string) (*models.Account, error) {
+	row := m.selectAccounts().
+		Where(sq.Eq{"accounts.id": id}).
+		Limit(1).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx)
+
+	account, err := scanAccount(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.NewNotFoundError("user not found")
+		}
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return account, nil
+}
+
+func (m *sqlAccountRepository) FindByIDs(ctx context.Context, ids []string) ([]*models.Account, error) {
+	if len(ids) == 0 {
+		return []*models.Account{}, nil
+	}
+
+	rows, err := m.selectAccounts().
+		Where(sq.Eq{"accounts.id": ids}).
+		RunWith(runner(ctx, m.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	res := make([]*models.Account, 0, len(ids))
+	for rows.Next() {
+		account, err := scanAccount(rows)
+		if err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		res = append(res, account)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return res, nil
+}
+
+func (m *sqlAccountRepository) FindByAccessToken(ctx context.Context, token string) (*models.Account, error) {
+	row := m.builder.
+		Select("accounts.id", "accounts.email", "webhook_details.callback_url", "accounts.display_name", "webhook_details.webhook_key", "access_tokens.environment").
+		From("access_tokens").
+		Join("accounts on access_tokens.account_id = accounts.id").
+		LeftJoin("webhook_details on webhook_details.id = accounts.id").
+		Where(sq.Eq{"token": token}).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx)
+
+	account := &models.Account{}
+	err := row.Scan(&account.ID, &account.Email, &account.WebhookDetails.CallbackURL, &account.DisplayName, &account.WebhookDetails.WebhookKey, &account.Environment)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return account, nil
+}
+
+func (m *sqlAccountRepository) ListSubAccounts(ctx context.Context, parentID string, env models.Environment, page requests.Pagination) ([]*models.Account, *responses.Pagination, error) {
+	db := runner(ctx, m.db)
+	stmt, pagination, err := Paginate(
+		ctx, db,
+		m.builder.Select().From("accounts").Where(sq.Eq{"parent_id": parentID, "environment": env}),
+		page, "created_at", "id",
+		"id", "sn", "display_name", "email", "first_name", "last_name", "created_at", "updated_at", "environment", "frozen",
+		"phone_number", "date_of_birth", "country", "kyc_tier",
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := stmt.RunWith(db).QueryContext(ctx)
+	if err != nil {
+		return nil, nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	res := make([]*models.Account, 0)
+	for rows.Next() {
+		acc := &models.Account{ParentID: &parentID}
+		err := rows.Scan(
+			&acc.ID, &acc.SN, &acc.DisplayName, &acc.Email, &acc.FirstName, &acc.LastName, &acc.CreatedAt, &acc.UpdatedAt, &acc.Environment, &acc.Frozen,
+			&acc.PhoneNumber, &acc.DateOfBirth, &acc.Country, &acc.KYCTier,
+		)
+		if err != nil {
+			return nil, nil, errors.HandleDataDBError(err)
+		}
+		res = append(res, acc)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, errors.HandleDataDBError(err)
+	}
+	res = TrimPage(res, pagination, func(acc *models.Account) any {
+		return KeysetCursor{CreatedAt: *acc.CreatedAt, ID: acc.ID}
+	})
+
+	return res, pagination, nil
+}
+
+func (m *sqlAccountRepository) Update(ctx context.Context, account *models.Account) error {
+	_, err := m.builder.
+		Update("accounts").
+		Set("first_name", account.FirstName).
+		Set("last_name", account.LastName).
+		Set("phone_number", account.PhoneNumber).
+		Set("date_of_birth", account.DateOfBirth).
+		Set("country", account.Country).
+		Set("updated_at", account.UpdatedAt).
+		Where(sq.Eq{"id": account.ID}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlAccountRepository) SetFrozen(ctx context.Context, id string, frozen bool) error {
+	_, err := m.builder.
+		Update("accounts").
+		Set("frozen", frozen).
+		Where(sq.Eq{"id": id}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlAccountRepository) SetKYCTier(ctx context.Context, id string, tier models.KYCTier) error {
+	_, err := m.builder.
+		Update("accounts").
+		Set("kyc_tier", tier).
+		Where(sq.Eq{"id": id}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlAccountRepository) Delete(ctx context.Context, id string) error {
+	db := runner(ctx, m.db)
+	for _, stmt := range []sq.DeleteBuilder{
+		m.builder.Delete("credentials").Where(sq.Eq{"id": id}),
+		m.builder.Delete("accounts").Where(sq.Eq{"id": id}),
+	} {
+		if _, err := stmt.RunWith(db).ExecContext(ctx); err != nil {
+			return errors.HandleDataDBError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/errors"
+	sq "github.com/Masterminds/squirrel"
+)
+
+// FixtureRepository records the fixture steps that have been seeded with the id of what each made
+type FixtureRepository interface {
+	// Find returns the ref recorded for the step, it reports false when the step has not been seeded
+	Find(ctx context.Context, id string) (string, bool, error)
+	Record(ctx context.Context, id string, ref string, at time.Time) error
+}
+
+func NewSQLFixtureRepository(dataDatabase *sql.DB) FixtureRepository {
+	return &sqlFixtureRepository{db: dataDatabase, builder: db.DialectOf(dataDatabase).Builder()}
+}
+
+type sqlFixtureRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+func (m *sqlFixtureRepository) Find(ctx context.Context, id string) (string, bool, error) {
+	var ref string
+	err := m.builder.
+		Select("ref").
+		From("seeded_fixtures").
+		Where(sq.Eq{"id": id}).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx).
+		Scan(&ref)
+	switch {
+	case err == nil:
+		return ref, true, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return "", false, nil
+	default:
+		return "", false, errors.HandleDataDBError(err)
+	}
+}
+
+func (m *sqlFixtureRepository) Record(ctx context.Context, id string, ref string, at time.Time) error {
+	_, err := m.builder.
+		Insert("seeded_fixtures").
+		Columns("id", "ref", "applied_at").
+		Values(id, ref, at).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	sq "github.com/Masterminds/squirrel"
+)
+
+type IdempotencyRepository interface {
+	// Reserve stores the key for its request, or returns the stored key when the account has already used it
+	Reserve(context.Context, *models.IdempotencyKey) (*models.IdempotencyKey, error)
+	// TakeOver reserves the stored key for its request again when it has not been completed and its lease has run
+	// out before now, it reports whether the key was taken over
+	TakeOver(ctx context.Context, key *models.IdempotencyKey, now time.Time) (bool, error)
+	// Complete stores the response of the key's request
+	Complete(context.Context, *models.IdempotencyKey) error
+	Delete(ctx context.Context, accountID string, key string) error
+}
+
+func NewSQLIdempotencyRepository(dataDatabase *sql.DB) IdempotencyRepository {
+	return &sqlIdempotencyRepository{db: dataDatabase, builder: db.DialectOf(dataDatabase).Builder()}
+}
+
+type sqlIdempotencyRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+func (m *sqlIdempotencyRepository) Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	_, insertErr := m.builder.
+		Insert("idempotency_keys").
+		Columns("account_id", "idempotency_key", "request", "created_at", "lease_expires_at").
+		Values(key.AccountID, key.Key, key.Request, key.CreatedAt, key.LeaseExpiresAt).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if insertErr == nil {
+		return key, nil
+	}
+
+	// the insert fails on the primary key when the key has been used, the stored row is returned in that case
+	stored := &models.IdempotencyKey{}
+	err := m.builder.
+		Select("account_id", "idempotency_key", "request", "status_code", "response", "created_at", "lease_expires_at").
+		From("idempotency_keys").
+		Where(sq.Eq{"account_id": key.AccountID, "idempotency_key": key.Key}).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx).
+		Scan(&stored.AccountID, &stored.Key, &stored.Request, &stored.StatusCode, &stored.Response, &stored.CreatedAt, &stored.LeaseExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.HandleDataDBError(insertErr)
+		}
+		return nil, errors.HandleDataDBError(err)
+	}
+	return stored, nil
+}
+
+func (m *sqlIdempotencyRepository) TakeOver(ctx context.Context, key *models.IdempotencyKey, now time.Time) (bool, error) {
+	res, err := m.builder.
+		Update("idempotency_keys").
+		Set("created_at", key.CreatedAt).
+		Set("lease_expires_at", key.LeaseExpiresAt).
+		Where(sq.Eq{"account_id": key.AccountID, "idempotency_key": key.Key, "request": key.Request, "status_code": nil}).
+		Where(sq.Or{
+			sq.Eq{"lease_expires_at": nil},
+			sq.Lt{"lease_expires_at": now},
+		}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+	return n > 0, nil
+}
+
+func (m *sqlIdempotencyRepository) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+	_, err := m.builder.
+		Update("idempotency_keys").
+		Set("status_code", key.StatusCode).
+		Set("response", key.Response).
+		Where(sq.Eq{"account_id": key.AccountID, "idempotency_key": key.Key}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlIdempotencyRepository) Delete(ctx context.Context, accountID string, key string) error {
+	_, err := m.builder.
+		Delete("idempotency_keys").
+		Where(sq.Eq{"account_id": accountID, "idempotency_key": key}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
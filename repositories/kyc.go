@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	sq "github.com/Masterminds/squirrel"
+)
+
+type KYCRepository interface {
+	Create(context.Context, *models.KYCSubmission) error
+	// Find returns the account's submission
+	Find(ctx context.Context, id string, accountID string) (*models.KYCSubmission, error)
+	// List returns the requested page of the account's submissions, newest first
+	List(ctx context.Context, accountID string, p requests.Pagination) ([]*models.KYCSubmission, *responses.Pagination, error)
+	// Decide records the decision on the submission when it is still pending, it reports whether it was
+	Decide(ctx context.Context, id string, decision *models.KYCDecision, at time.Time) (bool, error)
+}
+
+func NewSQLKYCRepository(dataDatabase *sql.DB) KYCRepository {
+	return &sqlKYCRepository{db: dataDatabase, builder: db.DialectOf(dataDatabase).Builder()}
+}
+
+type sqlKYCRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+var kycSubmissionColumns = []string{
+	"id", "account_id", "tier", "phone_number", "date_of_birth", "country", "id_type", "id_number", "status", "reason", "created_at", "updated_at",
+}
+
+func scanKYCSubmission(row sq.RowScanner) (*models.KYCSubmission, error) {
+	submission := &models.KYCSubmission{}
+	err := row.Scan(
+		&submission.ID, &submission.AccountID, &submission.Tier, &submission.PhoneNumber, &submission.DateOfBirth, &submission.Country,
+		&submission.IDType, &submission.IDNumber, &submission.Status, &submission.Reason, &submission.CreatedAt, &submission.UpdatedAt,
+	)
+	return submission, err
+}
+
+func (m *sqlKYCRepository) Create(ctx context.Context, submission *models.KYCSubmission) error {
+	_, err := m.builder.
+		Insert("kyc_submissions").
+		Columns("id", "account_id", "tier", "phone_number", "date_of_birth", "country", "id_type", "id_number", "status", "created_at", "updated_at").
+		Values(submission.ID, submission.AccountID, submission.Tier, submission.PhoneNumber, submission.DateOfBirth, submission.Country, submission.IDType, submission.IDNumber, submission.Status, submission.CreatedAt, submission.UpdatedAt).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlKYCRepository) Find(ctx context.Context, id string, accountID string) (*models.KYCSubmission, error) {
+	row := m.builder.
+		Select(kycSubmissionColumns...).
+		From("kyc_submissions").
+		Where(sq.Eq{"id": id, "account_id": accountID}).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx)
+
+	submission, err := scanKYCSubmission(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.NewNotFoundError("kyc submission not found")
+		}
+		return nil, errors.HandleDataDBError(err)
+	}
+	return submission, nil
+}
+
+func (m *sqlKYCRepository) List(ctx context.Context, accountID string, p requests.Pagination) ([]*models.KYCSubmission, *responses.Pagination, error) {
+	stmt, pagination, err := Paginate(
+		ctx,
+		runner(ctx, m.db),
+		m.builder.Select().From("kyc_submissions").Where(sq.Eq{"account_id": accountID}),
+		p, "created_at", "id",
+		kycSubmissionColumns...,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := stmt.RunWith(runner(ctx, m.db)).QueryContext(ctx)
+	if err != nil {
+		return nil, nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	res := make([]*models.KYCSubmission, 0)
+	for rows.Next() {
+		submission, err := scanKYCSubmission(rows)
+		if err != nil {
+			return nil, nil, errors.HandleDataDBError(err)
+		}
+		res = append(res, submission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, errors.HandleDataDBError(err)
+	}
+	res = TrimPage(res, pagination, func(submission *models.KYCSubmission) any {
+		return KeysetCursor{CreatedAt: *submission.CreatedAt, ID: submission.ID}
+	})
+
+	return res, pagination, nil
+}
+
+func (m *sqlKYCRepository) Decide(ctx context.Context, id string, decision *models.KYCDecision, at time.Time) (bool, error) {
+	// only a pending submission is decided, so of two decisions made at the same time only one applies
+	res, err := m.builder.
+		Update("kyc_submissions").
+		Set("status", decision.Status).
+		Set("reason", decision.Reason).
+		Set("updated_at", at).
+		Where(sq.Eq{"id": id, "status": models.Pending_KYCStatus}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+	return n > 0, nil
+}
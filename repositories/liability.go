@@ -0,0 +1,262 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"math/big"
+
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/merkle"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	sq "github.com/Masterminds/squirrel"
+)
+
+// LiabilityRepository stores liability snapshots with the root and leaves of each currency's tree
+type LiabilityRepository interface {
+	Create(context.Context, *models.LiabilitySnapshot) error
+	// Finish saves the snapshot's status, reason and completion time
+	Finish(context.Context, *models.LiabilitySnapshot) error
+	// Find returns the snapshot without its roots
+	Find(context.Context, string) (*models.LiabilitySnapshot, error)
+	// ListCompleted returns the requested page of completed snapshots of the environment, or of every
+	// environment when it is nil, newest first and without their roots
+	ListCompleted(context.Context, *models.Environment, requests.Pagination) ([]*models.LiabilitySnapshot, *responses.Pagination, error)
+	// SaveTree stores the root of the currency's tree with its leaves, in tree order
+	SaveTree(ctx context.Context, snapshotID string, root *models.LiabilityRoot, leaves []merkle.Leaf) error
+	// Roots returns the roots of the snapshot ordered by currency
+	Roots(ctx context.Context, snapshotID string) ([]*models.LiabilityRoot, error)
+	// Leaves returns the leaves of the currency's tree in tree order
+	Leaves(ctx context.Context, snapshotID string, currency string) ([]merkle.Leaf, error)
+	// LeafIndexes returns the position of the user's leaf in the tree of each currency the user has one in
+	LeafIndexes(ctx context.Context, snapshotID string, userID string) (map[string]int, error)
+}
+
+func NewSQLLiabilityRepository(dataDatabase *sql.DB) LiabilityRepository {
+	return &sqlLiabilityRepository{db: dataDatabase, builder: db.DialectOf(dataDatabase).Builder()}
+}
+
+type sqlLiabilityRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+var liabilitySnapshotColumns = []string{"id", "environment", "status", "reason", "ledger_timestamp", "created_at", "completed_at"}
+
+func scanLiabilitySnapshot(row sq.RowScanner) (*models.LiabilitySnapshot, error) {
+	snapshot := &models.LiabilitySnapshot{}
+	err := row.Scan(
+		&snapshot.ID, &snapshot.Environment, &snapshot.Status, &snapshot.Reason, &snapshot.LedgerTimestamp,
+		&snapshot.CreatedAt, &snapshot.CompletedAt,
+	)
+	return snapshot, err
+}
+
+func (m *sqlLiabilityRepository) Create(ctx context.Context, snapshot *models.LiabilitySnapshot) error {
+	_, err := m.builder.
+		Insert("liability_snapshots").
+		Columns("id", "environment", "status", "ledger_timestamp", "created_at").
+		Values(snapshot.ID, snapshot.Environment, snapshot.Status, snapshot.LedgerTimestamp, snapshot.CreatedAt).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlLiabilityRepository) Finish(ctx context.Context, snapshot *models.LiabilitySnapshot) error {
+	_, err := m.builder.
+		Update("liability_snapshots").
+		Set("status", snapshot.Status).
+		Set("reason", snapshot.Reason).
+		Set("completed_at", snapshot.CompletedAt).
+		Where(sq.Eq{"id": snapshot.ID}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlLiabilityRepository) Find(ctx context.Context, id string) (*models.LiabilitySnapshot, error) {
+	row := m.builder.
+		Select(liabilitySnapshotColumns...).
+		From("liability_snapshots").
+		Where(sq.Eq{"id": id}).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx)
+
+	snapshot, err := scanLiabilitySnapshot(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.NewNotFoundError("liability snapshot not found")
+		}
+		return nil, errors.HandleDataDBError(err)
+	}
+	return snapshot, nil
+}
+
+func (m *sqlLiabilityRepository) ListCompleted(ctx context.Context, env *models.Environment, p requests.Pagination) ([]*models.LiabilitySnapshot, *responses.Pagination, error) {
+	filter := sq.Eq{"status": models.Completed_LiabilitySnapshotStatus}
+	if env != nil {
+		filter["environment"] = *env
+	}
+
+	stmt, pagination, err := Paginate(
+		ctx, runner(ctx, m.db),
+		m.builder.Select().From("liability_snapshots").Where(filter),
+		p, "created_at", "id",
+		liabilitySnapshotColumns...,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := stmt.RunWith(runner(ctx, m.db)).QueryContext(ctx)
+	if err != nil {
+		return nil, nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	res := make([]*models.LiabilitySnapshot, 0)
+	for rows.Next() {
+		snapshot, err := scanLiabilitySnapshot(rows)
+		if err != nil {
+			return nil, nil, errors.HandleDataDBError(err)
+		}
+		res = append(res, snapshot)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, errors.HandleDataDBError(err)
+	}
+	res = TrimPage(res, pagination, func(snapshot *models.LiabilitySnapshot) any {
+		return KeysetCursor{CreatedAt: snapshot.CreatedAt, ID: snapshot.ID}
+	})
+
+	return res, pagination, nil
+}
+
+func (m *sqlLiabilityRepository) SaveTree(ctx context.Context, snapshotID string, root *models.LiabilityRoot, leaves []merkle.Leaf) error {
+	const batchSize = 500
+	for start := 0; start < len(leaves); start += batchSize {
+		stmt := m.builder.
+			Insert("liability_leaves").
+			Columns("snapshot_id", "currency", "leaf_index", "user_id", "wallet_id", "nonce", "balance")
+		for i, leaf := range leaves[start:min(start+batchSize, len(leaves))] {
+			stmt = stmt.Values(snapshotID, root.Currency, start+i, leaf.UserID, leaf.WalletID, leaf.Nonce, leaf.Balance.String())
+		}
+		if _, err := stmt.RunWith(runner(ctx, m.db)).ExecContext(ctx); err != nil {
+			return errors.HandleDataDBError(err)
+		}
+	}
+	_, err := m.builder.
+		Insert("liability_roots").
+		Columns("snapshot_id", "currency", "root_hash", "total", "leaf_count").
+		Values(snapshotID, root.Currency, root.Hash.String(), root.Total.String(), root.Leaves).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlLiabilityRepository) Roots(ctx context.Context, snapshotID string) ([]*models.LiabilityRoot, error) {
+	rows, err := m.builder.
+		Select("currency", "root_hash", "total", "leaf_count").
+		From("liability_roots").
+		Where(sq.Eq{"snapshot_id": snapshotID}).
+		OrderBy("currency").
+		RunWith(runner(ctx, m.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	roots := make([]*models.LiabilityRoot, 0)
+	for rows.Next() {
+		root := &models.LiabilityRoot{}
+		var hash, total string
+		if err := rows.Scan(&root.Currency, &hash, &total, &root.Leaves); err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		if err := root.Hash.UnmarshalText([]byte(hash)); err != nil {
+			return nil, errors.NewImplementationError()
+		}
+		var ok bool
+		if root.Total, ok = new(big.Int).SetString(total, 10); !ok {
+			return nil, errors.NewImplementationError()
+		}
+		roots = append(roots, root)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return roots, nil
+}
+
+func (m *sqlLiabilityRepository) Leaves(ctx context.Context, snapshotID string, currency string) ([]merkle.Leaf, error) {
+	rows, err := m.builder.
+		Select("user_id", "wallet_id", "nonce", "balance").
+		From("liability_leaves").
+		Where(sq.Eq{"snapshot_id": snapshotID, "currency": currency}).
+		OrderBy("leaf_index").
+		RunWith(runner(ctx, m.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	leaves := make([]merkle.Leaf, 0)
+	for rows.Next() {
+		var leaf merkle.Leaf
+		var balance string
+		if err := rows.Scan(&leaf.UserID, &leaf.WalletID, &leaf.Nonce, &balance); err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		var ok bool
+		if leaf.Balance, ok = new(big.Int).SetString(balance, 10); !ok {
+			return nil, errors.NewImplementationError()
+		}
+		leaves = append(leaves, leaf)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return leaves, nil
+}
+
+func (m *sqlLiabilityRepository) LeafIndexes(ctx context.Context, snapshotID string, userID string) (map[string]int, error) {
+	rows, err := m.builder.
+		Select("currency", "leaf_index").
+		From("liability_leaves").
+		Where(sq.Eq{"snapshot_id": snapshotID, "user_id": userID}).
+		RunWith(runner(ctx, m.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	indexes := map[string]int{}
+	for rows.Next() {
+		var currency string
+		var index int
+		if err := rows.Scan(&currency, &index); err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		indexes[currency] = index
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return indexes, nil
+}
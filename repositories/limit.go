@@ -0,0 +1,158 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	sq "github.com/Masterminds/squirrel"
+)
+
+type LimitRepository interface {
+	// Find returns the limits the main account set for the operation on the currency for customers of the kyc
+	// tier, nil when it set none
+	Find(ctx context.Context, accountID string, tier models.KYCTier, currency string, op models.LimitOperation) (*models.TransactionLimit, error)
+	// Set replaces the limits the main account set for the operation on the currency for customers of the kyc tier
+	Set(ctx context.Context, accountID string, tier models.KYCTier, currency string, op models.LimitOperation, limit models.TransactionLimit) error
+	// Usage returns the amounts moved out of the wallet with the operation in each of the periods, periods
+	// nothing was moved in are left out
+	Usage(ctx context.Context, walletID string, op models.LimitOperation, periods ...string) (map[string]float64, error)
+	// Reserve adds the amount to the wallet's usage for the period unless the usage would exceed the limit,
+	// a nil limit is unlimited. It reports whether the amount was added
+	Reserve(ctx context.Context, walletID string, op models.LimitOperation, period string, amount float64, limit *float64) (bool, error)
+	// Release deducts a reserved amount from the wallet's usage for the period
+	Release(ctx context.Context, walletID string, op models.LimitOperation, period string, amount float64) error
+}
+
+func NewSQLLimitRepository(dataDatabase *sql.DB) LimitRepository {
+	dialect := db.DialectOf(dataDatabase)
+	return &sqlLimitRepository{db: dataDatabase, dialect: dialect, builder: dialect.Builder()}
+}
+
+type sqlLimitRepository struct {
+	db      *sql.DB
+	dialect db.Dialect
+	builder sq.StatementBuilderType
+}
+
+func (m *sqlLimitRepository) Find(ctx context.Context, accountID string, tier models.KYCTier, currency string, op models.LimitOperation) (*models.TransactionLimit, error) {
+	var single, daily, monthly sql.NullFloat64
+	err := m.builder.
+		Select("single_limit", "daily_limit", "monthly_limit").
+		From("transaction_limits").
+		Where(sq.Eq{"account_id": accountID, "kyc_tier": tier, "currency": currency, "operation": op}).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx).
+		Scan(&single, &daily, &monthly)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	limit := &models.TransactionLimit{}
+	if single.Valid {
+		limit.Single = &single.Float64
+	}
+	if daily.Valid {
+		limit.Daily = &daily.Float64
+	}
+	if monthly.Valid {
+		limit.Monthly = &monthly.Float64
+	}
+	return limit, nil
+}
+
+func (m *sqlLimitRepository) Set(ctx context.Context, accountID string, tier models.KYCTier, currency string, op models.LimitOperation, limit models.TransactionLimit) error {
+	insert := m.builder.
+		Insert("transaction_limits").
+		Columns("account_id", "kyc_tier", "currency", "operation", "single_limit", "daily_limit", "monthly_limit").
+		Values(accountID, tier, currency, op, limit.Single, limit.Daily, limit.Monthly)
+	_, err := m.dialect.Upsert(insert, "account_id, kyc_tier, currency, operation", "single_limit", "daily_limit", "monthly_limit").
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlLimitRepository) Usage(ctx context.Context, walletID string, op models.LimitOperation, periods ...string) (map[string]float64, error) {
+	rows, err := m.builder.
+		Select("period", "used").
+		From("limit_usage").
+		Where(sq.Eq{"wallet_id": walletID, "operation": op, "period": periods}).
+		RunWith(runner(ctx, m.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	usage := make(map[string]float64, len(periods))
+	for rows.Next() {
+		var period string
+		var used float64
+		if err = rows.Scan(&period, &used); err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		usage[period] = used
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return usage, nil
+}
+
+func (m *sqlLimitRepository) Reserve(ctx context.Context, walletID string, op models.LimitOperation, period string, amount float64, limit *float64) (bool, error) {
+	// the period's row is created empty when it does not exist yet, the key is rewritten with itself when it does
+	insert := m.builder.
+		Insert("limit_usage").
+		Columns("wallet_id", "operation", "period", "used").
+		Values(walletID, op, period, 0)
+	_, err := m.dialect.Upsert(insert, "wallet_id, operation, period", "period").
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+
+	// the usage is checked against the limit by the update itself, so of two reservations made together only
+	// those that fit are added
+	where := sq.And{sq.Eq{"wallet_id": walletID, "operation": op, "period": period}}
+	if limit != nil {
+		where = append(where, sq.Expr("used + ? <= ?", amount, *limit))
+	}
+	res, err := m.builder.
+		Update("limit_usage").
+		Set("used", sq.Expr("used + ?", amount)).
+		Where(where).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+
+	return n > 0, nil
+}
+
+func (m *sqlLimitRepository) Release(ctx context.Context, walletID string, op models.LimitOperation, period string, amount float64) error {
+	_, err := m.builder.
+		Update("limit_usage").
+		Set("used", sq.Expr("used - ?", amount)).
+		Where(sq.Eq{"wallet_id": walletID, "operation": op, "period": period}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
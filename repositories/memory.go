@@ -0,0 +1,1246 @@
+package repositories
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"maps"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/merkle"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+)
+
+// memoryStore keeps the rows of every in-memory repository, the repositories share it so lookups that join
+// tables in the data database see the same rows. Values are copied in and out so callers can not change stored rows
+type memoryStore struct {
+	mu sync.RWMutex
+	memoryTables
+}
+
+type memoryTables struct {
+	accounts    map[string]models.Account
+	credentials map[string]models.Credentials
+	webhooks    map[string]models.WebhookDetails
+	wallets     map[string]models.Wallet
+	tokens      map[string]models.AccessToken
+	withdrawals map[string]models.Withdrawal
+	swaps       map[string]models.InstantSwap
+	// idempotency keys by account id and key
+	idempotencyKeys map[[2]string]models.IdempotencyKey
+	limitUsage      map[limitUsageKey]float64
+	limits          map[transactionLimitKey]models.TransactionLimit
+	rateLimits      map[rateLimitKey]models.RateLimit
+	kycSubmissions  map[string]models.KYCSubmission
+	snapshots       map[string]models.LiabilitySnapshot
+	// liability roots ordered by currency and leaves in tree order, by snapshot id and by snapshot id and currency
+	liabilityRoots  map[string][]models.LiabilityRoot
+	liabilityLeaves map[[2]string][]merkle.Leaf
+	reports         map[string]models.ReconciliationReport
+	// reconciliation issues in order, by report id
+	issues         map[string][]models.ReconciliationIssue
+	sagas          map[string]models.Saga
+	exports        map[string]models.StatementExport
+	seededFixtures map[string]string
+}
+
+// clone copies the tables, rows are replaced rather than changed in place so the rows themselves are shared
+func (t *memoryTables) clone() memoryTables {
+	return memoryTables{
+		accounts:        maps.Clone(t.accounts),
+		credentials:     maps.Clone(t.credentials),
+		webhooks:        maps.Clone(t.webhooks),
+		wallets:         maps.Clone(t.wallets),
+		tokens:          maps.Clone(t.tokens),
+		withdrawals:     maps.Clone(t.withdrawals),
+		swaps:           maps.Clone(t.swaps),
+		idempotencyKeys: maps.Clone(t.idempotencyKeys),
+		limitUsage:      maps.Clone(t.limitUsage),
+		limits:          maps.Clone(t.limits),
+		rateLimits:      maps.Clone(t.rateLimits),
+		kycSubmissions:  maps.Clone(t.kycSubmissions),
+		snapshots:       maps.Clone(t.snapshots),
+		liabilityRoots:  maps.Clone(t.liabilityRoots),
+		liabilityLeaves: maps.Clone(t.liabilityLeaves),
+		reports:         maps.Clone(t.reports),
+		issues:          maps.Clone(t.issues),
+		sagas:           maps.Clone(t.sagas),
+		exports:         maps.Clone(t.exports),
+		seededFixtures:  maps.Clone(t.seededFixtures),
+	}
+}
+
+// NewMemoryRepositories returns repositories that keep their rows in memory, for running services without a
+// database. A transaction holds the store's lock until it ends, so transactions run one at a time and a rolled
+// back transaction leaves the rows as they were when it began
+func NewMemoryRepositories() *Repositories {
+	store := &memoryStore{memoryTables: memoryTables{
+		accounts:    map[string]models.Account{},
+		credentials: map[string]models.Credentials{},
+		webhooks:    map[string]models.WebhookDetails{},
+		wallets:     map[string]models.Wallet{},
+		tokens:      map[string]models.AccessToken{},
+		withdrawals: map[string]models.Withdrawal{},
+		swaps:       map[string]models.InstantSwap{},
+
+		idempotencyKeys: map[[2]string]models.IdempotencyKey{},
+		limitUsage:      map[limitUsageKey]float64{},
+		limits:          map[transactionLimitKey]models.TransactionLimit{},
+		rateLimits:      map[rateLimitKey]models.RateLimit{},
+		kycSubmissions:  map[string]models.KYCSubmission{},
+		snapshots:       map[string]models.LiabilitySnapshot{},
+		liabilityRoots:  map[string][]models.LiabilityRoot{},
+		liabilityLeaves: map[[2]string][]merkle.Leaf{},
+		reports:         map[string]models.ReconciliationReport{},
+		issues:          map[string][]models.ReconciliationIssue{},
+		sagas:           map[string]models.Saga{},
+		exports:         map[string]models.StatementExport{},
+		seededFixtures:  map[string]string{},
+	}}
+	return &Repositories{
+		Transactor:     &memoryTransactor{store},
+		Accounts:       &memoryAccountRepository{store},
+		Wallets:        &memoryWalletRepository{store},
+		Tokens:         &memoryTokenRepository{store},
+		Withdrawals:    &memoryWithdrawalRepository{store},
+		Swaps:          &memorySwapRepository{store},
+		Idempotency:    &memoryIdempotencyRepository{store},
+		Limits:         &memoryLimitRepository{store},
+		KYC:            &memoryKYCRepository{store},
+		Liabilities:    &memoryLiabilityRepository{store},
+		Reconciliation: &memoryReconciliationRepository{store},
+		Sagas:          &memorySagaRepository{store},
+		Statements:     &memoryStatementExportRepository{store},
+		Fixtures:       &memoryFixtureRepository{store},
+	}
+}
+
+type memoryTxKey struct{}
+
+// lock takes the write lock of the store and returns its release, the lock is already held when the context is
+// in a transaction of the store. The write is recorded in the context's effects, a transaction's writes are
+// recorded when it succeeds
+func (m *memoryStore) lock(ctx context.Context) func() {
+	if ctx.Value(memoryTxKey{}) == m {
+		return func() {}
+	}
+	m.mu.Lock()
+	models.RecordEffect(ctx)
+	return m.mu.Unlock
+}
+
+// rlock takes the read lock of the store and returns its release, see lock
+func (m *memoryStore) rlock(ctx context.Context) func() {
+	if ctx.Value(memoryTxKey{}) == m {
+		return func() {}
+	}
+	m.mu.RLock()
+	return m.mu.RUnlock
+}
+
+type memoryTransactor struct {
+	*memoryStore
+}
+
+func (m *memoryTransactor) InTx(ctx context.Context, fn func(context.Context) error) error {
+	if ctx.Value(memoryTxKey{}) == m.memoryStore {
+		return fn(ctx)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	saved := m.clone()
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, m.memoryStore)); err != nil {
+		m.memoryTables = saved
+		return err
+	}
+	models.RecordEffect(ctx)
+	return nil
+}
+
+// newestFirst orders rows by creation time and then id, both descending, like the sql repositories page them
+func newestFirst[T any](items []T, position func(T) KeysetCursor) {
+	slices.SortFunc(items, func(a, b T) int {
+		pa, pb := position(a), position(b)
+		if c := pb.CreatedAt.Compare(pa.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(pb.ID, pa.ID)
+	})
+}
+
+// page returns the requested page of items ordered newest first, or all of them when the page is nil
+func page[T any](items []T, p *requests.Pagination, position func(T) KeysetCursor) ([]T, *responses.Pagination, error) {
+	newestFirst(items, position)
+	if p == nil {
+		return items, nil, nil
+	}
+	return PageSlice(items, *p, position)
+}
+
+type memoryAccountRepository struct {
+	*memoryStore
+}
+
+func (m *memoryAccountRepository) Create(ctx context.Context, account *models.Account) error {
+	defer m.lock(ctx)()
+
+	if _, ok := m.accounts[account.ID]; ok {
+		return errors.NewConflictError("account already exists")
+	}
+	stored := *account
+	stored.IsMainAccount = account.ParentID == nil
+	stored.WebhookDetails = models.WebhookDetails{}
+	m.accounts[account.ID] = stored
+	return nil
+}
+
+func (m *memoryAccountRepository) SaveCredentials(ctx context.Context, credentials *models.Credentials) error {
+	defer m.lock(ctx)()
+
+	m.credentials[credentials.ID] = *credentials
+	return nil
+}
+
+func (m *memoryAccountRepository) SaveWebhookDetails(ctx context.Context, details *models.WebhookDetails) error {
+	defer m.lock(ctx)()
+
+	m.webhooks[details.ID] = *details
+	return nil
+}
+
+// account returns a copy of the stored account with its webhook details, the caller must hold the lock
+func (m *memoryAccountRepository) account(id string) (*models.Account, bool) {
+	stored, ok := m.accounts[id]
+	if !ok {
+		return nil, false
+	}
+	account := stored
+	if details, ok := m.webhooks[account.ID]; ok {
+		account.WebhookDetails = models.WebhookDetails{CallbackURL: details.CallbackURL, WebhookKey: details.WebhookKey}
+	} else if account.ParentID != nil {
+		if details, ok := m.webhooks[*account.ParentID]; ok {
+			account.WebhookDetails = models.WebhookDetails{CallbackURL: details.CallbackURL, WebhookKey: details.WebhookKey}
+		}
+	}
+	return &account, true
+}
+
+func (m *memoryAccountRepository) FindByID(ctx context.Context, id string) (*models.Account, error) {
+	defer m.rlock(ctx)()
+
+	account, ok := m.account(id)
+	if !ok {
+		return nil, errors.NewNotFoundError("user not found")
+	}
+	return account, nil
+}
+
+func (m *memoryAccountRepository) FindByIDs(ctx context.Context, ids []string) ([]*models.Account, error) {
+	defer m.rlock(ctx)()
+
+	res := make([]*models.Account, 0, len(ids))
+	for _, id := range ids {
+		if account, ok := m.account(id); ok {
+			res = append(res, account)
+		}
+	}
+	return res, nil
+}
+
+func (m *memoryAccountRepository) FindByAccessToken(ctx context.Context, token string) (*models.Account, error) {
+	defer m.rlock(ctx)()
+
+	for _, accessToken := range m.tokens {
+		if accessToken.Token != token {
+			continue
+		}
+		stored, ok := m.accounts[accessToken.AccountID]
+		if !ok {
+			break
+		}
+		account := &models.Account{ID: stored.ID, Email: stored.Email, DisplayName: stored.DisplayName, Environment: accessToken.Environment}
+		if details, ok := m.webhooks[stored.ID]; ok {
+			account.WebhookDetails = models.WebhookDetails{CallbackURL: details.CallbackURL, WebhookKey: details.WebhookKey}
+		}
+		return account, nil
+	}
+	return nil, errors.HandleDataDBError(sql.ErrNoRows)
+}
+
+func (m *memoryAccountRepository) ListSubAccounts(ctx context.Context, parentID string, env models.Environment, p requests.Pagination) ([]*models.Account, *responses.Pagination, error) {
+	defer m.rlock(ctx)()
+
+	res := make([]*models.Account, 0)
+	for _, stored := range m.accounts {
+		if stored.ParentID == nil || *stored.ParentID != parentID || stored.Environment != env {
+			continue
+		}
+		account := stored
+		res = append(res, &account)
+	}
+	return page(res, &p, func(account *models.Account) KeysetCursor {
+		return KeysetCursor{CreatedAt: *account.CreatedAt, ID: account.ID}
+	})
+}
+
+func (m *memoryAccountRepository) Update(ctx context.Context, account *models.Account) error {
+	defer m.lock(ctx)()
+
+	stored, ok := m.accounts[account.ID]
+	if !ok {
+		return nil
+	}
+	stored.FirstName = account.FirstName
+	stored.LastName = account.LastName
+	stored.PhoneNumber = account.PhoneNumber
+	stored.DateOfBirth = account.DateOfBirth
+	stored.Country = account.Country
+	stored.UpdatedAt = account.UpdatedAt
+	m.accounts[account.ID] = stored
+	return nil
+}
+
+func (m *memoryAccountRepository) SetFrozen(ctx context.Context, id string, frozen bool) error {
+	defer m.lock(ctx)()
+
+	if stored, ok := m.accounts[id]; ok {
+		stored.Frozen = frozen
+		m.accounts[id] = stored
+	}
+	return nil
+}
+
+func (m *memoryAccountRepository) SetKYCTier(ctx context.Context, id string, tier models.KYCTier) error {
+	defer m.lock(ctx)()
+
+	if stored, ok := m.accounts[id]; ok {
+		stored.KYCTier = tier
+		m.accounts[id] = stored
+	}
+	return nil
+}
+
+func (m *memoryAccountRepository) Delete(ctx context.Context, id string) error {
+	defer m.lock(ctx)()
+
+	delete(m.credentials, id)
+	delete(m.accounts, id)
+	return nil
+}
+
+type memoryWalletRepository struct {
+	*memoryStore
+}
+
+// listWallets returns copies of the stored wallets keep accepts ordered by currency, the caller must hold the lock
+func (m *memoryWalletRepository) listWallets(keep func(models.Wallet) bool) []*models.Wallet {
+	res := make([]*models.Wallet, 0)
+	for _, stored := range m.wallets {
+		if !keep(stored) {
+			continue
+		}
+		wallet := stored
+		res = append(res, &wallet)
+	}
+	slices.SortFunc(res, func(a, b *models.Wallet) int {
+		return cmp.Or(cmp.Compare(a.Token, b.Token), cmp.Compare(a.ID, b.ID))
+	})
+	return res
+}
+
+func (m *memoryWalletRepository) Create(ctx context.Context, wallets []*models.Wallet) error {
+	defer m.lock(ctx)()
+
+	for _, wallet := range wallets {
+		if _, ok := m.wallets[wallet.ID]; ok {
+			return errors.NewConflictError("wallet already exists")
+		}
+	}
+	for _, wallet := range wallets {
+		m.wallets[wallet.ID] = *wallet
+	}
+	return nil
+}
+
+func (m *memoryWalletRepository) FindByID(ctx context.Context, id string) (*models.Wallet, error) {
+	defer m.rlock(ctx)()
+
+	wallet, ok := m.wallets[id]
+	if !ok {
+		return nil, errors.NewNotFoundError("wallet not found")
+	}
+	return &wallet, nil
+}
+
+func (m *memoryWalletRepository) FindByAccount(ctx context.Context, accountID string, currency string, env models.Environment) (*models.Wallet, error) {
+	wallets, err := m.ListByAccount(ctx, accountID, env, currency)
+	if err != nil {
+		return nil, err
+	}
+	if len(wallets) == 0 {
+		return nil, errors.NewNotFoundError("wallet not found")
+	}
+	return wallets[0], nil
+}
+
+func (m *memoryWalletRepository) ListByAccount(ctx context.Context, accountID string, env models.Environment, currency string) ([]*models.Wallet, error) {
+	defer m.rlock(ctx)()
+
+	return m.listWallets(func(wallet models.Wallet) bool {
+		return wallet.AccountID == accountID && wallet.Environment == env && (currency == "" || wallet.Token == currency)
+	}), nil
+}
+
+func (m *memoryWalletRepository) FindByIDs(ctx context.Context, ids []string) ([]*models.Wallet, error) {
+	defer m.rlock(ctx)()
+
+	return m.listWallets(func(wallet models.Wallet) bool {
+		return slices.Contains(ids, wallet.ID)
+	}), nil
+}
+
+func (m *memoryWalletRepository) List(ctx context.Context) ([]*models.Wallet, error) {
+	defer m.rlock(ctx)()
+
+	return m.listWallets(func(models.Wallet) bool { return true }), nil
+}
+
+func (m *memoryWalletRepository) SetFrozen(ctx context.Context, id string, frozen bool) error {
+	defer m.lock(ctx)()
+
+	if wallet, ok := m.wallets[id]; ok {
+		wallet.Frozen = frozen
+		m.wallets[id] = wallet
+	}
+	return nil
+}
+
+func (m *memoryWalletRepository) DeleteByAccount(ctx context.Context, accountID string) error {
+	defer m.lock(ctx)()
+
+	for id, wallet := range m.wallets {
+		if wallet.AccountID == accountID {
+			delete(m.wallets, id)
+		}
+	}
+	return nil
+}
+
+type rateLimitKey struct {
+	accessTokenID, routeGroup string
+}
+
+type memoryTokenRepository struct {
+	*memoryStore
+}
+
+func (m *memoryTokenRepository) Create(ctx context.Context, tokens []*models.AccessToken) error {
+	defer m.lock(ctx)()
+
+	for _, token := range tokens {
+		m.tokens[token.ID] = *token
+	}
+	return nil
+}
+
+func (m *memoryTokenRepository) DeleteByAccount(ctx context.Context, accountID string) error {
+	defer m.lock(ctx)()
+
+	for id, token := range m.tokens {
+		if token.AccountID == accountID {
+			delete(m.tokens, id)
+		}
+	}
+	return nil
+}
+
+func (m *memoryTokenRepository) Delete(ctx context.Context, id string) error {
+	defer m.lock(ctx)()
+
+	if _, ok := m.tokens[id]; !ok {
+		return errors.NewNotFoundError("access token not found")
+	}
+	for key := range m.rateLimits {
+		if key.accessTokenID == id {
+			delete(m.rateLimits, key)
+		}
+	}
+	delete(m.tokens, id)
+	return nil
+}
+
+func (m *memoryTokenRepository) RateLimits(ctx context.Context, token string) ([]*models.RateLimit, error) {
+	defer m.rlock(ctx)()
+
+	res := make([]*models.RateLimit, 0)
+	for _, limit := range m.rateLimits {
+		if m.tokens[limit.AccessTokenID].Token == token {
+			res = append(res, &limit)
+		}
+	}
+	return res, nil
+}
+
+func (m *memoryTokenRepository) SetRateLimit(ctx context.Context, limit *models.RateLimit) error {
+	defer m.lock(ctx)()
+
+	if _, ok := m.tokens[limit.AccessTokenID]; !ok {
+		return errors.NewNotFoundError("access token not found")
+	}
+	m.rateLimits[rateLimitKey{limit.AccessTokenID, limit.RouteGroup}] = *limit
+	return nil
+}
+
+type memoryWithdrawalRepository struct {
+	*memoryStore
+}
+
+func copyWithdrawal(withdrawal models.Withdrawal) *models.Withdrawal {
+	if withdrawal.Recipient != nil {
+		recipient := *withdrawal.Recipient
+		if recipient.Details != nil {
+			details := *recipient.Details
+			recipient.Details = &details
+		}
+		withdrawal.Recipient = &recipient
+	}
+	return &withdrawal
+}
+
+// matches reports whether the filter matches the withdrawal, the caller must hold the lock
+func (m *memoryWithdrawalRepository) matches(filter WithdrawalFilter, withdrawal models.Withdrawal) bool {
+	wallet, ok := m.wallets[withdrawal.WalletID]
+	if !ok {
+		return false
+	}
+	var destinationTag string
+	if withdrawal.Recipient != nil && withdrawal.Recipient.Details != nil && withdrawal.Recipient.Details.DestinationTag != nil {
+		destinationTag = *withdrawal.Recipient.Details.DestinationTag
+	}
+
+	switch {
+	case filter.ID != "" && withdrawal.ID != filter.ID:
+		return false
+	case filter.Reference != "" && withdrawal.Ref != filter.Reference:
+		return false
+	case filter.AccountID != "" && wallet.AccountID != filter.AccountID && destinationTag != filter.AccountID:
+		return false
+	case filter.Environment != nil && wallet.Environment != *filter.Environment:
+		return false
+	case filter.Status != nil && withdrawal.Status != *filter.Status:
+		return false
+	case filter.Currency != nil && wallet.Token != *filter.Currency:
+		return false
+	case filter.From != nil && withdrawal.CreatedAt.Before(*filter.From):
+		return false
+	case filter.To != nil && withdrawal.CreatedAt.After(*filter.To):
+		return false
+	case filter.MinAmount > 0 && withdrawal.Amount < filter.MinAmount:
+		return false
+	case filter.MaxAmount > 0 && withdrawal.Amount > filter.MaxAmount:
+		return false
+	default:
+		return true
+	}
+}
+
+func (m *memoryWithdrawalRepository) Create(ctx context.Context, withdrawal *models.Withdrawal) error {
+	defer m.lock(ctx)()
+
+	if _, ok := m.withdrawals[withdrawal.ID]; ok {
+		return errors.NewConflictError("withdrawal already exists")
+	}
+	m.withdrawals[withdrawal.ID] = *copyWithdrawal(*withdrawal)
+	return nil
+}
+
+func (m *memoryWithdrawalRepository) Delete(ctx context.Context, id string) error {
+	defer m.lock(ctx)()
+
+	delete(m.withdrawals, id)
+	return nil
+}
+
+func (m *memoryWithdrawalRepository) SetStatus(ctx context.Context, id string, status models.WithdrawalStatus) error {
+	defer m.lock(ctx)()
+
+	if withdrawal, ok := m.withdrawals[id]; ok {
+		withdrawal.Status = status
+		m.withdrawals[id] = withdrawal
+	}
+	return nil
+}
+
+func (m *memoryWithdrawalRepository) Find(ctx context.Context, filter WithdrawalFilter) (*models.Withdrawal, error) {
+	withdrawals, _, err := m.List(ctx, filter, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(withdrawals) == 0 {
+		return nil, errors.NewNotFoundError("withdrawal not found")
+	}
+	return withdrawals[0], nil
+}
+
+func (m *memoryWithdrawalRepository) List(ctx context.Context, filter WithdrawalFilter, p *requests.Pagination) ([]*models.Withdrawal, *responses.Pagination, error) {
+	defer m.rlock(ctx)()
+
+	res := make([]*models.Withdrawal, 0)
+	for _, withdrawal := range m.withdrawals {
+		if m.matches(filter, withdrawal) {
+			res = append(res, copyWithdrawal(withdrawal))
+		}
+	}
+	return page(res, p, func(withdrawal *models.Withdrawal) KeysetCursor {
+		return KeysetCursor{CreatedAt: withdrawal.CreatedAt, ID: withdrawal.ID}
+	})
+}
+
+func (m *memoryWithdrawalRepository) FindByTxIDs(ctx context.Context, txIDs []string) ([]*models.Withdrawal, error) {
+	defer m.rlock(ctx)()
+
+	res := make([]*models.Withdrawal, 0)
+	for _, withdrawal := range m.withdrawals {
+		if slices.Contains(txIDs, withdrawal.TxID) {
+			res = append(res, copyWithdrawal(withdrawal))
+		}
+	}
+	return res, nil
+}
+
+type memorySwapRepository struct {
+	*memoryStore
+}
+
+// matches reports whether the filter matches the swap, the caller must hold the lock
+func (m *memorySwapRepository) matches(filter SwapFilter, swap models.InstantSwap) bool {
+	from, ok := m.wallets[swap.FromWalletID]
+	if !ok {
+		return false
+	}
+	to, ok := m.wallets[swap.ToWalletID]
+	if !ok {
+		return false
+	}
+
+	switch {
+	case filter.AccountID != "" && from.AccountID != filter.AccountID:
+		return false
+	case filter.Environment != nil && from.Environment != *filter.Environment:
+		return false
+	case filter.Currency != "" && from.Token != filter.Currency && to.Token != filter.Currency:
+		return false
+	case filter.Reference != "" && swap.ID != filter.Reference && swap.QuotationID != filter.Reference:
+		return false
+	case filter.From != nil && swap.CreatedAt.Before(*filter.From):
+		return false
+	case filter.To != nil && swap.CreatedAt.After(*filter.To):
+		return false
+	default:
+		return true
+	}
+}
+
+func (m *memorySwapRepository) Create(ctx context.Context, swap *models.InstantSwap) error {
+	defer m.lock(ctx)()
+
+	if _, ok := m.swaps[swap.ID]; ok {
+		return errors.NewConflictError("swap already exists")
+	}
+	m.swaps[swap.ID] = *swap
+	return nil
+}
+
+func (m *memorySwapRepository) Delete(ctx context.Context, id string) error {
+	defer m.lock(ctx)()
+
+	delete(m.swaps, id)
+	return nil
+}
+
+func (m *memorySwapRepository) FindByID(ctx context.Context, id string) (*models.InstantSwap, error) {
+	defer m.rlock(ctx)()
+
+	swap, ok := m.swaps[id]
+	if !ok {
+		return nil, errors.NewNotFoundError("swap not found")
+	}
+	return &swap, nil
+}
+
+func (m *memorySwapRepository) FindByQuotationID(ctx context.Context, quotationID string) (*models.InstantSwap, error) {
+	defer m.rlock(ctx)()
+
+	for _, swap := range m.swaps {
+		if swap.QuotationID == quotationID {
+			return &swap, nil
+		}
+	}
+	return nil, errors.NewNotFoundError("swap not found")
+}
+
+func (m *memorySwapRepository) List(ctx context.Context, filter SwapFilter, p *requests.Pagination) ([]*models.InstantSwap, *responses.Pagination, error) {
+	defer m.rlock(ctx)()
+
+	res := make([]*models.InstantSwap, 0)
+	for _, stored := range m.swaps {
+		if m.matches(filter, stored) {
+			swap := stored
+			res = append(res, &swap)
+		}
+	}
+	return page(res, p, func(swap *models.InstantSwap) KeysetCursor {
+		return KeysetCursor{CreatedAt: swap.CreatedAt, ID: swap.ID}
+	})
+}
+
+func (m *memorySwapRepository) FindByTxIDs(ctx context.Context, txIDs []string) ([]*models.InstantSwap, error) {
+	defer m.rlock(ctx)()
+
+	res := make([]*models.InstantSwap, 0)
+	for _, stored := range m.swaps {
+		if slices.ContainsFunc([]string{stored.SwapTxID0, stored.SwapTxID1, stored.QuoteTxID0, stored.QuoteTxID1}, func(id string) bool {
+			return slices.Contains(txIDs, id)
+		}) {
+			swap := stored
+			res = append(res, &swap)
+		}
+	}
+	return res, nil
+}
+
+func (m *memorySwapRepository) Settle(ctx context.Context, id string, at time.Time) (bool, error) {
+	defer m.lock(ctx)()
+
+	swap, ok := m.swaps[id]
+	if !ok || swap.SettledAt != nil {
+		return false, nil
+	}
+	swap.SettledAt = &at
+	m.swaps[id] = swap
+	return true, nil
+}
+
+func (m *memorySwapRepository) Unsettle(ctx context.Context, id string) error {
+	defer m.lock(ctx)()
+
+	if swap, ok := m.swaps[id]; ok {
+		swap.SettledAt = nil
+		m.swaps[id] = swap
+	}
+	return nil
+}
+
+func (m *memorySwapRepository) ListUnsettled(ctx context.Context) ([]*models.InstantSwap, error) {
+	defer m.rlock(ctx)()
+
+	res := make([]*models.InstantSwap, 0)
+	for _, stored := range m.swaps {
+		if stored.SettledAt == nil {
+			swap := stored
+			res = append(res, &swap)
+		}
+	}
+	slices.SortFunc(res, func(a, b *models.InstantSwap) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return res, nil
+}
+
+type memoryIdempotencyRepository struct {
+	*memoryStore
+}
+
+func (m *memoryIdempotencyRepository) Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	defer m.lock(ctx)()
+
+	if stored, ok := m.idempotencyKeys[[2]string{key.AccountID, key.Key}]; ok {
+		stored.Response = slices.Clone(stored.Response)
+		return &stored, nil
+	}
+	m.idempotencyKeys[[2]string{key.AccountID, key.Key}] = *key
+	return key, nil
+}
+
+func (m *memoryIdempotencyRepository) TakeOver(ctx context.Context, key *models.IdempotencyKey, now time.Time) (bool, error) {
+	defer m.lock(ctx)()
+
+	stored, ok := m.idempotencyKeys[[2]string{key.AccountID, key.Key}]
+	switch {
+	case !ok, stored.Request != key.Request, stored.StatusCode != nil:
+		return false, nil
+	case stored.LeaseExpiresAt != nil && !stored.LeaseExpiresAt.Before(now):
+		return false, nil
+	}
+	stored.CreatedAt, stored.LeaseExpiresAt = key.CreatedAt, key.LeaseExpiresAt
+	m.idempotencyKeys[[2]string{key.AccountID, key.Key}] = stored
+	return true, nil
+}
+
+func (m *memoryIdempotencyRepository) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+	defer m.lock(ctx)()
+
+	stored, ok := m.idempotencyKeys[[2]string{key.AccountID, key.Key}]
+	if !ok {
+		return nil
+	}
+	stored.StatusCode = key.StatusCode
+	stored.Response = slices.Clone(key.Response)
+	m.idempotencyKeys[[2]string{key.AccountID, key.Key}] = stored
+	return nil
+}
+
+func (m *memoryIdempotencyRepository) Delete(ctx context.Context, accountID string, key string) error {
+	defer m.lock(ctx)()
+
+	delete(m.idempotencyKeys, [2]string{accountID, key})
+	return nil
+}
+
+type limitUsageKey struct {
+	walletID string
+	op       models.LimitOperation
+	period   string
+}
+
+type transactionLimitKey struct {
+	accountID string
+	tier      models.KYCTier
+	currency  string
+	op        models.LimitOperation
+}
+
+type memoryLimitRepository struct {
+	*memoryStore
+}
+
+func (m *memoryLimitRepository) Find(ctx context.Context, accountID string, tier models.KYCTier, currency string, op models.LimitOperation) (*models.TransactionLimit, error) {
+	defer m.rlock(ctx)()
+
+	limit, ok := m.limits[transactionLimitKey{accountID, tier, currency, op}]
+	if !ok {
+		return nil, nil
+	}
+	return &limit, nil
+}
+
+func (m *memoryLimitRepository) Set(ctx context.Context, accountID string, tier models.KYCTier, currency string, op models.LimitOperation, limit models.TransactionLimit) error {
+	defer m.lock(ctx)()
+
+	m.limits[transactionLimitKey{accountID, tier, currency, op}] = limit
+	return nil
+}
+
+func (m *memoryLimitRepository) Usage(ctx context.Context, walletID string, op models.LimitOperation, periods ...string) (map[string]float64, error) {
+	defer m.rlock(ctx)()
+
+	usage := make(map[string]float64, len(periods))
+	for _, period := range periods {
+		if used, ok := m.limitUsage[limitUsageKey{walletID, op, period}]; ok {
+			usage[period] = used
+		}
+	}
+	return usage, nil
+}
+
+func (m *memoryLimitRepository) Reserve(ctx context.Context, walletID string, op models.LimitOperation, period string, amount float64, limit *float64) (bool, error) {
+	defer m.lock(ctx)()
+
+	key := limitUsageKey{walletID, op, period}
+	if limit != nil && m.limitUsage[key]+amount > *limit {
+		return false, nil
+	}
+	m.limitUsage[key] += amount
+	return true, nil
+}
+
+func (m *memoryLimitRepository) Release(ctx context.Context, walletID string, op models.LimitOperation, period string, amount float64) error {
+	defer m.lock(ctx)()
+
+	key := limitUsageKey{walletID, op, period}
+	if _, ok := m.limitUsage[key]; ok {
+		m.limitUsage[key] -= amount
+	}
+	return nil
+}
+
+type memoryKYCRepository struct {
+	*memoryStore
+}
+
+func (m *memoryKYCRepository) Create(ctx context.Context, submission *models.KYCSubmission) error {
+	defer m.lock(ctx)()
+
+	if _, ok := m.kycSubmissions[submission.ID]; ok {
+		return errors.NewConflictError("kyc submission already exists")
+	}
+	stored := *submission
+	stored.Reason, stored.User = nil, nil
+	m.kycSubmissions[submission.ID] = stored
+	return nil
+}
+
+func (m *memoryKYCRepository) Find(ctx context.Context, id string, accountID string) (*models.KYCSubmission, error) {
+	defer m.rlock(ctx)()
+
+	submission, ok := m.kycSubmissions[id]
+	if !ok || submission.AccountID != accountID {
+		return nil, errors.NewNotFoundError("kyc submission not found")
+	}
+	return &submission, nil
+}
+
+func (m *memoryKYCRepository) List(ctx context.Context, accountID string, p requests.Pagination) ([]*models.KYCSubmission, *responses.Pagination, error) {
+	defer m.rlock(ctx)()
+
+	res := make([]*models.KYCSubmission, 0)
+	for _, stored := range m.kycSubmissions {
+		if stored.AccountID == accountID {
+			submission := stored
+			res = append(res, &submission)
+		}
+	}
+	return page(res, &p, func(submission *models.KYCSubmission) KeysetCursor {
+		return KeysetCursor{CreatedAt: *submission.CreatedAt, ID: submission.ID}
+	})
+}
+
+func (m *memoryKYCRepository) Decide(ctx context.Context, id string, decision *models.KYCDecision, at time.Time) (bool, error) {
+	defer m.lock(ctx)()
+
+	submission, ok := m.kycSubmissions[id]
+	if !ok || submission.Status != models.Pending_KYCStatus {
+		return false, nil
+	}
+	submission.Status, submission.Reason, submission.UpdatedAt = decision.Status, decision.Reason, &at
+	m.kycSubmissions[id] = submission
+	return true, nil
+}
+
+type memoryLiabilityRepository struct {
+	*memoryStore
+}
+
+func (m *memoryLiabilityRepository) Create(ctx context.Context, snapshot *models.LiabilitySnapshot) error {
+	defer m.lock(ctx)()
+
+	if _, ok := m.snapshots[snapshot.ID]; ok {
+		return errors.NewConflictError("liability snapshot already exists")
+	}
+	stored := *snapshot
+	stored.Roots = nil
+	m.snapshots[snapshot.ID] = stored
+	return nil
+}
+
+func (m *memoryLiabilityRepository) Finish(ctx context.Context, snapshot *models.LiabilitySnapshot) error {
+	defer m.lock(ctx)()
+
+	if stored, ok := m.snapshots[snapshot.ID]; ok {
+		stored.Status, stored.Reason, stored.CompletedAt = snapshot.Status, snapshot.Reason, snapshot.CompletedAt
+		m.snapshots[snapshot.ID] = stored
+	}
+	return nil
+}
+
+func (m *memoryLiabilityRepository) Find(ctx context.Context, id string) (*models.LiabilitySnapshot, error) {
+	defer m.rlock(ctx)()
+
+	snapshot, ok := m.snapshots[id]
+	if !ok {
+		return nil, errors.NewNotFoundError("liability snapshot not found")
+	}
+	return &snapshot, nil
+}
+
+func (m *memoryLiabilityRepository) ListCompleted(ctx context.Context, env *models.Environment, p requests.Pagination) ([]*models.LiabilitySnapshot, *responses.Pagination, error) {
+	defer m.rlock(ctx)()
+
+	res := make([]*models.LiabilitySnapshot, 0)
+	for _, stored := range m.snapshots {
+		if stored.Status == models.Completed_LiabilitySnapshotStatus && (env == nil || stored.Environment == *env) {
+			snapshot := stored
+			res = append(res, &snapshot)
+		}
+	}
+	return page(res, &p, func(snapshot *models.LiabilitySnapshot) KeysetCursor {
+		return KeysetCursor{CreatedAt: snapshot.CreatedAt, ID: snapshot.ID}
+	})
+}
+
+func (m *memoryLiabilityRepository) SaveTree(ctx context.Context, snapshotID string, root *models.LiabilityRoot, leaves []merkle.Leaf) error {
+	defer m.lock(ctx)()
+
+	roots := append(slices.Clone(m.liabilityRoots[snapshotID]), *root)
+	slices.SortFunc(roots, func(a, b models.LiabilityRoot) int {
+		return cmp.Compare(a.Currency, b.Currency)
+	})
+	m.liabilityRoots[snapshotID] = roots
+	m.liabilityLeaves[[2]string{snapshotID, root.Currency}] = slices.Clone(leaves)
+	return nil
+}
+
+func (m *memoryLiabilityRepository) Roots(ctx context.Context, snapshotID string) ([]*models.LiabilityRoot, error) {
+	defer m.rlock(ctx)()
+
+	roots := make([]*models.LiabilityRoot, 0, len(m.liabilityRoots[snapshotID]))
+	for _, stored := range m.liabilityRoots[snapshotID] {
+		root := stored
+		root.Total = new(big.Int).Set(stored.Total)
+		roots = append(roots, &root)
+	}
+	return roots, nil
+}
+
+func (m *memoryLiabilityRepository) Leaves(ctx context.Context, snapshotID string, currency string) ([]merkle.Leaf, error) {
+	defer m.rlock(ctx)()
+
+	leaves := make([]merkle.Leaf, 0, len(m.liabilityLeaves[[2]string{snapshotID, currency}]))
+	for _, leaf := range m.liabilityLeaves[[2]string{snapshotID, currency}] {
+		leaf.Balance = new(big.Int).Set(leaf.Balance)
+		leaves = append(leaves, leaf)
+	}
+	return leaves, nil
+}
+
+func (m *memoryLiabilityRepository) LeafIndexes(ctx context.Context, snapshotID string, userID string) (map[string]int, error) {
+	defer m.rlock(ctx)()
+
+	indexes := map[string]int{}
+	for key, leaves := range m.liabilityLeaves {
+		if key[0] != snapshotID {
+			continue
+		}
+		for i, leaf := range leaves {
+			if leaf.UserID == userID {
+				indexes[key[1]] = i
+			}
+		}
+	}
+	return indexes, nil
+}
+
+type memoryReconciliationRepository struct {
+	*memoryStore
+}
+
+func (m *memoryReconciliationRepository) Create(ctx context.Context, report *models.ReconciliationReport) error {
+	defer m.lock(ctx)()
+
+	if _, ok := m.reports[report.ID]; ok {
+		return errors.NewConflictError("reconciliation already exists")
+	}
+	stored := *report
+	stored.Issues = nil
+	m.reports[report.ID] = stored
+	return nil
+}
+
+func (m *memoryReconciliationRepository) Finish(ctx context.Context, report *models.ReconciliationReport) error {
+	defer m.lock(ctx)()
+
+	if _, ok := m.reports[report.ID]; ok {
+		stored := *report
+		stored.Issues = nil
+		m.reports[report.ID] = stored
+	}
+	return nil
+}
+
+func (m *memoryReconciliationRepository) AddIssues(ctx context.Context, reportID string, issues []*models.ReconciliationIssue) error {
+	defer m.lock(ctx)()
+
+	stored := slices.Clone(m.issues[reportID])
+	for _, issue := range issues {
+		stored = append(stored, *issue)
+	}
+	m.issues[reportID] = stored
+	return nil
+}
+
+func (m *memoryReconciliationRepository) Find(ctx context.Context, id string) (*models.ReconciliationReport, error) {
+	defer m.rlock(ctx)()
+
+	report, ok := m.reports[id]
+	if !ok {
+		return nil, errors.NewNotFoundError("reconciliation not found")
+	}
+	report.Issues = make([]*models.ReconciliationIssue, 0, len(m.issues[id]))
+	for _, stored := range m.issues[id] {
+		issue := stored
+		report.Issues = append(report.Issues, &issue)
+	}
+	return &report, nil
+}
+
+func (m *memoryReconciliationRepository) List(ctx context.Context, p requests.Pagination) ([]*models.ReconciliationReport, *responses.Pagination, error) {
+	defer m.rlock(ctx)()
+
+	res := make([]*models.ReconciliationReport, 0, len(m.reports))
+	for _, stored := range m.reports {
+		report := stored
+		res = append(res, &report)
+	}
+	return page(res, &p, func(report *models.ReconciliationReport) KeysetCursor {
+		return KeysetCursor{CreatedAt: report.StartedAt, ID: report.ID}
+	})
+}
+
+type memorySagaRepository struct {
+	*memoryStore
+}
+
+func (m *memorySagaRepository) Create(ctx context.Context, saga *models.Saga) error {
+	defer m.lock(ctx)()
+
+	if _, ok := m.sagas[saga.ID]; ok {
+		return errors.NewConflictError("saga already exists")
+	}
+	stored := *saga
+	stored.Payload = slices.Clone(saga.Payload)
+	m.sagas[saga.ID] = stored
+	return nil
+}
+
+func (m *memorySagaRepository) Update(ctx context.Context, saga *models.Saga) (bool, error) {
+	defer m.lock(ctx)()
+
+	stored, ok := m.sagas[saga.ID]
+	if !ok || !equalPointers(stored.Owner, saga.Owner) {
+		return false, nil
+	}
+	stored.Step, stored.State, stored.Reason, stored.UpdatedAt = saga.Step, saga.State, saga.Reason, saga.UpdatedAt
+	stored.LeaseExpiresAt = saga.LeaseExpiresAt
+	m.sagas[saga.ID] = stored
+	return true, nil
+}
+
+func (m *memorySagaRepository) Claim(ctx context.Context, id string, owner string, now time.Time, leaseExpiresAt time.Time) (bool, error) {
+	defer m.lock(ctx)()
+
+	stored, ok := m.sagas[id]
+	if !ok {
+		return false, nil
+	}
+	if stored.Owner != nil && *stored.Owner != owner && stored.LeaseExpiresAt != nil && !stored.LeaseExpiresAt.Before(now) {
+		return false, nil
+	}
+	stored.Owner, stored.LeaseExpiresAt = &owner, &leaseExpiresAt
+	m.sagas[id] = stored
+	return true, nil
+}
+
+func (m *memorySagaRepository) ListUnfinished(ctx context.Context) ([]*models.Saga, error) {
+	defer m.rlock(ctx)()
+
+	sagas := make([]*models.Saga, 0)
+	for _, stored := range m.sagas {
+		if stored.State == models.Running_SagaState || stored.State == models.Compensating_SagaState {
+			saga := stored
+			saga.Payload = slices.Clone(stored.Payload)
+			sagas = append(sagas, &saga)
+		}
+	}
+	slices.SortFunc(sagas, func(a, b *models.Saga) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return sagas, nil
+}
+
+// equalPointers reports whether both pointers are nil or point to equal values
+func equalPointers[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+type memoryStatementExportRepository struct {
+	*memoryStore
+}
+
+func (m *memoryStatementExportRepository) Create(ctx context.Context, export *models.StatementExport) error {
+	defer m.lock(ctx)()
+
+	if _, ok := m.exports[export.ID]; ok {
+		return errors.NewConflictError("statement export already exists")
+	}
+	m.exports[export.ID] = *export
+	return nil
+}
+
+func (m *memoryStatementExportRepository) Finish(ctx context.Context, export *models.StatementExport) error {
+	defer m.lock(ctx)()
+
+	if stored, ok := m.exports[export.ID]; ok {
+		stored.Status, stored.Reason, stored.CompletedAt = export.Status, export.Reason, export.CompletedAt
+		m.exports[export.ID] = stored
+	}
+	return nil
+}
+
+func (m *memoryStatementExportRepository) Find(ctx context.Context, id string, accountID string) (*models.StatementExport, error) {
+	defer m.rlock(ctx)()
+
+	export, ok := m.exports[id]
+	if !ok || export.AccountID != accountID {
+		return nil, errors.NewNotFoundError("statement export not found")
+	}
+	return &export, nil
+}
+
+func (m *memoryStatementExportRepository) ListPending(ctx context.Context) ([]*models.StatementExport, error) {
+	defer m.rlock(ctx)()
+
+	exports := make([]*models.StatementExport, 0)
+	for _, export := range m.exports {
+		if export.Status == models.Pending_StatementExportStatus {
+			exports = append(exports, &export)
+		}
+	}
+	slices.SortFunc(exports, func(a, b *models.StatementExport) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return exports, nil
+}
+
+func (m *memoryStatementExportRepository) ListCompletedBefore(ctx context.Context, before time.Time) ([]*models.StatementExport, error) {
+	defer m.rlock(ctx)()
+
+	exports := make([]*models.StatementExport, 0)
+	for _, export := range m.exports {
+		if export.Status == models.Completed_StatementExportStatus && export.CompletedAt.Before(before) {
+			exports = append(exports, &export)
+		}
+	}
+	slices.SortFunc(exports, func(a, b *models.StatementExport) int {
+		return a.CompletedAt.Compare(*b.CompletedAt)
+	})
+	return exports, nil
+}
+
+type memoryFixtureRepository struct {
+	*memoryStore
+}
+
+func (m *memoryFixtureRepository) Find(ctx context.Context, id string) (string, bool, error) {
+	defer m.rlock(ctx)()
+
+	ref, ok := m.seededFixtures[id]
+	return ref, ok, nil
+}
+
+func (m *memoryFixtureRepository) Record(ctx context.Context, id string, ref string, _ time.Time) error {
+	defer m.lock(ctx)()
+
+	m.seededFixtures[id] = ref
+	return nil
+}
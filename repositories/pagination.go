@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	"github.com/2HgO/quidax-go/utils"
+	sq "github.com/Masterminds/squirrel"
+)
+
+// KeysetCursor is the position of the last row of a page in a table ordered by creation time
+type KeysetCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"i"`
+}
+
+// Paginate counts the rows matched by stmt, which must not select any columns yet, and returns the query for
+// the requested page, newest first. One row more than the page size is selected so callers can tell whether there is a next page
+func Paginate(ctx context.Context, db sq.BaseRunner, stmt sq.SelectBuilder, page requests.Pagination, createdAtColumn string, idColumn string, columns ...string) (sq.SelectBuilder, *responses.Pagination, error) {
+	pagination := &responses.Pagination{Page: page.Page, PerPage: page.PerPage}
+
+	err := stmt.Columns("count(*)").RunWith(db).QueryRowContext(ctx).Scan(&pagination.Total)
+	if err != nil {
+		return stmt, nil, errors.HandleDataDBError(err)
+	}
+
+	stmt = stmt.
+		Columns(columns...).
+		OrderBy(createdAtColumn+" desc", idColumn+" desc").
+		Limit(uint64(page.PerPage + 1))
+
+	switch page.Cursor {
+	case "":
+		stmt = stmt.Offset(uint64(page.Offset()))
+	default:
+		var cursor KeysetCursor
+		if err = utils.DecodeCursor(page.Cursor, &cursor); err != nil {
+			return stmt, nil, err
+		}
+		stmt = stmt.Where(sq.Or{
+			sq.Lt{createdAtColumn: cursor.CreatedAt},
+			sq.And{sq.Eq{createdAtColumn: cursor.CreatedAt}, sq.Lt{idColumn: cursor.ID}},
+		})
+	}
+
+	return stmt, pagination, nil
+}
+
+// TrimPage drops the extra row selected by Paginate and sets the cursor for the next page when there is one
+func TrimPage[T any](items []T, pagination *responses.Pagination, cursor func(T) any) []T {
+	if len(items) <= pagination.PerPage {
+		return items
+	}
+	items = items[:pagination.PerPage]
+	pagination.NextCursor = utils.EncodeCursor(cursor(items[len(items)-1]))
+	return items
+}
+
+// PageSlice returns the requested page of items that were filtered in memory, items must be ordered newest first
+func PageSlice[T any](items []T, page requests.Pagination, position func(T) KeysetCursor) ([]T, *responses.Pagination, error) {
+	pagination := &responses.Pagination{Page: page.Page, PerPage: page.PerPage, Total: len(items)}
+
+	start := min(page.Offset(), len(items))
+	if page.Cursor != "" {
+		var cursor KeysetCursor
+		if err := utils.DecodeCursor(page.Cursor, &cursor); err != nil {
+			return nil, nil, err
+		}
+		start = len(items)
+		for i, item := range items {
+			p := position(item)
+			if p.CreatedAt.Before(cursor.CreatedAt) || (p.CreatedAt.Equal(cursor.CreatedAt) && p.ID < cursor.ID) {
+				start = i
+				break
+			}
+		}
+	}
+
+	items = items[start:min(start+page.PerPage+1, len(items))]
+	items = TrimPage(items, pagination, func(item T) any {
+		return position(item)
+	})
+
+	return items, pagination, nil
+}
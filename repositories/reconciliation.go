@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	sq "github.com/Masterminds/squirrel"
+)
+
+type ReconciliationRepository interface {
+	Create(context.Context, *models.ReconciliationReport) error
+	// Finish saves the report's status, reason, counts and completion time
+	Finish(context.Context, *models.ReconciliationReport) error
+	// AddIssues stores the report's issues in order
+	AddIssues(ctx context.Context, reportID string, issues []*models.ReconciliationIssue) error
+	// Find returns the report with its issues
+	Find(context.Context, string) (*models.ReconciliationReport, error)
+	// List returns the requested page of reports without their issues, newest first
+	List(context.Context, requests.Pagination) ([]*models.ReconciliationReport, *responses.Pagination, error)
+}
+
+func NewSQLReconciliationRepository(dataDatabase *sql.DB) ReconciliationRepository {
+	return &sqlReconciliationRepository{db: dataDatabase, builder: db.DialectOf(dataDatabase).Builder()}
+}
+
+type sqlReconciliationRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+var reconciliationReportColumns = []string{
+	"id", "trigger_source", "status", "reason", "wallets_checked", "withdrawals_checked", "swaps_checked", "issue_count", "started_at", "completed_at",
+}
+
+func scanReconciliationReport(row sq.RowScanner) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{}
+	err := row.Scan(
+		&report.ID, &report.Trigger, &report.Status, &report.Reason, &report.WalletsChecked, &report.WithdrawalsChecked,
+		&report.SwapsChecked, &report.IssueCount, &report.StartedAt, &report.CompletedAt,
+	)
+	return report, err
+}
+
+func (m *sqlReconciliationRepository) Create(ctx context.Context, report *models.ReconciliationReport) error {
+	_, err := m.builder.
+		Insert("reconciliation_reports").
+		Columns("id", "trigger_source", "status", "started_at").
+		Values(report.ID, report.Trigger, report.Status, report.StartedAt).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlReconciliationRepository) Finish(ctx context.Context, report *models.ReconciliationReport) error {
+	_, err := m.builder.
+		Update("reconciliation_reports").
+		Set("status", report.Status).
+		Set("reason", report.Reason).
+		Set("wallets_checked", report.WalletsChecked).
+		Set("withdrawals_checked", report.WithdrawalsChecked).
+		Set("swaps_checked", report.SwapsChecked).
+		Set("issue_count", report.IssueCount).
+		Set("completed_at", report.CompletedAt).
+		Where(sq.Eq{"id": report.ID}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlReconciliationRepository) AddIssues(ctx context.Context, reportID string, issues []*models.ReconciliationIssue) error {
+	const batchSize = 500
+	for start := 0; start < len(issues); start += batchSize {
+		stmt := m.builder.
+			Insert("reconciliation_issues").
+			Columns("report_id", "seq", "type", "resource", "resource_id", "environment", "detail")
+		for i, issue := range issues[start:min(start+batchSize, len(issues))] {
+			stmt = stmt.Values(reportID, start+i, issue.Type, issue.Resource, issue.ResourceID, issue.Environment, issue.Detail)
+		}
+		if _, err := stmt.RunWith(runner(ctx, m.db)).ExecContext(ctx); err != nil {
+			return errors.HandleDataDBError(err)
+		}
+	}
+	return nil
+}
+
+func (m *sqlReconciliationRepository) Find(ctx context.Context, id string) (*models.ReconciliationReport, error) {
+	row := m.builder.
+		Select(reconciliationReportColumns...).
+		From("reconciliation_reports").
+		Where(sq.Eq{"id": id}).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx)
+
+	report, err := scanReconciliationReport(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.NewNotFoundError("reconciliation not found")
+		}
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	rows, err := m.builder.
+		Select("type", "resource", "resource_id", "environment", "detail").
+		From("reconciliation_issues").
+		Where(sq.Eq{"report_id": report.ID}).
+		OrderBy("seq").
+		RunWith(runner(ctx, m.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	report.Issues = make([]*models.ReconciliationIssue, 0, report.IssueCount)
+	for rows.Next() {
+		issue := &models.ReconciliationIssue{}
+		if err := rows.Scan(&issue.Type, &issue.Resource, &issue.ResourceID, &issue.Environment, &issue.Detail); err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return report, nil
+}
+
+func (m *sqlReconciliationRepository) List(ctx context.Context, p requests.Pagination) ([]*models.ReconciliationReport, *responses.Pagination, error) {
+	stmt, pagination, err := Paginate(
+		ctx, runner(ctx, m.db),
+		m.builder.Select().From("reconciliation_reports"),
+		p, "started_at", "id",
+		reconciliationReportColumns...,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := stmt.RunWith(runner(ctx, m.db)).QueryContext(ctx)
+	if err != nil {
+		return nil, nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	res := make([]*models.ReconciliationReport, 0)
+	for rows.Next() {
+		report, err := scanReconciliationReport(rows)
+		if err != nil {
+			return nil, nil, errors.HandleDataDBError(err)
+		}
+		res = append(res, report)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, errors.HandleDataDBError(err)
+	}
+	res = TrimPage(res, pagination, func(report *models.ReconciliationReport) any {
+		return KeysetCursor{CreatedAt: report.StartedAt, ID: report.ID}
+	})
+
+	return res, pagination, nil
+}
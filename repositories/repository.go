@@ -0,0 +1,117 @@
+// Package repositories reads and writes the rows of the data database behind interfaces, each repository
+// has a sql implementation, which follows the dialect of the database it is given, and an in-memory
+// implementation for running services without a database
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Transactor runs work that must be kept or dropped as a whole
+type Transactor interface {
+	// InTx runs fn in a transaction that is committed when fn succeeds and rolled back when it fails.
+	// Repositories called with the context fn is given take part in the transaction, a call made with a
+	// context that is already in one joins it
+	InTx(ctx context.Context, fn func(context.Context) error) error
+}
+
+// Repositories are the repositories of one data database backend
+type Repositories struct {
+	Transactor     Transactor
+	Accounts       AccountRepository
+	Wallets        WalletRepository
+	Tokens         TokenRepository
+	Withdrawals    WithdrawalRepository
+	Swaps          SwapRepository
+	Idempotency    IdempotencyRepository
+	Limits         LimitRepository
+	KYC            KYCRepository
+	Liabilities    LiabilityRepository
+	Reconciliation ReconciliationRepository
+	Sagas          SagaRepository
+	Statements     StatementExportRepository
+	Fixtures       FixtureRepository
+}
+
+// NewSQLRepositories returns the repositories of the database
+func NewSQLRepositories(dataDatabase *sql.DB) *Repositories {
+	return &Repositories{
+		Transactor:     NewSQLTransactor(dataDatabase),
+		Accounts:       NewSQLAccountRepository(dataDatabase),
+		Wallets:        NewSQLWalletRepository(dataDatabase),
+		Tokens:         NewSQLTokenRepository(dataDatabase),
+		Withdrawals:    NewSQLWithdrawalRepository(dataDatabase),
+		Swaps:          NewSQLSwapRepository(dataDatabase),
+		Idempotency:    NewSQLIdempotencyRepository(dataDatabase),
+		Limits:         NewSQLLimitRepository(dataDatabase),
+		KYC:            NewSQLKYCRepository(dataDatabase),
+		Liabilities:    NewSQLLiabilityRepository(dataDatabase),
+		Reconciliation: NewSQLReconciliationRepository(dataDatabase),
+		Sagas:          NewSQLSagaRepository(dataDatabase),
+		Statements:     NewSQLStatementExportRepository(dataDatabase),
+		Fixtures:       NewSQLFixtureRepository(dataDatabase),
+	}
+}
+
+type txKey struct{}
+
+func NewSQLTransactor(dataDatabase *sql.DB) Transactor {
+	return &sqlTransactor{db: dataDatabase}
+}
+
+type sqlTransactor struct {
+	db *sql.DB
+}
+
+func (s *sqlTransactor) InTx(ctx context.Context, fn func(context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	defer tx.Rollback()
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	// a commit that fails may still have been applied
+	err = tx.Commit()
+	models.RecordEffect(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+// runner returns the transaction in the context, or the database when there is none. Statements run on the
+// database record their changes in the context's effects, those run in a transaction are recorded when it
+// is committed
+func runner(ctx context.Context, db *sql.DB) sq.StdSqlCtx {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return effectRunner{db}
+}
+
+type effectRunner struct {
+	*sql.DB
+}
+
+func (r effectRunner) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	res, err := r.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		models.RecordEffect(ctx)
+	}
+	return res, nil
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	sq "github.com/Masterminds/squirrel"
+)
+
+type SagaRepository interface {
+	Create(context.Context, *models.Saga) error
+	// Update saves the saga's step, state, reason, update time and lease while the saga is owned by its owner,
+	// it reports whether it did
+	Update(context.Context, *models.Saga) (bool, error)
+	// Claim makes the owner the saga's owner until the lease expires, when the saga is already the owner's or
+	// the lease of its owner has run out. It reports whether the saga was claimed
+	Claim(ctx context.Context, id string, owner string, now time.Time, leaseExpiresAt time.Time) (bool, error)
+	// ListUnfinished returns the sagas that are running or compensating, oldest first
+	ListUnfinished(context.Context) ([]*models.Saga, error)
+}
+
+func NewSQLSagaRepository(dataDatabase *sql.DB) SagaRepository {
+	return &sqlSagaRepository{db: dataDatabase, builder: db.DialectOf(dataDatabase).Builder()}
+}
+
+type sqlSagaRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+func (m *sqlSagaRepository) Create(ctx context.Context, saga *models.Saga) error {
+	_, err := m.builder.
+		Insert("sagas").
+		Columns("id", "kind", "payload", "state", "step", "created_at", "updated_at", "owner", "lease_expires_at").
+		Values(saga.ID, saga.Kind, string(saga.Payload), saga.State, saga.Step, saga.CreatedAt, saga.UpdatedAt, saga.Owner, saga.LeaseExpiresAt).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlSagaRepository) Update(ctx context.Context, saga *models.Saga) (bool, error) {
+	res, err := m.builder.
+		Update("sagas").
+		Set("step", saga.Step).
+		Set("state", saga.State).
+		Set("reason", saga.Reason).
+		Set("updated_at", saga.UpdatedAt).
+		Set("lease_expires_at", saga.LeaseExpiresAt).
+		Where(sq.Eq{"id": saga.ID, "owner": saga.Owner}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+	return n > 0, nil
+}
+
+func (m *sqlSagaRepository) Claim(ctx context.Context, id string, owner string, now time.Time, leaseExpiresAt time.Time) (bool, error) {
+	res, err := m.builder.
+		Update("sagas").
+		Set("owner", owner).
+		Set("lease_expires_at", leaseExpiresAt).
+		Where(sq.Eq{"id": id}).
+		Where(sq.Or{
+			sq.Eq{"owner": owner},
+			sq.Eq{"owner": nil},
+			sq.Eq{"lease_expires_at": nil},
+			sq.Lt{"lease_expires_at": now},
+		}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+	return n > 0, nil
+}
+
+func (m *sqlSagaRepository) ListUnfinished(ctx context.Context) ([]*models.Saga, error) {
+	rows, err := m.builder.
+		Select("id", "kind", "payload", "state", "step", "reason", "created_at", "updated_at", "owner", "lease_expires_at").
+		From("sagas").
+		Where(sq.Eq{"state": []models.SagaState{models.Running_SagaState, models.Compensating_SagaState}}).
+		OrderBy("created_at").
+		RunWith(runner(ctx, m.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	sagas := make([]*models.Saga, 0)
+	for rows.Next() {
+		saga := &models.Saga{}
+		var payload []byte
+		err := rows.Scan(
+			&saga.ID, &saga.Kind, &payload, &saga.State, &saga.Step, &saga.Reason, &saga.CreatedAt, &saga.UpdatedAt,
+			&saga.Owner, &saga.LeaseExpiresAt,
+		)
+		if err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		saga.Payload = payload
+		sagas = append(sagas, saga)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return sagas, nil
+}
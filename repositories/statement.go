@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	sq "github.com/Masterminds/squirrel"
+)
+
+type StatementExportRepository interface {
+	Create(context.Context, *models.StatementExport) error
+	// Finish saves the export's status, reason and completion time
+	Finish(context.Context, *models.StatementExport) error
+	// Find returns the account's export
+	Find(ctx context.Context, id string, accountID string) (*models.StatementExport, error)
+	// ListPending returns the exports that have not finished, oldest first
+	ListPending(context.Context) ([]*models.StatementExport, error)
+	// ListCompletedBefore returns the exports that completed before the time, oldest first
+	ListCompletedBefore(context.Context, time.Time) ([]*models.StatementExport, error)
+}
+
+func NewSQLStatementExportRepository(dataDatabase *sql.DB) StatementExportRepository {
+	return &sqlStatementExportRepository{db: dataDatabase, builder: db.DialectOf(dataDatabase).Builder()}
+}
+
+type sqlStatementExportRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+func (m *sqlStatementExportRepository) Create(ctx context.Context, export *models.StatementExport) error {
+	_, err := m.builder.
+		Insert("statement_exports").
+		Columns("id", "account_id", "format", "currency", "period_from", "period_to", "status", "created_at").
+		Values(export.ID, export.AccountID, export.Format, export.Currency, export.From, export.To, export.Status, export.CreatedAt).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlStatementExportRepository) Finish(ctx context.Context, export *models.StatementExport) error {
+	_, err := m.builder.
+		Update("statement_exports").
+		Set("status", export.Status).
+		Set("reason", export.Reason).
+		Set("completed_at", export.CompletedAt).
+		Where(sq.Eq{"id": export.ID}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+var statementExportColumns = []string{
+	"id", "account_id", "format", "currency", "period_from", "period_to", "status", "reason", "created_at", "completed_at",
+}
+
+func scanStatementExport(row sq.RowScanner) (*models.StatementExport, error) {
+	export := &models.StatementExport{}
+	err := row.Scan(
+		&export.ID, &export.AccountID, &export.Format, &export.Currency, &export.From, &export.To,
+		&export.Status, &export.Reason, &export.CreatedAt, &export.CompletedAt,
+	)
+	return export, err
+}
+
+func (m *sqlStatementExportRepository) Find(ctx context.Context, id string, accountID string) (*models.StatementExport, error) {
+	row := m.builder.
+		Select(statementExportColumns...).
+		From("statement_exports").
+		Where(sq.Eq{"id": id, "account_id": accountID}).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx)
+
+	export, err := scanStatementExport(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.NewNotFoundError("statement export not found")
+		}
+		return nil, errors.HandleDataDBError(err)
+	}
+	return export, nil
+}
+
+func (m *sqlStatementExportRepository) ListPending(ctx context.Context) ([]*models.StatementExport, error) {
+	rows, err := m.builder.
+		Select(statementExportColumns...).
+		From("statement_exports").
+		Where(sq.Eq{"status": models.Pending_StatementExportStatus}).
+		OrderBy("created_at").
+		RunWith(runner(ctx, m.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	exports := make([]*models.StatementExport, 0)
+	for rows.Next() {
+		export, err := scanStatementExport(rows)
+		if err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		exports = append(exports, export)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return exports, nil
+}
+
+func (m *sqlStatementExportRepository) ListCompletedBefore(ctx context.Context, before time.Time) ([]*models.StatementExport, error) {
+	rows, err := m.builder.
+		Select(statementExportColumns...).
+		From("statement_exports").
+		Where(sq.Eq{"status": models.Completed_StatementExportStatus}).
+		Where(sq.Lt{"completed_at": before}).
+		OrderBy("completed_at").
+		RunWith(runner(ctx, m.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	exports := make([]*models.StatementExport, 0)
+	for rows.Next() {
+		export, err := scanStatementExport(rows)
+		if err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		exports = append(exports, export)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return exports, nil
+}
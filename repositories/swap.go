@@ -0,0 +1,258 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/2HgO/quidax-go/db"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	sq "github.com/Masterminds/squirrel"
+)
+
+// SwapFilter narrows down instant swaps, unset fields match every swap
+type SwapFilter struct {
+	// AccountID matches swaps from the account's wallets
+	AccountID string
+	// Environment matches the environment of the wallet swapped from
+	Environment *models.Environment
+	// Currency matches swaps from or to the currency
+	Currency string
+	// Reference matches the swap's id or its quotation id
+	Reference string
+	From      *time.Time
+	To        *time.Time
+}
+
+type SwapRepository interface {
+	Create(context.Context, *models.InstantSwap) error
+	Delete(context.Context, string) error
+	FindByID(context.Context, string) (*models.InstantSwap, error)
+	FindByQuotationID(context.Context, string) (*models.InstantSwap, error)
+	// List returns the requested page of swaps matched by the filter newest first, or all of them when the
+	// page is nil
+	List(context.Context, SwapFilter, *requests.Pagination) ([]*models.InstantSwap, *responses.Pagination, error)
+	// FindByTxIDs returns the swaps whose holds or swap transfers are among the transfers
+	FindByTxIDs(context.Context, []string) ([]*models.InstantSwap, error)
+	// Settle marks the swap as settled unless it already is, it reports whether it did. Of a confirmation and a
+	// reversal made at the same time only one settles the swap
+	Settle(ctx context.Context, id string, at time.Time) (bool, error)
+	// Unsettle marks the swap's holds as pending again, for confirmations that are undone
+	Unsettle(context.Context, string) error
+	// ListUnsettled returns the swaps whose holds are pending, oldest first
+	ListUnsettled(context.Context) ([]*models.InstantSwap, error)
+}
+
+func NewSQLSwapRepository(dataDatabase *sql.DB) SwapRepository {
+	return &sqlSwapRepository{db: dataDatabase, builder: db.DialectOf(dataDatabase).Builder()}
+}
+
+type sqlSwapRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+var swapColumns = []string{
+	"instant_swaps.id", "quotation_id", "from_wallet_id", "to_wallet_id", "quotation_rate", "execution_rate",
+	"swap_tx_id_0", "swap_tx_id_1", "quote_tx_id_0", "quote_tx_id_1", "instant_swaps.created_at", "settled_at",
+}
+
+func scanSwap(row sq.RowScanner) (*models.InstantSwap, error) {
+	swap := &models.InstantSwap{}
+	err := row.Scan(
+		&swap.ID,
+		&swap.QuotationID,
+		&swap.FromWalletID,
+		&swap.ToWalletID,
+		&swap.QuotationRate,
+		&swap.ExecutionRate,
+		&swap.SwapTxID0,
+		&swap.SwapTxID1,
+		&swap.QuoteTxID0,
+		&swap.QuoteTxID1,
+		&swap.CreatedAt,
+		&swap.SettledAt,
+	)
+	return swap, err
+}
+
+// filterSwaps selects the swaps matched by the filter joined with both of their wallets, without any columns
+func (m *sqlSwapRepository) filterSwaps(filter SwapFilter) sq.SelectBuilder {
+	stmt := m.builder.
+		Select().
+		From("instant_swaps").
+		Join("wallets on wallets.id = instant_swaps.from_wallet_id").
+		Join("wallets as to_wallets on to_wallets.id = instant_swaps.to_wallet_id")
+	if filter.AccountID != "" {
+		stmt = stmt.Where(sq.Eq{"wallets.account_id": filter.AccountID})
+	}
+	if filter.Environment != nil {
+		stmt = stmt.Where(sq.Eq{"wallets.environment": *filter.Environment})
+	}
+	if filter.Currency != "" {
+		stmt = stmt.Where(sq.Or{sq.Eq{"wallets.token": filter.Currency}, sq.Eq{"to_wallets.token": filter.Currency}})
+	}
+	if filter.Reference != "" {
+		stmt = stmt.Where(sq.Or{sq.Eq{"instant_swaps.id": filter.Reference}, sq.Eq{"instant_swaps.quotation_id": filter.Reference}})
+	}
+	if filter.From != nil {
+		stmt = stmt.Where(sq.GtOrEq{"instant_swaps.created_at": *filter.From})
+	}
+	if filter.To != nil {
+		stmt = stmt.Where(sq.LtOrEq{"instant_swaps.created_at": *filter.To})
+	}
+	return stmt
+}
+
+func (m *sqlSwapRepository) querySwaps(ctx context.Context, stmt sq.SelectBuilder) ([]*models.InstantSwap, error) {
+	rows, err := stmt.RunWith(runner(ctx, m.db)).QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	swaps := make([]*models.InstantSwap, 0)
+	for rows.Next() {
+		swap, err := scanSwap(rows)
+		if err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		swaps = append(swaps, swap)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return swaps, nil
+}
+
+func (m *sqlSwapRepository) findSwap(ctx context.Context, where sq.Eq) (*models.InstantSwap, error) {
+	row := m.builder.
+		Select(swapColumns...).
+		From("instant_swaps").
+		Where(where).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx)
+
+	swap, err := scanSwap(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.NewNotFoundError("swap not found")
+		}
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return swap, nil
+}
+
+func (m *sqlSwapRepository) Create(ctx context.Context, swap *models.InstantSwap) error {
+	_, err := m.builder.
+		Insert("instant_swaps").
+		Columns("id", "quotation_id", "from_wallet_id", "to_wallet_id", "quotation_rate", "execution_rate", "swap_tx_id_0", "swap_tx_id_1", "quote_tx_id_0", "quote_tx_id_1", "created_at").
+		Values(swap.ID, swap.QuotationID, swap.FromWalletID, swap.ToWalletID, swap.QuotationRate, swap.ExecutionRate, swap.SwapTxID0, swap.SwapTxID1, swap.QuoteTxID0, swap.QuoteTxID1, swap.CreatedAt).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlSwapRepository) Delete(ctx context.Context, id string) error {
+	_, err := m.builder.
+		Delete("instant_swaps").
+		Where(sq.Eq{"id": id}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlSwapRepository) FindByID(ctx context.Context, id string) (*models.InstantSwap, error) {
+	return m.findSwap(ctx, sq.Eq{"id": id})
+}
+
+func (m *sqlSwapRepository) FindByQuotationID(ctx context.Context, quotationID string) (*models.InstantSwap, error) {
+	return m.findSwap(ctx, sq.Eq{"quotation_id": quotationID})
+}
+
+func (m *sqlSwapRepository) List(ctx context.Context, filter SwapFilter, page *requests.Pagination) ([]*models.InstantSwap, *responses.Pagination, error) {
+	stmt := m.filterSwaps(filter)
+	if page == nil {
+		swaps, err := m.querySwaps(ctx, stmt.Columns(swapColumns...).OrderBy("instant_swaps.created_at desc", "instant_swaps.id desc"))
+		return swaps, nil, err
+	}
+
+	stmt, pagination, err := Paginate(ctx, runner(ctx, m.db), stmt, *page, "instant_swaps.created_at", "instant_swaps.id", swapColumns...)
+	if err != nil {
+		return nil, nil, err
+	}
+	swaps, err := m.querySwaps(ctx, stmt)
+	if err != nil {
+		return nil, nil, err
+	}
+	swaps = TrimPage(swaps, pagination, func(swap *models.InstantSwap) any {
+		return KeysetCursor{CreatedAt: swap.CreatedAt, ID: swap.ID}
+	})
+
+	return swaps, pagination, nil
+}
+
+func (m *sqlSwapRepository) FindByTxIDs(ctx context.Context, txIDs []string) ([]*models.InstantSwap, error) {
+	if len(txIDs) == 0 {
+		return []*models.InstantSwap{}, nil
+	}
+	return m.querySwaps(ctx, m.builder.
+		Select(swapColumns...).
+		From("instant_swaps").
+		Where(sq.Or{
+			sq.Eq{"swap_tx_id_0": txIDs},
+			sq.Eq{"swap_tx_id_1": txIDs},
+			sq.Eq{"quote_tx_id_0": txIDs},
+			sq.Eq{"quote_tx_id_1": txIDs},
+		}))
+}
+
+func (m *sqlSwapRepository) Settle(ctx context.Context, id string, at time.Time) (bool, error) {
+	res, err := m.builder.
+		Update("instant_swaps").
+		Set("settled_at", at).
+		Where(sq.Eq{"id": id, "settled_at": nil}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.HandleDataDBError(err)
+	}
+	return n > 0, nil
+}
+
+func (m *sqlSwapRepository) Unsettle(ctx context.Context, id string) error {
+	_, err := m.builder.
+		Update("instant_swaps").
+		Set("settled_at", nil).
+		Where(sq.Eq{"id": id}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlSwapRepository) ListUnsettled(ctx context.Context) ([]*models.InstantSwap, error) {
+	return m.querySwaps(ctx, m.builder.
+		Select(swapColumns...).
+		From("instant_swaps").
+		Where(sq.Eq{"settled_at": nil}).
+		OrderBy("created_at"),
+	)
+}
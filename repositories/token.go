@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	sq "github.com/Masterminds/squirrel"
+)
+
+type TokenRepository interface {
+	Create(context.Context, []*models.AccessToken) error
+	DeleteByAccount(context.Context, string) error
+	// Delete removes the access token and its rate limits
+	Delete(context.Context, string) error
+	// RateLimits returns the rate limits configured for the access token
+	RateLimits(context.Context, string) ([]*models.RateLimit, error)
+	// SetRateLimit creates or replaces the access token's limit for the route group
+	SetRateLimit(context.Context, *models.RateLimit) error
+}
+
+func NewSQLTokenRepository(dataDatabase *sql.DB) TokenRepository {
+	dialect := db.DialectOf(dataDatabase)
+	return &sqlTokenRepository{db: dataDatabase, dialect: dialect, builder: dialect.Builder()}
+}
+
+type sqlTokenRepository struct {
+	db      *sql.DB
+	dialect db.Dialect
+	builder sq.StatementBuilderType
+}
+
+func (m *sqlTokenRepository) Create(ctx context.Context, tokens []*models.AccessToken) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	stmt := m.builder.
+		Insert("access_tokens").
+		Columns("id", "name", "description", "account_id", "token", "environment")
+	for _, token := range tokens {
+		stmt = stmt.Values(token.ID, token.Name, token.Description, token.AccountID, token.Token, token.Environment)
+	}
+
+	_, err := stmt.
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlTokenRepository) DeleteByAccount(ctx context.Context, accountID string) error {
+	_, err := m.builder.
+		Delete("access_tokens").
+		Where(sq.Eq{"account_id": accountID}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlTokenRepository) Delete(ctx context.Context, id string) error {
+	_, err := m.builder.
+		Delete("rate_limits").
+		Where(sq.Eq{"access_token_id": id}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+
+	res, err := m.builder.
+		Delete("access_tokens").
+		Where(sq.Eq{"id": id}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return errors.HandleDataDBError(err)
+	} else if n == 0 {
+		return errors.NewNotFoundError("access token not found")
+	}
+	return nil
+}
+
+func (m *sqlTokenRepository) RateLimits(ctx context.Context, token string) ([]*models.RateLimit, error) {
+	rows, err := m.builder.
+		Select("rate_limits.access_token_id", "rate_limits.route_group", "rate_limits.rate", "rate_limits.burst").
+		From("rate_limits").
+		Join("access_tokens on access_tokens.id = rate_limits.access_token_id").
+		Where(sq.Eq{"access_tokens.token": token}).
+		RunWith(runner(ctx, m.db)).
+		QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	res := make([]*models.RateLimit, 0)
+	for rows.Next() {
+		limit := &models.RateLimit{}
+		err := rows.Scan(&limit.AccessTokenID, &limit.RouteGroup, &limit.Rate, &limit.Burst)
+		if err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		res = append(res, limit)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return res, nil
+}
+
+func (m *sqlTokenRepository) SetRateLimit(ctx context.Context, limit *models.RateLimit) error {
+	var id string
+	err := m.builder.
+		Select("id").
+		From("access_tokens").
+		Where(sq.Eq{"id": limit.AccessTokenID}).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx).
+		Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.NewNotFoundError("access token not found")
+		}
+		return errors.HandleDataDBError(err)
+	}
+
+	insert := m.builder.
+		Insert("rate_limits").
+		Columns("access_token_id", "route_group", "rate", "burst").
+		Values(limit.AccessTokenID, limit.RouteGroup, limit.Rate, limit.Burst)
+	_, err = m.dialect.Upsert(insert, "access_token_id, route_group", "rate", "burst").
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
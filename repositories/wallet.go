@@ -0,0 +1,162 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/2HgO/quidax-go/db"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	sq "github.com/Masterminds/squirrel"
+)
+
+type WalletRepository interface {
+	Create(context.Context, []*models.Wallet) error
+	FindByID(context.Context, string) (*models.Wallet, error)
+	// FindByAccount returns the account's wallet for the currency in the environment
+	FindByAccount(context.Context, string, string, models.Environment) (*models.Wallet, error)
+	// ListByAccount returns the account's wallets in the environment ordered by currency, limited to the
+	// currency when one is given
+	ListByAccount(context.Context, string, models.Environment, string) ([]*models.Wallet, error)
+	FindByIDs(context.Context, []string) ([]*models.Wallet, error)
+	// List returns every wallet of every account
+	List(context.Context) ([]*models.Wallet, error)
+	SetFrozen(context.Context, string, bool) error
+	DeleteByAccount(context.Context, string) error
+}
+
+func NewSQLWalletRepository(dataDatabase *sql.DB) WalletRepository {
+	return &sqlWalletRepository{db: dataDatabase, builder: db.DialectOf(dataDatabase).Builder()}
+}
+
+type sqlWalletRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+func (m *sqlWalletRepository) selectWallets() sq.SelectBuilder {
+	return m.builder.
+		Select("id", "account_id", "token", "environment", "frozen").
+		From("wallets")
+}
+
+func scanWallet(row sq.RowScanner) (*models.Wallet, error) {
+	wallet := &models.Wallet{}
+	err := row.Scan(&wallet.ID, &wallet.AccountID, &wallet.Token, &wallet.Environment, &wallet.Frozen)
+	return wallet, err
+}
+
+func (m *sqlWalletRepository) queryWallets(ctx context.Context, stmt sq.SelectBuilder) ([]*models.Wallet, error) {
+	rows, err := stmt.RunWith(runner(ctx, m.db)).QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	wallets := make([]*models.Wallet, 0)
+	for rows.Next() {
+		wallet, err := scanWallet(rows)
+		if err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		wallets = append(wallets, wallet)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return wallets, nil
+}
+
+func (m *sqlWalletRepository) findWallet(ctx context.Context, where sq.Eq) (*models.Wallet, error) {
+	row := m.selectWallets().
+		Where(where).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx)
+
+	wallet, err := scanWallet(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.NewNotFoundError("wallet not found")
+		}
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return wallet, nil
+}
+
+func (m *sqlWalletRepository) Create(ctx context.Context, wallets []*models.Wallet) error {
+	if len(wallets) == 0 {
+		return nil
+	}
+
+	stmt := m.builder.
+		Insert("wallets").
+		Columns("id", "account_id", "token", "environment")
+	for _, wallet := range wallets {
+		stmt = stmt.Values(wallet.ID, wallet.AccountID, wallet.Token, wallet.Environment)
+	}
+
+	_, err := stmt.
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlWalletRepository) FindByID(ctx context.Context, id string) (*models.Wallet, error) {
+	return m.findWallet(ctx, sq.Eq{"id": id})
+}
+
+func (m *sqlWalletRepository) FindByAccount(ctx context.Context, accountID string, currency string, env models.Environment) (*models.Wallet, error) {
+	return m.findWallet(ctx, sq.Eq{"account_id": accountID, "token": currency, "environment": env})
+}
+
+func (m *sqlWalletRepository) ListByAccount(ctx context.Context, accountID string, env models.Environment, currency string) ([]*models.Wallet, error) {
+	stmt := m.selectWallets().
+		Where(sq.Eq{"account_id": accountID, "environment": env}).
+		OrderBy("token")
+	if currency != "" {
+		stmt = stmt.Where(sq.Eq{"token": currency})
+	}
+
+	return m.queryWallets(ctx, stmt)
+}
+
+func (m *sqlWalletRepository) FindByIDs(ctx context.Context, ids []string) ([]*models.Wallet, error) {
+	if len(ids) == 0 {
+		return []*models.Wallet{}, nil
+	}
+	return m.queryWallets(ctx, m.selectWallets().Where(sq.Eq{"id": ids}))
+}
+
+func (m *sqlWalletRepository) List(ctx context.Context) ([]*models.Wallet, error) {
+	return m.queryWallets(ctx, m.selectWallets())
+}
+
+func (m *sqlWalletRepository) SetFrozen(ctx context.Context, id string, frozen bool) error {
+	_, err := m.builder.
+		Update("wallets").
+		Set("frozen", frozen).
+		Where(sq.Eq{"id": id}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlWalletRepository) DeleteByAccount(ctx context.Context, accountID string) error {
+	_, err := m.builder.
+		Delete("wallets").
+		Where(sq.Eq{"account_id": accountID}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
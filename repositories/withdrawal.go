@@ -0,0 +1,226 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/2HgO/quidax-go/db"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	sq "github.com/Masterminds/squirrel"
+)
+
+// WithdrawalFilter narrows down withdrawals, unset fields match every withdrawal
+type WithdrawalFilter struct {
+	ID        string
+	Reference string
+	// AccountID matches withdrawals from the account's wallets and withdrawals sent to the account
+	AccountID string
+	// Environment matches the environment of the withdrawn wallet
+	Environment *models.Environment
+	Status      *models.WithdrawalStatus
+	Currency    *string
+	From        *time.Time
+	To          *time.Time
+	MinAmount   float64
+	MaxAmount   float64
+}
+
+type WithdrawalRepository interface {
+	Create(context.Context, *models.Withdrawal) error
+	Delete(context.Context, string) error
+	SetStatus(context.Context, string, models.WithdrawalStatus) error
+	// Find returns the first withdrawal matched by the filter
+	Find(context.Context, WithdrawalFilter) (*models.Withdrawal, error)
+	// List returns the requested page of withdrawals matched by the filter newest first, or all of them
+	// when the page is nil
+	List(context.Context, WithdrawalFilter, *requests.Pagination) ([]*models.Withdrawal, *responses.Pagination, error)
+	// FindByTxIDs returns the withdrawals made by the transfers
+	FindByTxIDs(context.Context, []string) ([]*models.Withdrawal, error)
+}
+
+func NewSQLWithdrawalRepository(dataDatabase *sql.DB) WithdrawalRepository {
+	return &sqlWithdrawalRepository{db: dataDatabase, builder: db.DialectOf(dataDatabase).Builder()}
+}
+
+type sqlWithdrawalRepository struct {
+	db      *sql.DB
+	builder sq.StatementBuilderType
+}
+
+var withdrawalColumns = []string{
+	"withdrawals.id", "withdrawals.wallet_id", "withdrawals.ref", "withdrawals.tx_id", "withdrawals.transaction_note",
+	"withdrawals.narration", "withdrawals.reason", "withdrawals.status", "withdrawals.recipient_type", "withdrawals.recipient_details_name",
+	"withdrawals.recipient_details_destination_tag", "withdrawals.recipient_details_address", "withdrawals.amount", "withdrawals.created_at",
+}
+
+func scanWithdrawal(row sq.RowScanner) (*models.Withdrawal, error) {
+	withdrawal := &models.Withdrawal{
+		Recipient: &models.Recipient{
+			Details: &models.RecipientDetails{},
+		},
+	}
+	err := row.Scan(
+		&withdrawal.ID, &withdrawal.WalletID, &withdrawal.Ref, &withdrawal.TxID, &withdrawal.TransactionNote,
+		&withdrawal.Narration, &withdrawal.Reason, &withdrawal.Status, &withdrawal.Recipient.Type, &withdrawal.Recipient.Details.Name,
+		&withdrawal.Recipient.Details.DestinationTag, &withdrawal.Recipient.Details.Address, &withdrawal.Amount, &withdrawal.CreatedAt,
+	)
+	return withdrawal, err
+}
+
+// filterWithdrawals selects the withdrawals matched by the filter joined with their wallets, without any columns
+func (m *sqlWithdrawalRepository) filterWithdrawals(filter WithdrawalFilter) sq.SelectBuilder {
+	stmt := m.builder.
+		Select().
+		From("withdrawals").
+		Join("wallets on withdrawals.wallet_id = wallets.id")
+	if filter.ID != "" {
+		stmt = stmt.Where(sq.Eq{"withdrawals.id": filter.ID})
+	}
+	if filter.Reference != "" {
+		stmt = stmt.Where(sq.Eq{"withdrawals.ref": filter.Reference})
+	}
+	if filter.AccountID != "" {
+		stmt = stmt.Where(sq.Or{sq.Eq{"wallets.account_id": filter.AccountID}, sq.Eq{"withdrawals.recipient_details_destination_tag": filter.AccountID}})
+	}
+	if filter.Environment != nil {
+		stmt = stmt.Where(sq.Eq{"wallets.environment": *filter.Environment})
+	}
+	if filter.Status != nil {
+		stmt = stmt.Where(sq.Eq{"withdrawals.status": *filter.Status})
+	}
+	if filter.Currency != nil {
+		stmt = stmt.Where(sq.Eq{"wallets.token": *filter.Currency})
+	}
+	if filter.From != nil {
+		stmt = stmt.Where(sq.GtOrEq{"withdrawals.created_at": *filter.From})
+	}
+	if filter.To != nil {
+		stmt = stmt.Where(sq.LtOrEq{"withdrawals.created_at": *filter.To})
+	}
+	if filter.MinAmount > 0 {
+		stmt = stmt.Where(sq.GtOrEq{"withdrawals.amount": filter.MinAmount})
+	}
+	if filter.MaxAmount > 0 {
+		stmt = stmt.Where(sq.LtOrEq{"withdrawals.amount": filter.MaxAmount})
+	}
+	return stmt
+}
+
+func (m *sqlWithdrawalRepository) queryWithdrawals(ctx context.Context, stmt sq.SelectBuilder) ([]*models.Withdrawal, error) {
+	rows, err := stmt.RunWith(runner(ctx, m.db)).QueryContext(ctx)
+	if err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+	defer rows.Close()
+
+	withdrawals := make([]*models.Withdrawal, 0)
+	for rows.Next() {
+		withdrawal, err := scanWithdrawal(rows)
+		if err != nil {
+			return nil, errors.HandleDataDBError(err)
+		}
+		withdrawals = append(withdrawals, withdrawal)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return withdrawals, nil
+}
+
+func (m *sqlWithdrawalRepository) Create(ctx context.Context, withdrawal *models.Withdrawal) error {
+	_, err := m.builder.
+		Insert("withdrawals").
+		Columns(
+			"id", "wallet_id", "ref", "tx_id", "transaction_note", "narration",
+			"status", "recipient_type", "recipient_details_name",
+			"recipient_details_destination_tag", "recipient_details_address", "amount", "created_at",
+		).
+		Values(
+			withdrawal.ID, withdrawal.WalletID, withdrawal.Ref, withdrawal.TxID, withdrawal.TransactionNote, withdrawal.Narration,
+			withdrawal.Status, withdrawal.Recipient.Type, withdrawal.Recipient.Details.Name,
+			withdrawal.Recipient.Details.DestinationTag, withdrawal.Recipient.Details.Address, withdrawal.Amount, withdrawal.CreatedAt,
+		).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlWithdrawalRepository) Delete(ctx context.Context, id string) error {
+	_, err := m.builder.
+		Delete("withdrawals").
+		Where(sq.Eq{"id": id}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlWithdrawalRepository) SetStatus(ctx context.Context, id string, status models.WithdrawalStatus) error {
+	_, err := m.builder.
+		Update("withdrawals").
+		Set("status", status).
+		Where(sq.Eq{"id": id}).
+		RunWith(runner(ctx, m.db)).
+		ExecContext(ctx)
+	if err != nil {
+		return errors.HandleDataDBError(err)
+	}
+	return nil
+}
+
+func (m *sqlWithdrawalRepository) Find(ctx context.Context, filter WithdrawalFilter) (*models.Withdrawal, error) {
+	row := m.filterWithdrawals(filter).
+		Columns(withdrawalColumns...).
+		Limit(1).
+		RunWith(runner(ctx, m.db)).
+		QueryRowContext(ctx)
+
+	withdrawal, err := scanWithdrawal(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.NewNotFoundError("withdrawal not found")
+		}
+		return nil, errors.HandleDataDBError(err)
+	}
+
+	return withdrawal, nil
+}
+
+func (m *sqlWithdrawalRepository) List(ctx context.Context, filter WithdrawalFilter, page *requests.Pagination) ([]*models.Withdrawal, *responses.Pagination, error) {
+	stmt := m.filterWithdrawals(filter)
+	if page == nil {
+		withdrawals, err := m.queryWithdrawals(ctx, stmt.Columns(withdrawalColumns...).OrderBy("withdrawals.created_at desc", "withdrawals.id desc"))
+		return withdrawals, nil, err
+	}
+
+	stmt, pagination, err := Paginate(ctx, runner(ctx, m.db), stmt, *page, "withdrawals.created_at", "withdrawals.id", withdrawalColumns...)
+	if err != nil {
+		return nil, nil, err
+	}
+	withdrawals, err := m.queryWithdrawals(ctx, stmt)
+	if err != nil {
+		return nil, nil, err
+	}
+	withdrawals = TrimPage(withdrawals, pagination, func(withdrawal *models.Withdrawal) any {
+		return KeysetCursor{CreatedAt: withdrawal.CreatedAt, ID: withdrawal.ID}
+	})
+
+	return withdrawals, pagination, nil
+}
+
+func (m *sqlWithdrawalRepository) FindByTxIDs(ctx context.Context, txIDs []string) ([]*models.Withdrawal, error) {
+	if len(txIDs) == 0 {
+		return []*models.Withdrawal{}, nil
+	}
+	return m.queryWithdrawals(ctx, m.builder.Select(withdrawalColumns...).From("withdrawals").Where(sq.Eq{"tx_id": txIDs}))
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/2HgO/quidax-go/client"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/handlers"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/services"
+	"github.com/2HgO/quidax-go/types/requests"
+)
+
+// roundTripper records the requests sent through it and their responses, failing the first n by dropping
+// their response after the api has handled them
+type roundTripper struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	replayed int
+	drop     int
+}
+
+func (t *roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(r)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests = append(t.requests, r)
+	if err != nil {
+		return nil, err
+	}
+	if res.Header.Get("Idempotent-Replayed") == "true" {
+		t.replayed++
+	}
+	if t.drop > 0 {
+		t.drop--
+		res.Body.Close()
+		return nil, fmt.Errorf("connection reset")
+	}
+	return res, nil
+}
+
+func (h *harness) sdk(token string, transport http.RoundTripper) *sdk.Client {
+	return sdk.New(h.api.base, token,
+		sdk.WithHTTPClient(&http.Client{Transport: transport, Timeout: waitTimeout}),
+		sdk.WithBackoff(time.Millisecond, 10*time.Millisecond),
+	)
+}
+
+// TestClientRoutes fails when a route in handlers.Spec has no method in the client, or a method calls a route
+// that is not in it
+func TestClientRoutes(t *testing.T) {
+	wildcard := regexp.MustCompile(`\{\w+\}`)
+	var entries []string
+	for _, route := range handlers.Spec {
+		entries = append(entries, wildcard.ReplaceAllString(route.Pattern(), "{}"))
+	}
+
+	// every method is called with "{}" for its path wildcards, the transport records the pattern and fails the call
+	var called []string
+	transport := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		called = append(called, r.Method+" "+r.URL.Path)
+		return nil, fmt.Errorf("recorded")
+	})
+	c := sdk.New("http://127.0.0.1", "", sdk.WithHTTPClient(&http.Client{Transport: transport}), sdk.WithMaxRetries(0))
+	ctxType := reflect.TypeFor[context.Context]()
+	v := reflect.ValueOf(c)
+	for i := 0; i < v.NumMethod(); i++ {
+		method := v.Type().Method(i)
+		if method.Type.NumIn() < 2 || method.Type.In(1) != ctxType {
+			continue
+		}
+		args := []reflect.Value{reflect.ValueOf(context.Background())}
+		for j := 2; j < method.Type.NumIn(); j++ {
+			args = append(args, placeholder(method.Type.In(j)))
+		}
+		before := len(called)
+		v.Method(i).Call(args)
+		if len(called) != before+1 {
+			t.Errorf("%s sent %d requests, want 1", method.Name, len(called)-before)
+		}
+	}
+
+	for _, pattern := range called {
+		if !slices.Contains(entries, pattern) {
+			t.Errorf("client calls %q, which is not in handlers.Spec", pattern)
+		}
+	}
+	for _, entry := range entries {
+		if !slices.Contains(called, entry) {
+			t.Errorf("handlers.Spec entry %q has no client method", entry)
+		}
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// placeholder returns an argument of the type with "{}" for its path wildcards
+func placeholder(t reflect.Type) reflect.Value {
+	switch {
+	case t.Kind() == reflect.String:
+		return reflect.ValueOf("{}")
+	case t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct:
+		req := reflect.New(t.Elem())
+		for _, field := range reflect.VisibleFields(t.Elem()) {
+			if _, ok := field.Tag.Lookup("uri"); ok && field.Type.Kind() == reflect.String {
+				req.Elem().FieldByIndex(field.Index).SetString("{}")
+			}
+		}
+		return req
+	default:
+		return reflect.Zero(t)
+	}
+}
+
+func TestClientIdempotentRetries(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	customer := h.createCustomer(m, "tolu@acme.test", models.Tier0_KYCTier)
+	ctx := context.Background()
+
+	// the response of the deposit is lost, the retry is answered with it instead of depositing again
+	transport := &roundTripper{drop: 1}
+	c := h.sdk(m.api.token, transport)
+	deposit, err := c.DepositAmount(ctx, &requests.DepositAmountRequest{UserID: customer, Currency: "ngn", Amount: 5_000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.requests) != 2 || transport.replayed != 1 {
+		t.Fatalf("sent %d requests with %d replayed, want 2 with 1 replayed", len(transport.requests), transport.replayed)
+	}
+	first, second := transport.requests[0].Header.Get("idempotency-key"), transport.requests[1].Header.Get("idempotency-key")
+	if first == "" || first != second {
+		t.Errorf("retry was sent with idempotency key %q, want %q", second, first)
+	}
+	wallet, err := c.FetchUserWallet(ctx, &requests.FetchUserWalletRequest{UserID: customer, Currency: "ngn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Data.Balance != 5_000 {
+		t.Errorf("balance is %v after the retried deposit %s, want 5000", wallet.Data.Balance, deposit.Data.ID)
+	}
+
+	// a key given by the caller is replayed across calls, and can not be used for another request
+	keyed := sdk.ContextWithIdempotencyKey(ctx, "create-sub-account")
+	created, err := c.CreateSubAccount(keyed, &requests.CreateSubAccountRequest{Email: "bayo@acme.test", FirstName: "Bayo", LastName: "Ade"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := c.CreateSubAccount(keyed, &requests.CreateSubAccountRequest{Email: "bayo@acme.test", FirstName: "Bayo", LastName: "Ade"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Data.ID != created.Data.ID {
+		t.Errorf("replayed sub-account %s, want %s", again.Data.ID, created.Data.ID)
+	}
+
+	_, err = c.CreateSubAccount(keyed, &requests.CreateSubAccountRequest{Email: "kemi@acme.test", FirstName: "Kemi", LastName: "Ade"})
+	var appErr errors.AppError
+	if !stderrors.As(err, &appErr) || appErr.Code != http.StatusBadRequest || appErr.Type != errors.ErrValidation {
+		t.Errorf("reusing the key for another request failed with %v, want a validation error", err)
+	}
+
+	// bodies sent with a key are read to fingerprint the request, oversized ones are refused before they are
+	_, err = c.CreateSubAccount(ctx, &requests.CreateSubAccountRequest{Email: "wale@acme.test", FirstName: strings.Repeat("w", 2<<20), LastName: "Ade"})
+	if !stderrors.As(err, &appErr) || appErr.Code != http.StatusBadRequest || appErr.Message != "request body is too large" {
+		t.Errorf("sending an oversized body failed with %v, want a validation error", err)
+	}
+}
+
+func TestAbandonedIdempotencyKey(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	customer := h.createCustomer(m, "tolu@acme.test", models.Tier0_KYCTier)
+
+	path := "/api/v1/users/" + customer + "/deposits/ngn"
+	body := []byte(`{"amount":5000}`)
+	deposit := func() *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, h.api.base+path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("authorization", "Bearer "+m.api.token)
+		req.Header.Set("idempotency-key", "crashed-deposit")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	// the key is reserved the way the middleware reserves it, by a process that stops before completing it
+	sum := sha256.Sum256(append([]byte("\n"), body...))
+	ctx := models.ContextWithPrincipal(context.Background(), models.NewAccountPrincipal(&models.Account{ID: m.id, Environment: models.Test_Environment}))
+	if _, err := h.idempotency.Begin(ctx, "crashed-deposit", http.MethodPost+" "+path+" "+hex.EncodeToString(sum[:])); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := deposit(); res.StatusCode != http.StatusConflict || res.Header.Get("Retry-After") == "" {
+		t.Fatalf("retry while the key is leased responded with %d, want 409 with a Retry-After header", res.StatusCode)
+	}
+
+	// once the lease has run out the retry takes the key over and is handled, later retries replay its response
+	h.clock.Advance(services.IdempotencyKeyLease + time.Second)
+	if res := deposit(); res.StatusCode != http.StatusCreated || res.Header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("retry after the lease ran out responded with %d, replayed %q, want 201 handled again", res.StatusCode, res.Header.Get("Idempotent-Replayed"))
+	}
+	if res := deposit(); res.StatusCode != http.StatusCreated || res.Header.Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("retry of the handled request responded with %d, replayed %q, want 201 replayed", res.StatusCode, res.Header.Get("Idempotent-Replayed"))
+	}
+
+	wallet, err := m.api.FetchUserWallet(customer, "ngn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Data.Balance != 5_000 {
+		t.Errorf("balance is %v after the retried deposit, want 5000", wallet.Data.Balance)
+	}
+}
+
+func TestStatementExportExpiry(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	h.deposit(m, m.id, "ngn", 5000)
+	c := h.sdk(m.api.token, http.DefaultTransport)
+	ctx := context.Background()
+
+	export, _, err := c.ExportStatement(ctx, &requests.ExportStatementRequest{
+		UserID: m.id,
+		From:   h.clock.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		To:     h.clock.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		Format: models.CSV_StatementFormat,
+		Async:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetch := &requests.FetchStatementExportRequest{UserID: m.id, ExportID: export.Data.ID}
+	status := func() models.StatementExportStatus {
+		t.Helper()
+		res, err := c.FetchStatementExport(ctx, fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Data.Status
+	}
+	h.eventually("the statement export to complete", func() bool { return status() == models.Completed_StatementExportStatus })
+
+	// exports within the retention are kept
+	if err = h.statements.ExpireExports(ctx); err != nil {
+		t.Fatal(err)
+	}
+	download, err := c.DownloadStatementExport(ctx, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	download.Body.Close()
+
+	h.clock.Advance(h.cfg.Statements.Retention + time.Second)
+	if err = h.statements.ExpireExports(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != models.Expired_StatementExportStatus {
+		t.Errorf("export is %s once older than the retention, want expired", got)
+	}
+	if _, err = c.DownloadStatementExport(ctx, fetch); err == nil || !strings.Contains(err.Error(), "statement export has expired") {
+		t.Errorf("downloading the expired export failed with %v, want it to have expired", err)
+	}
+	files, err := os.ReadDir(h.cfg.Statements.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("statements dir has %d files once the export expired, want none", len(files))
+	}
+}
+
+func TestClientPagination(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	for i := range 5 {
+		h.createCustomer(m, fmt.Sprintf("customer%d@acme.test", i), models.Tier0_KYCTier)
+	}
+	c := h.sdk(m.api.token, http.DefaultTransport)
+
+	it := c.FetchAllSubAccountsIter(&requests.FetchAllSubAccountsRequest{Pagination: requests.Pagination{PerPage: 2}})
+	var emails []string
+	for it.Next(context.Background()) {
+		emails = append(emails, it.Item().Email)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(emails)
+	want := []string{"customer0@acme.test", "customer1@acme.test", "customer2@acme.test", "customer3@acme.test", "customer4@acme.test"}
+	if !slices.Equal(emails, want) {
+		t.Errorf("iterated over %v, want %v", emails, want)
+	}
+	if p := it.Pagination(); p == nil || p.Total != 5 {
+		t.Errorf("last page has pagination %+v, want a total of 5", p)
+	}
+
+	_, err := c.WithToken("sec_test_unknown").FetchAccountDetails(context.Background(), &requests.FetchAccountDetailsRequest{UserID: "me"})
+	var appErr errors.AppError
+	if !stderrors.As(err, &appErr) || appErr.Code != http.StatusNotFound || appErr.Type != errors.ErrNotFound {
+		t.Errorf("call with an unknown token failed with %v, want a not found error", err)
+	}
+}
+
+// TestLedgerPagination walks lists read from the ledger page by page, entries of every wallet are merged into
+// the pages in order and none are repeated or skipped
+func TestLedgerPagination(t *testing.T) {
+	h := newHarness(t)
+	m := h.createMerchant("ops@acme.test")
+	customer := h.createCustomer(m, "tolu@acme.test", models.Tier1_KYCTier)
+	for i := range 7 {
+		h.deposit(m, customer, []string{"ngn", "usdt"}[i%2], float64(100*(i+1)))
+	}
+	c := h.sdk(m.api.token, http.DefaultTransport)
+	ctx := context.Background()
+
+	all, err := c.FetchTransactions(ctx, &requests.FetchTransactionsRequest{UserID: customer, Pagination: requests.Pagination{PerPage: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, entry := range all.Data {
+		want = append(want, entry.ID)
+	}
+	if len(want) != 7 {
+		t.Fatalf("listed %d transactions, want 7", len(want))
+	}
+
+	it := c.FetchTransactionsIter(&requests.FetchTransactionsRequest{UserID: customer, Pagination: requests.Pagination{PerPage: 2}})
+	var walked []string
+	for it.Next(ctx) {
+		walked = append(walked, it.Item().ID)
+	}
+	if err = it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(walked, want) {
+		t.Errorf("walked %v, want %v", walked, want)
+	}
+
+	page, err := c.FetchTransactions(ctx, &requests.FetchTransactionsRequest{UserID: customer, Pagination: requests.Pagination{Page: 2, PerPage: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbered []string
+	for _, entry := range page.Data {
+		numbered = append(numbered, entry.ID)
+	}
+	if !slices.Equal(numbered, want[3:6]) {
+		t.Errorf("page 2 has %v, want %v", numbered, want[3:6])
+	}
+
+	balances := c.FetchWalletBalanceHistoryIter(&requests.FetchWalletBalanceHistoryRequest{UserID: customer, Currency: "ngn", Pagination: requests.Pagination{PerPage: 3}})
+	var amounts []float64
+	for balances.Next(ctx) {
+		amounts = append(amounts, balances.Item().Balance)
+	}
+	if err = balances.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{1600, 900, 400, 100}; !slices.Equal(amounts, want) {
+		t.Errorf("walked balances %v, want %v", amounts, want)
+	}
+}
@@ -2,11 +2,9 @@ package services
 
 import (
 	"context"
-	"database/sql"
 	"strings"
 	"time"
 
-	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	"github.com/lucsky/cuid"
 	tdb "github.com/tigerbeetle/tigerbeetle-go"
@@ -18,6 +16,7 @@ import (
 
 	"github.com/2HgO/quidax-go/errors"
 	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
 	"github.com/2HgO/quidax-go/types/requests"
 	"github.com/2HgO/quidax-go/types/responses"
 )
@@ -27,27 +26,100 @@ type AccountService interface {
 	EditSubAccountDetails(context.Context, *requests.EditSubAccountDetailsRequest) (*responses.Response[*models.Account], error)
 	FetchAllSubAccounts(context.Context, *requests.FetchAllSubAccountsRequest) (*responses.Response[[]*models.Account], error)
 	FetchAccountDetails(context.Context, *requests.FetchAccountDetailsRequest) (*responses.Response[*models.Account], error)
+	FreezeSubAccount(context.Context, *requests.FreezeSubAccountRequest) (*responses.Response[*models.Account], error)
+	UnfreezeSubAccount(context.Context, *requests.FreezeSubAccountRequest) (*responses.Response[*models.Account], error)
 
 	CreateAccount(context.Context, *requests.CreateAccountRequest) (*responses.Response[*responses.CreateAccountResponseData], error)
 	UpdateWebHookURL(context.Context, *requests.UpdateWebhookURLRequest) error
 	// GenerateToken(context.Context, *requests.GenerateTokenRequest) (*responses.Response[*models.AccessToken], error)
 	GetAccountByAccessToken(context.Context, string) (*models.Account, error)
+	GetRateLimits(context.Context, string) ([]*models.RateLimit, error)
+	// IssueToken creates an access token for a main account, only admins may issue tokens
+	IssueToken(context.Context, *requests.IssueTokenRequest) (*responses.Response[*models.AccessToken], error)
+	// RevokeToken deletes an access token, requests made with it are rejected from then on
+	RevokeToken(context.Context, *requests.RevokeTokenRequest) error
+	// SetTokenRateLimit sets an access token's limit for a route group, only admins may set limits. Clients
+	// get the new limit once their bucket for the group has gone idle
+	SetTokenRateLimit(context.Context, *requests.SetTokenRateLimitRequest) (*responses.Response[*models.RateLimit], error)
 }
 
-func NewAccountService(txDatabase tdb.Client, dataDatabase *sql.DB, log *zap.Logger) AccountService {
-	return &accountService{
+func NewAccountService(
+	transactor repositories.Transactor,
+	txDatabase tdb.Client,
+	accountRepository repositories.AccountRepository,
+	walletRepository repositories.WalletRepository,
+	tokenRepository repositories.TokenRepository,
+	authService AuthorizationService,
+	webhookService WebhookService,
+	sagaService SagaService,
+	log *zap.Logger,
+) AccountService {
+	a := &accountService{
 		service{
-			transactionDB: txDatabase,
-			dataDB:        dataDatabase,
-			log:           log,
+			transactor:        transactor,
+			transactionDB:     txDatabase,
+			authService:       authService,
+			webhookService:    webhookService,
+			sagaService:       sagaService,
+			log:               log,
+			accountRepository: accountRepository,
+			walletRepository:  walletRepository,
+			tokenRepository:   tokenRepository,
 		},
 	}
+	sagaService.Register(models.CreateAccount_SagaKind, newSagaBuilder(a.accountSagaDefinition))
+
+	return a
 }
 
 type accountService struct {
 	service
 }
 
+// accountSaga is the payload of account creation sagas
+type accountSaga struct {
+	AccountID string              `json:"account_id"`
+	Wallets   []tdb_types.Account `json:"wallets"`
+}
+
+// accountSagaDefinition writes the account's rows and then its wallet accounts, the rows are deleted when the wallet
+// accounts cannot be created
+func (a *accountService) accountSagaDefinition(payload accountSaga) *sagaDefinition {
+	return &sagaDefinition{
+		steps: []sagaStep{
+			{
+				name: "insert account",
+				compensate: func(ctx context.Context) error {
+					if err := a.walletRepository.DeleteByAccount(ctx, payload.AccountID); err != nil {
+						return err
+					}
+					if err := a.tokenRepository.DeleteByAccount(ctx, payload.AccountID); err != nil {
+						return err
+					}
+					return a.accountRepository.Delete(ctx, payload.AccountID)
+				},
+			},
+			a.createAccountsStep(payload.Wallets),
+		},
+		pivot: 1,
+	}
+}
+
+// insertWallets stores the wallets refs of the ledger accounts in the wallets collection
+func (s *service) insertWallets(ctx context.Context, accountID string, accounts []tdb_types.Account) error {
+	wallets := make([]*models.Wallet, 0, len(accounts))
+	for _, account := range accounts {
+		wallets = append(wallets, &models.Wallet{
+			ID:          account.ID.String(),
+			AccountID:   accountID,
+			Token:       Ledgers[account.Ledger],
+			Environment: LedgerEnvironment(account.Ledger),
+		})
+	}
+
+	return s.walletRepository.Create(ctx, wallets)
+}
+
 func (a *accountService) CreateAccount(ctx context.Context, req *requests.CreateAccountRequest) (*responses.Response[*responses.CreateAccountResponseData], error) {
 	now := time.Now()
 	accountID := uuid.New()
@@ -68,60 +140,21 @@ func (a *accountService) CreateAccount(ctx context.Context, req *requests.Create
 		return nil, err
 	}
 
-	tx, err := a.dataDB.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-	// Defer a rollback in case anything fails.
-	defer tx.Rollback()
-
-	// * create user account
-	_, err = sq.
-		Insert("accounts").
-		Columns("id", "sn", "display_name", "email", "first_name", "last_name", "created_at", "updated_at", "is_main_account").
-		Values(account.ID, account.SN, account.DisplayName, account.Email, account.FirstName, account.LastName, now, now, true).
-		RunWith(tx).
-		ExecContext(ctx)
-
-	if err != nil {
-		return nil, errors.HandleDataDBError(err)
-	}
-
 	credentials := &models.Credentials{
 		ID:       account.ID,
 		Password: string(password),
 	}
 
-	// * create user access token to authenticate requests
-	_, err = sq.
-		Insert("credentials").
-		Columns("id", "password").
-		Values(credentials.ID, credentials.Password).
-		RunWith(tx).
-		ExecContext(ctx)
-
-	if err != nil {
-		return nil, errors.HandleDataDBError(err)
-	}
-
-	accessToken := &models.AccessToken{
-		ID:          uuid.NewString(),
-		Name:        "Default Token",
-		Description: "default token for user requests",
-		AccountID:   account.ID,
-		Token:       "pub_test_" + cuid.Slug(),
-	}
-
-	// * create user access token to authenticate requests
-	_, err = sq.
-		Insert("access_tokens").
-		Columns("id", "name", "description", "account_id", "token").
-		Values(accessToken.ID, accessToken.Name, accessToken.Description, accessToken.AccountID, accessToken.Token).
-		RunWith(tx).
-		ExecContext(ctx)
-
-	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+	accessTokens := map[models.Environment]*models.AccessToken{}
+	for _, env := range []models.Environment{models.Test_Environment, models.Live_Environment} {
+		accessTokens[env] = &models.AccessToken{
+			ID:          uuid.NewString(),
+			Name:        "Default Token",
+			Description: "default " + env.String() + " token for user requests",
+			AccountID:   account.ID,
+			Token:       env.KeyPrefix() + cuid.New(),
+			Environment: env,
+		}
 	}
 
 	wallets := make([]tdb_types.Account, 0, len(Ledgers))
@@ -139,79 +172,43 @@ func (a *accountService) CreateAccount(ctx context.Context, req *requests.Create
 		})
 	}
 
-	// * store wallets ref in wallets collection
-	walletsInsertStmt := sq.
-		Insert("wallets").
-		Columns("id", "account_id", "token")
-	for _, wallet := range wallets {
-		walletsInsertStmt = walletsInsertStmt.
-			Values(wallet.ID.String(), account.ID, Ledgers[wallet.Ledger])
-	}
+	// * store the account's rows, then create its wallet accounts in the financial transaction database
+	err = a.sagaService.Run(ctx, models.CreateAccount_SagaKind, accountSaga{AccountID: account.ID, Wallets: wallets}, func(ctx context.Context) error {
+		// * create user account
+		if err := a.accountRepository.Create(ctx, account); err != nil {
+			return err
+		}
+		if err := a.accountRepository.SaveCredentials(ctx, credentials); err != nil {
+			return err
+		}
 
-	_, err = walletsInsertStmt.
-		RunWith(tx).
-		ExecContext(ctx)
-	if err != nil {
-		return nil, errors.HandleDataDBError(err)
-	}
+		// * create user access tokens to authenticate requests in each environment
+		tokens := []*models.AccessToken{accessTokens[models.Test_Environment], accessTokens[models.Live_Environment]}
+		if err := a.tokenRepository.Create(ctx, tokens); err != nil {
+			return err
+		}
 
-	// * create wallet accounts in financial transaction database
-	txRes, err := a.transactionDB.CreateAccounts(wallets)
+		return a.insertWallets(ctx, account.ID, wallets)
+	})
 	if err != nil {
-		return nil, errors.HandleTxDBError(err)
-	}
-	if len(txRes) > 0 {
-		return nil, errors.NewUnknownError(txRes[0].Result.String())
-	}
-
-	if err = tx.Commit(); err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
 	}
 
 	return &responses.Response[*responses.CreateAccountResponseData]{
 		Status:  "successful",
 		Message: "Account Created successfully",
 		Data: &responses.CreateAccountResponseData{
-			User:  account,
-			Token: accessToken,
+			User:      account,
+			Token:     accessTokens[models.Test_Environment],
+			LiveToken: accessTokens[models.Live_Environment],
 		},
 	}, nil
 }
 
 func (a *accountService) FetchAccountDetails(ctx context.Context, req *requests.FetchAccountDetailsRequest) (*responses.Response[*models.Account], error) {
-	parent, ok := ctx.Value("user").(*models.Account)
-	if ok && req.UserID == parent.ID {
-		req.UserID = "me"
-	}
-	stmt := sq.
-		Select("accounts.id", "sn", "display_name", "email", "first_name", "last_name", "created_at", "updated_at", "callback_url", "webhook_key").
-		From("accounts").
-		LeftJoin("webhook_details on webhook_details.id = accounts.id OR webhook_details.id = accounts.parent_id")
-
-	switch req.UserID {
-	case "me":
-		parent := ctx.Value("user").(*models.Account)
-		stmt = stmt.Where(sq.Eq{"is_main_account": true, "accounts.id": parent.ID})
-	default:
-		if ctx.Value("skip_check") == nil {
-			stmt = stmt.Where(sq.Eq{"accounts.id": req.UserID, "parent_id": parent.ID})
-		} else {
-			stmt = stmt.Where(sq.Eq{"accounts.id": req.UserID})
-		}
-	}
-
-	row := stmt.
-		Limit(1).
-		RunWith(a.dataDB).
-		QueryRowContext(ctx)
-
-	if row == nil {
-		return nil, errors.NewNotFoundError("user not found")
-	}
-	var account = &models.Account{}
-	err := row.Scan(&account.ID, &account.SN, &account.DisplayName, &account.Email, &account.FirstName, &account.LastName, &account.CreatedAt, &account.UpdatedAt, &account.WebhookDetails.CallbackURL, &account.WebhookDetails.WebhookKey)
+	account, err := a.authService.AuthorizeUser(ctx, req.UserID)
 	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
 	}
 
 	return &responses.Response[*models.Account]{
@@ -221,45 +218,109 @@ func (a *accountService) FetchAccountDetails(ctx context.Context, req *requests.
 }
 
 func (a *accountService) GetAccountByAccessToken(ctx context.Context, token string) (*models.Account, error) {
-	row := sq.
-		Select("accounts.id", "accounts.email", "webhook_details.callback_url", "accounts.display_name", "webhook_details.webhook_key").
-		From("access_tokens").
-		Join("accounts on access_tokens.account_id = accounts.id").
-		LeftJoin("webhook_details on webhook_details.id = accounts.id").
-		Where(sq.Eq{"token": token}).
-		RunWith(a.dataDB).
-		QueryRowContext(ctx)
-
-	if row == nil {
-		return nil, errors.NewNotFoundError("token not found")
-	}
-	var account = &models.Account{}
-	err := row.Scan(&account.ID, &account.Email, &account.WebhookDetails.CallbackURL, &account.DisplayName, &account.WebhookDetails.WebhookKey)
+	return a.accountRepository.FindByAccessToken(ctx, token)
+}
+
+func (a *accountService) GetRateLimits(ctx context.Context, token string) ([]*models.RateLimit, error) {
+	return a.tokenRepository.RateLimits(ctx, token)
+}
+
+func (a *accountService) UpdateWebHookURL(ctx context.Context, req *requests.UpdateWebhookURLRequest) error {
+	parent, err := a.authService.MainAccount(ctx)
 	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return err
 	}
 
-	return account, nil
+	return a.accountRepository.SaveWebhookDetails(ctx, &models.WebhookDetails{
+		ID:          parent.ID,
+		CallbackURL: &req.CallbackURL,
+		WebhookKey:  req.WebhookKey,
+	})
 }
 
-func (a *accountService) UpdateWebHookURL(ctx context.Context, req *requests.UpdateWebhookURLRequest) error {
-	parent := ctx.Value("user").(*models.Account)
-
-	_, err := sq.
-		Replace("webhook_details").
-		Columns("id", "callback_url", "webhook_key").
-		Values(parent.ID, req.CallbackURL, req.WebhookKey).
-		RunWith(a.dataDB).
-		ExecContext(ctx)
+func (a *accountService) IssueToken(ctx context.Context, req *requests.IssueTokenRequest) (*responses.Response[*models.AccessToken], error) {
+	if err := a.authService.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	account, err := a.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !account.IsMainAccount {
+		return nil, errors.NewValidationError("access tokens can only be issued to main accounts")
+	}
+
+	env := *req.Environment
+	token := &models.AccessToken{
+		ID:          uuid.NewString(),
+		Name:        req.Name,
+		Description: req.Description,
+		AccountID:   account.ID,
+		Token:       env.KeyPrefix() + cuid.New(),
+		Environment: env,
+	}
+	err = a.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := a.tokenRepository.Create(ctx, []*models.AccessToken{token}); err != nil {
+			return err
+		}
+		for _, limit := range req.RateLimits {
+			err := a.tokenRepository.SetRateLimit(ctx, &models.RateLimit{
+				AccessTokenID: token.ID,
+				RouteGroup:    limit.RouteGroup,
+				Rate:          limit.Rate,
+				Burst:         limit.Burst,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return errors.HandleDataDBError(err)
+		return nil, err
 	}
 
-	return nil
+	return &responses.Response[*models.AccessToken]{
+		Status:  "successful",
+		Message: "Access token issued successfully",
+		Data:    token,
+	}, nil
+}
+
+func (a *accountService) RevokeToken(ctx context.Context, req *requests.RevokeTokenRequest) error {
+	if err := a.authService.AuthorizeAdmin(ctx); err != nil {
+		return err
+	}
+	return a.tokenRepository.Delete(ctx, req.TokenID)
+}
+
+func (a *accountService) SetTokenRateLimit(ctx context.Context, req *requests.SetTokenRateLimitRequest) (*responses.Response[*models.RateLimit], error) {
+	if err := a.authService.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	limit := &models.RateLimit{
+		AccessTokenID: req.TokenID,
+		RouteGroup:    req.RouteGroup,
+		Rate:          req.Rate,
+		Burst:         req.Burst,
+	}
+	if err := a.tokenRepository.SetRateLimit(ctx, limit); err != nil {
+		return nil, err
+	}
+
+	return &responses.Response[*models.RateLimit]{
+		Status:  "successful",
+		Message: "Rate limit set successfully",
+		Data:    limit,
+	}, nil
 }
 
 func (a *accountService) CreateSubAccount(ctx context.Context, req *requests.CreateSubAccountRequest) (*responses.Response[*models.Account], error) {
-	parent := ctx.Value("user").(*models.Account)
+	parent, err := a.authService.MainAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	now := time.Now()
 	accountID := uuid.New()
@@ -273,35 +334,17 @@ func (a *accountService) CreateSubAccount(ctx context.Context, req *requests.Cre
 		ParentID:    &parent.ID,
 		CreatedAt:   &now,
 		UpdatedAt:   &now,
+		Environment: parent.Environment,
 	}
 
-	tx, err := a.dataDB.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-	// Defer a rollback in case anything fails.
-	defer tx.Rollback()
-
-	// * create sub account user
-	_, err = sq.
-		Insert("accounts").
-		Columns("id", "sn", "display_name", "email", "first_name", "last_name", "created_at", "updated_at", "is_main_account", "parent_id").
-		Values(account.ID, account.SN, parent.DisplayName, account.Email, account.FirstName, account.LastName, now, now, false, parent.ID).
-		RunWith(tx).
-		ExecContext(ctx)
-
-	if err != nil {
-		return nil, err
-	}
-
-	wallets := make([]tdb_types.Account, 0, len(Ledgers))
-	for ledgerId := range Ledgers {
+	wallets := make([]tdb_types.Account, 0, len(LedgerIDs[account.Environment]))
+	for _, ledgerId := range LedgerIDs[account.Environment] {
 		wallets = append(wallets, tdb_types.Account{
 			ID: tdb_types.ID(),
 			Flags: tdb_types.AccountFlags{
 				History:                    true,
 				DebitsMustNotExceedCredits: true,
-				Linked:                     len(wallets) < (len(Ledgers) - 1),
+				Linked:                     len(wallets) < (len(LedgerIDs[account.Environment]) - 1),
 			}.ToUint16(),
 			Ledger:      ledgerId,
 			Code:        1,
@@ -309,33 +352,17 @@ func (a *accountService) CreateSubAccount(ctx context.Context, req *requests.Cre
 		})
 	}
 
-	// * store wallets ref in wallets collection
-	walletsInsertStmt := sq.
-		Insert("wallets").
-		Columns("id", "account_id", "token")
-	for _, wallet := range wallets {
-		walletsInsertStmt = walletsInsertStmt.
-			Values(wallet.ID.String(), account.ID, Ledgers[wallet.Ledger])
-	}
-
-	_, err = walletsInsertStmt.
-		RunWith(tx).
-		ExecContext(ctx)
-	if err != nil {
-		return nil, errors.HandleDataDBError(err)
-	}
+	// * store the sub account's rows, then create its wallet accounts in the financial transaction database
+	err = a.sagaService.Run(ctx, models.CreateAccount_SagaKind, accountSaga{AccountID: account.ID, Wallets: wallets}, func(ctx context.Context) error {
+		// * create sub account user
+		if err := a.accountRepository.Create(ctx, account); err != nil {
+			return err
+		}
 
-	// * create wallet accounts in financial transaction database
-	txRes, err := a.transactionDB.CreateAccounts(wallets)
+		return a.insertWallets(ctx, account.ID, wallets)
+	})
 	if err != nil {
-		return nil, errors.HandleTxDBError(err)
-	}
-	if len(txRes) > 0 {
-		return nil, errors.NewUnknownError(txRes[0].Result.String())
-	}
-
-	if err = tx.Commit(); err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
 	}
 
 	return &responses.Response[*models.Account]{
@@ -346,67 +373,82 @@ func (a *accountService) CreateSubAccount(ctx context.Context, req *requests.Cre
 }
 
 func (a *accountService) EditSubAccountDetails(ctx context.Context, req *requests.EditSubAccountDetailsRequest) (*responses.Response[*models.Account], error) {
-	parent := ctx.Value("user").(*models.Account)
-	if req.UserID == parent.ID {
-		req.UserID = "me"
+	account, err := a.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
 	}
 
-	stmt := sq.
-		Update("accounts").
-		Set("updated_at", time.Now())
-
+	now := time.Now()
+	account.UpdatedAt = &now
 	if req.FirstName != "" {
-		stmt = stmt.Set("first_name", req.FirstName)
+		account.FirstName = req.FirstName
 	}
 	if req.LastName != "" {
-		stmt = stmt.Set("last_name", req.LastName)
+		account.LastName = req.LastName
 	}
 	if req.PhoneNumber != "" {
-		// todo: add phone number to accounts schema
-		// stmt = stmt.Set("first_name", req.FirstName)
+		account.PhoneNumber = &req.PhoneNumber
 	}
 
-	switch req.UserID {
-	case "me":
-		stmt = stmt.Where(sq.Eq{"is_main_account": true, "id": parent.ID})
-	default:
-		stmt = stmt.Where(sq.Eq{"id": req.UserID, "parent_id": parent.ID})
+	if err = a.accountRepository.Update(ctx, account); err != nil {
+		return nil, err
 	}
 
-	_, err := stmt.RunWith(a.dataDB).ExecContext(ctx)
+	return a.FetchAccountDetails(ctx, &requests.FetchAccountDetailsRequest{UserID: account.ID})
+}
 
+func (a *accountService) FetchAllSubAccounts(ctx context.Context, req *requests.FetchAllSubAccountsRequest) (*responses.Response[[]*models.Account], error) {
+	parent, err := a.authService.MainAccount(ctx)
 	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
 	}
 
-	return a.FetchAccountDetails(ctx, &requests.FetchAccountDetailsRequest{UserID: req.UserID})
+	res, pagination, err := a.accountRepository.ListSubAccounts(ctx, parent.ID, parent.Environment, req.Pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.Response[[]*models.Account]{
+		Status:     "successful",
+		Data:       res,
+		Pagination: pagination,
+	}, nil
 }
 
-func (a *accountService) FetchAllSubAccounts(ctx context.Context, req *requests.FetchAllSubAccountsRequest) (*responses.Response[[]*models.Account], error) {
-	parent := ctx.Value("user").(*models.Account)
-
-	rows, err := sq.
-		Select("id", "sn", "display_name", "email", "first_name", "last_name", "created_at", "updated_at").
-		From("accounts").
-		Where("parent_id", parent.ID).
-		RunWith(a.dataDB).
-		QueryContext(ctx)
+func (a *accountService) FreezeSubAccount(ctx context.Context, req *requests.FreezeSubAccountRequest) (*responses.Response[*models.Account], error) {
+	return a.setSubAccountFrozen(ctx, req.UserID, true)
+}
+
+func (a *accountService) UnfreezeSubAccount(ctx context.Context, req *requests.FreezeSubAccountRequest) (*responses.Response[*models.Account], error) {
+	return a.setSubAccountFrozen(ctx, req.UserID, false)
+}
+
+func (a *accountService) setSubAccountFrozen(ctx context.Context, userID string, frozen bool) (*responses.Response[*models.Account], error) {
+	account, err := a.authService.AuthorizeUser(ctx, userID)
 	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
 	}
+	if account.IsMainAccount {
+		return nil, errors.NewPermissionError("main accounts cannot be frozen")
+	}
+
+	if account.Frozen != frozen {
+		// only the frozen column is written, so a profile edit made at the same time is not undone
+		if err = a.accountRepository.SetFrozen(ctx, account.ID, frozen); err != nil {
+			return nil, err
+		}
+		account.Frozen = frozen
 
-	res := make([]*models.Account, 0)
-	for rows.Next() {
-		acc := &models.Account{}
-		err := rows.Scan(&acc.ID, &acc.SN, &acc.DisplayName, &acc.Email, &acc.FirstName, &acc.LastName, &acc.CreatedAt, &acc.UpdatedAt)
-		if err != nil {
-			return nil, errors.HandleDataDBError(err)
+		switch frozen {
+		case true:
+			go a.webhookService.SendUserFrozenEvent(account.WebhookDetails, account)
+		default:
+			go a.webhookService.SendUserUnfrozenEvent(account.WebhookDetails, account)
 		}
-		res = append(res, acc)
 	}
 
-	return &responses.Response[[]*models.Account]{
+	return &responses.Response[*models.Account]{
 		Status: "successful",
-		Data:   res,
+		Data:   account,
 	}, nil
 }
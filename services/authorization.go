@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+	"go.uber.org/zap"
+)
+
+// AuthorizationService decides whether the principal in a request context may act on a resource.
+// Resources the principal may not act on are reported as not found so their existence is not leaked.
+type AuthorizationService interface {
+	Principal(context.Context) (*models.Principal, error)
+	// MainAccount returns the main account the principal acts for
+	MainAccount(context.Context) (*models.Account, error)
+
+	AuthorizeUser(context.Context, string) (*models.Account, error)
+	AuthorizeRecipient(context.Context, string) (*models.Account, error)
+	AuthorizeWallet(context.Context, string) (*models.Wallet, error)
+	AuthorizeTransaction(context.Context, tdb_types.Transfer) error
+	AuthorizeAdmin(context.Context) error
+}
+
+func NewAuthorizationService(accountRepository repositories.AccountRepository, walletRepository repositories.WalletRepository, log *zap.Logger) AuthorizationService {
+	return &authorizationService{
+		service{
+			log:               log,
+			accountRepository: accountRepository,
+			walletRepository:  walletRepository,
+		},
+	}
+}
+
+type authorizationService struct {
+	service
+}
+
+func (a *authorizationService) Principal(ctx context.Context) (*models.Principal, error) {
+	principal, ok := models.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, errors.NewAuthenticationError("request is not authenticated")
+	}
+	return principal, nil
+}
+
+func (a *authorizationService) MainAccount(ctx context.Context) (*models.Account, error) {
+	principal, err := a.Principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if principal.IsSystem() {
+		return nil, errors.NewPermissionError("system principal does not act for a main account")
+	}
+	return principal.Account, nil
+}
+
+// canActOn reports whether the principal owns the account, either as the main account itself or
+// as the parent of a sub account in the principal's environment
+func (a *authorizationService) canActOn(principal *models.Principal, account *models.Account) bool {
+	switch {
+	case principal.IsSystem():
+		return true
+	case account.ID == principal.Account.ID:
+		return true
+	case account.ParentID != nil && *account.ParentID == principal.Account.ID:
+		return account.Environment == principal.Environment
+	default:
+		return false
+	}
+}
+
+func (a *authorizationService) lookupAccount(ctx context.Context, id string) (*models.Account, error) {
+	return a.accountRepository.FindByID(ctx, id)
+}
+
+// AuthorizeUser resolves the user id, `me` refers to the principal's main account, and returns the
+// account when the principal may act on it
+func (a *authorizationService) AuthorizeUser(ctx context.Context, userID string) (*models.Account, error) {
+	principal, err := a.Principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if userID == "me" {
+		if principal.IsSystem() {
+			return nil, errors.NewNotFoundError("user not found")
+		}
+		userID = principal.Account.ID
+	}
+
+	account, err := a.lookupAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !a.canActOn(principal, account) {
+		return nil, errors.NewNotFoundError("user not found")
+	}
+	if account.IsMainAccount && !principal.IsSystem() {
+		// main accounts span both environments, report the one the principal is authenticated against
+		account.Environment = principal.Environment
+	}
+
+	return account, nil
+}
+
+// AuthorizeRecipient returns the account when the principal may transfer funds to it, any user in the
+// principal's environment may receive funds
+func (a *authorizationService) AuthorizeRecipient(ctx context.Context, userID string) (*models.Account, error) {
+	principal, err := a.Principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := a.lookupAccount(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !account.IsMainAccount && account.Environment != principal.Environment {
+		return nil, errors.NewNotFoundError("user not found")
+	}
+	if account.IsMainAccount {
+		account.Environment = principal.Environment
+	}
+
+	return account, nil
+}
+
+func (a *authorizationService) AuthorizeWallet(ctx context.Context, walletID string) (*models.Wallet, error) {
+	principal, err := a.Principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := a.walletRepository.FindByID(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if !principal.IsSystem() && wallet.Environment != principal.Environment {
+		return nil, errors.NewNotFoundError("wallet not found")
+	}
+
+	account, err := a.lookupAccount(ctx, wallet.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	if !a.canActOn(principal, account) {
+		return nil, errors.NewNotFoundError("wallet not found")
+	}
+
+	return wallet, nil
+}
+
+// AuthorizeTransaction allows the principal to act on a transfer that debits or credits any wallet it may act on
+func (a *authorizationService) AuthorizeTransaction(ctx context.Context, transfer tdb_types.Transfer) error {
+	principal, err := a.Principal(ctx)
+	if err != nil {
+		return err
+	}
+	if principal.IsSystem() {
+		return nil
+	}
+	if LedgerEnvironment(transfer.Ledger) != principal.Environment {
+		return errors.NewNotFoundError("transaction not found")
+	}
+
+	for _, walletID := range []tdb_types.Uint128{transfer.DebitAccountID, transfer.CreditAccountID} {
+		_, err := a.AuthorizeWallet(ctx, walletID.String())
+		switch {
+		case err == nil:
+			return nil
+		case errors.AsAppError(err).Type != errors.ErrNotFound:
+			return err
+		}
+	}
+
+	return errors.NewNotFoundError("transaction not found")
+}
+
+// AuthorizeAdmin allows operators authenticated with the admin token and internal jobs
+func (a *authorizationService) AuthorizeAdmin(ctx context.Context) error {
+	principal, err := a.Principal(ctx)
+	if err != nil {
+		return err
+	}
+	if !principal.IsSystem() {
+		return errors.NewPermissionError("admin access required")
+	}
+	return nil
+}
@@ -2,15 +2,14 @@ package services
 
 import (
 	"context"
-	"database/sql"
 	"time"
 
 	"github.com/2HgO/quidax-go/errors"
 	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
 	"github.com/2HgO/quidax-go/types/requests"
 	"github.com/2HgO/quidax-go/types/responses"
 	"github.com/2HgO/quidax-go/utils"
-	"github.com/google/uuid"
 	tdb "github.com/tigerbeetle/tigerbeetle-go"
 	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
 	"go.uber.org/zap"
@@ -24,21 +23,24 @@ type DepositService interface {
 }
 
 func NewDepositService(
+	authService AuthorizationService,
 	accountService AccountService,
 	walletService WalletService,
 	webhooService WebhookService,
 	txDatabase tdb.Client,
-	dataDatabase *sql.DB,
+	walletRepository repositories.WalletRepository,
 	log *zap.Logger,
 ) DepositService {
 	return &depositService{
 		service{
+			authService:    authService,
 			accountService: accountService,
 			transactionDB:  txDatabase,
-			dataDB:         dataDatabase,
 			log:            log,
 			walletService:  walletService,
 			webhookService: webhooService,
+
+			walletRepository: walletRepository,
 		},
 	}
 }
@@ -48,10 +50,18 @@ type depositService struct {
 }
 
 func (d *depositService) CreateDeposit(ctx context.Context, req *requests.DepositAmountRequest) (*responses.Response[*responses.DepositResponseData], error) {
+	env := environment(ctx)
+	if env != models.Test_Environment {
+		return nil, errors.NewPermissionError("deposits can only be simulated in the test environment")
+	}
+
 	wallet, err := d.walletService.FetchUserWallet(ctx, &requests.FetchUserWalletRequest{UserID: req.UserID, Currency: req.Currency})
 	if err != nil {
 		return nil, err
 	}
+	if err = checkKYC(wallet.Data.User, deposit_kycOperation, wallet.Data.Currency); err != nil {
+		return nil, err
+	}
 	walletId, _ := tdb_types.HexStringToUint128(wallet.Data.ID)
 
 	amount := utils.ApproximateAmount(wallet.Data.Currency, float64(req.Amount))
@@ -59,15 +69,20 @@ func (d *depositService) CreateDeposit(ctx context.Context, req *requests.Deposi
 		ID:              tdb_types.ID(),
 		Amount:          utils.ToAmount(amount),
 		CreditAccountID: walletId,
-		DebitAccountID:  tdb_types.ToUint128(uint64(LedgerIDs[wallet.Data.Currency])),
-		Ledger:          LedgerIDs[wallet.Data.Currency],
-		Code:            3,
+		DebitAccountID:  tdb_types.ToUint128(uint64(LedgerIDs[env][wallet.Data.Currency])),
+		Ledger:          LedgerIDs[env][wallet.Data.Currency],
+		Code:            deposit_TransferCode,
 	}
 
 	res, err := d.transactionDB.CreateTransfers([]tdb_types.Transfer{transfer})
 	if err != nil {
+		// the transfer may have been created before the ledger's reply was lost
+		models.RecordEffect(ctx)
 		return nil, err
 	}
+	if len(res) == 0 {
+		models.RecordEffect(ctx)
+	}
 	if len(res) > 0 {
 		return nil, errors.NewUnknownError(res[0].Result.String())
 	}
@@ -101,6 +116,9 @@ func (d *depositService) FetchDeposit(ctx context.Context, req *requests.FetchDe
 	}
 
 	deposit := transfer[0]
+	if err = d.authService.AuthorizeTransaction(ctx, deposit); err != nil || deposit.Code != deposit_TransferCode {
+		return nil, errors.NewNotFoundError("deposit not found")
+	}
 	wallets, err := d.walletService.LookupWallets(ctx, []string{deposit.CreditAccountID.String()})
 	if err != nil {
 		return nil, err
@@ -137,27 +155,42 @@ func (d *depositService) FetchDeposits(ctx context.Context, req *requests.FetchD
 		return nil, err
 	}
 
-	query := tdb_types.AccountFilter{
-		UserData128: tdb_types.BytesToUint128(uuid.MustParse(user.Data.ID)),
-		Code:        3,
-		Limit:       8000,
-		Flags: tdb_types.AccountFilterFlags{
-			Reversed: true,
-			Credits:  true,
-		}.ToUint32(),
+	// deposits are listed from each of the user's wallets in the environment, account filters need an account
+	userWallets, err := d.walletRepository.ListByAccount(ctx, user.Data.ID, environment(ctx), req.Currency)
+	if err != nil {
+		return nil, err
 	}
-	if req.Currency != "" {
-		wallet, err := d.walletService.FetchUserWallet(ctx, &requests.FetchUserWalletRequest{UserID: req.UserID, Currency: req.Currency})
+	if req.Currency != "" && len(userWallets) == 0 {
+		return nil, errors.NewNotFoundError("wallet not found")
+	}
+
+	from, to := req.Period()
+	keep := func(transfer tdb_types.Transfer) bool {
+		switch {
+		case req.Reference != "" && transfer.ID.String() != req.Reference:
+			return false
+		default:
+			return req.MatchesAmount(utils.FromAmount(transfer.Amount))
+		}
+	}
+	sources := make([]ledgerSource[tdb_types.Transfer], 0, len(userWallets))
+	for _, wallet := range userWallets {
+		filter, err := walletFilter(wallet, from, to)
 		if err != nil {
 			return nil, err
 		}
-		query.AccountID, _ = tdb_types.HexStringToUint128(wallet.Data.ID)
-		query.UserData128 = tdb_types.ToUint128(0)
+		filter.Code = deposit_TransferCode
+		filter.Flags = tdb_types.AccountFilterFlags{Credits: true}.ToUint32()
+		sources = append(sources, ledgerSource[tdb_types.Transfer]{filter: filter, keep: keep})
 	}
 
-	transfers, err := d.transactionDB.GetAccountTransfers(query)
+	page, pagination, err := ledgerPage(req.Pagination, sources, d.transactionDB.GetAccountTransfers, transferTimestamp)
 	if err != nil {
-		return nil, errors.HandleTxDBError(err)
+		return nil, err
+	}
+	transfers := make([]tdb_types.Transfer, 0, len(page))
+	for _, entry := range page {
+		transfers = append(transfers, entry.item)
 	}
 
 	walletIds := make([]string, 0)
@@ -192,7 +225,8 @@ func (d *depositService) FetchDeposits(ctx context.Context, req *requests.FetchD
 	}
 
 	return &responses.Response[[]*responses.DepositResponseData]{
-		Status: "successful",
-		Data:   data,
+		Status:     "successful",
+		Data:       data,
+		Pagination: pagination,
 	}, nil
 }
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
+	"github.com/2HgO/quidax-go/utils"
+	"go.uber.org/zap"
+)
+
+// IdempotencyKeyTTL is how long a key answers retries with the stored response, the key can be used for a new
+// request once it has expired
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// IdempotencyKeyLease is how long a request holds its key while it is handled, well beyond the time requests
+// take. A retry takes over the key of a request that has not completed within it, such as one whose process
+// stopped while handling it
+const IdempotencyKeyLease = time.Minute
+
+type IdempotencyService interface {
+	// Begin reserves the key for the request of the main account in the context. The returned key has a status
+	// code and response when the request has already been handled, which should be replayed
+	Begin(ctx context.Context, key string, request string) (*models.IdempotencyKey, error)
+	// Complete stores the response to replay for the reserved key
+	Complete(ctx context.Context, key *models.IdempotencyKey, statusCode int, response []byte) error
+	// Abandon releases the reserved key so the request can be retried with it, for requests that failed
+	// before they could take effect
+	Abandon(ctx context.Context, key *models.IdempotencyKey) error
+}
+
+func NewIdempotencyService(idempotencyRepository repositories.IdempotencyRepository, clock Clock, log *zap.Logger) IdempotencyService {
+	return &idempotencyService{
+		service{
+			log:                   log,
+			idempotencyRepository: idempotencyRepository,
+		},
+		clock,
+	}
+}
+
+type idempotencyService struct {
+	service
+	clock Clock
+}
+
+func (s *idempotencyService) Begin(ctx context.Context, key string, request string) (*models.IdempotencyKey, error) {
+	principal, ok := models.PrincipalFromContext(ctx)
+	if !ok || principal.Account == nil {
+		return nil, errors.NewPermissionError("idempotency keys can only be used by main accounts")
+	}
+	if len(key) > 255 {
+		return nil, errors.NewValidationError("idempotency key must not be longer than 255 characters")
+	}
+
+	// keys are scoped to the account, the environment is part of the request so a key used with a test
+	// secret key is not replayed for a live one
+	now := s.clock.Now().UTC()
+	reserved := &models.IdempotencyKey{
+		AccountID:      principal.Account.ID,
+		Key:            key,
+		Request:        principal.Environment.String() + " " + request,
+		CreatedAt:      now,
+		LeaseExpiresAt: utils.Time(now.Add(IdempotencyKeyLease)),
+	}
+	stored, err := s.idempotencyRepository.Reserve(ctx, reserved)
+	if err != nil {
+		return nil, err
+	}
+	if stored.CreatedAt.Add(IdempotencyKeyTTL).Before(reserved.CreatedAt) {
+		if err := s.idempotencyRepository.Delete(ctx, stored.AccountID, stored.Key); err != nil {
+			return nil, err
+		}
+		if stored, err = s.idempotencyRepository.Reserve(ctx, reserved); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case stored.Request != reserved.Request:
+		return nil, errors.NewValidationError("idempotency key has already been used for a different request")
+	case stored != reserved && stored.StatusCode == nil:
+		takenOver, err := s.idempotencyRepository.TakeOver(ctx, reserved, now)
+		if err != nil {
+			return nil, err
+		}
+		if !takenOver {
+			return nil, errors.NewConflictError("a request with the idempotency key is still being handled")
+		}
+		return reserved, nil
+	}
+	return stored, nil
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, key *models.IdempotencyKey, statusCode int, response []byte) error {
+	key.StatusCode, key.Response = &statusCode, response
+	return s.idempotencyRepository.Complete(ctx, key)
+}
+
+func (s *idempotencyService) Abandon(ctx context.Context, key *models.IdempotencyKey) error {
+	return s.idempotencyRepository.Delete(ctx, key.AccountID, key.Key)
+}
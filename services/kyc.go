@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/2HgO/quidax-go/config"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// KYCVerifier checks the identity details of a kyc submission. A nil decision leaves the submission
+// pending so it can be reviewed later
+type KYCVerifier interface {
+	Verify(context.Context, *models.KYCSubmission) (*models.KYCDecision, error)
+}
+
+// NewLocalKYCVerifier returns a verifier deciding submissions by the `kyc` settings, without calling an
+// identity provider. In `manual` mode every submission is left pending for review
+func NewLocalKYCVerifier(cfg *config.Config, clock Clock) KYCVerifier {
+	return &localKYCVerifier{config: cfg.KYC, clock: clock}
+}
+
+type localKYCVerifier struct {
+	config config.KYC
+	clock  Clock
+}
+
+// Verify approves submissions from holders of the minimum age in a supported country and rejects the others
+func (l *localKYCVerifier) Verify(_ context.Context, submission *models.KYCSubmission) (*models.KYCDecision, error) {
+	if l.config.Verifier == "manual" {
+		return nil, nil
+	}
+
+	var reason string
+	switch {
+	case submission.DateOfBirth == nil || submission.DateOfBirth.AddDate(l.config.MinimumAge, 0, 0).After(l.clock.Now()):
+		reason = fmt.Sprintf("user must be at least %d years old", l.config.MinimumAge)
+	case !slices.Contains(l.config.Countries, strings.ToUpper(submission.Country)):
+		reason = "country " + submission.Country + " is not supported"
+	default:
+		return &models.KYCDecision{Status: models.Approved_KYCStatus}, nil
+	}
+	return &models.KYCDecision{Status: models.Rejected_KYCStatus, Reason: &reason}, nil
+}
+
+type kycOperation uint8
+
+const (
+	deposit_kycOperation kycOperation = iota
+	swap_kycOperation
+	withdraw_kycOperation
+)
+
+func (k kycOperation) String() string {
+	switch k {
+	case deposit_kycOperation:
+		return "deposit"
+	case swap_kycOperation:
+		return "swap"
+	case withdraw_kycOperation:
+		return "withdraw"
+	default:
+		panic("unreachable")
+	}
+}
+
+// kycPolicy lists the operations each kyc tier may perform per currency
+var kycPolicy = map[models.KYCTier]map[string][]kycOperation{
+	models.Tier0_KYCTier: {
+		"ngn": {deposit_kycOperation},
+	},
+	models.Tier1_KYCTier: {
+		"ngn":  {deposit_kycOperation, swap_kycOperation, withdraw_kycOperation},
+		"usdt": {deposit_kycOperation, swap_kycOperation, withdraw_kycOperation},
+		"usdc": {deposit_kycOperation, swap_kycOperation, withdraw_kycOperation},
+	},
+	models.Tier2_KYCTier: {
+		"ngn":  {deposit_kycOperation, swap_kycOperation, withdraw_kycOperation},
+		"usdt": {deposit_kycOperation, swap_kycOperation, withdraw_kycOperation},
+		"usdc": {deposit_kycOperation, swap_kycOperation, withdraw_kycOperation},
+		"btc":  {deposit_kycOperation, swap_kycOperation, withdraw_kycOperation},
+		"eth":  {deposit_kycOperation, swap_kycOperation, withdraw_kycOperation},
+		"bnb":  {deposit_kycOperation, swap_kycOperation, withdraw_kycOperation},
+		"sol":  {deposit_kycOperation, swap_kycOperation, withdraw_kycOperation},
+	},
+}
+
+// checkKYC fails when the account's kyc tier does not allow the operation on every currency given.
+// Main accounts are verified when they sign up so they are not gated
+func checkKYC(account *models.Account, op kycOperation, currencies ...string) error {
+	if account.IsMainAccount {
+		return nil
+	}
+	for _, currency := range currencies {
+		allowed := false
+		for _, o := range kycPolicy[account.KYCTier][currency] {
+			if o == op {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return errors.NewKYCRequiredError("user kyc tier does not allow " + op.String() + " on " + currency)
+		}
+	}
+	return nil
+}
+
+type KYCService interface {
+	SubmitKYC(context.Context, *requests.SubmitKYCRequest) (*responses.Response[*models.KYCSubmission], error)
+	FetchKYCSubmissions(context.Context, *requests.FetchKYCSubmissionsRequest) (*responses.Response[[]*models.KYCSubmission], error)
+	ReviewKYCSubmission(context.Context, *requests.ReviewKYCSubmissionRequest) (*responses.Response[*models.KYCSubmission], error)
+}
+
+func NewKYCService(transactor repositories.Transactor, accountRepository repositories.AccountRepository, kycRepository repositories.KYCRepository, authService AuthorizationService, webhookService WebhookService, verifier KYCVerifier, log *zap.Logger) KYCService {
+	return &kycService{
+		service: service{
+			transactor:        transactor,
+			authService:       authService,
+			webhookService:    webhookService,
+			log:               log,
+			accountRepository: accountRepository,
+		},
+		kycRepository: kycRepository,
+		verifier:      verifier,
+	}
+}
+
+type kycService struct {
+	service
+	kycRepository repositories.KYCRepository
+	verifier      KYCVerifier
+}
+
+func (k *kycService) SubmitKYC(ctx context.Context, req *requests.SubmitKYCRequest) (*responses.Response[*models.KYCSubmission], error) {
+	user, err := k.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsMainAccount {
+		return nil, errors.NewPermissionError("kyc is only required for sub accounts")
+	}
+	if req.Tier <= user.KYCTier {
+		return nil, errors.NewValidationError("user is already verified for the requested tier")
+	}
+	dob, err := time.Parse(time.DateOnly, req.DateOfBirth)
+	if err != nil {
+		return nil, errors.NewValidationError("invalid date of birth")
+	}
+
+	now := time.Now()
+	submission := &models.KYCSubmission{
+		ID:          uuid.NewString(),
+		AccountID:   user.ID,
+		Tier:        req.Tier,
+		PhoneNumber: req.PhoneNumber,
+		DateOfBirth: &dob,
+		Country:     req.Country,
+		Status:      models.Pending_KYCStatus,
+		CreatedAt:   &now,
+		UpdatedAt:   &now,
+		User:        user,
+	}
+	if req.IDType != "" {
+		submission.IDType = &req.IDType
+	}
+	if req.IDNumber != "" {
+		submission.IDNumber = &req.IDNumber
+	}
+
+	if err = k.kycRepository.Create(ctx, submission); err != nil {
+		return nil, err
+	}
+
+	decision, err := k.verifier.Verify(ctx, submission)
+	if err != nil {
+		return nil, err
+	}
+	switch decision {
+	case nil:
+		go k.webhookService.SendKYCUpdatedEvent(user.WebhookDetails, submission)
+	default:
+		if err = k.applyDecision(ctx, submission, decision); err != nil {
+			return nil, err
+		}
+	}
+
+	return &responses.Response[*models.KYCSubmission]{
+		Status: "successful",
+		Data:   submission,
+	}, nil
+}
+
+func (k *kycService) FetchKYCSubmissions(ctx context.Context, req *requests.FetchKYCSubmissionsRequest) (*responses.Response[[]*models.KYCSubmission], error) {
+	user, err := k.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, pagination, err := k.kycRepository.List(ctx, user.ID, req.Pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.Response[[]*models.KYCSubmission]{
+		Status:     "successful",
+		Data:       res,
+		Pagination: pagination,
+	}, nil
+}
+
+// ReviewKYCSubmission decides a pending submission by hand. Live submissions are decided by the verifier
+// so manual reviews are only allowed in the test environment
+func (k *kycService) ReviewKYCSubmission(ctx context.Context, req *requests.ReviewKYCSubmissionRequest) (*responses.Response[*models.KYCSubmission], error) {
+	if environment(ctx) != models.Test_Environment {
+		return nil, errors.NewPermissionError("kyc submissions can only be reviewed in the test environment")
+	}
+	user, err := k.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	submission, err := k.kycRepository.Find(ctx, req.SubmissionID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	submission.User = user
+	if submission.Status != models.Pending_KYCStatus {
+		return nil, errors.NewValidationError("kyc submission has already been reviewed")
+	}
+
+	err = k.applyDecision(ctx, submission, &models.KYCDecision{Status: req.Status, Reason: req.Reason})
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.Response[*models.KYCSubmission]{
+		Status: "successful",
+		Data:   submission,
+	}, nil
+}
+
+// applyDecision records the decision on the submission and, when approved, raises the user's tier to the
+// submission's if it is higher and copies the verified profile onto the account
+func (k *kycService) applyDecision(ctx context.Context, submission *models.KYCSubmission, decision *models.KYCDecision) error {
+	now := time.Now()
+	tier := submission.Tier
+	err := k.transactor.InTx(ctx, func(ctx context.Context) error {
+		// only a pending submission is decided, so of two reviews made at the same time only one applies
+		decided, err := k.kycRepository.Decide(ctx, submission.ID, decision, now)
+		if err != nil {
+			return err
+		}
+		if !decided {
+			return errors.NewValidationError("kyc submission has already been reviewed")
+		}
+		if decision.Status != models.Approved_KYCStatus {
+			return nil
+		}
+
+		account, err := k.accountRepository.FindByID(ctx, submission.AccountID)
+		if err != nil {
+			return err
+		}
+		account.PhoneNumber = &submission.PhoneNumber
+		account.DateOfBirth = submission.DateOfBirth
+		account.Country = &submission.Country
+		account.UpdatedAt = &now
+		if err = k.accountRepository.Update(ctx, account); err != nil {
+			return err
+		}
+		// a lower tier submission decided after a higher one does not downgrade the user
+		if submission.Tier <= account.KYCTier {
+			tier = account.KYCTier
+			return nil
+		}
+		return k.accountRepository.SetKYCTier(ctx, account.ID, submission.Tier)
+	})
+	if err != nil {
+		return err
+	}
+
+	submission.Status = decision.Status
+	submission.Reason = decision.Reason
+	submission.UpdatedAt = &now
+	if submission.User != nil {
+		if decision.Status == models.Approved_KYCStatus {
+			submission.User.KYCTier = tier
+			submission.User.PhoneNumber = &submission.PhoneNumber
+			submission.User.DateOfBirth = submission.DateOfBirth
+			submission.User.Country = &submission.Country
+			submission.User.UpdatedAt = &now
+		}
+		go k.webhookService.SendKYCUpdatedEvent(submission.User.WebhookDetails, submission)
+	}
+
+	return nil
+}
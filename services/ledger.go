@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	"github.com/2HgO/quidax-go/utils"
+	tdb "github.com/tigerbeetle/tigerbeetle-go"
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+	"go.uber.org/zap"
+)
+
+// LedgerService reports the house's side of every ledger, the system accounts fund deposits and take the
+// other side of swaps
+type LedgerService interface {
+	FetchLedgerPositions(context.Context, *requests.FetchLedgerPositionsRequest) (*responses.Response[[]*responses.LedgerPositionResponseData], error)
+	// InspectLedgerRecord looks up a ledger account, with its latest transfers, or a transfer by its id
+	InspectLedgerRecord(context.Context, *requests.InspectLedgerRecordRequest) (*responses.Response[*responses.LedgerRecordResponseData], error)
+}
+
+func NewLedgerService(txDatabase tdb.Client, authService AuthorizationService, log *zap.Logger) LedgerService {
+	return &ledgerService{
+		service{
+			transactionDB: txDatabase,
+			authService:   authService,
+			log:           log,
+		},
+	}
+}
+
+type ledgerService struct {
+	service
+}
+
+func (l *ledgerService) FetchLedgerPositions(ctx context.Context, req *requests.FetchLedgerPositionsRequest) (*responses.Response[[]*responses.LedgerPositionResponseData], error) {
+	if err := l.authService.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	ledgers := make([]uint32, 0, len(Ledgers))
+	for ledger, currency := range Ledgers {
+		if req.Environment != "" && LedgerEnvironment(ledger).String() != req.Environment {
+			continue
+		}
+		if req.Currency != "" && currency != req.Currency {
+			continue
+		}
+		ledgers = append(ledgers, ledger)
+	}
+	slices.Sort(ledgers)
+
+	ids := make([]tdb_types.Uint128, 0, len(ledgers))
+	for _, ledger := range ledgers {
+		ids = append(ids, tdb_types.ToUint128(uint64(ledger)))
+	}
+	systemAccounts, err := l.transactionDB.LookupAccounts(ids)
+	if err != nil {
+		return nil, errors.HandleTxDBError(err)
+	}
+	system := make(map[uint32]tdb_types.Account, len(systemAccounts))
+	for _, account := range systemAccounts {
+		system[account.Ledger] = account
+	}
+
+	res := make([]*responses.LedgerPositionResponseData, 0, len(ledgers))
+	for _, ledger := range ledgers {
+		account, ok := system[ledger]
+		if !ok {
+			return nil, errors.NewFailedDependencyError("system account not found for ledger")
+		}
+		position, err := l.ledgerPosition(account)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, position)
+	}
+
+	return &responses.Response[[]*responses.LedgerPositionResponseData]{
+		Status: "successful",
+		Data:   res,
+	}, nil
+}
+
+func (l *ledgerService) InspectLedgerRecord(ctx context.Context, req *requests.InspectLedgerRecordRequest) (*responses.Response[*responses.LedgerRecordResponseData], error) {
+	if err := l.authService.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	id, err := tdb_types.HexStringToUint128(req.ID)
+	if err != nil {
+		return nil, errors.NewValidationError("invalid ledger record id")
+	}
+
+	data := &responses.LedgerRecordResponseData{}
+	accounts, err := l.transactionDB.LookupAccounts([]tdb_types.Uint128{id})
+	if err != nil {
+		return nil, errors.HandleTxDBError(err)
+	}
+	if len(accounts) == 1 {
+		data.Account = ledgerAccountData(accounts[0])
+		data.Transfers = make([]*responses.LedgerTransferResponseData, 0)
+		if req.Transfers > 0 {
+			transfers, err := l.transactionDB.GetAccountTransfers(tdb_types.AccountFilter{
+				AccountID: id,
+				Limit:     req.Transfers,
+				Flags: tdb_types.AccountFilterFlags{
+					Debits:   true,
+					Credits:  true,
+					Reversed: true,
+				}.ToUint32(),
+			})
+			if err != nil {
+				return nil, errors.HandleTxDBError(err)
+			}
+			for _, transfer := range transfers {
+				data.Transfers = append(data.Transfers, ledgerTransferData(transfer))
+			}
+		}
+	} else {
+		transfers, err := l.transactionDB.LookupTransfers([]tdb_types.Uint128{id})
+		if err != nil {
+			return nil, errors.HandleTxDBError(err)
+		}
+		if len(transfers) != 1 {
+			return nil, errors.NewNotFoundError("ledger record not found")
+		}
+		data.Transfer = ledgerTransferData(transfers[0])
+	}
+
+	return &responses.Response[*responses.LedgerRecordResponseData]{
+		Status: "successful",
+		Data:   data,
+	}, nil
+}
+
+// the names of account and transfer flags, in the order of their bits
+var (
+	accountFlagNames  = []string{"linked", "debits_must_not_exceed_credits", "credits_must_not_exceed_debits", "history", "imported", "closed"}
+	transferFlagNames = []string{"linked", "pending", "post_pending_transfer", "void_pending_transfer", "balancing_debit", "balancing_credit", "closing_debit", "closing_credit", "imported"}
+)
+
+func flagNames(names []string, flags uint16) []string {
+	res := make([]string, 0)
+	for bit, name := range names {
+		if flags&(1<<bit) != 0 {
+			res = append(res, name)
+		}
+	}
+	return res
+}
+
+func ledgerAccountData(account tdb_types.Account) *responses.LedgerAccountResponseData {
+	currency := Ledgers[account.Ledger]
+	return &responses.LedgerAccountResponseData{
+		ID:             account.ID.String(),
+		Ledger:         account.Ledger,
+		Environment:    LedgerEnvironment(account.Ledger),
+		Currency:       currency,
+		Code:           account.Code,
+		Flags:          flagNames(accountFlagNames, account.Flags),
+		DebitsPending:  utils.ApproximateAmount(currency, utils.FromAmount(account.DebitsPending)),
+		DebitsPosted:   utils.ApproximateAmount(currency, utils.FromAmount(account.DebitsPosted)),
+		CreditsPending: utils.ApproximateAmount(currency, utils.FromAmount(account.CreditsPending)),
+		CreditsPosted:  utils.ApproximateAmount(currency, utils.FromAmount(account.CreditsPosted)),
+		UserData128:    account.UserData128.String(),
+		UserData64:     account.UserData64,
+		UserData32:     account.UserData32,
+		CreatedAt:      time.Unix(0, int64(account.Timestamp)),
+	}
+}
+
+func ledgerTransferData(transfer tdb_types.Transfer) *responses.LedgerTransferResponseData {
+	currency := Ledgers[transfer.Ledger]
+	data := &responses.LedgerTransferResponseData{
+		ID:              transfer.ID.String(),
+		DebitAccountID:  transfer.DebitAccountID.String(),
+		CreditAccountID: transfer.CreditAccountID.String(),
+		Amount:          utils.ApproximateAmount(currency, utils.FromAmount(transfer.Amount)),
+		Ledger:          transfer.Ledger,
+		Environment:     LedgerEnvironment(transfer.Ledger),
+		Currency:        currency,
+		Code:            transfer.Code,
+		Flags:           flagNames(transferFlagNames, transfer.Flags),
+		Timeout:         transfer.Timeout,
+		UserData128:     transfer.UserData128.String(),
+		UserData64:      transfer.UserData64,
+		UserData32:      transfer.UserData32,
+		CreatedAt:       time.Unix(0, int64(transfer.Timestamp)),
+	}
+	if transfer.PendingID != (tdb_types.Uint128{}) {
+		data.PendingID = transfer.PendingID.String()
+	}
+	return data
+}
+
+// ledgerPosition sums the balances of every wallet on the system account's ledger. Wallets are read after
+// the system account so the two can be a few transfers apart while the ledger is in use
+func (l *ledgerService) ledgerPosition(system tdb_types.Account) (*responses.LedgerPositionResponseData, error) {
+	currency := Ledgers[system.Ledger]
+	asOf := time.Now()
+
+	wallets, err := l.scanQueryAccounts(tdb_types.QueryFilter{
+		Ledger: system.Ledger,
+		Code:   1,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	liabilities, pendingDebits, pendingCredits := new(big.Int), new(big.Int), new(big.Int)
+	for _, wallet := range wallets {
+		credits, debits := wallet.CreditsPosted.BigInt(), wallet.DebitsPosted.BigInt()
+		liabilities.Add(liabilities, &credits)
+		liabilities.Sub(liabilities, &debits)
+
+		held, incoming := wallet.DebitsPending.BigInt(), wallet.CreditsPending.BigInt()
+		pendingDebits.Add(pendingDebits, &held)
+		pendingCredits.Add(pendingCredits, &incoming)
+	}
+	exposure := new(big.Int).Sub(liabilities, pendingDebits)
+	exposure.Add(exposure, pendingCredits)
+
+	credits, debits := system.CreditsPosted.BigInt(), system.DebitsPosted.BigInt()
+	position := new(big.Int).Sub(&credits, &debits)
+
+	netExposure := signedAmount(currency, exposure)
+	return &responses.LedgerPositionResponseData{
+		Ledger:               system.Ledger,
+		Environment:          LedgerEnvironment(system.Ledger),
+		Currency:             currency,
+		SystemAccountID:      system.ID.String(),
+		SystemPosition:       signedAmount(currency, position),
+		SystemDebitsPending:  utils.ApproximateAmount(currency, utils.FromAmount(system.DebitsPending)),
+		SystemCreditsPending: utils.ApproximateAmount(currency, utils.FromAmount(system.CreditsPending)),
+		Wallets:              len(wallets),
+		Liabilities:          signedAmount(currency, liabilities),
+		PendingDebits:        signedAmount(currency, pendingDebits),
+		PendingCredits:       signedAmount(currency, pendingCredits),
+		NetExposure:          netExposure,
+		NetExposureUSDT:      utils.ApproximateAmount("usdt", netExposure*Rates[currency]["usdt"]),
+		AsOf:                 asOf,
+	}, nil
+}
+
+// signedAmount converts a sum of ledger amounts, which may be negative or overflow a uint64, rounding towards zero
+func signedAmount(currency string, amount *big.Int) float64 {
+	value, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), big.NewFloat(1e9)).Float64()
+	if value < 0 {
+		return -utils.ApproximateAmount(currency, -value)
+	}
+	return utils.ApproximateAmount(currency, value)
+}
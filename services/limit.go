@@ -0,0 +1,239 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/2HgO/quidax-go/config"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	"github.com/2HgO/quidax-go/utils"
+	"go.uber.org/zap"
+)
+
+type LimitService interface {
+	FetchUserLimits(context.Context, *requests.FetchUserLimitsRequest) (*responses.Response[[]*responses.UserLimitResponseData], error)
+	// SetSubAccountLimit sets the limit the main account's sub accounts of a kyc tier get in place of the tier's
+	// default, caps it leaves unset fall back to the default
+	SetSubAccountLimit(context.Context, *requests.SetSubAccountLimitRequest) (*responses.Response[*responses.SubAccountLimitResponseData], error)
+	// ReserveLimit fails when moving the amount out of the user's wallet at the time would exceed any of its
+	// limits, otherwise it adds the amount to the wallet's usage. The usage is written through the transaction in
+	// the context, the one recording the operation, so it is only kept when the operation is
+	ReserveLimit(ctx context.Context, user *models.Account, walletID string, currency string, op models.LimitOperation, amount float64, at time.Time) error
+	// ReleaseLimit gives back the amount reserved at the time, for operations that were undone or did not go through
+	ReleaseLimit(ctx context.Context, walletID string, op models.LimitOperation, amount float64, at time.Time) error
+}
+
+func NewLimitService(walletRepository repositories.WalletRepository, limitRepository repositories.LimitRepository, authService AuthorizationService, cfg *config.Config, log *zap.Logger) LimitService {
+	return &limitService{
+		service{
+			authService:      authService,
+			config:           cfg,
+			log:              log,
+			walletRepository: walletRepository,
+		},
+		limitRepository,
+	}
+}
+
+type limitService struct {
+	service
+	limitRepository repositories.LimitRepository
+}
+
+// limitPeriods returns the keys usage is recorded under for the day and the month of the time, in utc
+func limitPeriods(at time.Time) (day string, month string) {
+	at = at.UTC()
+	return at.Format(time.DateOnly), at.Format("2006-01")
+}
+
+// tierLimit returns the configured default of the tier for the operation on the currency
+func (l *limitService) tierLimit(tier models.KYCTier, currency string, op models.LimitOperation) models.TransactionLimit {
+	limit := l.config.Limits.Limit(int(tier), currency, op.String())
+	return models.TransactionLimit{Single: limit.Single, Daily: limit.Daily, Monthly: limit.Monthly}
+}
+
+// limit returns the limit the user's main account set for the operation, falling back to the tier default
+// for any cap it did not set
+func (l *limitService) limit(ctx context.Context, user *models.Account, currency string, op models.LimitOperation) (models.TransactionLimit, error) {
+	limit := l.tierLimit(user.KYCTier, currency, op)
+	if user.ParentID == nil {
+		return limit, nil
+	}
+
+	set, err := l.limitRepository.Find(ctx, *user.ParentID, user.KYCTier, currency, op)
+	if err != nil || set == nil {
+		return limit, err
+	}
+	if set.Single != nil {
+		limit.Single = set.Single
+	}
+	if set.Daily != nil {
+		limit.Daily = set.Daily
+	}
+	if set.Monthly != nil {
+		limit.Monthly = set.Monthly
+	}
+
+	return limit, nil
+}
+
+// usage returns the amounts moved out of the wallet with the operation in the day and the month of the time
+func (l *limitService) usage(ctx context.Context, walletID string, op models.LimitOperation, at time.Time) (daily float64, monthly float64, err error) {
+	day, month := limitPeriods(at)
+	usage, err := l.limitRepository.Usage(ctx, walletID, op, day, month)
+	if err != nil {
+		return 0, 0, err
+	}
+	return usage[day], usage[month], nil
+}
+
+func (l *limitService) ReserveLimit(ctx context.Context, user *models.Account, walletID string, currency string, op models.LimitOperation, amount float64, at time.Time) error {
+	// main accounts are not limited, same as kyc
+	if user.IsMainAccount {
+		return nil
+	}
+
+	limit, err := l.limit(ctx, user, currency, op)
+	if err != nil {
+		return err
+	}
+	if limit.Single != nil && amount > *limit.Single {
+		return errors.NewLimitExceededError(
+			fmt.Sprintf("%s of %v %s exceeds the single transaction limit", op, amount, currency),
+			errors.LimitExceededDetails{Currency: currency, Period: "single", Limit: *limit.Single, Remaining: *limit.Single},
+		)
+	}
+
+	// usage is recorded for unlimited periods too, so it is right when a limit is set later
+	day, month := limitPeriods(at)
+	for _, period := range []struct {
+		name  string
+		key   string
+		limit *float64
+	}{{"daily", day, limit.Daily}, {"monthly", month, limit.Monthly}} {
+		reserved, err := l.limitRepository.Reserve(ctx, walletID, op, period.key, amount, period.limit)
+		if err != nil {
+			return err
+		}
+		if reserved {
+			continue
+		}
+
+		usage, err := l.limitRepository.Usage(ctx, walletID, op, period.key)
+		if err != nil {
+			return err
+		}
+		return errors.NewLimitExceededError(
+			fmt.Sprintf("%s of %v %s exceeds the %s limit", op, amount, currency, period.name),
+			errors.LimitExceededDetails{Currency: currency, Period: period.name, Limit: *period.limit, Remaining: max(*period.limit-usage[period.key], 0)},
+		)
+	}
+
+	return nil
+}
+
+func (l *limitService) ReleaseLimit(ctx context.Context, walletID string, op models.LimitOperation, amount float64, at time.Time) error {
+	day, month := limitPeriods(at)
+	for _, period := range []string{day, month} {
+		if err := l.limitRepository.Release(ctx, walletID, op, period, amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *limitService) FetchUserLimits(ctx context.Context, req *requests.FetchUserLimitsRequest) (*responses.Response[[]*responses.UserLimitResponseData], error) {
+	user, err := l.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]*responses.UserLimitResponseData, 0)
+	if user.IsMainAccount {
+		return &responses.Response[[]*responses.UserLimitResponseData]{
+			Status: "successful",
+			Data:   data,
+		}, nil
+	}
+
+	currencies := make([]string, 0, len(LedgerIDs[user.Environment]))
+	for currency := range LedgerIDs[user.Environment] {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	now := time.Now()
+	for _, currency := range currencies {
+		wallet, err := l.findWallet(ctx, user.ID, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, op := range []models.LimitOperation{models.Withdrawal_LimitOperation, models.Swap_LimitOperation} {
+			limit, err := l.limit(ctx, user, currency, op)
+			if err != nil {
+				return nil, err
+			}
+			daily, monthly, err := l.usage(ctx, wallet.ID, op, now)
+			if err != nil {
+				return nil, err
+			}
+
+			data = append(data, &responses.UserLimitResponseData{
+				Currency:  currency,
+				Operation: op,
+				Single:    limit.Single,
+				Daily:     limitUsage(limit.Daily, daily),
+				Monthly:   limitUsage(limit.Monthly, monthly),
+			})
+		}
+	}
+
+	return &responses.Response[[]*responses.UserLimitResponseData]{
+		Status: "successful",
+		Data:   data,
+	}, nil
+}
+
+func (l *limitService) SetSubAccountLimit(ctx context.Context, req *requests.SetSubAccountLimitRequest) (*responses.Response[*responses.SubAccountLimitResponseData], error) {
+	account, err := l.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !account.IsMainAccount {
+		return nil, errors.NewValidationError("limits are set by main accounts for their sub accounts")
+	}
+
+	set := models.TransactionLimit{Single: req.Single, Daily: req.Daily, Monthly: req.Monthly}
+	if err = l.limitRepository.Set(ctx, account.ID, req.Tier, req.Currency, *req.Operation, set); err != nil {
+		return nil, err
+	}
+
+	limit := l.tierLimit(req.Tier, req.Currency, *req.Operation)
+	return &responses.Response[*responses.SubAccountLimitResponseData]{
+		Status: "successful",
+		Data: &responses.SubAccountLimitResponseData{
+			Tier:      req.Tier,
+			Currency:  req.Currency,
+			Operation: *req.Operation,
+			Single:    cmp.Or(set.Single, limit.Single),
+			Daily:     cmp.Or(set.Daily, limit.Daily),
+			Monthly:   cmp.Or(set.Monthly, limit.Monthly),
+		},
+	}, nil
+}
+
+func limitUsage(limit *float64, used float64) responses.LimitUsage {
+	usage := responses.LimitUsage{Limit: limit, Used: used}
+	if limit != nil {
+		usage.Remaining = utils.Float64(max(*limit-used, 0))
+	}
+	return usage
+}
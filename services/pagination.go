@@ -0,0 +1,229 @@
+package services
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/repositories"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	"github.com/2HgO/quidax-go/utils"
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+)
+
+// keysetCursor is the position of the last row of a page in a table ordered by creation time
+type keysetCursor = repositories.KeysetCursor
+
+// ledgerCursor is the position of the last entry of a page read from tigerbeetle, entries are ordered by their
+// timestamp and then by the account they were read from
+type ledgerCursor struct {
+	Timestamp uint64 `json:"t"`
+	Account   string `json:"a"`
+}
+
+// ledgerBatchSize is the most entries read from tigerbeetle with one request
+const ledgerBatchSize = 8000
+
+func trimPage[T any](items []T, pagination *responses.Pagination, cursor func(T) any) []T {
+	return repositories.TrimPage(items, pagination, cursor)
+}
+
+// scanAccountTransfers reads every transfer matched by the filter, newest first, keeping the ones keep accepts
+func (s *service) scanAccountTransfers(filter tdb_types.AccountFilter, keep func(tdb_types.Transfer) bool) ([]tdb_types.Transfer, error) {
+	filter.Limit = ledgerBatchSize
+	filter.Flags = filter.Flags | tdb_types.AccountFilterFlags{Reversed: true}.ToUint32()
+
+	result := make([]tdb_types.Transfer, 0)
+	for {
+		transfers, err := s.transactionDB.GetAccountTransfers(filter)
+		if err != nil {
+			return nil, errors.HandleTxDBError(err)
+		}
+		for _, transfer := range transfers {
+			if keep(transfer) {
+				result = append(result, transfer)
+			}
+		}
+		if len(transfers) < ledgerBatchSize {
+			break
+		}
+		filter.TimestampMax = transfers[len(transfers)-1].Timestamp - 1
+	}
+
+	return result, nil
+}
+
+// scanAccountBalances reads every historical balance of the account matched by the filter, newest first
+func (s *service) scanAccountBalances(filter tdb_types.AccountFilter) ([]tdb_types.AccountBalance, error) {
+	filter.Limit = ledgerBatchSize
+	filter.Flags = filter.Flags | tdb_types.AccountFilterFlags{Reversed: true}.ToUint32()
+
+	result := make([]tdb_types.AccountBalance, 0)
+	for {
+		balances, err := s.transactionDB.GetAccountBalances(filter)
+		if err != nil {
+			return nil, errors.HandleTxDBError(err)
+		}
+		result = append(result, balances...)
+		if len(balances) < ledgerBatchSize {
+			break
+		}
+		filter.TimestampMax = balances[len(balances)-1].Timestamp - 1
+	}
+
+	return result, nil
+}
+
+// scanQueryAccounts reads every account matched by the query filter, oldest first
+func (s *service) scanQueryAccounts(filter tdb_types.QueryFilter) ([]tdb_types.Account, error) {
+	filter.Limit = ledgerBatchSize
+
+	result := make([]tdb_types.Account, 0)
+	for {
+		accounts, err := s.transactionDB.QueryAccounts(filter)
+		if err != nil {
+			return nil, errors.HandleTxDBError(err)
+		}
+		result = append(result, accounts...)
+		if len(accounts) < ledgerBatchSize {
+			break
+		}
+		filter.TimestampMin = accounts[len(accounts)-1].Timestamp + 1
+	}
+
+	return result, nil
+}
+
+// scanQueryTransfers reads every transfer matched by the query filter, oldest first
+func (s *service) scanQueryTransfers(filter tdb_types.QueryFilter) ([]tdb_types.Transfer, error) {
+	filter.Limit = ledgerBatchSize
+
+	result := make([]tdb_types.Transfer, 0)
+	for {
+		transfers, err := s.transactionDB.QueryTransfers(filter)
+		if err != nil {
+			return nil, errors.HandleTxDBError(err)
+		}
+		result = append(result, transfers...)
+		if len(transfers) < ledgerBatchSize {
+			break
+		}
+		filter.TimestampMin = transfers[len(transfers)-1].Timestamp + 1
+	}
+
+	return result, nil
+}
+
+// ledgerSource is an account whose entries matched by the filter are listed, keep drops the entries the
+// filter can not express and is nil when every entry is kept
+type ledgerSource[T any] struct {
+	filter tdb_types.AccountFilter
+	keep   func(T) bool
+}
+
+// ledgerEntry is a listed entry with the account it was read from
+type ledgerEntry[T any] struct {
+	account tdb_types.Uint128
+	item    T
+}
+
+// ledgerPage returns the requested page of the entries of the sources newest first, read returns the entries of
+// an account matched by a filter and timestamp orders them. The cursor, or the entries before the page when it
+// is requested by number, bounds the filters so every account is only read up to the end of the page. Counting
+// every match would read the accounts' whole history, so the total counts the entries up to the end of the page
+// with one more when there is a next page
+func ledgerPage[T any](page requests.Pagination, sources []ledgerSource[T], read func(tdb_types.AccountFilter) ([]T, error), timestamp func(T) uint64) ([]ledgerEntry[T], *responses.Pagination, error) {
+	skip := page.Offset()
+	var cursor *ledgerCursor
+	if page.Cursor != "" {
+		cursor = new(ledgerCursor)
+		if err := utils.DecodeCursor(page.Cursor, cursor); err != nil {
+			return nil, nil, err
+		}
+		skip = 0
+	}
+	want := skip + page.PerPage + 1
+
+	entries := make([]ledgerEntry[T], 0)
+	for _, source := range sources {
+		filter := source.filter
+		if cursor != nil {
+			// entries at the cursor's timestamp are still to be listed for accounts ordered after the cursor's
+			before := cursor.Timestamp - 1
+			if source.filter.AccountID.String() < cursor.Account {
+				before = cursor.Timestamp
+			}
+			if before == 0 || before < filter.TimestampMin {
+				continue
+			}
+			if filter.TimestampMax == 0 || before < filter.TimestampMax {
+				filter.TimestampMax = before
+			}
+		}
+
+		items, err := readNewest(filter, want, read, timestamp, source.keep)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, item := range items {
+			entries = append(entries, ledgerEntry[T]{account: source.filter.AccountID, item: item})
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b ledgerEntry[T]) int {
+		if c := cmp.Compare(timestamp(b.item), timestamp(a.item)); c != 0 {
+			return c
+		}
+		return strings.Compare(b.account.String(), a.account.String())
+	})
+	entries = entries[:min(want, len(entries))]
+
+	pagination := &responses.Pagination{Page: page.Page, PerPage: page.PerPage, Total: len(entries)}
+	entries = trimPage(entries[min(skip, len(entries)):], pagination, func(entry ledgerEntry[T]) any {
+		return ledgerCursor{Timestamp: timestamp(entry.item), Account: entry.account.String()}
+	})
+
+	return entries, pagination, nil
+}
+
+// readNewest reads up to n of the entries matched by the filter that keep accepts, newest first. Entries are read
+// in batches of n so no more are read than needed when keep accepts them all
+func readNewest[T any](filter tdb_types.AccountFilter, n int, read func(tdb_types.AccountFilter) ([]T, error), timestamp func(T) uint64, keep func(T) bool) ([]T, error) {
+	filter.Limit = uint32(min(n, ledgerBatchSize))
+	filter.Flags = filter.Flags | tdb_types.AccountFilterFlags{Reversed: true}.ToUint32()
+
+	result := make([]T, 0, n)
+	for {
+		items, err := read(filter)
+		if err != nil {
+			return nil, errors.HandleTxDBError(err)
+		}
+		for _, item := range items {
+			if keep != nil && !keep(item) {
+				continue
+			}
+			if result = append(result, item); len(result) == n {
+				return result, nil
+			}
+		}
+
+		last := uint64(0)
+		if len(items) > 0 {
+			last = timestamp(items[len(items)-1])
+		}
+		if len(items) < int(filter.Limit) || last <= max(filter.TimestampMin, 1) {
+			return result, nil
+		}
+		filter.TimestampMax = last - 1
+	}
+}
+
+func transferTimestamp(transfer tdb_types.Transfer) uint64 {
+	return transfer.Timestamp
+}
+
+func balanceTimestamp(balance tdb_types.AccountBalance) uint64 {
+	return balance.Timestamp
+}
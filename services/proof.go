@@ -0,0 +1,390 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	mrand "math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/2HgO/quidax-go/config"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/merkle"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	"github.com/2HgO/quidax-go/utils"
+	"github.com/google/uuid"
+	"github.com/madflojo/tasks"
+	tdb "github.com/tigerbeetle/tigerbeetle-go"
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+	"go.uber.org/zap"
+)
+
+// snapshotDelay is how far in the past snapshots are taken, so every transfer up to the snapshot's ledger
+// timestamp has been committed when the balances are read
+const snapshotDelay = time.Minute
+
+// ProofService snapshots every wallet balance into a merkle sum tree per currency, the roots are published
+// and users are given proofs that their balances are included in them
+type ProofService interface {
+	// Snapshot takes and stores a liability snapshot of the environment
+	Snapshot(context.Context, models.Environment) (*models.LiabilitySnapshot, error)
+
+	StartLiabilitySnapshot(context.Context, *requests.StartLiabilitySnapshotRequest) (*responses.Response[*models.LiabilitySnapshot], error)
+	FetchLiabilitySnapshots(context.Context, *requests.FetchLiabilitySnapshotsRequest) (*responses.Response[[]*models.LiabilitySnapshot], error)
+	FetchLiabilitySnapshot(context.Context, *requests.FetchLiabilitySnapshotRequest) (*responses.Response[*models.LiabilitySnapshot], error)
+	FetchLiabilityProof(context.Context, *requests.FetchLiabilityProofRequest) (*responses.Response[*responses.LiabilityProofResponseData], error)
+}
+
+func NewProofService(liabilityRepository repositories.LiabilityRepository, txDatabase tdb.Client, authService AuthorizationService, accountService AccountService, scheduler *tasks.Scheduler, clock Clock, cfg *config.Config, log *zap.Logger) ProofService {
+	p := &proofService{
+		service: service{
+			transactionDB:  txDatabase,
+			authService:    authService,
+			accountService: accountService,
+			log:            log,
+		},
+		liabilityRepository: liabilityRepository,
+		clock:               clock,
+	}
+
+	if interval := cfg.Proofs.SnapshotInterval; interval > 0 {
+		_, err := scheduler.Add(&tasks.Task{
+			Interval:          interval,
+			RunSingleInstance: true,
+			TaskFunc: func() error {
+				for _, env := range []models.Environment{models.Test_Environment, models.Live_Environment} {
+					if _, err := p.Snapshot(context.Background(), env); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			ErrFunc: func(err error) {
+				p.log.Error("taking scheduled liability snapshot", zap.Error(err))
+			},
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return p
+}
+
+type proofService struct {
+	service
+	liabilityRepository repositories.LiabilityRepository
+	clock               Clock
+	// held while a snapshot is taken so snapshots never overlap within the process
+	running sync.Mutex
+}
+
+func (p *proofService) Snapshot(ctx context.Context, env models.Environment) (*models.LiabilitySnapshot, error) {
+	snapshot, err := p.begin(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	p.run(ctx, snapshot)
+
+	return snapshot, nil
+}
+
+// StartLiabilitySnapshot takes a snapshot in the background, the snapshot can be polled until it completes
+func (p *proofService) StartLiabilitySnapshot(ctx context.Context, req *requests.StartLiabilitySnapshotRequest) (*responses.Response[*models.LiabilitySnapshot], error) {
+	if err := p.authService.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	snapshot, err := p.begin(ctx, *req.Environment)
+	if err != nil {
+		return nil, err
+	}
+	started := *snapshot
+	go p.run(context.Background(), snapshot)
+
+	return &responses.Response[*models.LiabilitySnapshot]{
+		Status: "successful",
+		Data:   &started,
+	}, nil
+}
+
+// FetchLiabilitySnapshots lists completed snapshots with their roots, newest first. Roots are public so
+// partners can check them against the proofs users are given
+func (p *proofService) FetchLiabilitySnapshots(ctx context.Context, req *requests.FetchLiabilitySnapshotsRequest) (*responses.Response[[]*models.LiabilitySnapshot], error) {
+	var env *models.Environment
+	for _, e := range []models.Environment{models.Test_Environment, models.Live_Environment} {
+		if req.Environment == e.String() {
+			env = &e
+		}
+	}
+
+	res, pagination, err := p.liabilityRepository.ListCompleted(ctx, env, req.Pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snapshot := range res {
+		if snapshot.Roots, err = p.roots(ctx, snapshot.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return &responses.Response[[]*models.LiabilitySnapshot]{
+		Status:     "successful",
+		Data:       res,
+		Pagination: pagination,
+	}, nil
+}
+
+func (p *proofService) FetchLiabilitySnapshot(ctx context.Context, req *requests.FetchLiabilitySnapshotRequest) (*responses.Response[*models.LiabilitySnapshot], error) {
+	snapshot, err := p.snapshot(ctx, req.SnapshotID)
+	if err != nil {
+		return nil, err
+	}
+	// running and failed snapshots are only visible to admins
+	if snapshot.Status != models.Completed_LiabilitySnapshotStatus {
+		if err := p.authService.AuthorizeAdmin(ctx); err != nil {
+			return nil, errors.NewNotFoundError("liability snapshot not found")
+		}
+	}
+
+	if snapshot.Roots, err = p.roots(ctx, snapshot.ID); err != nil {
+		return nil, err
+	}
+
+	return &responses.Response[*models.LiabilitySnapshot]{
+		Status: "successful",
+		Data:   snapshot,
+	}, nil
+}
+
+// FetchLiabilityProof proves the user's balance of every currency is included in the snapshot's roots. The
+// currency's tree is rebuilt from the stored leaves and checked against its stored root first
+func (p *proofService) FetchLiabilityProof(ctx context.Context, req *requests.FetchLiabilityProofRequest) (*responses.Response[*responses.LiabilityProofResponseData], error) {
+	user, err := p.accountService.FetchAccountDetails(ctx, &requests.FetchAccountDetailsRequest{UserID: req.UserID})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := p.snapshot(ctx, req.SnapshotID)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.Environment != environment(ctx) {
+		return nil, errors.NewNotFoundError("liability snapshot not found")
+	}
+	if snapshot.Status != models.Completed_LiabilitySnapshotStatus {
+		return nil, errors.NewConflictError("liability snapshot is " + snapshot.Status.String())
+	}
+	roots, err := p.roots(ctx, snapshot.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := p.liabilityRepository.LeafIndexes(ctx, snapshot.ID, user.Data.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	proofs := make([]*responses.LiabilityProof, 0, len(indexes))
+	for _, root := range roots {
+		index, ok := indexes[root.Currency]
+		if !ok {
+			continue
+		}
+		tree, err := p.tree(ctx, snapshot.ID, root)
+		if err != nil {
+			return nil, err
+		}
+		proof, err := tree.Prove(index)
+		if err != nil {
+			return nil, errors.NewImplementationError()
+		}
+		proofs = append(proofs, &responses.LiabilityProof{Currency: root.Currency, Proof: proof})
+	}
+
+	return &responses.Response[*responses.LiabilityProofResponseData]{
+		Status: "successful",
+		Data: &responses.LiabilityProofResponseData{
+			SnapshotID:      snapshot.ID,
+			Environment:     snapshot.Environment,
+			LedgerTimestamp: snapshot.LedgerTimestamp,
+			Proofs:          proofs,
+		},
+	}, nil
+}
+
+func (p *proofService) snapshot(ctx context.Context, id string) (*models.LiabilitySnapshot, error) {
+	return p.liabilityRepository.Find(ctx, id)
+}
+
+func (p *proofService) roots(ctx context.Context, snapshotID string) ([]*models.LiabilityRoot, error) {
+	return p.liabilityRepository.Roots(ctx, snapshotID)
+}
+
+// tree rebuilds the currency's tree from its stored leaves, failing when it does not match the stored root
+func (p *proofService) tree(ctx context.Context, snapshotID string, root *models.LiabilityRoot) (*merkle.Tree, error) {
+	leaves, err := p.liabilityRepository.Leaves(ctx, snapshotID, root.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := merkle.Build(leaves)
+	if err != nil {
+		p.log.Error("rebuilding liability tree", zap.String("snapshot", snapshotID), zap.String("currency", root.Currency), zap.Error(err))
+		return nil, errors.NewImplementationError()
+	}
+	if rebuilt := tree.Root(); rebuilt.Hash != root.Hash || rebuilt.Sum.Cmp(root.Total) != 0 {
+		p.log.Error("liability tree does not match its root", zap.String("snapshot", snapshotID), zap.String("currency", root.Currency))
+		return nil, errors.NewImplementationError()
+	}
+
+	return tree, nil
+}
+
+// begin records a running snapshot, callers must call run with the snapshot to release the lock
+func (p *proofService) begin(ctx context.Context, env models.Environment) (*models.LiabilitySnapshot, error) {
+	if !p.running.TryLock() {
+		return nil, errors.NewConflictError("a liability snapshot is already running")
+	}
+
+	now := p.clock.Now()
+	snapshot := &models.LiabilitySnapshot{
+		ID:              uuid.NewString(),
+		Environment:     env,
+		Status:          models.Running_LiabilitySnapshotStatus,
+		LedgerTimestamp: uint64(now.Add(-snapshotDelay).UnixNano()),
+		Roots:           make([]*models.LiabilityRoot, 0),
+		CreatedAt:       now,
+	}
+	if err := p.liabilityRepository.Create(ctx, snapshot); err != nil {
+		p.running.Unlock()
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// run builds and stores the tree of every currency, a failure marks the snapshot failed
+func (p *proofService) run(ctx context.Context, snapshot *models.LiabilitySnapshot) {
+	defer p.running.Unlock()
+
+	currencies := make([]string, 0, len(LedgerIDs[snapshot.Environment]))
+	for currency := range LedgerIDs[snapshot.Environment] {
+		currencies = append(currencies, currency)
+	}
+	slices.Sort(currencies)
+
+	var err error
+	for _, currency := range currencies {
+		if err = p.snapshotLedger(ctx, snapshot, currency); err != nil {
+			break
+		}
+	}
+
+	now := p.clock.Now()
+	snapshot.Status = models.Completed_LiabilitySnapshotStatus
+	snapshot.CompletedAt = &now
+	if err != nil {
+		p.log.Error("taking liability snapshot", zap.String("snapshot", snapshot.ID), zap.Error(err))
+		snapshot.Status = models.Failed_LiabilitySnapshotStatus
+		snapshot.Reason = utils.String(errors.AsAppError(err).Message)
+	}
+
+	err = p.liabilityRepository.Finish(ctx, snapshot)
+	if err != nil {
+		p.log.Error("updating liability snapshot", zap.String("snapshot", snapshot.ID), zap.Error(err))
+	}
+}
+
+// snapshotLedger reads the posted balance of every wallet on the currency's ledger at the snapshot's
+// timestamp and stores the tree over them. Current balances are used for wallets with no transfers after
+// the timestamp, the others are read from their balance history. Empty wallets are left out
+func (p *proofService) snapshotLedger(ctx context.Context, snapshot *models.LiabilitySnapshot, currency string) error {
+	ledger := LedgerIDs[snapshot.Environment][currency]
+
+	wallets, err := p.scanQueryAccounts(tdb_types.QueryFilter{
+		Ledger:       ledger,
+		Code:         1,
+		TimestampMax: snapshot.LedgerTimestamp,
+	})
+	if err != nil {
+		return err
+	}
+	later, err := p.scanQueryTransfers(tdb_types.QueryFilter{
+		Ledger:       ledger,
+		TimestampMin: snapshot.LedgerTimestamp + 1,
+	})
+	if err != nil {
+		return err
+	}
+	changed := make(map[tdb_types.Uint128]bool)
+	for _, transfer := range later {
+		changed[transfer.DebitAccountID] = true
+		changed[transfer.CreditAccountID] = true
+	}
+
+	leaves := make([]merkle.Leaf, 0, len(wallets))
+	for _, wallet := range wallets {
+		creditsPosted, debitsPosted := wallet.CreditsPosted, wallet.DebitsPosted
+		if changed[wallet.ID] {
+			balance, err := p.accountBalanceAt(wallet.ID, snapshot.LedgerTimestamp)
+			if err != nil {
+				return err
+			}
+			if balance == nil {
+				continue
+			}
+			creditsPosted, debitsPosted = balance.CreditsPosted, balance.DebitsPosted
+		}
+
+		credits, debits := creditsPosted.BigInt(), debitsPosted.BigInt()
+		balance := new(big.Int).Sub(&credits, &debits)
+		if balance.Sign() <= 0 {
+			continue
+		}
+
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		leaves = append(leaves, merkle.Leaf{
+			UserID:   uuid.UUID(wallet.UserData128.Bytes()).String(),
+			WalletID: wallet.ID.String(),
+			Nonce:    hex.EncodeToString(nonce),
+			Balance:  balance,
+		})
+	}
+	if len(leaves) == 0 {
+		return nil
+	}
+	// leaves are shuffled so a leaf's position says nothing about when its wallet was opened
+	mrand.Shuffle(len(leaves), func(i, j int) {
+		leaves[i], leaves[j] = leaves[j], leaves[i]
+	})
+
+	tree, err := merkle.Build(leaves)
+	if err != nil {
+		return err
+	}
+	root := tree.Root()
+
+	stored := &models.LiabilityRoot{
+		Currency: currency,
+		Hash:     root.Hash,
+		Total:    root.Sum,
+		Leaves:   len(leaves),
+	}
+	if err = p.liabilityRepository.SaveTree(ctx, snapshot.ID, stored, leaves); err != nil {
+		return err
+	}
+
+	snapshot.Roots = append(snapshot.Roots, stored)
+	return nil
+}
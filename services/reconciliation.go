@@ -0,0 +1,455 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/2HgO/quidax-go/config"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	"github.com/2HgO/quidax-go/utils"
+	"github.com/google/uuid"
+	"github.com/madflojo/tasks"
+	tdb "github.com/tigerbeetle/tigerbeetle-go"
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+	"go.uber.org/zap"
+)
+
+// reconciliationGracePeriod keeps records of sagas that may still be running out of the checks, ledger
+// accounts are created before their wallet rows are committed and withdrawal and swap rows are committed
+// before their transfers are made
+const reconciliationGracePeriod = time.Minute
+
+// unresolvedHoldAge is how old a swap hold may get before it is reported, holds are posted or voided
+// within seconds of the quote expiring
+const unresolvedHoldAge = time.Hour
+
+type ReconciliationService interface {
+	// Reconcile cross checks wallets, withdrawals and swaps against the ledger and stores the report
+	Reconcile(context.Context, models.ReconciliationTrigger) (*models.ReconciliationReport, error)
+
+	StartReconciliation(context.Context) (*responses.Response[*models.ReconciliationReport], error)
+	FetchReconciliationReports(context.Context, *requests.FetchReconciliationReportsRequest) (*responses.Response[[]*models.ReconciliationReport], error)
+	FetchReconciliationReport(context.Context, *requests.FetchReconciliationReportRequest) (*responses.Response[*models.ReconciliationReport], error)
+}
+
+func NewReconciliationService(
+	walletRepository repositories.WalletRepository,
+	withdrawalRepository repositories.WithdrawalRepository,
+	swapRepository repositories.SwapRepository,
+	reconciliationRepository repositories.ReconciliationRepository,
+	txDatabase tdb.Client,
+	authService AuthorizationService,
+	scheduler *tasks.Scheduler,
+	clock Clock,
+	cfg *config.Config,
+	log *zap.Logger,
+) ReconciliationService {
+	r := &reconciliationService{
+		service: service{
+			transactionDB:        txDatabase,
+			authService:          authService,
+			log:                  log,
+			walletRepository:     walletRepository,
+			withdrawalRepository: withdrawalRepository,
+			swapRepository:       swapRepository,
+		},
+		reconciliationRepository: reconciliationRepository,
+		clock:                    clock,
+	}
+
+	if interval := cfg.Reconciliation.Interval; interval > 0 {
+		_, err := scheduler.Add(&tasks.Task{
+			Interval:          interval,
+			RunSingleInstance: true,
+			TaskFunc: func() error {
+				_, err := r.Reconcile(context.Background(), models.Scheduled_ReconciliationTrigger)
+				return err
+			},
+			ErrFunc: func(err error) {
+				r.log.Error("running scheduled reconciliation", zap.Error(err))
+			},
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return r
+}
+
+type reconciliationService struct {
+	service
+	reconciliationRepository repositories.ReconciliationRepository
+	clock                    Clock
+	// held while a reconciliation runs so runs never overlap within the process
+	running sync.Mutex
+}
+
+func (r *reconciliationService) Reconcile(ctx context.Context, trigger models.ReconciliationTrigger) (*models.ReconciliationReport, error) {
+	report, err := r.begin(ctx, trigger)
+	if err != nil {
+		return nil, err
+	}
+	r.run(ctx, report)
+
+	return report, nil
+}
+
+// StartReconciliation runs a reconciliation in the background, the report can be polled until it completes
+func (r *reconciliationService) StartReconciliation(ctx context.Context) (*responses.Response[*models.ReconciliationReport], error) {
+	if err := r.authService.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	report, err := r.begin(ctx, models.Admin_ReconciliationTrigger)
+	if err != nil {
+		return nil, err
+	}
+	started := *report
+	go r.run(context.Background(), report)
+
+	return &responses.Response[*models.ReconciliationReport]{
+		Status: "successful",
+		Data:   &started,
+	}, nil
+}
+
+func (r *reconciliationService) FetchReconciliationReports(ctx context.Context, req *requests.FetchReconciliationReportsRequest) (*responses.Response[[]*models.ReconciliationReport], error) {
+	if err := r.authService.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	res, pagination, err := r.reconciliationRepository.List(ctx, req.Pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.Response[[]*models.ReconciliationReport]{
+		Status:     "successful",
+		Data:       res,
+		Pagination: pagination,
+	}, nil
+}
+
+func (r *reconciliationService) FetchReconciliationReport(ctx context.Context, req *requests.FetchReconciliationReportRequest) (*responses.Response[*models.ReconciliationReport], error) {
+	if err := r.authService.AuthorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	report, err := r.reconciliationRepository.Find(ctx, req.ReconciliationID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.Response[*models.ReconciliationReport]{
+		Status: "successful",
+		Data:   report,
+	}, nil
+}
+
+// begin records a running reconciliation, callers must call run with the report to release the lock
+func (r *reconciliationService) begin(ctx context.Context, trigger models.ReconciliationTrigger) (*models.ReconciliationReport, error) {
+	if !r.running.TryLock() {
+		return nil, errors.NewConflictError("a reconciliation is already running")
+	}
+
+	report := &models.ReconciliationReport{
+		ID:        uuid.NewString(),
+		Trigger:   trigger,
+		Status:    models.Running_ReconciliationStatus,
+		StartedAt: r.clock.Now(),
+	}
+	if err := r.reconciliationRepository.Create(ctx, report); err != nil {
+		r.running.Unlock()
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// run performs the checks and stores the issues found, a failed check marks the report failed with the
+// issues found before it
+func (r *reconciliationService) run(ctx context.Context, report *models.ReconciliationReport) {
+	defer r.running.Unlock()
+
+	cutoff := uint64(report.StartedAt.Add(-reconciliationGracePeriod).UnixNano())
+	err := r.checkWallets(ctx, report, cutoff)
+	if err == nil {
+		err = r.checkWithdrawals(ctx, report, cutoff)
+	}
+	if err == nil {
+		err = r.checkSwaps(ctx, report, cutoff)
+	}
+
+	now := r.clock.Now()
+	report.Status = models.Completed_ReconciliationStatus
+	report.CompletedAt = &now
+	report.IssueCount = len(report.Issues)
+	if err != nil {
+		r.log.Error("reconciling data and ledger", zap.String("reconciliation", report.ID), zap.Error(err))
+		report.Status = models.Failed_ReconciliationStatus
+		report.Reason = utils.String(errors.AsAppError(err).Message)
+	}
+
+	if err = r.reconciliationRepository.AddIssues(ctx, report.ID, report.Issues); err != nil {
+		r.log.Error("storing reconciliation issues", zap.String("reconciliation", report.ID), zap.Error(err))
+		report.Status = models.Failed_ReconciliationStatus
+		report.Reason = utils.String("issues could not be stored")
+	}
+
+	err = r.reconciliationRepository.Finish(ctx, report)
+	if err != nil {
+		r.log.Error("updating reconciliation report", zap.String("reconciliation", report.ID), zap.Error(err))
+	}
+}
+
+func addIssue(report *models.ReconciliationReport, issueType models.ReconciliationIssueType, resource string, id string, env models.Environment, detail string, args ...any) {
+	report.Issues = append(report.Issues, &models.ReconciliationIssue{
+		Type:        issueType,
+		Resource:    resource,
+		ResourceID:  id,
+		Environment: env,
+		Detail:      fmt.Sprintf(detail, args...),
+	})
+}
+
+// walletsByID reads every wallet, keyed by id
+func (r *reconciliationService) walletsByID(ctx context.Context) (map[string]*models.Wallet, error) {
+	wallets, err := r.walletRepository.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*models.Wallet, len(wallets))
+	for _, wallet := range wallets {
+		res[wallet.ID] = wallet
+	}
+	return res, nil
+}
+
+// checkWallets matches every wallet row with its ledger account, and every ledger wallet account older
+// than the cutoff with a wallet row
+func (r *reconciliationService) checkWallets(ctx context.Context, report *models.ReconciliationReport, cutoff uint64) error {
+	accounts, err := r.scanQueryAccounts(tdb_types.QueryFilter{Code: 1})
+	if err != nil {
+		return err
+	}
+	ledgerAccounts := make(map[tdb_types.Uint128]tdb_types.Account, len(accounts))
+	for _, account := range accounts {
+		ledgerAccounts[account.ID] = account
+	}
+
+	wallets, err := r.walletRepository.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[tdb_types.Uint128]bool, len(accounts))
+	for _, wallet := range wallets {
+		report.WalletsChecked++
+
+		walletID, err := tdb_types.HexStringToUint128(wallet.ID)
+		if err != nil {
+			addIssue(report, models.AccountMismatch_ReconciliationIssueType, "wallet", wallet.ID, wallet.Environment, "wallet id is not a ledger account id")
+			continue
+		}
+		seen[walletID] = true
+
+		account, ok := ledgerAccounts[walletID]
+		if !ok {
+			addIssue(report, models.MissingLedgerAccount_ReconciliationIssueType, "wallet", wallet.ID, wallet.Environment, "no ledger account for %s wallet", wallet.Token)
+			continue
+		}
+		if ledger := LedgerIDs[wallet.Environment][wallet.Token]; account.Ledger != ledger {
+			addIssue(report, models.AccountMismatch_ReconciliationIssueType, "wallet", wallet.ID, wallet.Environment, "ledger account is on ledger %d, expected %d", account.Ledger, ledger)
+		}
+		owner, err := uuid.Parse(wallet.AccountID)
+		if err != nil || account.UserData128 != tdb_types.BytesToUint128(owner) {
+			addIssue(report, models.AccountMismatch_ReconciliationIssueType, "wallet", wallet.ID, wallet.Environment, "ledger account is not owned by user %s", wallet.AccountID)
+		}
+	}
+
+	for _, account := range accounts {
+		if seen[account.ID] || account.Timestamp > cutoff {
+			continue
+		}
+		addIssue(report, models.OrphanLedgerAccount_ReconciliationIssueType, "ledger_account", account.ID.String(), LedgerEnvironment(account.Ledger), "%s ledger account has no wallet", Ledgers[account.Ledger])
+	}
+
+	return nil
+}
+
+// checkWithdrawals matches every withdrawal row with its transfer, and every withdrawal transfer older than
+// the cutoff with a withdrawal row
+func (r *reconciliationService) checkWithdrawals(ctx context.Context, report *models.ReconciliationReport, cutoff uint64) error {
+	withdrawals, _, err := r.withdrawalRepository.List(ctx, repositories.WithdrawalFilter{}, nil)
+	if err != nil {
+		return err
+	}
+	// wallets are read after the withdrawals so every withdrawal's wallet is found
+	wallets, err := r.walletsByID(ctx)
+	if err != nil {
+		return err
+	}
+	report.WithdrawalsChecked = len(withdrawals)
+
+	ids := make([]tdb_types.Uint128, 0, len(withdrawals))
+	for _, w := range withdrawals {
+		txID, _ := tdb_types.HexStringToUint128(w.TxID)
+		ids = append(ids, txID)
+	}
+	transfers, err := r.lookupTransfers(ids)
+	if err != nil {
+		return err
+	}
+
+	since := report.StartedAt.Add(-reconciliationGracePeriod)
+	for i, w := range withdrawals {
+		wallet := wallets[w.WalletID]
+		env := wallet.Environment
+		transfer, ok := transfers[ids[i]]
+		if !ok {
+			if w.CreatedAt.After(since) {
+				continue
+			}
+			addIssue(report, models.MissingLedgerTransfer_ReconciliationIssueType, "withdrawal", w.ID, env, "transfer %s not found", w.TxID)
+			continue
+		}
+		walletID, _ := tdb_types.HexStringToUint128(w.WalletID)
+		switch {
+		case transfer.DebitAccountID != walletID:
+			addIssue(report, models.TransferMismatch_ReconciliationIssueType, "withdrawal", w.ID, env, "transfer %s does not debit wallet %s", w.TxID, w.WalletID)
+		case transfer.Code != withdrawal_TransferCode:
+			addIssue(report, models.TransferMismatch_ReconciliationIssueType, "withdrawal", w.ID, env, "transfer %s has code %d", w.TxID, transfer.Code)
+		case transfer.Ledger != LedgerIDs[env][wallet.Token]:
+			addIssue(report, models.TransferMismatch_ReconciliationIssueType, "withdrawal", w.ID, env, "transfer %s is on ledger %d", w.TxID, transfer.Ledger)
+		// withdrawals made before amounts were recorded have a zero amount
+		case w.Amount != 0 && transfer.Amount != utils.ToAmount(w.Amount):
+			addIssue(report, models.TransferMismatch_ReconciliationIssueType, "withdrawal", w.ID, env, "transfer %s moved %v, withdrawal is for %v", w.TxID, utils.FromAmount(transfer.Amount), w.Amount)
+		}
+	}
+
+	ledgerTransfers, err := r.scanQueryTransfers(tdb_types.QueryFilter{Code: withdrawal_TransferCode, TimestampMax: cutoff})
+	if err != nil {
+		return err
+	}
+	for _, transfer := range ledgerTransfers {
+		if _, ok := transfers[transfer.ID]; ok {
+			continue
+		}
+		addIssue(report, models.OrphanLedgerTransfer_ReconciliationIssueType, "ledger_transfer", transfer.ID.String(), LedgerEnvironment(transfer.Ledger), "withdrawal transfer of %v %s has no withdrawal", utils.FromAmount(transfer.Amount), Ledgers[transfer.Ledger])
+	}
+
+	return nil
+}
+
+// checkSwaps matches every swap row with its holds and their resolution, and every swap hold older than the
+// cutoff with a swap row
+func (r *reconciliationService) checkSwaps(ctx context.Context, report *models.ReconciliationReport, cutoff uint64) error {
+	swaps, _, err := r.swapRepository.List(ctx, repositories.SwapFilter{}, nil)
+	if err != nil {
+		return err
+	}
+	wallets, err := r.walletsByID(ctx)
+	if err != nil {
+		return err
+	}
+	environments := make([]models.Environment, 0, len(swaps))
+	for _, swap := range swaps {
+		environments = append(environments, wallets[swap.FromWalletID].Environment)
+	}
+	report.SwapsChecked = len(swaps)
+
+	ids := make([]tdb_types.Uint128, 0, 4*len(swaps))
+	for _, swap := range swaps {
+		for _, id := range []string{swap.QuoteTxID0, swap.QuoteTxID1, swap.SwapTxID0, swap.SwapTxID1} {
+			txID, _ := tdb_types.HexStringToUint128(id)
+			ids = append(ids, txID)
+		}
+	}
+	transfers, err := r.lookupTransfers(ids)
+	if err != nil {
+		return err
+	}
+
+	since := report.StartedAt.Add(-reconciliationGracePeriod)
+	holdCutoff := uint64(report.StartedAt.Add(-unresolvedHoldAge).UnixNano())
+	for i, swap := range swaps {
+		env := environments[i]
+		quote0, quote1, stx0, stx1 := ids[4*i], ids[4*i+1], ids[4*i+2], ids[4*i+3]
+		fromWalletID, _ := tdb_types.HexStringToUint128(swap.FromWalletID)
+		toWalletID, _ := tdb_types.HexStringToUint128(swap.ToWalletID)
+
+		hold0, ok0 := transfers[quote0]
+		hold1, ok1 := transfers[quote1]
+		if (!ok0 || !ok1) && swap.CreatedAt.After(since) {
+			continue
+		}
+		switch {
+		case !ok0:
+			addIssue(report, models.MissingLedgerTransfer_ReconciliationIssueType, "instant_swap", swap.ID, env, "hold %s not found", swap.QuoteTxID0)
+			continue
+		case !ok1:
+			addIssue(report, models.MissingLedgerTransfer_ReconciliationIssueType, "instant_swap", swap.ID, env, "hold %s not found", swap.QuoteTxID1)
+			continue
+		case !hold0.TransferFlags().Pending || hold0.DebitAccountID != fromWalletID || hold0.Code != swap_TransferCode:
+			addIssue(report, models.TransferMismatch_ReconciliationIssueType, "instant_swap", swap.ID, env, "hold %s is not a pending swap debit of wallet %s", swap.QuoteTxID0, swap.FromWalletID)
+			continue
+		case !hold1.TransferFlags().Pending || hold1.CreditAccountID != toWalletID || hold1.Code != swap_TransferCode:
+			addIssue(report, models.TransferMismatch_ReconciliationIssueType, "instant_swap", swap.ID, env, "hold %s is not a pending swap credit of wallet %s", swap.QuoteTxID1, swap.ToWalletID)
+			continue
+		}
+
+		resolution0, ok0 := transfers[stx0]
+		resolution1, ok1 := transfers[stx1]
+		switch {
+		case !ok0 && !ok1:
+			if hold0.Timestamp < holdCutoff {
+				addIssue(report, models.UnresolvedPendingTransfer_ReconciliationIssueType, "instant_swap", swap.ID, env, "holds were neither posted nor voided")
+			}
+		case ok0 != ok1:
+			addIssue(report, models.MissingLedgerTransfer_ReconciliationIssueType, "instant_swap", swap.ID, env, "only one of the holds was resolved")
+		case resolution0.PendingID != quote0 || resolution1.PendingID != quote1:
+			addIssue(report, models.TransferMismatch_ReconciliationIssueType, "instant_swap", swap.ID, env, "resolutions do not reference the swap holds")
+		}
+	}
+
+	ledgerTransfers, err := r.scanQueryTransfers(tdb_types.QueryFilter{Code: swap_TransferCode, TimestampMax: cutoff})
+	if err != nil {
+		return err
+	}
+	for _, transfer := range ledgerTransfers {
+		if !transfer.TransferFlags().Pending {
+			continue
+		}
+		if _, ok := transfers[transfer.ID]; ok {
+			continue
+		}
+		addIssue(report, models.OrphanLedgerTransfer_ReconciliationIssueType, "ledger_transfer", transfer.ID.String(), LedgerEnvironment(transfer.Ledger), "swap hold of %v %s has no swap", utils.FromAmount(transfer.Amount), Ledgers[transfer.Ledger])
+	}
+
+	return nil
+}
+
+// lookupTransfers looks up the transfers in batches the ledger accepts, missing transfers are left out of the result
+func (r *reconciliationService) lookupTransfers(ids []tdb_types.Uint128) (map[tdb_types.Uint128]tdb_types.Transfer, error) {
+	const batchSize = 8000
+
+	result := make(map[tdb_types.Uint128]tdb_types.Transfer, len(ids))
+	for start := 0; start < len(ids); start += batchSize {
+		transfers, err := r.transactionDB.LookupTransfers(ids[start:min(start+batchSize, len(ids))])
+		if err != nil {
+			return nil, errors.HandleTxDBError(err)
+		}
+		for _, transfer := range transfers {
+			result[transfer.ID] = transfer
+		}
+	}
+
+	return result, nil
+}
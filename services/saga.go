@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
+	"github.com/2HgO/quidax-go/utils"
+	"github.com/google/uuid"
+	"github.com/madflojo/tasks"
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+	"go.uber.org/zap"
+)
+
+// sagaStep is a step of a saga. Every step runs in a data database transaction that also records the
+// saga's progress, so rows written through the transaction are committed together with the progress
+type sagaStep struct {
+	name string
+	// forward performs the step, steps after the pivot are retried on startup so they must be safe to repeat
+	forward func(context.Context) error
+	// done reports whether a step that failed or was interrupted took effect anyway. Ledger writes are not
+	// part of the transaction so ledger steps set it to look up the ids they write
+	done func(context.Context) (bool, error)
+	// compensate undoes the step, nil when there is nothing to undo
+	compensate func(context.Context) error
+}
+
+// sagaDefinition is rebuilt from the saga's payload whenever the saga runs, so a resumed saga runs the same
+// steps it started with. The first step writes the saga's rows, its forward is given to Run and never
+// runs again since the saga is recorded in the same transaction
+type sagaDefinition struct {
+	steps []sagaStep
+	// pivot is the index of the step that commits the saga, failures up to it are compensated and failures
+	// after it are retried
+	pivot int
+}
+
+type sagaBuilder func(payload []byte) (*sagaDefinition, error)
+
+// newSagaBuilder decodes the payload before building the saga's definition
+func newSagaBuilder[P any](build func(P) *sagaDefinition) sagaBuilder {
+	return func(payload []byte) (*sagaDefinition, error) {
+		var p P
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, err
+		}
+		return build(p), nil
+	}
+}
+
+// SagaService runs operations that write to both the data database and the ledger so that a failure or a
+// crash at any point is either compensated or completed
+type SagaService interface {
+	// Register sets how sagas of the kind are rebuilt from their payload
+	Register(models.SagaKind, sagaBuilder)
+	// Run records the saga with its payload and runs it, prepare performs the first step
+	Run(ctx context.Context, kind models.SagaKind, payload any, prepare func(context.Context) error) error
+	// Recover compensates sagas left unfinished before their pivot and completes the rest. Only sagas this
+	// process owns and is not running, or whose owner's lease has run out, are recovered
+	Recover(context.Context) error
+}
+
+// sagaLease is how long a process holds the sagas it runs, the lease is renewed with every step. Sagas whose
+// lease has run out were left by a process that stopped and are recovered as often
+const sagaLease = time.Minute
+
+func NewSagaService(transactor repositories.Transactor, sagaRepository repositories.SagaRepository, scheduler *tasks.Scheduler, log *zap.Logger) SagaService {
+	s := &sagaService{
+		service: service{
+			transactor: transactor,
+			log:        log,
+		},
+		sagaRepository: sagaRepository,
+		owner:          uuid.NewString(),
+		builders:       map[models.SagaKind]sagaBuilder{},
+		running:        map[string]struct{}{},
+	}
+
+	_, err := scheduler.Add(&tasks.Task{
+		Interval:          sagaLease,
+		RunSingleInstance: true,
+		TaskFunc: func() error {
+			return s.Recover(context.Background())
+		},
+		ErrFunc: func(err error) {
+			s.log.Error("recovering sagas", zap.Error(err))
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+type sagaService struct {
+	service
+	sagaRepository repositories.SagaRepository
+	// owner identifies this process in the sagas it runs
+	owner string
+
+	mu       sync.RWMutex
+	builders map[models.SagaKind]sagaBuilder
+	// ids of the sagas this process is running, they are not recovered while they run
+	running map[string]struct{}
+}
+
+func (s *sagaService) Register(kind models.SagaKind, build sagaBuilder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.builders[kind] = build
+}
+
+// start marks the saga as running in this process, it reports false when it already is
+func (s *sagaService) start(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.running[id]; ok {
+		return false
+	}
+	s.running[id] = struct{}{}
+	return true
+}
+
+func (s *sagaService) stop(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.running, id)
+}
+
+func (s *sagaService) definition(saga *models.Saga) (*sagaDefinition, error) {
+	s.mu.RLock()
+	build, ok := s.builders[saga.Kind]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, errors.NewImplementationError()
+	}
+	return build(saga.Payload)
+}
+
+func (s *sagaService) Run(ctx context.Context, kind models.SagaKind, payload any, prepare func(context.Context) error) error {
+	// the saga must reach a consistent state even when the caller goes away
+	ctx = context.WithoutCancel(ctx)
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	saga := &models.Saga{
+		ID:             uuid.NewString(),
+		Kind:           kind,
+		Payload:        data,
+		State:          models.Running_SagaState,
+		Step:           1,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Owner:          &s.owner,
+		LeaseExpiresAt: utils.Time(now.Add(sagaLease)),
+	}
+	def, err := s.definition(saga)
+	if err != nil {
+		return err
+	}
+	s.start(saga.ID)
+	defer s.stop(saga.ID)
+
+	err = s.transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := prepare(ctx); err != nil {
+			return err
+		}
+		return s.sagaRepository.Create(ctx, saga)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.execute(ctx, saga, def)
+}
+
+func (s *sagaService) Recover(ctx context.Context) error {
+	sagas, err := s.sagaRepository.ListUnfinished(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, saga := range sagas {
+		if err = s.recover(ctx, saga); err != nil {
+			s.log.Error("recovering saga", zap.String("saga", saga.ID), zap.String("kind", saga.Kind.String()), zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// recover claims the saga and resumes it, sagas running in this process or held by another are left as they are
+func (s *sagaService) recover(ctx context.Context, saga *models.Saga) error {
+	if !s.start(saga.ID) {
+		return nil
+	}
+	defer s.stop(saga.ID)
+
+	now := time.Now()
+	claimed, err := s.sagaRepository.Claim(ctx, saga.ID, s.owner, now, now.Add(sagaLease))
+	if err != nil || !claimed {
+		return err
+	}
+	saga.Owner, saga.LeaseExpiresAt = &s.owner, utils.Time(now.Add(sagaLease))
+
+	def, err := s.definition(saga)
+	if err != nil {
+		return err
+	}
+	if err = s.resume(ctx, saga, def); err != nil {
+		return err
+	}
+	s.log.Info("recovered saga", zap.String("saga", saga.ID), zap.String("kind", saga.Kind.String()), zap.String("state", saga.State.String()))
+	return nil
+}
+
+func (s *sagaService) resume(ctx context.Context, saga *models.Saga, def *sagaDefinition) error {
+	if saga.State == models.Compensating_SagaState {
+		return s.compensate(ctx, saga, def)
+	}
+
+	if saga.Step <= def.pivot {
+		// the process may have stopped after the step took effect but before its progress was recorded
+		if step := def.steps[saga.Step]; step.done != nil {
+			done, err := step.done(ctx)
+			if err != nil {
+				return err
+			}
+			if done {
+				if err = s.advance(ctx, saga, nil, saga.Step+1, models.Running_SagaState); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if saga.Step <= def.pivot {
+		saga.Reason = utils.String("interrupted before it was committed")
+		return s.compensate(ctx, saga, def)
+	}
+
+	return s.execute(ctx, saga, def)
+}
+
+// execute runs the saga's remaining steps
+func (s *sagaService) execute(ctx context.Context, saga *models.Saga, def *sagaDefinition) error {
+	for saga.Step < len(def.steps) {
+		step := def.steps[saga.Step]
+		err := s.advance(ctx, saga, step.forward, saga.Step+1, models.Running_SagaState)
+		if err == nil {
+			continue
+		}
+
+		if step.done != nil {
+			done, doneErr := step.done(ctx)
+			switch {
+			case doneErr != nil:
+				// the outcome of the step is unknown so it is left for recovery to settle
+				s.log.Error("checking saga step", zap.String("saga", saga.ID), zap.String("step", step.name), zap.Error(doneErr))
+				return err
+			case done:
+				if err = s.advance(ctx, saga, nil, saga.Step+1, models.Running_SagaState); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if saga.Step > def.pivot {
+			// the saga is committed, the step is retried when the saga is recovered
+			s.log.Error("running saga step, it will be retried on startup", zap.String("saga", saga.ID), zap.String("step", step.name), zap.Error(err))
+			return nil
+		}
+
+		saga.Reason = utils.String(errors.AsAppError(err).Message)
+		if cErr := s.compensate(ctx, saga, def); cErr != nil {
+			s.log.Error("compensating saga", zap.String("saga", saga.ID), zap.Error(cErr))
+		}
+		return err
+	}
+
+	return s.advance(ctx, saga, nil, saga.Step, models.Completed_SagaState)
+}
+
+// compensate undoes the saga's completed steps, latest first
+func (s *sagaService) compensate(ctx context.Context, saga *models.Saga, def *sagaDefinition) error {
+	if err := s.advance(ctx, saga, nil, saga.Step, models.Compensating_SagaState); err != nil {
+		return err
+	}
+	for saga.Step > 0 {
+		step := def.steps[saga.Step-1]
+		if err := s.advance(ctx, saga, step.compensate, saga.Step-1, models.Compensating_SagaState); err != nil {
+			return err
+		}
+	}
+
+	return s.advance(ctx, saga, nil, 0, models.Compensated_SagaState)
+}
+
+// advance runs fn and records the saga's progress in the same transaction
+func (s *sagaService) advance(ctx context.Context, saga *models.Saga, fn func(context.Context) error, step int, state models.SagaState) error {
+	progress := *saga
+	progress.Step, progress.State, progress.UpdatedAt = step, state, time.Now()
+	progress.LeaseExpiresAt = utils.Time(progress.UpdatedAt.Add(sagaLease))
+	err := s.transactor.InTx(ctx, func(ctx context.Context) error {
+		if fn != nil {
+			if err := fn(ctx); err != nil {
+				return err
+			}
+		}
+		updated, err := s.sagaRepository.Update(ctx, &progress)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			// the lease ran out and another process has taken the saga over
+			return errors.NewConflictError("saga is run by another process")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	saga.Step, saga.State, saga.UpdatedAt = step, state, progress.UpdatedAt
+	return nil
+}
+
+// createAccountsStep creates the ledger accounts, the accounts' ids are fixed by the payload so the step
+// can be looked up after a failure
+func (s *service) createAccountsStep(accounts []tdb_types.Account) sagaStep {
+	ids := make([]tdb_types.Uint128, 0, len(accounts))
+	for _, account := range accounts {
+		ids = append(ids, account.ID)
+	}
+
+	return sagaStep{
+		name: "create ledger accounts",
+		forward: func(context.Context) error {
+			res, err := s.transactionDB.CreateAccounts(accounts)
+			if err != nil {
+				return errors.HandleTxDBError(err)
+			}
+			if len(res) > 0 {
+				return errors.NewFailedDependencyError(res[0].Result.String())
+			}
+			return nil
+		},
+		done: func(context.Context) (bool, error) {
+			found, err := s.transactionDB.LookupAccounts(ids)
+			if err != nil {
+				return false, errors.HandleTxDBError(err)
+			}
+			return len(found) == len(ids), nil
+		},
+	}
+}
+
+// createTransfersStep creates the ledger transfers, the transfers' ids are fixed by the payload so the step
+// can be looked up after a failure
+func (s *service) createTransfersStep(transfers []tdb_types.Transfer) sagaStep {
+	ids := make([]tdb_types.Uint128, 0, len(transfers))
+	for _, transfer := range transfers {
+		ids = append(ids, transfer.ID)
+	}
+
+	return sagaStep{
+		name: "create ledger transfers",
+		forward: func(context.Context) error {
+			res, err := s.transactionDB.CreateTransfers(transfers)
+			if err != nil {
+				return errors.HandleTxDBError(err)
+			}
+			if len(res) > 0 {
+				for _, r := range res {
+					if r.Result == tdb_types.TransferExceedsCredits {
+						return errors.NewFailedDependencyError("Insufficient Balance")
+					}
+				}
+				return errors.NewFailedDependencyError(res[0].Result.String())
+			}
+			return nil
+		},
+		done: func(context.Context) (bool, error) {
+			found, err := s.transactionDB.LookupTransfers(ids)
+			if err != nil {
+				return false, errors.HandleTxDBError(err)
+			}
+			return len(found) == len(ids), nil
+		},
+	}
+}
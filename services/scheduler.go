@@ -2,179 +2,243 @@ package services
 
 import (
 	"context"
-	"database/sql"
 	"time"
 
+	"github.com/2HgO/quidax-go/config"
 	"github.com/2HgO/quidax-go/errors"
 	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
 	"github.com/2HgO/quidax-go/types/requests"
 	"github.com/2HgO/quidax-go/types/responses"
 	"github.com/2HgO/quidax-go/utils"
-	sq "github.com/Masterminds/squirrel"
 	tdb "github.com/tigerbeetle/tigerbeetle-go"
 	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
 	"go.uber.org/zap"
-
-	"github.com/madflojo/tasks"
 )
 
 type SchedulerService interface {
 	ScheduleInstantSwapReversal(string, time.Time)
+	// RescheduleInstantSwapReversals schedules the reversal of every swap whose holds are pending, the reversals
+	// scheduled by a previous process are lost when it stops
+	RescheduleInstantSwapReversals(context.Context) error
 	// ScheduleEventRetry(parent *models.Account, event *models.Webhook)
 }
 
-func NewSchedulerService(dataDB *sql.DB, txDatabase tdb.Client, scheduler *tasks.Scheduler, accountService AccountService, walletService WalletService, webhookService WebhookService, log *zap.Logger) SchedulerService {
+// Clock is the time scheduled tasks run by. The system clock is used outside of tests, which move a fake
+// clock forward instead of waiting for tasks to come due
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine once d has passed on the clock
+	AfterFunc(d time.Duration, f func())
+}
+
+func NewSystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) {
+	time.AfterFunc(d, f)
+}
+
+func NewSchedulerService(transactor repositories.Transactor, walletRepository repositories.WalletRepository, swapRepository repositories.SwapRepository, txDatabase tdb.Client, clock Clock, accountService AccountService, walletService WalletService, webhookService WebhookService, limitService LimitService, cfg *config.Config, log *zap.Logger) SchedulerService {
 	return &schedulerService{
 		service{
-			transactionDB:  txDatabase,
-			webhookService: webhookService,
-			accountService: accountService,
-			walletService:  walletService,
-			log:            log,
-			dataDB:         dataDB,
+			transactionDB:    txDatabase,
+			transactor:       transactor,
+			webhookService:   webhookService,
+			limitService:     limitService,
+			accountService:   accountService,
+			walletService:    walletService,
+			config:           cfg,
+			log:              log,
+			walletRepository: walletRepository,
+			swapRepository:   swapRepository,
 		},
-		scheduler,
+		clock,
 	}
 }
 
 type schedulerService struct {
 	service
-	scheduler *tasks.Scheduler
+	clock Clock
 }
 
+// a failed swap reversal is retried after swapReversalBackoff, the wait doubles with every failure up to
+// swapReversalMaxBackoff
+const (
+	swapReversalBackoff    = time.Second
+	swapReversalMaxBackoff = 5 * time.Minute
+)
+
+// ScheduleInstantSwapReversal voids the holds of the quotation once it is due, reversals that are already due
+// run straight away. Swaps settled by then are left as they are
 func (s *schedulerService) ScheduleInstantSwapReversal(id string, dueAt time.Time) {
-	s.scheduler.AddWithID(id, &tasks.Task{
-		RunOnce:    true,
-		Interval:   1 * time.Second,
-		StartAfter: dueAt,
-		TaskFunc: func() error {
-			s.log.Info("attempting to reverse instant swap transfer...")
-			row := sq.
-				Select("instant_swaps.id", "quotation_id", "from_wallet_id", "to_wallet_id", "quotation_rate", "execution_rate", "swap_tx_id_0", "swap_tx_id_1", "quote_tx_id_0", "quote_tx_id_1", "wallets.token", "wallets.account_id").
-				From("instant_swaps").
-				Join("wallets on wallets.id = instant_swaps.from_wallet_id").
-				Where(sq.Eq{"quotation_id": id}).
-				RunWith(s.dataDB).
-				QueryRow()
-			if row == nil {
-				return errors.NewNotFoundError("swap not found")
-			}
+	s.scheduleInstantSwapReversal(id, dueAt.Sub(s.clock.Now()), swapReversalBackoff)
+}
 
-			var swap models.InstantSwap
-			var wallet models.Wallet
-			err := row.Scan(
-				&swap.ID,
-				&swap.QuotationID,
-				&swap.FromWalletID,
-				&swap.ToWalletID,
-				&swap.QuotationRate,
-				&swap.ExecutionRate,
-				&swap.SwapTxID0,
-				&swap.SwapTxID1,
-				&swap.QuoteTxID0,
-				&swap.QuoteTxID1,
-				&wallet.Token,
-				&wallet.AccountID,
-			)
-			if err != nil {
-				s.log.Error("fetching instant swap for reversal", zap.Error(err))
-				return err
-			}
+// scheduleInstantSwapReversal reverses the swap once the wait has passed, a reversal that fails is scheduled
+// again after the backoff until it succeeds. Swaps that no longer exist are not retried
+func (s *schedulerService) scheduleInstantSwapReversal(id string, wait time.Duration, backoff time.Duration) {
+	s.clock.AfterFunc(wait, func() {
+		err := s.reverseInstantSwap(id)
+		if err == nil {
+			return
+		}
+		if errors.AsAppError(err).Type == errors.ErrNotFound {
+			s.log.Error("reversing instant swap", zap.String("quotation_id", id), zap.Error(err))
+			return
+		}
+		s.log.Error("reversing instant swap", zap.String("quotation_id", id), zap.Duration("retry_in", backoff), zap.Error(err))
+		s.scheduleInstantSwapReversal(id, backoff, min(2*backoff, swapReversalMaxBackoff))
+	})
+}
 
-			user, err := s.accountService.FetchAccountDetails(context.WithValue(context.Background(), "skip_check", true), &requests.FetchAccountDetailsRequest{UserID: wallet.AccountID})
-			if err != nil {
-				s.log.Error("fetching user details for instant swap reversal", zap.Error(err))
-				return err
-			}
+func (s *schedulerService) RescheduleInstantSwapReversals(ctx context.Context) error {
+	swaps, err := s.swapRepository.ListUnsettled(ctx)
+	if err != nil {
+		return err
+	}
+	for _, swap := range swaps {
+		s.ScheduleInstantSwapReversal(swap.QuotationID, swap.CreatedAt.Add(s.config.Swaps.QuoteTTL))
+	}
+	return nil
+}
 
-			qtx0, _ := tdb_types.HexStringToUint128(swap.QuoteTxID0)
-			qtx1, _ := tdb_types.HexStringToUint128(swap.QuoteTxID1)
-			transactions, err := s.transactionDB.LookupTransfers([]tdb_types.Uint128{qtx0, qtx1})
-			if err != nil {
-				return errors.HandleTxDBError(err)
-			}
-			if len(transactions) != 2 {
-				s.log.Error("fetching pending transactions", zap.Error(errors.NewFailedDependencyError("transaction not found")))
-				return errors.NewFailedDependencyError("transaction not found")
-			}
+func (s *schedulerService) reverseInstantSwap(id string) error {
+	s.log.Info("attempting to reverse instant swap transfer...")
+	swap, err := s.swapRepository.FindByQuotationID(context.Background(), id)
+	if err != nil {
+		s.log.Error("fetching instant swap for reversal", zap.Error(err))
+		return err
+	}
+	if swap.SettledAt != nil {
+		return nil
+	}
+	wallet, err := s.walletRepository.FindByID(context.Background(), swap.FromWalletID)
+	if err != nil {
+		s.log.Error("fetching instant swap wallet for reversal", zap.Error(err))
+		return err
+	}
 
-			stx0, _ := tdb_types.HexStringToUint128(swap.SwapTxID0)
-			stx1, _ := tdb_types.HexStringToUint128(swap.SwapTxID1)
-			res, err := s.transactionDB.CreateTransfers([]tdb_types.Transfer{
-				{
-					ID:              stx0,
-					CreditAccountID: transactions[0].CreditAccountID,
-					DebitAccountID:  transactions[0].DebitAccountID,
-					Ledger:          transactions[0].Ledger,
-					UserData128:     transactions[0].UserData128,
-					PendingID:       transactions[0].ID,
-					Code:            1,
-					Flags: tdb_types.TransferFlags{
-						Linked:              true,
-						VoidPendingTransfer: true,
-					}.ToUint16(),
-				},
-				{
-					ID:              stx1,
-					CreditAccountID: transactions[1].CreditAccountID,
-					DebitAccountID:  transactions[1].DebitAccountID,
-					Ledger:          transactions[1].Ledger,
-					UserData128:     transactions[1].UserData128,
-					PendingID:       transactions[1].ID,
-					Code:            1,
-					Flags: tdb_types.TransferFlags{
-						VoidPendingTransfer: true,
-					}.ToUint16(),
-				},
-			})
-			if err != nil {
-				s.log.Error("reversing pending transaction", zap.Error(err))
-				return err
-			}
+	ctx := models.ContextWithPrincipal(context.Background(), models.NewSystemPrincipal(wallet.Environment))
+	user, err := s.accountService.FetchAccountDetails(ctx, &requests.FetchAccountDetailsRequest{UserID: wallet.AccountID})
+	if err != nil {
+		s.log.Error("fetching user details for instant swap reversal", zap.Error(err))
+		return err
+	}
 
-			if len(res) > 0 {
-				for _, r := range res {
-					if r.Result != tdb_types.TransferPendingTransferNotPending {
-						s.log.Error("reversing pending transactions", zap.String("error status", r.Result.String()))
-					}
-				}
-				return nil
-			}
+	qtx0, _ := tdb_types.HexStringToUint128(swap.QuoteTxID0)
+	qtx1, _ := tdb_types.HexStringToUint128(swap.QuoteTxID1)
+	transactions, err := s.transactionDB.LookupTransfers([]tdb_types.Uint128{qtx0, qtx1})
+	if err != nil {
+		return errors.HandleTxDBError(err)
+	}
+	if len(transactions) != 2 {
+		s.log.Error("fetching pending transactions", zap.Error(errors.NewFailedDependencyError("transaction not found")))
+		return errors.NewFailedDependencyError("transaction not found")
+	}
 
-			fromAmount := utils.FromAmount(transactions[0].Amount)
-			toAmount := utils.FromAmount(transactions[1].Amount)
-			now := time.Now()
-			data := &responses.InstantSwapResponseData{
-				ID:             swap.ID,
-				FromCurrency:   Ledgers[transactions[0].Ledger],
-				ToCurrency:     Ledgers[transactions[1].Ledger],
-				ExecutionPrice: utils.Formatter.Sprintf("%f", swap.ExecutionRate),
-				FromAmount:     utils.ApproximateAmount(Ledgers[transactions[0].Ledger], fromAmount),
-				ReceivedAmount: utils.ApproximateAmount(Ledgers[transactions[1].Ledger], toAmount),
-				CreatedAt:      now,
-				UpdatedAt:      now,
-				User:           user.Data,
-				Status:         "reversed",
-				SwapQuotation: &responses.InstantSwapQuotationResponseData{
-					ID:             swap.QuotationID,
-					FromCurrency:   Ledgers[transactions[0].Ledger],
-					ToCurrency:     Ledgers[transactions[1].Ledger],
-					QuotedPrice:    swap.QuotationRate,
-					QuotedCurrency: Ledgers[transactions[1].Ledger],
-					FromAmount:     utils.ApproximateAmount(Ledgers[transactions[0].Ledger], fromAmount),
-					ToAmount:       utils.ApproximateAmount(Ledgers[transactions[1].Ledger], toAmount),
-					Confirmed:      false,
-					ExpiresAt:      time.UnixMicro(int64(transactions[0].Timestamp / 1000)).Add(12 * time.Second),
-					CreatedAt:      time.UnixMicro(int64(transactions[0].Timestamp / 1000)),
-					User:           user.Data,
-				},
+	stx0, _ := tdb_types.HexStringToUint128(swap.SwapTxID0)
+	stx1, _ := tdb_types.HexStringToUint128(swap.SwapTxID1)
+	fromAmount := utils.FromAmount(transactions[0].Amount)
+	toAmount := utils.FromAmount(transactions[1].Amount)
+	// * the swap is settled in the same transaction as its holds are voided, a confirmation made at the same time
+	// * finds it settled. The void is kept when the transaction fails, a later reversal finds it made
+	var reversed bool
+	err = s.transactor.InTx(ctx, func(ctx context.Context) error {
+		settled, err := s.swapRepository.Settle(ctx, swap.ID, s.clock.Now())
+		if err != nil || !settled {
+			return err
+		}
+
+		res, err := s.transactionDB.CreateTransfers([]tdb_types.Transfer{
+			{
+				ID:              stx0,
+				CreditAccountID: transactions[0].CreditAccountID,
+				DebitAccountID:  transactions[0].DebitAccountID,
+				Ledger:          transactions[0].Ledger,
+				UserData128:     transactions[0].UserData128,
+				PendingID:       transactions[0].ID,
+				Code:            swap_TransferCode,
+				Flags: tdb_types.TransferFlags{
+					Linked:              true,
+					VoidPendingTransfer: true,
+				}.ToUint16(),
+			},
+			{
+				ID:              stx1,
+				CreditAccountID: transactions[1].CreditAccountID,
+				DebitAccountID:  transactions[1].DebitAccountID,
+				Ledger:          transactions[1].Ledger,
+				UserData128:     transactions[1].UserData128,
+				PendingID:       transactions[1].ID,
+				Code:            swap_TransferCode,
+				Flags: tdb_types.TransferFlags{
+					VoidPendingTransfer: true,
+				}.ToUint16(),
+			},
+		})
+		if err != nil {
+			return errors.HandleTxDBError(err)
+		}
+		if len(res) > 0 {
+			voided, err := s.transactionDB.LookupTransfers([]tdb_types.Uint128{stx0, stx1})
+			if err != nil {
+				return errors.HandleTxDBError(err)
 			}
-			if data.SwapQuotation.FromCurrency == "ngn" {
-				data.SwapQuotation.QuotedCurrency = data.SwapQuotation.FromCurrency
+			if len(voided) != 2 || !voided[0].TransferFlags().VoidPendingTransfer {
+				return errors.NewFailedDependencyError(res[0].Result.String())
 			}
+		}
 
-			s.webhookService.SendInstantSwapReversedEvent(user.Data.WebhookDetails, data)
-			return nil
-		},
+		// the voided quote no longer counts towards the wallet's limits
+		reversed = true
+		return s.limitService.ReleaseLimit(ctx, swap.FromWalletID, models.Swap_LimitOperation, fromAmount, swap.CreatedAt)
 	})
+	if err != nil {
+		s.log.Error("reversing pending transactions", zap.String("swap_id", swap.ID), zap.Error(err))
+		return err
+	}
+	if !reversed {
+		return nil
+	}
+
+	now := s.clock.Now()
+	data := &responses.InstantSwapResponseData{
+		ID:             swap.ID,
+		FromCurrency:   Ledgers[transactions[0].Ledger],
+		ToCurrency:     Ledgers[transactions[1].Ledger],
+		ExecutionPrice: utils.Formatter.Sprintf("%f", swap.ExecutionRate),
+		FromAmount:     utils.ApproximateAmount(Ledgers[transactions[0].Ledger], fromAmount),
+		ReceivedAmount: utils.ApproximateAmount(Ledgers[transactions[1].Ledger], toAmount),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		User:           user.Data,
+		Status:         "reversed",
+		SwapQuotation: &responses.InstantSwapQuotationResponseData{
+			ID:             swap.QuotationID,
+			FromCurrency:   Ledgers[transactions[0].Ledger],
+			ToCurrency:     Ledgers[transactions[1].Ledger],
+			QuotedPrice:    swap.QuotationRate,
+			QuotedCurrency: Ledgers[transactions[1].Ledger],
+			FromAmount:     utils.ApproximateAmount(Ledgers[transactions[0].Ledger], fromAmount),
+			ToAmount:       utils.ApproximateAmount(Ledgers[transactions[1].Ledger], toAmount),
+			Confirmed:      false,
+			ExpiresAt:      time.UnixMicro(int64(transactions[0].Timestamp / 1000)).Add(s.config.Swaps.QuoteTTL),
+			CreatedAt:      time.UnixMicro(int64(transactions[0].Timestamp / 1000)),
+			User:           user.Data,
+		},
+	}
+	if data.SwapQuotation.FromCurrency == "ngn" {
+		data.SwapQuotation.QuotedCurrency = data.SwapQuotation.FromCurrency
+	}
+
+	s.webhookService.SendInstantSwapReversedEvent(user.Data.WebhookDetails, data)
+	return nil
 }
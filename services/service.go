@@ -1,7 +1,14 @@
 package services
 
 import (
-	"database/sql"
+	"context"
+
+	"github.com/2HgO/quidax-go/config"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
+	"github.com/2HgO/quidax-go/utils"
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
 
 	tdb "github.com/tigerbeetle/tigerbeetle-go"
 	"go.uber.org/zap"
@@ -9,15 +16,39 @@ import (
 
 type service struct {
 	transactionDB  tdb.Client
-	dataDB         *sql.DB
+	transactor     repositories.Transactor
+	authService    AuthorizationService
 	accountService AccountService
 	swapService    InstantSwapService
 	walletService  WalletService
 	webhookService WebhookService
+	limitService   LimitService
 	scheduler      SchedulerService
+	sagaService    SagaService
+	config         *config.Config
 	log            *zap.Logger
+
+	accountRepository    repositories.AccountRepository
+	walletRepository     repositories.WalletRepository
+	tokenRepository      repositories.TokenRepository
+	withdrawalRepository repositories.WithdrawalRepository
+	swapRepository       repositories.SwapRepository
+
+	idempotencyRepository repositories.IdempotencyRepository
 }
 
+// transfer codes record why funds moved between accounts
+const (
+	swap_TransferCode       uint16 = 1
+	withdrawal_TransferCode uint16 = 2
+	deposit_TransferCode    uint16 = 3
+	fee_TransferCode        uint16 = 4
+)
+
+// live ledgers are offset from their test counterparts so that transfers in one
+// environment can never move balances in the other
+const liveLedgerOffset = 100
+
 var Ledgers = map[uint32]string{
 	1: "ngn",
 	2: "usdt",
@@ -26,16 +57,114 @@ var Ledgers = map[uint32]string{
 	5: "bnb",
 	6: "sol",
 	7: "btc",
+
+	liveLedgerOffset + 1: "ngn",
+	liveLedgerOffset + 2: "usdt",
+	liveLedgerOffset + 3: "usdc",
+	liveLedgerOffset + 4: "eth",
+	liveLedgerOffset + 5: "bnb",
+	liveLedgerOffset + 6: "sol",
+	liveLedgerOffset + 7: "btc",
+}
+
+var LedgerIDs = map[models.Environment]map[string]uint32{
+	models.Test_Environment: {
+		"ngn":  1,
+		"usdt": 2,
+		"usdc": 3,
+		"eth":  4,
+		"bnb":  5,
+		"sol":  6,
+		"btc":  7,
+	},
+	models.Live_Environment: {
+		"ngn":  liveLedgerOffset + 1,
+		"usdt": liveLedgerOffset + 2,
+		"usdc": liveLedgerOffset + 3,
+		"eth":  liveLedgerOffset + 4,
+		"bnb":  liveLedgerOffset + 5,
+		"sol":  liveLedgerOffset + 6,
+		"btc":  liveLedgerOffset + 7,
+	},
+}
+
+func LedgerEnvironment(ledger uint32) models.Environment {
+	if ledger > liveLedgerOffset {
+		return models.Live_Environment
+	}
+	return models.Test_Environment
+}
+
+// environment returns the environment of the principal in the context
+func environment(ctx context.Context) models.Environment {
+	if principal, ok := models.PrincipalFromContext(ctx); ok {
+		return principal.Environment
+	}
+	return models.Test_Environment
+}
+
+// findWallet looks up the account's wallet for the currency in the context's environment, callers
+// must authorize access to the account beforehand
+func (s *service) findWallet(ctx context.Context, accountID string, currency string) (*models.Wallet, error) {
+	return s.walletRepository.FindByAccount(ctx, accountID, currency, environment(ctx))
+}
+
+// balanceAt returns the last entry of the wallet's balance history at or before the ledger timestamp,
+// nil when no transfer had been applied to the wallet by then
+func (s *service) balanceAt(wallet *models.Wallet, timestamp uint64) (*tdb_types.AccountBalance, error) {
+	walletID, err := tdb_types.HexStringToUint128(wallet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.accountBalanceAt(walletID, timestamp)
+}
+
+// accountBalanceAt returns the last entry of the ledger account's balance history at or before the ledger
+// timestamp, the account must have been created with the history flag
+func (s *service) accountBalanceAt(accountID tdb_types.Uint128, timestamp uint64) (*tdb_types.AccountBalance, error) {
+	balances, err := s.transactionDB.GetAccountBalances(tdb_types.AccountFilter{
+		AccountID:    accountID,
+		TimestampMax: timestamp,
+		Limit:        1,
+		Flags: tdb_types.AccountFilterFlags{
+			Debits:   true,
+			Credits:  true,
+			Reversed: true,
+		}.ToUint32(),
+	})
+	if err != nil {
+		return nil, errors.HandleTxDBError(err)
+	}
+	if len(balances) == 0 {
+		return nil, nil
+	}
+
+	return &balances[0], nil
+}
+
+// walletBalance returns the available and locked balance of a wallet from its ledger totals
+func walletBalance(currency string, creditsPosted, debitsPosted, debitsPending tdb_types.Uint128) (balance float64, locked float64) {
+	credits := creditsPosted.BigInt()
+	debits := debitsPosted.BigInt()
+	pending := debitsPending.BigInt()
+	available := credits.Sub(&credits, &debits)
+	available = available.Sub(available, &pending)
+
+	return utils.ApproximateAmount(currency, utils.FromAmount(tdb_types.BigIntToUint128(*available))),
+		utils.ApproximateAmount(currency, utils.FromAmount(debitsPending))
 }
 
-var LedgerIDs = map[string]uint32{
-	"ngn":  1,
-	"usdt": 2,
-	"usdc": 3,
-	"eth":  4,
-	"bnb":  5,
-	"sol":  6,
-	"btc":  7,
+// checkFrozen fails when the account or its wallet for the currency has been frozen
+func checkFrozen(account *models.Account, currency string, walletFrozen bool) error {
+	switch {
+	case account.Frozen:
+		return errors.NewFrozenError("user account is frozen")
+	case walletFrozen:
+		return errors.NewFrozenError(currency + " wallet is frozen")
+	default:
+		return nil
+	}
 }
 
 var Rates = map[string]map[string]float64{
@@ -0,0 +1,466 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/2HgO/quidax-go/config"
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	"github.com/2HgO/quidax-go/utils"
+	"github.com/google/uuid"
+	"github.com/madflojo/tasks"
+	tdb "github.com/tigerbeetle/tigerbeetle-go"
+	"go.uber.org/zap"
+)
+
+// statementSyncLimit is the number of entries a statement may have to be streamed in the response,
+// larger statements are exported in the background
+const statementSyncLimit = 5000
+
+// statementExpiryInterval is the interval between runs deleting the exports older than the retention
+const statementExpiryInterval = time.Hour
+
+// StatementColumns is the layout of csv statements, jsonl statements use the same keys. Every currency
+// starts with an `opening` record, followed by its `entry` records oldest first and ends with a `closing` record
+var StatementColumns = []string{
+	"record_type", "currency", "wallet_id", "transaction_id", "type", "direction", "status", "reference", "amount", "balance", "locked", "timestamp",
+}
+
+type statementRecord struct {
+	RecordType    string    `json:"record_type"`
+	Currency      string    `json:"currency"`
+	WalletID      string    `json:"wallet_id"`
+	TransactionID string    `json:"transaction_id"`
+	Type          string    `json:"type"`
+	Direction     string    `json:"direction"`
+	Status        string    `json:"status"`
+	Reference     string    `json:"reference"`
+	Amount        string    `json:"amount"`
+	Balance       string    `json:"balance"`
+	Locked        string    `json:"locked"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+func (r statementRecord) fields() []string {
+	return []string{
+		r.RecordType, r.Currency, r.WalletID, r.TransactionID, r.Type, r.Direction, r.Status, r.Reference, r.Amount, r.Balance, r.Locked,
+		r.Timestamp.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// statementSection is the part of a statement covering one wallet
+type statementSection struct {
+	wallet        *models.Wallet
+	opening       float64
+	openingLocked float64
+	// ordered oldest first
+	entries []*responses.TransactionResponseData
+}
+
+type StatementService interface {
+	// ExportStatement returns a function writing the statement when it is small enough to be streamed,
+	// otherwise the statement is exported in the background and the export is returned
+	ExportStatement(context.Context, *requests.ExportStatementRequest) (*responses.Response[*models.StatementExport], func(io.Writer) error, error)
+	FetchStatementExport(context.Context, *requests.FetchStatementExportRequest) (*responses.Response[*models.StatementExport], error)
+	DownloadStatementExport(context.Context, *requests.FetchStatementExportRequest) (*models.StatementExport, io.ReadCloser, error)
+	// ResumeExports runs the exports left pending by a previous process again
+	ResumeExports(context.Context) error
+	// ExpireExports deletes the files of the exports that completed longer than the retention ago
+	ExpireExports(context.Context) error
+}
+
+func NewStatementService(
+	txDatabase tdb.Client,
+	accountRepository repositories.AccountRepository,
+	walletRepository repositories.WalletRepository,
+	withdrawalRepository repositories.WithdrawalRepository,
+	swapRepository repositories.SwapRepository,
+	statementExportRepository repositories.StatementExportRepository,
+	authService AuthorizationService,
+	scheduler *tasks.Scheduler,
+	clock Clock,
+	cfg *config.Config,
+	log *zap.Logger,
+) StatementService {
+	dir := cfg.Statements.Dir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "quidax-go", "statements")
+	}
+	s := &statementService{
+		service: service{
+			transactionDB:        txDatabase,
+			authService:          authService,
+			log:                  log,
+			accountRepository:    accountRepository,
+			walletRepository:     walletRepository,
+			withdrawalRepository: withdrawalRepository,
+			swapRepository:       swapRepository,
+		},
+		statementExportRepository: statementExportRepository,
+		dir:                       dir,
+		retention:                 cfg.Statements.Retention,
+		clock:                     clock,
+	}
+
+	if s.retention > 0 {
+		_, err := scheduler.Add(&tasks.Task{
+			Interval:          statementExpiryInterval,
+			RunSingleInstance: true,
+			TaskFunc: func() error {
+				return s.ExpireExports(context.Background())
+			},
+			ErrFunc: func(err error) {
+				s.log.Error("expiring statement exports", zap.Error(err))
+			},
+		})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return s
+}
+
+type statementService struct {
+	service
+	statementExportRepository repositories.StatementExportRepository
+	dir                       string
+	retention                 time.Duration
+	clock                     Clock
+}
+
+func (s *statementService) ExportStatement(ctx context.Context, req *requests.ExportStatementRequest) (*responses.Response[*models.StatementExport], func(io.Writer) error, error) {
+	user, err := s.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	from, _ := time.Parse(time.RFC3339, req.From)
+	to, _ := time.Parse(time.RFC3339, req.To)
+	if !to.After(from) {
+		return nil, nil, errors.NewValidationError("statement period must end after it starts")
+	}
+
+	wallets, err := s.userWallets(ctx, user, req.Currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !req.Async {
+		size, err := s.statementSize(wallets, from, to, statementSyncLimit)
+		if err != nil {
+			return nil, nil, err
+		}
+		if size <= statementSyncLimit {
+			sections, err := s.buildStatement(ctx, wallets, from, to)
+			if err != nil {
+				return nil, nil, err
+			}
+			return nil, func(w io.Writer) error {
+				return writeStatement(w, req.Format, from, to, sections)
+			}, nil
+		}
+	}
+
+	export := &models.StatementExport{
+		ID:        uuid.NewString(),
+		AccountID: user.ID,
+		Format:    req.Format,
+		From:      from,
+		To:        to,
+		Status:    models.Pending_StatementExportStatus,
+		CreatedAt: s.clock.Now(),
+	}
+	if req.Currency != "" {
+		export.Currency = &req.Currency
+	}
+	if err = s.statementExportRepository.Create(ctx, export); err != nil {
+		return nil, nil, err
+	}
+
+	go s.runExport(context.WithoutCancel(ctx), *export, user)
+
+	return &responses.Response[*models.StatementExport]{
+		Status: "successful",
+		Data:   export,
+	}, nil, nil
+}
+
+// ResumeExports runs the exports left pending by a previous process again in the background, one at a time
+func (s *statementService) ResumeExports(ctx context.Context) error {
+	exports, err := s.statementExportRepository.ListPending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(exports) == 0 {
+		return nil
+	}
+
+	go func() {
+		for _, export := range exports {
+			user, err := s.accountRepository.FindByID(context.Background(), export.AccountID)
+			if err != nil {
+				s.log.Error("fetching the account of a pending statement export", zap.String("export_id", export.ID), zap.Error(err))
+				s.finishExport(*export, err)
+				continue
+			}
+			ctx := models.ContextWithPrincipal(context.Background(), models.NewSystemPrincipal(user.Environment))
+			s.runExport(ctx, *export, user)
+		}
+	}()
+	return nil
+}
+
+// runExport reads the statement, writes it to the export's file and records the outcome
+func (s *statementService) runExport(ctx context.Context, export models.StatementExport, user *models.Account) {
+	err := func() error {
+		currency := ""
+		if export.Currency != nil {
+			currency = *export.Currency
+		}
+		wallets, err := s.userWallets(ctx, user, currency)
+		if err != nil {
+			return err
+		}
+		sections, err := s.buildStatement(ctx, wallets, export.From, export.To)
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(s.dir, 0o750); err != nil {
+			return err
+		}
+		file, err := os.Create(s.exportPath(&export))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err = writeStatement(file, export.Format, export.From, export.To, sections); err != nil {
+			return err
+		}
+		return file.Sync()
+	}()
+	if err != nil {
+		s.log.Error("exporting statement", zap.String("export_id", export.ID), zap.Error(err))
+	}
+	s.finishExport(export, err)
+}
+
+// finishExport records the outcome of the export, it failed when err is not nil
+func (s *statementService) finishExport(export models.StatementExport, err error) {
+	now := s.clock.Now()
+	export.Status, export.CompletedAt = models.Completed_StatementExportStatus, &now
+	if err != nil {
+		export.Status, export.Reason = models.Failed_StatementExportStatus, utils.String("statement could not be written")
+	}
+	if err = s.statementExportRepository.Finish(context.Background(), &export); err != nil {
+		s.log.Error("recording statement export", zap.String("export_id", export.ID), zap.Error(err))
+	}
+}
+
+// ExpireExports deletes the files of the exports that completed longer than the retention ago and marks them expired,
+// the file is deleted first so an export is never left completed without its file
+func (s *statementService) ExpireExports(ctx context.Context) error {
+	if s.retention <= 0 {
+		return nil
+	}
+	exports, err := s.statementExportRepository.ListCompletedBefore(ctx, s.clock.Now().Add(-s.retention))
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		if err = os.Remove(s.exportPath(export)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		export.Status = models.Expired_StatementExportStatus
+		if err = s.statementExportRepository.Finish(ctx, export); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *statementService) exportPath(export *models.StatementExport) string {
+	return filepath.Join(s.dir, export.ID+"."+export.Format.String())
+}
+
+func (s *statementService) FetchStatementExport(ctx context.Context, req *requests.FetchStatementExportRequest) (*responses.Response[*models.StatementExport], error) {
+	user, err := s.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	export, err := s.statementExportRepository.Find(ctx, req.ExportID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.Response[*models.StatementExport]{
+		Status: "successful",
+		Data:   export,
+	}, nil
+}
+
+func (s *statementService) DownloadStatementExport(ctx context.Context, req *requests.FetchStatementExportRequest) (*models.StatementExport, io.ReadCloser, error) {
+	res, err := s.FetchStatementExport(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	export := res.Data
+	if export.Status == models.Expired_StatementExportStatus {
+		return nil, nil, errors.NewNotFoundError("statement export has expired")
+	}
+	if export.Status != models.Completed_StatementExportStatus {
+		return nil, nil, errors.NewValidationError("statement export is " + export.Status.String())
+	}
+
+	file, err := os.Open(s.exportPath(export))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, errors.NewNotFoundError("statement export has expired")
+		}
+		return nil, nil, errors.NewFatalError(err)
+	}
+
+	return export, file, nil
+}
+
+// statementSize counts the transfers on the wallets within the period, counting stops once there are more than limit
+func (s *statementService) statementSize(wallets []*models.Wallet, from time.Time, to time.Time, limit int) (int, error) {
+	size := 0
+	for _, wallet := range wallets {
+		filter, err := walletFilter(wallet, &from, &to)
+		if err != nil {
+			return 0, err
+		}
+		filter.Limit = uint32(limit - size + 1)
+		transfers, err := s.transactionDB.GetAccountTransfers(filter)
+		if err != nil {
+			return 0, errors.HandleTxDBError(err)
+		}
+		if size += len(transfers); size > limit {
+			break
+		}
+	}
+	return size, nil
+}
+
+// buildStatement reads the history of the wallets within the period along with their opening balances
+func (s *statementService) buildStatement(ctx context.Context, wallets []*models.Wallet, from time.Time, to time.Time) ([]statementSection, error) {
+	sections := make([]statementSection, 0, len(wallets))
+	for _, wallet := range wallets {
+		var err error
+		section := statementSection{wallet: wallet}
+		section.opening, section.openingLocked, err = s.balanceBefore(wallet, from)
+		if err != nil {
+			return nil, err
+		}
+		section.entries, err = s.walletHistory(ctx, wallet, &from, &to)
+		if err != nil {
+			return nil, err
+		}
+		slices.Reverse(section.entries)
+		sections = append(sections, section)
+	}
+
+	return sections, nil
+}
+
+// balanceBefore returns the wallet's balances before the given time from its balance history
+func (s *service) balanceBefore(wallet *models.Wallet, at time.Time) (balance float64, locked float64, err error) {
+	if at.UnixNano() <= 0 {
+		return 0, 0, nil
+	}
+	history, err := s.balanceAt(wallet, uint64(at.UnixNano())-1)
+	if err != nil || history == nil {
+		return 0, 0, err
+	}
+
+	balance, locked = walletBalance(wallet.Token, history.CreditsPosted, history.DebitsPosted, history.DebitsPending)
+	return balance, locked, nil
+}
+
+func writeStatement(w io.Writer, format models.StatementFormat, from time.Time, to time.Time, sections []statementSection) error {
+	var write func(statementRecord) error
+	var flush func() error
+	switch format {
+	case models.JSONL_StatementFormat:
+		encoder := json.NewEncoder(w)
+		write = func(record statementRecord) error { return encoder.Encode(record) }
+		flush = func() error { return nil }
+	default:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(StatementColumns); err != nil {
+			return err
+		}
+		write = func(record statementRecord) error { return writer.Write(record.fields()) }
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	}
+
+	for _, section := range sections {
+		balance, locked := section.opening, section.openingLocked
+		err := write(statementRecord{
+			RecordType: "opening",
+			Currency:   section.wallet.Token,
+			WalletID:   section.wallet.ID,
+			Balance:    formatAmount(balance),
+			Locked:     formatAmount(locked),
+			Timestamp:  from,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range section.entries {
+			balance, locked = entry.Balance, entry.LockedBalance
+			err := write(statementRecord{
+				RecordType:    "entry",
+				Currency:      entry.Currency,
+				WalletID:      entry.WalletID,
+				TransactionID: entry.ID,
+				Type:          entry.Type.String(),
+				Direction:     entry.Direction,
+				Status:        entry.Status,
+				Reference:     entry.Reference,
+				Amount:        formatAmount(entry.Amount),
+				Balance:       formatAmount(entry.Balance),
+				Locked:        formatAmount(entry.LockedBalance),
+				Timestamp:     entry.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		err = write(statementRecord{
+			RecordType: "closing",
+			Currency:   section.wallet.Token,
+			WalletID:   section.wallet.ID,
+			Balance:    formatAmount(balance),
+			Locked:     formatAmount(locked),
+			Timestamp:  to,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return flush()
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
@@ -2,17 +2,17 @@ package services
 
 import (
 	"context"
-	"database/sql"
 
 	"slices"
 	"time"
 
+	"github.com/2HgO/quidax-go/config"
 	"github.com/2HgO/quidax-go/errors"
 	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
 	"github.com/2HgO/quidax-go/types/requests"
 	"github.com/2HgO/quidax-go/types/responses"
 	"github.com/2HgO/quidax-go/utils"
-	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	tdb "github.com/tigerbeetle/tigerbeetle-go"
 	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
@@ -30,30 +30,241 @@ type InstantSwapService interface {
 
 func NewInstantSwapService(
 	txDatabase tdb.Client,
-	dataDatabase *sql.DB,
+	swapRepository repositories.SwapRepository,
+	authService AuthorizationService,
 	accountService AccountService,
 	walletService WalletService,
 	scheduler SchedulerService,
 	webhookService WebhookService,
+	limitService LimitService,
+	sagaService SagaService,
+	cfg *config.Config,
 	log *zap.Logger,
 ) InstantSwapService {
-	return &instantSwapService{
+	i := &instantSwapService{
 		service{
 			transactionDB:  txDatabase,
-			dataDB:         dataDatabase,
+			authService:    authService,
 			accountService: accountService,
 			walletService:  walletService,
+			limitService:   limitService,
 			webhookService: webhookService,
 			scheduler:      scheduler,
+			sagaService:    sagaService,
+			config:         cfg,
 			log:            log,
+			swapRepository: swapRepository,
 		},
 	}
+	sagaService.Register(models.InstantSwap_SagaKind, newSagaBuilder(i.instantSwapSagaDefinition))
+	sagaService.Register(models.ConfirmInstantSwap_SagaKind, newSagaBuilder(i.confirmInstantSwapSagaDefinition))
+
+	return i
 }
 
 type instantSwapService struct {
 	service
 }
 
+// instantSwapSaga is the payload of instant swap sagas
+type instantSwapSaga struct {
+	SwapID      string               `json:"swap_id"`
+	QuotationID string               `json:"quotation_id"`
+	Holds       []tdb_types.Transfer `json:"holds"`
+	ExpiresAt   time.Time            `json:"expires_at"`
+	// the wallet's limit usage reserved for the swap, given back when it is undone
+	FromWalletID string    `json:"from_wallet_id"`
+	Amount       float64   `json:"amount"`
+	ReservedAt   time.Time `json:"reserved_at"`
+}
+
+// instantSwapSagaDefinition records the swap, places its holds and schedules their reversal. Swaps whose
+// holds cannot be placed are deleted and their limit usage given back
+func (i *instantSwapService) instantSwapSagaDefinition(payload instantSwapSaga) *sagaDefinition {
+	return &sagaDefinition{
+		steps: []sagaStep{
+			{
+				name: "insert swap",
+				compensate: func(ctx context.Context) error {
+					err := i.limitService.ReleaseLimit(ctx, payload.FromWalletID, models.Swap_LimitOperation, payload.Amount, payload.ReservedAt)
+					if err != nil {
+						return err
+					}
+					return i.swapRepository.Delete(ctx, payload.SwapID)
+				},
+			},
+			i.createTransfersStep(payload.Holds),
+			{
+				name: "schedule swap reversal",
+				forward: func(context.Context) error {
+					// reversals of expired quotes run straight away
+					i.scheduler.ScheduleInstantSwapReversal(payload.QuotationID, payload.ExpiresAt)
+					return nil
+				},
+			},
+		},
+		pivot: 1,
+	}
+}
+
+// confirmInstantSwapSaga is the payload of instant swap confirmation sagas
+type confirmInstantSwapSaga struct {
+	SwapID      string               `json:"swap_id"`
+	QuotationID string               `json:"quotation_id"`
+	Holds       []tdb_types.Transfer `json:"holds"`
+	// transfers posting the holds, or voiding them when the swap fails
+	Transfers []tdb_types.Transfer `json:"transfers"`
+	ExpiresAt time.Time            `json:"expires_at"`
+	// the wallet's limit usage reserved for the swap, given back when the swap fails
+	FromWalletID string    `json:"from_wallet_id"`
+	Amount       float64   `json:"amount"`
+	ReservedAt   time.Time `json:"reserved_at"`
+	ConfirmedAt  time.Time `json:"confirmed_at"`
+}
+
+// confirmInstantSwapSagaDefinition settles the swap so it is no longer reversed, then posts or voids its holds.
+// Swaps whose holds cannot be settled are marked pending again and left to be reversed
+func (i *instantSwapService) confirmInstantSwapSagaDefinition(payload confirmInstantSwapSaga) *sagaDefinition {
+	return &sagaDefinition{
+		steps: []sagaStep{
+			{
+				name: "settle swap",
+				compensate: func(ctx context.Context) error {
+					return i.swapRepository.Unsettle(ctx, payload.SwapID)
+				},
+			},
+			i.settleHoldsStep(payload.Transfers),
+			{
+				name: "release limit usage",
+				forward: func(ctx context.Context) error {
+					voided, err := i.swapVoided(payload.Transfers[0].ID)
+					if err != nil || !voided {
+						return err
+					}
+					// failed swaps give back the limit usage reserved for them
+					return i.limitService.ReleaseLimit(ctx, payload.FromWalletID, models.Swap_LimitOperation, payload.Amount, payload.ReservedAt)
+				},
+			},
+			{
+				name: "send swap webhook",
+				forward: func(ctx context.Context) error {
+					return i.sendSwapSettledEvent(ctx, payload)
+				},
+			},
+		},
+		pivot: 1,
+	}
+}
+
+// settleHoldsStep creates the transfers posting or voiding the swap's holds. Posts the ledger refuses for a
+// lack of funds void the holds instead, the swap then fails
+func (i *instantSwapService) settleHoldsStep(transfers []tdb_types.Transfer) sagaStep {
+	step := i.createTransfersStep(transfers)
+	step.name = "settle swap holds"
+	step.forward = func(context.Context) error {
+		res, err := i.transactionDB.CreateTransfers(transfers)
+		if err != nil {
+			return errors.HandleTxDBError(err)
+		}
+		if len(res) == 0 {
+			return nil
+		}
+		if !slices.ContainsFunc(res, func(r tdb_types.TransferEventResult) bool { return r.Result == tdb_types.TransferExceedsCredits }) {
+			return errors.NewFailedDependencyError(res[0].Result.String())
+		}
+
+		voids := slices.Clone(transfers)
+		for n := range voids {
+			voids[n].Flags = tdb_types.TransferFlags{
+				Linked:              n == 0,
+				VoidPendingTransfer: true,
+			}.ToUint16()
+		}
+		res, err = i.transactionDB.CreateTransfers(voids)
+		if err != nil {
+			return errors.HandleTxDBError(err)
+		}
+		if len(res) > 0 {
+			return errors.NewFailedDependencyError(res[0].Result.String())
+		}
+		return nil
+	}
+	return step
+}
+
+// swapVoided reports whether the transfer settling the swap's holds voided them
+func (i *instantSwapService) swapVoided(id tdb_types.Uint128) (bool, error) {
+	found, err := i.transactionDB.LookupTransfers([]tdb_types.Uint128{id})
+	if err != nil {
+		return false, errors.HandleTxDBError(err)
+	}
+	if len(found) != 1 {
+		return false, errors.NewFailedDependencyError("transaction not found")
+	}
+	return found[0].TransferFlags().VoidPendingTransfer, nil
+}
+
+// sendSwapSettledEvent sends the completed or failed event of the confirmed swap
+func (i *instantSwapService) sendSwapSettledEvent(ctx context.Context, payload confirmInstantSwapSaga) error {
+	holds := payload.Holds
+	ctx = models.ContextWithPrincipal(ctx, models.NewSystemPrincipal(LedgerEnvironment(holds[0].Ledger)))
+	user, err := i.accountService.FetchAccountDetails(ctx, &requests.FetchAccountDetailsRequest{UserID: uuid.UUID(holds[0].UserData128.Bytes()).String()})
+	if err != nil {
+		return err
+	}
+	swap, err := i.swapRepository.FindByID(ctx, payload.SwapID)
+	if err != nil {
+		return err
+	}
+	failed, err := i.swapVoided(payload.Transfers[0].ID)
+	if err != nil {
+		return err
+	}
+
+	fromAmount := utils.FromAmount(holds[0].Amount)
+	toAmount := utils.FromAmount(holds[1].Amount)
+	data := &responses.InstantSwapResponseData{
+		ID:             swap.ID,
+		FromCurrency:   Ledgers[holds[0].Ledger],
+		ToCurrency:     Ledgers[holds[1].Ledger],
+		ExecutionPrice: utils.Formatter.Sprintf("%f", swap.ExecutionRate),
+		FromAmount:     utils.ApproximateAmount(Ledgers[holds[0].Ledger], fromAmount),
+		ReceivedAmount: utils.ApproximateAmount(Ledgers[holds[1].Ledger], toAmount),
+		CreatedAt:      payload.ConfirmedAt,
+		UpdatedAt:      payload.ConfirmedAt,
+		User:           user.Data,
+		Status:         "confirmed",
+		SwapQuotation: &responses.InstantSwapQuotationResponseData{
+			ID:             swap.QuotationID,
+			FromCurrency:   Ledgers[holds[0].Ledger],
+			ToCurrency:     Ledgers[holds[1].Ledger],
+			QuotedPrice:    swap.QuotationRate,
+			QuotedCurrency: Ledgers[holds[1].Ledger],
+			FromAmount:     utils.ApproximateAmount(Ledgers[holds[0].Ledger], fromAmount),
+			ToAmount:       utils.ApproximateAmount(Ledgers[holds[1].Ledger], toAmount),
+			Confirmed:      true,
+			ExpiresAt:      time.UnixMicro(int64(holds[0].Timestamp / 1000)).Add(i.config.Swaps.QuoteTTL),
+			CreatedAt:      time.UnixMicro(int64(holds[0].Timestamp / 1000)),
+			User:           user.Data,
+		},
+	}
+
+	switch failed {
+	case true:
+		data.Status = "failed"
+		i.webhookService.
+			SendInstantSwapFailedEvent(user.Data.WebhookDetails, data)
+
+		// todo: send wallet updated event for debit wallet
+	default:
+		i.webhookService.
+			SendInstantSwapCompletedEvent(user.Data.WebhookDetails, data)
+
+		// todo: send wallet updated event for credit and debit wallets
+	}
+	return nil
+}
+
 type normalizedSwapTransaction struct {
 	fromToken  string
 	toToken    string
@@ -74,6 +285,24 @@ func (i *instantSwapService) normalizeTransaction(from string, to string, amount
 }
 
 func (i *instantSwapService) QuoteInstantSwap(ctx context.Context, req *requests.CreateInstantSwapRequest) (*responses.Response[*responses.QuoteInstantSwapResponseData], error) {
+	fromWallet, err := i.walletService.FetchUserWallet(ctx, &requests.FetchUserWalletRequest{UserID: req.UserID, Currency: req.FromCurrency})
+	if err != nil {
+		return nil, err
+	}
+	toWallet, err := i.walletService.FetchUserWallet(ctx, &requests.FetchUserWalletRequest{UserID: req.UserID, Currency: req.ToCurrency})
+	if err != nil {
+		return nil, err
+	}
+	if err = checkFrozen(fromWallet.Data.User, req.FromCurrency, fromWallet.Data.Frozen); err != nil {
+		return nil, err
+	}
+	if err = checkFrozen(toWallet.Data.User, req.ToCurrency, toWallet.Data.Frozen); err != nil {
+		return nil, err
+	}
+	if err = checkKYC(fromWallet.Data.User, swap_kycOperation, req.FromCurrency, req.ToCurrency); err != nil {
+		return nil, err
+	}
+
 	transactionDetails := i.normalizeTransaction(req.FromCurrency, req.ToCurrency, float64(req.FromAmount))
 
 	data := &responses.QuoteInstantSwapResponseData{
@@ -112,14 +341,15 @@ func (i *instantSwapService) CreateInstantSwap(ctx context.Context, req *request
 	if err != nil {
 		return nil, err
 	}
-
-	tx, err := i.dataDB.BeginTx(ctx, nil)
-	if err != nil {
+	if err = checkFrozen(fromWallet.Data.User, req.FromCurrency, fromWallet.Data.Frozen); err != nil {
+		return nil, err
+	}
+	if err = checkFrozen(toWallet.Data.User, req.ToCurrency, toWallet.Data.Frozen); err != nil {
+		return nil, err
+	}
+	if err = checkKYC(fromWallet.Data.User, swap_kycOperation, req.FromCurrency, req.ToCurrency); err != nil {
 		return nil, err
 	}
-	// Defer a rollback in case anything fails.
-	defer tx.Rollback()
-
 	quoteTxID0 := tdb_types.ID()
 	quoteTxID1 := tdb_types.ID()
 	swap := &models.InstantSwap{
@@ -132,33 +362,25 @@ func (i *instantSwapService) CreateInstantSwap(ctx context.Context, req *request
 		SwapTxID1:     tdb_types.ID().String(),
 		QuoteTxID0:    quoteTxID0.String(),
 		QuoteTxID1:    quoteTxID1.String(),
+		CreatedAt:     time.Now(),
 	}
 	if req.FromCurrency == "ngn" {
 		swap.QuotationRate = utils.ApproximateAmount(req.FromCurrency, 1/Rates[req.FromCurrency][req.ToCurrency])
 	}
 	swap.ExecutionRate = swap.QuotationRate
 
-	_, err = sq.
-		Insert("instant_swaps").
-		Columns("id", "quotation_id", "from_wallet_id", "to_wallet_id", "quotation_rate", "execution_rate", "swap_tx_id_0", "swap_tx_id_1", "quote_tx_id_0", "quote_tx_id_1").
-		Values(swap.ID, swap.QuotationID, swap.FromWalletID, swap.ToWalletID, swap.QuotationRate, swap.ExecutionRate, swap.SwapTxID0, swap.SwapTxID1, swap.QuoteTxID0, swap.QuoteTxID1).
-		RunWith(tx).ExecContext(ctx)
-
-	if err != nil {
-		return nil, errors.HandleDataDBError(err)
-	}
-
+	env := environment(ctx)
 	now := time.Now()
-	timeout := now.Add(time.Second * 12)
+	timeout := now.Add(i.config.Swaps.QuoteTTL)
 	transactions := []tdb_types.Transfer{
 		{
 			ID:              quoteTxID0,
-			CreditAccountID: tdb_types.ToUint128(uint64(LedgerIDs[req.FromCurrency])),
+			CreditAccountID: tdb_types.ToUint128(uint64(LedgerIDs[env][req.FromCurrency])),
 			DebitAccountID:  fromWalletID,
 			Amount:          utils.ToAmount(transactionDetails.fromAmount),
 			UserData128:     tdb_types.BytesToUint128(uuid.MustParse(fromWallet.Data.User.ID)),
-			Ledger:          LedgerIDs[req.FromCurrency],
-			Code:            1,
+			Ledger:          LedgerIDs[env][req.FromCurrency],
+			Code:            swap_TransferCode,
 			Flags: tdb_types.TransferFlags{
 				Linked:  true,
 				Pending: true,
@@ -166,33 +388,40 @@ func (i *instantSwapService) CreateInstantSwap(ctx context.Context, req *request
 		},
 		{
 			ID:              quoteTxID1,
-			DebitAccountID:  tdb_types.ToUint128(uint64(LedgerIDs[req.ToCurrency])),
+			DebitAccountID:  tdb_types.ToUint128(uint64(LedgerIDs[env][req.ToCurrency])),
 			CreditAccountID: toWalletID,
 			Amount:          utils.ToAmount(transactionDetails.toAmount),
-			Ledger:          LedgerIDs[req.ToCurrency],
+			Ledger:          LedgerIDs[env][req.ToCurrency],
 			UserData128:     tdb_types.BytesToUint128(uuid.MustParse(toWallet.Data.User.ID)),
-			Code:            1,
+			Code:            swap_TransferCode,
 			Flags: tdb_types.TransferFlags{
 				Pending: true,
 			}.ToUint16(),
 		},
 	}
 
-	res, err := i.transactionDB.CreateTransfers(transactions)
-	if err != nil {
-		return nil, errors.HandleTxDBError(err)
-	}
-	if len(res) > 0 {
-		for _, r := range res {
-			if r.Result == tdb_types.TransferExceedsCredits {
-				return nil, errors.NewFailedDependencyError("Insufficient Balance")
-			}
+	// * the swap is recorded before its holds are placed, the holds are reversed unless the quote is confirmed in time.
+	// * the held amount is reserved against the wallet's limits in the same transaction, and given back when the
+	// * holds are voided
+	amount := utils.FromAmount(transactions[0].Amount)
+	saga := instantSwapSaga{
+		SwapID:       swap.ID,
+		QuotationID:  swap.QuotationID,
+		Holds:        transactions,
+		ExpiresAt:    timeout,
+		FromWalletID: swap.FromWalletID,
+		Amount:       amount,
+		ReservedAt:   swap.CreatedAt,
+	}
+	err = i.sagaService.Run(ctx, models.InstantSwap_SagaKind, saga, func(ctx context.Context) error {
+		err := i.limitService.ReserveLimit(ctx, fromWallet.Data.User, swap.FromWalletID, req.FromCurrency, models.Swap_LimitOperation, amount, swap.CreatedAt)
+		if err != nil {
+			return err
 		}
-		return nil, errors.NewFailedDependencyError(res[0].Result.String())
-	}
-
-	if err = tx.Commit(); err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return i.swapRepository.Create(ctx, swap)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	data := &responses.InstantSwapQuotationResponseData{
@@ -212,9 +441,7 @@ func (i *instantSwapService) CreateInstantSwap(ctx context.Context, req *request
 		data.QuotedCurrency = req.FromCurrency
 	}
 
-	parent := ctx.Value("user").(*models.Account)
-	i.scheduler.ScheduleInstantSwapReversal(swap.QuotationID, now.Add(time.Second*12))
-	go i.webhookService.SendWalletUpdatedEvent(parent.WebhookDetails, fromWallet.Data)
+	go i.webhookService.SendWalletUpdatedEvent(fromWallet.Data.User.WebhookDetails, fromWallet.Data)
 
 	return &responses.Response[*responses.InstantSwapQuotationResponseData]{
 		Status: "successful",
@@ -227,32 +454,30 @@ func (i *instantSwapService) ConfirmInstantSwap(ctx context.Context, req *reques
 	if err != nil {
 		return nil, err
 	}
+	if user.Data.Frozen {
+		return nil, errors.NewFrozenError("user account is frozen")
+	}
 
-	row := sq.
-		Select("instant_swaps.id", "quotation_id", "from_wallet_id", "to_wallet_id", "quotation_rate", "execution_rate", "swap_tx_id_0", "swap_tx_id_1", "quote_tx_id_0", "quote_tx_id_1").
-		From("instant_swaps").
-		Where(sq.Eq{"quotation_id": req.QuotationID}).
-		RunWith(i.dataDB).
-		QueryRow()
-	if row == nil {
-		return nil, errors.NewNotFoundError("swap quotation not found")
-	}
-
-	var swap models.InstantSwap
-	err = row.Scan(
-		&swap.ID,
-		&swap.QuotationID,
-		&swap.FromWalletID,
-		&swap.ToWalletID,
-		&swap.QuotationRate,
-		&swap.ExecutionRate,
-		&swap.SwapTxID0,
-		&swap.SwapTxID1,
-		&swap.QuoteTxID0,
-		&swap.QuoteTxID1,
-	)
+	found, err := i.swapRepository.FindByQuotationID(ctx, req.QuotationID)
 	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
+	}
+	swap := *found
+	if err = i.authorizeSwap(ctx, swap, user.Data); err != nil {
+		return nil, err
+	}
+	if swap.SettledAt != nil {
+		return nil, errors.NewValidationError("swap quotation has already been confirmed or reversed")
+	}
+	// wallets frozen since the quotation was made are not swapped from or into
+	for _, walletID := range []string{swap.FromWalletID, swap.ToWalletID} {
+		wallet, err := i.authService.AuthorizeWallet(ctx, walletID)
+		if err != nil {
+			return nil, err
+		}
+		if err = checkFrozen(user.Data, wallet.Token, wallet.Frozen); err != nil {
+			return nil, err
+		}
 	}
 
 	qtx0, _ := tdb_types.HexStringToUint128(swap.QuoteTxID0)
@@ -266,8 +491,53 @@ func (i *instantSwapService) ConfirmInstantSwap(ctx context.Context, req *reques
 	}
 
 	now := time.Now()
+	// * the mock exchange fails swaps of more than 100 units, their holds are voided instead of posted
+	failed := utils.FromAmount(transactions[0].Amount) > 100
+	stx0, _ := tdb_types.HexStringToUint128(swap.SwapTxID0)
+	stx1, _ := tdb_types.HexStringToUint128(swap.SwapTxID1)
+	confirmed := []tdb_types.Transfer{
+		{
+			ID:              stx0,
+			CreditAccountID: transactions[0].CreditAccountID,
+			DebitAccountID:  transactions[0].DebitAccountID,
+			Amount:          transactions[0].Amount,
+			Ledger:          transactions[0].Ledger,
+			UserData128:     transactions[0].UserData128,
+			PendingID:       transactions[0].ID,
+			Code:            swap_TransferCode,
+			Flags: tdb_types.TransferFlags{
+				Linked:              true,
+				PostPendingTransfer: !failed,
+				VoidPendingTransfer: failed,
+			}.ToUint16(),
+		},
+		{
+			ID:              stx1,
+			CreditAccountID: transactions[1].CreditAccountID,
+			DebitAccountID:  transactions[1].DebitAccountID,
+			Amount:          transactions[1].Amount,
+			Ledger:          transactions[1].Ledger,
+			UserData128:     transactions[1].UserData128,
+			PendingID:       transactions[1].ID,
+			Code:            swap_TransferCode,
+			Flags: tdb_types.TransferFlags{
+				PostPendingTransfer: !failed,
+				VoidPendingTransfer: failed,
+			}.ToUint16(),
+		},
+	}
 
-	go i.processSwap(swap, now, transactions)
+	go i.confirmSwap(confirmInstantSwapSaga{
+		SwapID:       swap.ID,
+		QuotationID:  swap.QuotationID,
+		Holds:        transactions,
+		Transfers:    confirmed,
+		ExpiresAt:    swap.CreatedAt.Add(i.config.Swaps.QuoteTTL),
+		FromWalletID: swap.FromWalletID,
+		Amount:       utils.FromAmount(transactions[0].Amount),
+		ReservedAt:   swap.CreatedAt,
+		ConfirmedAt:  now,
+	})
 
 	return &responses.Response[*responses.InstantSwapResponseData]{
 		Status: "successful",
@@ -291,7 +561,7 @@ func (i *instantSwapService) ConfirmInstantSwap(ctx context.Context, req *reques
 				FromAmount:     utils.ApproximateAmount(Ledgers[transactions[0].Ledger], utils.FromAmount(transactions[0].Amount)),
 				ToAmount:       utils.ApproximateAmount(Ledgers[transactions[1].Ledger], utils.FromAmount(transactions[1].Amount)),
 				Confirmed:      true,
-				ExpiresAt:      time.UnixMicro(int64(transactions[0].Timestamp / 1000)).Add(time.Second * 12),
+				ExpiresAt:      time.UnixMicro(int64(transactions[0].Timestamp / 1000)).Add(i.config.Swaps.QuoteTTL),
 				CreatedAt:      time.UnixMicro(int64(transactions[0].Timestamp / 1000)),
 				User:           user.Data,
 			},
@@ -299,118 +569,35 @@ func (i *instantSwapService) ConfirmInstantSwap(ctx context.Context, req *reques
 	}, nil
 }
 
-func (i *instantSwapService) processSwap(swap models.InstantSwap, ts time.Time, transactions []tdb_types.Transfer) {
-	failed := utils.FromAmount(transactions[0].Amount) > 100
-
-	user, err := i.accountService.FetchAccountDetails(context.WithValue(context.Background(), "skip_check", true), &requests.FetchAccountDetailsRequest{UserID: uuid.UUID(transactions[0].UserData128.Bytes()).String()})
+// authorizeSwap checks that the swap debits a wallet of the user
+func (i *instantSwapService) authorizeSwap(ctx context.Context, swap models.InstantSwap, user *models.Account) error {
+	wallet, err := i.authService.AuthorizeWallet(ctx, swap.FromWalletID)
 	if err != nil {
-		i.log.Error("fetching user details for instant swap processing", zap.Error(err))
-		return
+		return errors.NewNotFoundError("swap not found")
 	}
-
-	stx0, _ := tdb_types.HexStringToUint128(swap.SwapTxID0)
-	stx1, _ := tdb_types.HexStringToUint128(swap.SwapTxID1)
-	fromAmount := utils.FromAmount(transactions[0].Amount)
-	toAmount := utils.FromAmount(transactions[1].Amount)
-	confirmedTransactions := []tdb_types.Transfer{
-		{
-			ID:              stx0,
-			CreditAccountID: transactions[0].CreditAccountID,
-			DebitAccountID:  transactions[0].DebitAccountID,
-			Amount:          transactions[0].Amount,
-			Ledger:          transactions[0].Ledger,
-			UserData128:     transactions[0].UserData128,
-			PendingID:       transactions[0].ID,
-			Code:            1,
-			Flags: tdb_types.TransferFlags{
-				Linked:              true,
-				PostPendingTransfer: utils.FromAmount(transactions[0].Amount) <= 100,
-				VoidPendingTransfer: utils.FromAmount(transactions[0].Amount) > 100,
-			}.ToUint16(),
-		},
-		{
-			ID:              stx1,
-			CreditAccountID: transactions[1].CreditAccountID,
-			DebitAccountID:  transactions[1].DebitAccountID,
-			Amount:          transactions[1].Amount,
-			Ledger:          transactions[1].Ledger,
-			UserData128:     transactions[1].UserData128,
-			PendingID:       transactions[1].ID,
-			Code:            1,
-			Flags: tdb_types.TransferFlags{
-				PostPendingTransfer: utils.FromAmount(transactions[0].Amount) <= 100,
-				VoidPendingTransfer: utils.FromAmount(transactions[0].Amount) > 100,
-			}.ToUint16(),
-		},
+	if wallet.AccountID != user.ID {
+		return errors.NewNotFoundError("swap not found")
 	}
+	return nil
+}
 
-	res, err := i.transactionDB.CreateTransfers(confirmedTransactions)
-	if err != nil {
-		i.log.Error("processing swap transaction", zap.Error(err))
-		return
-	}
-
-	if len(res) > 0 {
-		for _, r := range res {
-			if r.Result == tdb_types.TransferExceedsCredits {
-				for i := range confirmedTransactions {
-					confirmedTransactions[i].Flags = tdb_types.TransferFlags{
-						Linked:              i == 0,
-						VoidPendingTransfer: true,
-					}.ToUint16()
-				}
-				res, err = i.transactionDB.CreateTransfers(confirmedTransactions)
-				if err != nil {
-					i.log.Error("failing swap transaction", zap.Error(err))
-				}
-				failed = true
-				goto failedTransfer
-			}
-			i.log.Error("processing swap transaction", zap.String("info", r.Result.String()))
+// confirmSwap runs the swap's confirmation saga. Confirmations that are undone leave the holds pending, their
+// reversal is scheduled again
+func (i *instantSwapService) confirmSwap(payload confirmInstantSwapSaga) {
+	ctx := models.ContextWithPrincipal(context.Background(), models.NewSystemPrincipal(LedgerEnvironment(payload.Holds[0].Ledger)))
+	err := i.sagaService.Run(ctx, models.ConfirmInstantSwap_SagaKind, payload, func(ctx context.Context) error {
+		settled, err := i.swapRepository.Settle(ctx, payload.SwapID, payload.ConfirmedAt)
+		if err != nil {
+			return err
 		}
-		// ?todo: retry transfer confirmation?
-		return
-	}
-
-failedTransfer:
-	data := &responses.InstantSwapResponseData{
-		ID:             swap.ID,
-		FromCurrency:   Ledgers[transactions[0].Ledger],
-		ToCurrency:     Ledgers[transactions[1].Ledger],
-		ExecutionPrice: utils.Formatter.Sprintf("%f", swap.ExecutionRate),
-		FromAmount:     utils.ApproximateAmount(Ledgers[transactions[0].Ledger], fromAmount),
-		ReceivedAmount: utils.ApproximateAmount(Ledgers[transactions[1].Ledger], toAmount),
-		CreatedAt:      ts,
-		UpdatedAt:      ts,
-		User:           user.Data,
-		Status:         "confirmed",
-		SwapQuotation: &responses.InstantSwapQuotationResponseData{
-			ID:             swap.QuotationID,
-			FromCurrency:   Ledgers[transactions[0].Ledger],
-			ToCurrency:     Ledgers[transactions[1].Ledger],
-			QuotedPrice:    swap.QuotationRate,
-			QuotedCurrency: Ledgers[transactions[1].Ledger],
-			FromAmount:     utils.ApproximateAmount(Ledgers[transactions[0].Ledger], fromAmount),
-			ToAmount:       utils.ApproximateAmount(Ledgers[transactions[1].Ledger], toAmount),
-			Confirmed:      true,
-			ExpiresAt:      time.UnixMicro(int64(transactions[0].Timestamp / 1000)).Add(time.Second * 12),
-			CreatedAt:      time.UnixMicro(int64(transactions[0].Timestamp / 1000)),
-			User:           user.Data,
-		},
-	}
-
-	switch failed {
-	case true:
-		i.webhookService.
-			SendInstantSwapFailedEvent(user.Data.WebhookDetails, data)
-
-		// todo: send wallet updated event for debit wallet
-	default:
-		data.Status = "failed"
-		i.webhookService.
-			SendInstantSwapCompletedEvent(user.Data.WebhookDetails, data)
-
-		// todo: send wallet updated event for credit and debit wallets
+		if !settled {
+			return errors.NewValidationError("swap quotation has already been confirmed or reversed")
+		}
+		return nil
+	})
+	if err != nil {
+		i.log.Error("confirming instant swap", zap.String("swap_id", payload.SwapID), zap.Error(err))
+		i.scheduler.ScheduleInstantSwapReversal(payload.QuotationID, payload.ExpiresAt)
 	}
 }
 
@@ -420,27 +607,13 @@ func (i *instantSwapService) FetchInstantSwapTransaction(ctx context.Context, re
 		return nil, err
 	}
 
-	row := sq.
-		Select("instant_swaps.id", "quotation_id", "from_wallet_id", "to_wallet_id", "quotation_rate", "execution_rate", "swap_tx_id_0", "swap_tx_id_1", "quote_tx_id_0", "quote_tx_id_1").
-		From("instant_swaps").
-		Where(sq.Eq{"id": req.SwapTransactionID}).
-		RunWith(i.dataDB).
-		QueryRowContext(ctx)
-	var swap models.InstantSwap
-	err = row.Scan(
-		&swap.ID,
-		&swap.QuotationID,
-		&swap.FromWalletID,
-		&swap.ToWalletID,
-		&swap.QuotationRate,
-		&swap.ExecutionRate,
-		&swap.SwapTxID0,
-		&swap.SwapTxID1,
-		&swap.QuoteTxID0,
-		&swap.QuoteTxID1,
-	)
+	found, err := i.swapRepository.FindByID(ctx, req.SwapTransactionID)
 	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
+	}
+	swap := *found
+	if err = i.authorizeSwap(ctx, swap, user.Data); err != nil {
+		return nil, err
 	}
 
 	stx0, _ := tdb_types.HexStringToUint128(swap.SwapTxID0)
@@ -478,43 +651,108 @@ func (i *instantSwapService) GetInstantSwapTransactions(ctx context.Context, req
 		return nil, err
 	}
 
-	rows, err := sq.
-		Select("instant_swaps.id", "quotation_id", "from_wallet_id", "to_wallet_id", "quotation_rate", "execution_rate", "swap_tx_id_0", "swap_tx_id_1", "quote_tx_id_0", "quote_tx_id_1").
-		From("instant_swaps").
-		Join("wallets on wallets.id = instant_swaps.from_wallet_id").
-		Where(sq.Eq{"wallets.account_id": user.Data.ID}).
-		RunWith(i.dataDB).
-		QueryContext(ctx)
+	env := environment(ctx)
+	from, to := req.Period()
+	filter := repositories.SwapFilter{
+		AccountID:   user.Data.ID,
+		Environment: &env,
+		Currency:    req.Currency,
+		Reference:   req.Reference,
+		From:        from,
+		To:          to,
+	}
+	// the state and amounts of a swap are only known once its transfers are read from the ledger, so with those
+	// filters swaps are read in batches until the page is filled
+	if req.State != "" || req.MinAmount > 0 || req.MaxAmount > 0 {
+		data, pagination, err := i.filterSwaps(ctx, filter, req, user.Data)
+		if err != nil {
+			return nil, err
+		}
+		return &responses.Response[[]*responses.InstantSwapResponseData]{
+			Status:     "successful",
+			Data:       data,
+			Pagination: pagination,
+		}, nil
+	}
+
+	swaps, pagination, err := i.swapRepository.List(ctx, filter, &req.Pagination)
 	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
 	}
-	var swaps = []models.InstantSwap{}
-	var swapIds = []tdb_types.Uint128{}
-	var quoteIds = []tdb_types.Uint128{}
-	for rows.Next() {
-		var swap = models.InstantSwap{}
-		err = rows.Scan(
-			&swap.ID,
-			&swap.QuotationID,
-			&swap.FromWalletID,
-			&swap.ToWalletID,
-			&swap.QuotationRate,
-			&swap.ExecutionRate,
-			&swap.SwapTxID0,
-			&swap.SwapTxID1,
-			&swap.QuoteTxID0,
-			&swap.QuoteTxID1,
-		)
+	data, err := i.describeSwaps(swaps, user.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.Response[[]*responses.InstantSwapResponseData]{
+		Status:     "successful",
+		Data:       data,
+		Pagination: pagination,
+	}, nil
+}
+
+// filterSwaps returns the requested page of the swaps matched by the filter whose state and amount match the
+// request's. Swaps are read in batches from the cursor, or from the first swap when the page is requested by
+// number, until the page is filled. Like lists read from the ledger the total counts the swaps up to the end of
+// the page with one more when there is a next page
+func (i *instantSwapService) filterSwaps(ctx context.Context, filter repositories.SwapFilter, req *requests.GetInstantSwapTransactionsRequest, user *models.Account) ([]*responses.InstantSwapResponseData, *responses.Pagination, error) {
+	skip := req.Offset()
+	if req.Cursor != "" {
+		skip = 0
+	}
+	want := skip + req.PerPage + 1
+
+	batch := requests.Pagination{Page: 1, PerPage: min(want, 100), Cursor: req.Cursor}
+	matched := make([]*responses.InstantSwapResponseData, 0, want)
+	createdAt := make(map[string]time.Time)
+	for len(matched) < want {
+		swaps, page, err := i.swapRepository.List(ctx, filter, &batch)
 		if err != nil {
-			return nil, errors.HandleDataDBError(err)
+			return nil, nil, err
 		}
+		for _, swap := range swaps {
+			createdAt[swap.ID] = swap.CreatedAt
+		}
+		data, err := i.describeSwaps(swaps, user)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, swap := range data {
+			if (req.State == "" || swap.Status == req.State) && req.MatchesAmount(swap.FromAmount) {
+				matched = append(matched, swap)
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		batch.Cursor = page.NextCursor
+	}
+	matched = matched[:min(want, len(matched))]
+
+	pagination := &responses.Pagination{Page: req.Page, PerPage: req.PerPage, Total: len(matched)}
+	matched = trimPage(matched[min(skip, len(matched)):], pagination, func(swap *responses.InstantSwapResponseData) any {
+		return keysetCursor{CreatedAt: createdAt[swap.ID], ID: swap.ID}
+	})
+
+	return matched, pagination, nil
+}
+
+// describeSwaps reads the transfers of the swaps from the ledger, swaps whose holds have not been placed yet are
+// left out
+func (i *instantSwapService) describeSwaps(found []*models.InstantSwap, user *models.Account) ([]*responses.InstantSwapResponseData, error) {
+	swaps := make([]models.InstantSwap, 0, len(found))
+	for _, swap := range found {
+		swaps = append(swaps, *swap)
+	}
 
+	var swapIds = []tdb_types.Uint128{}
+	var quoteIds = []tdb_types.Uint128{}
+	for _, swap := range swaps {
 		stx0, _ := tdb_types.HexStringToUint128(swap.SwapTxID0)
 		stx1, _ := tdb_types.HexStringToUint128(swap.SwapTxID1)
 		qtx0, _ := tdb_types.HexStringToUint128(swap.QuoteTxID0)
 		qtx1, _ := tdb_types.HexStringToUint128(swap.QuoteTxID1)
 
-		swaps = append(swaps, swap)
 		swapIds = append(swapIds, stx0, stx1)
 		quoteIds = append(quoteIds, qtx0, qtx1)
 	}
@@ -528,15 +766,7 @@ func (i *instantSwapService) GetInstantSwapTransactions(ctx context.Context, req
 		return nil, errors.HandleTxDBError(err)
 	}
 
-	data, err := i.groupTransactions(slices.Concat(transfers, pendingTxs), user.Data, swaps...)
-	if err != nil {
-		return nil, err
-	}
-
-	return &responses.Response[[]*responses.InstantSwapResponseData]{
-		Status: "successful",
-		Data:   data,
-	}, nil
+	return i.groupTransactions(slices.Concat(transfers, pendingTxs), user, swaps...)
 }
 
 func (i *instantSwapService) groupTransactions(txs []tdb_types.Transfer, user *models.Account, swaps ...models.InstantSwap) ([]*responses.InstantSwapResponseData, error) {
@@ -557,13 +787,17 @@ func (i *instantSwapService) groupTransactions(txs []tdb_types.Transfer, user *m
 		stx0, ok1 := swapMap[swap.SwapTxID0]
 		stx1, ok2 := swapMap[swap.SwapTxID1]
 
-		qtx0 := quoteMap[swap.QuoteTxID0]
+		qtx0, ok := quoteMap[swap.QuoteTxID0]
+		if !ok {
+			// the swap's holds have not been placed yet
+			continue
+		}
 		qtx1 := quoteMap[swap.QuoteTxID1]
 		if ok1 && ok2 {
 			switch {
 			case stx0.TransferFlags().PostPendingTransfer:
 				status = "confirmed"
-			case time.UnixMicro(int64(qtx0.Timestamp / 1000)).Add(time.Second * 12).Before(time.UnixMicro(int64(stx0.Timestamp / 1000))):
+			case time.UnixMicro(int64(qtx0.Timestamp / 1000)).Add(i.config.Swaps.QuoteTTL).Before(time.UnixMicro(int64(stx0.Timestamp / 1000))):
 				status = "reversed"
 			default:
 				status = "failed"
@@ -589,7 +823,7 @@ func (i *instantSwapService) groupTransactions(txs []tdb_types.Transfer, user *m
 				FromAmount:     utils.ApproximateAmount(Ledgers[qtx0.Ledger], utils.FromAmount(qtx0.Amount)),
 				ToAmount:       utils.ApproximateAmount(Ledgers[qtx1.Ledger], utils.FromAmount(qtx1.Amount)),
 				Confirmed:      status != "reversed",
-				ExpiresAt:      time.UnixMicro(int64(qtx0.Timestamp / 1000)).Add(12 * time.Second),
+				ExpiresAt:      time.UnixMicro(int64(qtx0.Timestamp / 1000)).Add(i.config.Swaps.QuoteTTL),
 				CreatedAt:      time.UnixMicro(int64(qtx0.Timestamp / 1000)),
 				User:           user,
 			},
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/2HgO/quidax-go/errors"
+	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
+	"github.com/2HgO/quidax-go/types/requests"
+	"github.com/2HgO/quidax-go/types/responses"
+	"github.com/2HgO/quidax-go/utils"
+	tdb "github.com/tigerbeetle/tigerbeetle-go"
+	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
+	"go.uber.org/zap"
+)
+
+var transactionTypes = map[uint16]models.TransactionType{
+	deposit_TransferCode:    models.Deposit_TransactionType,
+	withdrawal_TransferCode: models.Withdrawal_TransactionType,
+	swap_TransferCode:       models.Swap_TransactionType,
+	fee_TransferCode:        models.Fee_TransactionType,
+}
+
+type TransactionService interface {
+	FetchTransactions(context.Context, *requests.FetchTransactionsRequest) (*responses.Response[[]*responses.TransactionResponseData], error)
+}
+
+func NewTransactionService(
+	txDatabase tdb.Client,
+	walletRepository repositories.WalletRepository,
+	withdrawalRepository repositories.WithdrawalRepository,
+	swapRepository repositories.SwapRepository,
+	authService AuthorizationService,
+	log *zap.Logger,
+) TransactionService {
+	return &transactionService{
+		service{
+			transactionDB:        txDatabase,
+			authService:          authService,
+			log:                  log,
+			walletRepository:     walletRepository,
+			withdrawalRepository: withdrawalRepository,
+			swapRepository:       swapRepository,
+		},
+	}
+}
+
+type transactionService struct {
+	service
+}
+
+func (t *transactionService) FetchTransactions(ctx context.Context, req *requests.FetchTransactionsRequest) (*responses.Response[[]*responses.TransactionResponseData], error) {
+	user, err := t.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	wallets, err := t.userWallets(ctx, user, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	references, err := t.referencedTransfers(ctx, req.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	// every wallet is read up to the end of the page, the entries of all of them are then merged into the page
+	from, to := req.Period()
+	sources := make([]ledgerSource[tdb_types.Transfer], 0, len(wallets))
+	for _, wallet := range wallets {
+		filter, err := walletFilter(wallet, from, to)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, ledgerSource[tdb_types.Transfer]{filter: filter, keep: func(transfer tdb_types.Transfer) bool {
+			switch {
+			case req.Type != nil && transactionTypes[transfer.Code] != *req.Type:
+				return false
+			case references != nil && !references[transfer.ID.String()]:
+				return false
+			default:
+				return req.MatchesAmount(utils.ApproximateAmount(wallet.Token, utils.FromAmount(transfer.Amount)))
+			}
+		}})
+	}
+	page, pagination, err := ledgerPage(req.Pagination, sources, t.transactionDB.GetAccountTransfers, transferTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	// entries are built for each wallet's transfers on the page and put back in the page's order
+	positions := make(map[tdb_types.Uint128][]int)
+	for i, entry := range page {
+		positions[entry.account] = append(positions[entry.account], i)
+	}
+	data := make([]*responses.TransactionResponseData, len(page))
+	for i, wallet := range wallets {
+		walletPositions := positions[sources[i].filter.AccountID]
+		if len(walletPositions) == 0 {
+			continue
+		}
+		transfers := make([]tdb_types.Transfer, 0, len(walletPositions))
+		for _, position := range walletPositions {
+			transfers = append(transfers, page[position].item)
+		}
+		entries, err := t.historyEntries(ctx, wallet, transfers)
+		if err != nil {
+			return nil, err
+		}
+		for j, position := range walletPositions {
+			data[position] = entries[j]
+		}
+	}
+
+	return &responses.Response[[]*responses.TransactionResponseData]{
+		Status:     "successful",
+		Data:       data,
+		Pagination: pagination,
+	}, nil
+}
+
+// userWallets returns the user's wallets in its environment, limited to the currency when one is given
+func (s *service) userWallets(ctx context.Context, user *models.Account, currency string) ([]*models.Wallet, error) {
+	wallets, err := s.walletRepository.ListByAccount(ctx, user.ID, user.Environment, currency)
+	if err != nil {
+		return nil, err
+	}
+	if currency != "" && len(wallets) == 0 {
+		return nil, errors.NewNotFoundError("wallet not found")
+	}
+
+	return wallets, nil
+}
+
+// walletFilter matches the transfers on the wallet within the period
+func walletFilter(wallet *models.Wallet, from *time.Time, to *time.Time) (tdb_types.AccountFilter, error) {
+	walletID, err := tdb_types.HexStringToUint128(wallet.ID)
+	if err != nil {
+		return tdb_types.AccountFilter{}, err
+	}
+
+	filter := tdb_types.AccountFilter{
+		AccountID: walletID,
+		Flags: tdb_types.AccountFilterFlags{
+			Debits:  true,
+			Credits: true,
+		}.ToUint32(),
+	}
+	if from != nil {
+		filter.TimestampMin = uint64(from.UnixNano())
+	}
+	if to != nil {
+		filter.TimestampMax = uint64(to.UnixNano())
+	}
+	return filter, nil
+}
+
+// walletHistory reads every transfer on the wallet within the period, newest first, with the wallet's
+// balances once each transfer was applied
+func (s *service) walletHistory(ctx context.Context, wallet *models.Wallet, from *time.Time, to *time.Time) ([]*responses.TransactionResponseData, error) {
+	filter, err := walletFilter(wallet, from, to)
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := s.scanAccountTransfers(filter, func(tdb_types.Transfer) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	return s.historyEntries(ctx, wallet, transfers)
+}
+
+// historyEntries describes the wallet's transfers, ordered newest first, with the wallet's balances once each
+// was applied. Only the balances between the first and the last transfer are read
+func (s *service) historyEntries(ctx context.Context, wallet *models.Wallet, transfers []tdb_types.Transfer) ([]*responses.TransactionResponseData, error) {
+	history := make([]*responses.TransactionResponseData, 0, len(transfers))
+	if len(transfers) == 0 {
+		return history, nil
+	}
+	walletID, err := tdb_types.HexStringToUint128(wallet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	balances, err := s.scanAccountBalances(tdb_types.AccountFilter{
+		AccountID:    walletID,
+		TimestampMin: transfers[len(transfers)-1].Timestamp,
+		TimestampMax: transfers[0].Timestamp,
+		Flags: tdb_types.AccountFilterFlags{
+			Debits:  true,
+			Credits: true,
+		}.ToUint32(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	balanceAt := make(map[uint64]tdb_types.AccountBalance, len(balances))
+	for _, balance := range balances {
+		balanceAt[balance.Timestamp] = balance
+	}
+	references, err := s.transferReferences(ctx, transfers)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, transfer := range transfers {
+		entry := &responses.TransactionResponseData{
+			ID:        transfer.ID.String(),
+			Type:      transactionTypes[transfer.Code],
+			WalletID:  wallet.ID,
+			Currency:  wallet.Token,
+			Direction: "credit",
+			Amount:    utils.ApproximateAmount(wallet.Token, utils.FromAmount(transfer.Amount)),
+			Status:    "completed",
+			Reference: references[transfer.ID.String()],
+			CreatedAt: time.Unix(0, int64(transfer.Timestamp)),
+		}
+		if transfer.DebitAccountID == walletID {
+			entry.Direction = "debit"
+		}
+		switch flags := transfer.TransferFlags(); {
+		case flags.Pending:
+			entry.Status = "pending"
+		case flags.VoidPendingTransfer:
+			entry.Status = "reversed"
+		}
+		if balance, ok := balanceAt[transfer.Timestamp]; ok {
+			entry.Balance, entry.LockedBalance = walletBalance(wallet.Token, balance.CreditsPosted, balance.DebitsPosted, balance.DebitsPending)
+		}
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
+
+// referencedTransfers returns the ids of the transfers of the deposit, withdrawal or swap with the reference,
+// which may also be a transfer's id. It returns nil when no reference is given
+func (s *service) referencedTransfers(ctx context.Context, reference string) (map[string]bool, error) {
+	if reference == "" {
+		return nil, nil
+	}
+	ids := map[string]bool{reference: true}
+
+	withdrawal, err := s.withdrawalRepository.Find(ctx, repositories.WithdrawalFilter{ID: reference})
+	switch {
+	case err == nil:
+		ids[withdrawal.TxID] = true
+	case errors.AsAppError(err).Type != errors.ErrNotFound:
+		return nil, err
+	}
+
+	swap, err := s.swapRepository.FindByID(ctx, reference)
+	switch {
+	case err == nil:
+		for _, txID := range []string{swap.SwapTxID0, swap.SwapTxID1, swap.QuoteTxID0, swap.QuoteTxID1} {
+			ids[txID] = true
+		}
+	case errors.AsAppError(err).Type != errors.ErrNotFound:
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// transferReferences maps transfers to the id of the deposit, withdrawal or swap they belong to
+func (s *service) transferReferences(ctx context.Context, transfers []tdb_types.Transfer) (map[string]string, error) {
+	references := make(map[string]string, len(transfers))
+	withdrawalTxs := make([]string, 0)
+	swapTxs := make([]string, 0)
+	for _, transfer := range transfers {
+		switch transfer.Code {
+		case deposit_TransferCode:
+			references[transfer.ID.String()] = transfer.ID.String()
+		case withdrawal_TransferCode:
+			withdrawalTxs = append(withdrawalTxs, transfer.ID.String())
+		case swap_TransferCode:
+			swapTxs = append(swapTxs, transfer.ID.String())
+		}
+	}
+
+	withdrawals, err := s.withdrawalRepository.FindByTxIDs(ctx, withdrawalTxs)
+	if err != nil {
+		return nil, err
+	}
+	for _, withdrawal := range withdrawals {
+		references[withdrawal.TxID] = withdrawal.ID
+	}
+
+	swaps, err := s.swapRepository.FindByTxIDs(ctx, swapTxs)
+	if err != nil {
+		return nil, err
+	}
+	for _, swap := range swaps {
+		for _, txID := range []string{swap.SwapTxID0, swap.SwapTxID1, swap.QuoteTxID0, swap.QuoteTxID1} {
+			references[txID] = swap.ID
+		}
+	}
+
+	return references, nil
+}
@@ -2,15 +2,16 @@ package services
 
 import (
 	"context"
-	"database/sql"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/2HgO/quidax-go/errors"
 	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
 	"github.com/2HgO/quidax-go/types/requests"
 	"github.com/2HgO/quidax-go/types/responses"
 	"github.com/2HgO/quidax-go/utils"
-	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -24,17 +25,33 @@ type WalletService interface {
 	FetchUserWallets(context.Context, *requests.FetchUserWalletsRequest) (*responses.Response[[]*responses.UserWalletResponseData], error)
 	FetchUserWallet(context.Context, *requests.FetchUserWalletRequest) (*responses.Response[*responses.UserWalletResponseData], error)
 
+	FreezeUserWallet(context.Context, *requests.FreezeUserWalletRequest) (*responses.Response[*responses.UserWalletResponseData], error)
+	UnfreezeUserWallet(context.Context, *requests.FreezeUserWalletRequest) (*responses.Response[*responses.UserWalletResponseData], error)
+
+	FetchWalletBalance(context.Context, *requests.FetchWalletBalanceRequest) (*responses.Response[*responses.WalletBalanceResponseData], error)
+	FetchWalletBalanceHistory(context.Context, *requests.FetchWalletBalanceHistoryRequest) (*responses.Response[[]*responses.WalletBalanceResponseData], error)
+
 	LookupWallets(context.Context, []string) (map[string]*responses.UserWalletResponseData, error)
 }
 
-func NewWalletService(txDatabase tdb.Client, dataDatabase *sql.DB, accountService AccountService, webhookService WebhookService, log *zap.Logger) WalletService {
+func NewWalletService(
+	txDatabase tdb.Client,
+	accountRepository repositories.AccountRepository,
+	walletRepository repositories.WalletRepository,
+	authService AuthorizationService,
+	accountService AccountService,
+	webhookService WebhookService,
+	log *zap.Logger,
+) WalletService {
 	w := &walletService{
 		service{
-			transactionDB:  txDatabase,
-			dataDB:         dataDatabase,
-			accountService: accountService,
-			webhookService: webhookService,
-			log:            log,
+			transactionDB:     txDatabase,
+			authService:       authService,
+			accountService:    accountService,
+			webhookService:    webhookService,
+			log:               log,
+			accountRepository: accountRepository,
+			walletRepository:  walletRepository,
 		},
 	}
 
@@ -87,23 +104,13 @@ func (w *walletService) FetchUserWallets(ctx context.Context, req *requests.Fetc
 		return nil, err
 	}
 
-	rows, err := sq.
-		Select("id", "account_id", "token").
-		From("wallets").
-		Where(sq.Eq{"account_id": user.Data.ID}).
-		RunWith(w.dataDB).
-		QueryContext(ctx)
+	wallets, err := w.walletRepository.ListByAccount(ctx, user.Data.ID, environment(ctx), "")
 	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
 	}
 
 	var walletsMap = map[string]*models.Wallet{}
-	for rows.Next() {
-		wallet := &models.Wallet{}
-		err = rows.Scan(&wallet.ID, &wallet.AccountID, &wallet.Token)
-		if err != nil {
-			return nil, errors.HandleDataDBError(err)
-		}
+	for _, wallet := range wallets {
 		walletsMap[wallet.ID] = wallet
 	}
 
@@ -112,15 +119,19 @@ func (w *walletService) FetchUserWallets(ctx context.Context, req *requests.Fetc
 		return nil, err
 	}
 
-	data := make([]*responses.UserWalletResponseData, len(res))
+	data := make([]*responses.UserWalletResponseData, 0, len(walletsMap))
 	for i := range res {
+		wallet, ok := walletsMap[res[i].ID.String()]
+		if !ok {
+			// wallet belongs to the other environment
+			continue
+		}
 		credits := res[i].CreditsPosted.BigInt()
 		debits := res[i].DebitsPosted.BigInt()
 		pendingDebits := res[i].DebitsPending.BigInt()
 		balance := credits.Sub(&credits, &debits)
 		balance = balance.Sub(balance, &pendingDebits)
-		wallet := walletsMap[res[i].ID.String()]
-		data[i] = &responses.UserWalletResponseData{
+		data = append(data, &responses.UserWalletResponseData{
 			ID:                wallet.ID,
 			Name:              cases.Upper(language.English).String(wallet.Token),
 			Currency:          wallet.Token,
@@ -132,9 +143,15 @@ func (w *walletService) FetchUserWallets(ctx context.Context, req *requests.Fetc
 			UpdatedAt:         time.UnixMicro(int64(res[i].Timestamp / 1000)),
 			ReferenceCurrency: "ngn",
 			IsCrypto:          wallet.Token != "ngn",
-		}
+			Frozen:            wallet.Frozen,
+		})
 	}
 
+	// wallets are created in no particular order, they are listed by currency
+	slices.SortFunc(data, func(a, b *responses.UserWalletResponseData) int {
+		return strings.Compare(a.Currency, b.Currency)
+	})
+
 	return &responses.Response[[]*responses.UserWalletResponseData]{
 		Status: "successful",
 		Data:   data,
@@ -147,20 +164,9 @@ func (w *walletService) FetchUserWallet(ctx context.Context, req *requests.Fetch
 		return nil, err
 	}
 
-	row := sq.
-		Select("id", "account_id", "token").
-		From("wallets").
-		Where(sq.Eq{"account_id": user.Data.ID, "token": req.Currency}).
-		RunWith(w.dataDB).
-		QueryRowContext(ctx)
-	if row == nil {
-		return nil, errors.NewNotFoundError("wallet not found")
-	}
-
-	wallet := &models.Wallet{}
-	err = row.Scan(&wallet.ID, &wallet.AccountID, &wallet.Token)
+	wallet, err := w.findWallet(ctx, user.Data.ID, req.Currency)
 	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
 	}
 
 	walletId, err := tdb_types.HexStringToUint128(wallet.ID)
@@ -191,7 +197,8 @@ func (w *walletService) FetchUserWallet(ctx context.Context, req *requests.Fetch
 		CreatedAt:         time.UnixMicro(int64(res[0].Timestamp / 1000)),
 		UpdatedAt:         time.UnixMicro(int64(res[0].Timestamp / 1000)),
 		ReferenceCurrency: "ngn",
-		IsCrypto:          wallet.Token == "ngn",
+		IsCrypto:          wallet.Token != "ngn",
+		Frozen:            wallet.Frozen,
 	}
 
 	return &responses.Response[*responses.UserWalletResponseData]{
@@ -200,37 +207,35 @@ func (w *walletService) FetchUserWallet(ctx context.Context, req *requests.Fetch
 	}, nil
 }
 
+// LookupWallets loads wallets by id for populating transactions, callers are responsible for
+// redacting wallets the principal may not act on
 func (w *walletService) LookupWallets(ctx context.Context, ids []string) (map[string]*responses.UserWalletResponseData, error) {
-	rows, err := sq.
-		Select(
-			"wallets.id", "wallets.account_id", "wallets.token",
-
-			"accounts.id", "accounts.sn", "accounts.display_name", "accounts.email", "accounts.first_name",
-			"accounts.last_name", "accounts.created_at", "accounts.updated_at",
-		).
-		From("wallets").
-		Join("accounts on wallets.account_id = accounts.id").
-		Where(sq.Eq{"wallets.id": ids}).
-		RunWith(w.dataDB).
-		QueryContext(ctx)
+	if _, err := w.authService.Principal(ctx); err != nil {
+		return nil, err
+	}
+
+	wallets, err := w.walletRepository.FindByIDs(ctx, ids)
 	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
+	}
+	accountIds := make([]string, 0, len(wallets))
+	for _, wallet := range wallets {
+		accountIds = append(accountIds, wallet.AccountID)
+	}
+	accounts, err := w.accountRepository.FindByIDs(ctx, accountIds)
+	if err != nil {
+		return nil, err
 	}
 
 	var walletsMap = map[string]*models.Wallet{}
 	var accountMap = map[string]*models.Account{}
 	walletIds := make([]tdb_types.Uint128, 0)
-	for rows.Next() {
-		wallet := &models.Wallet{}
-		account := &models.Account{}
-		err = rows.Scan(
-			&wallet.ID, &wallet.AccountID, &wallet.Token,
-
-			&account.ID, &account.SN, &account.DisplayName, &account.Email, &account.FirstName,
-			&account.LastName, &account.CreatedAt, &account.UpdatedAt,
-		)
-		if err != nil {
-			return nil, errors.HandleDataDBError(err)
+	for _, account := range accounts {
+		accountMap[account.ID] = account
+	}
+	for _, wallet := range wallets {
+		if _, ok := accountMap[wallet.AccountID]; !ok {
+			continue
 		}
 		walletId, err := tdb_types.HexStringToUint128(wallet.ID)
 		if err != nil {
@@ -238,7 +243,6 @@ func (w *walletService) LookupWallets(ctx context.Context, ids []string) (map[st
 		}
 		walletIds = append(walletIds, walletId)
 		walletsMap[wallet.ID] = wallet
-		accountMap[account.ID] = account
 	}
 
 	res, err := w.transactionDB.LookupAccounts(walletIds)
@@ -268,8 +272,166 @@ func (w *walletService) LookupWallets(ctx context.Context, ids []string) (map[st
 			UpdatedAt:         time.UnixMicro(int64(res[i].Timestamp / 1000)),
 			ReferenceCurrency: "ngn",
 			IsCrypto:          wallet.Token != "ngn",
+			Frozen:            wallet.Frozen,
 		}
 	}
 
 	return data, nil
 }
+
+func (w *walletService) FreezeUserWallet(ctx context.Context, req *requests.FreezeUserWalletRequest) (*responses.Response[*responses.UserWalletResponseData], error) {
+	return w.setUserWalletFrozen(ctx, req, true)
+}
+
+func (w *walletService) UnfreezeUserWallet(ctx context.Context, req *requests.FreezeUserWalletRequest) (*responses.Response[*responses.UserWalletResponseData], error) {
+	return w.setUserWalletFrozen(ctx, req, false)
+}
+
+func (w *walletService) setUserWalletFrozen(ctx context.Context, req *requests.FreezeUserWalletRequest, frozen bool) (*responses.Response[*responses.UserWalletResponseData], error) {
+	user, err := w.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsMainAccount {
+		return nil, errors.NewPermissionError("main account wallets cannot be frozen")
+	}
+
+	wallet, err := w.findWallet(ctx, user.ID, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.Frozen == frozen {
+		return w.FetchUserWallet(ctx, &requests.FetchUserWalletRequest{UserID: user.ID, Currency: req.Currency})
+	}
+
+	if err = w.walletRepository.SetFrozen(ctx, wallet.ID, frozen); err != nil {
+		return nil, err
+	}
+
+	res, err := w.FetchUserWallet(ctx, &requests.FetchUserWalletRequest{UserID: user.ID, Currency: req.Currency})
+	if err != nil {
+		return nil, err
+	}
+
+	switch frozen {
+	case true:
+		go w.webhookService.SendWalletFrozenEvent(user.WebhookDetails, res.Data)
+	default:
+		go w.webhookService.SendWalletUnfrozenEvent(user.WebhookDetails, res.Data)
+	}
+
+	return res, nil
+}
+
+// FetchWalletBalance returns the wallet's balances as of a point in time, or right before a transaction was applied
+func (w *walletService) FetchWalletBalance(ctx context.Context, req *requests.FetchWalletBalanceRequest) (*responses.Response[*responses.WalletBalanceResponseData], error) {
+	user, err := w.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := w.findWallet(ctx, user.ID, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var timestamp uint64
+	switch req.Before {
+	case "":
+		at, _ := time.Parse(time.RFC3339, req.At)
+		if at.UnixNano() <= 0 {
+			return nil, errors.NewValidationError("at must be after the unix epoch")
+		}
+		timestamp = uint64(at.UnixNano())
+	default:
+		walletID, _ := tdb_types.HexStringToUint128(wallet.ID)
+		transferID, err := tdb_types.HexStringToUint128(req.Before)
+		if err != nil {
+			return nil, errors.NewNotFoundError("transaction not found")
+		}
+		transfers, err := w.transactionDB.LookupTransfers([]tdb_types.Uint128{transferID})
+		if err != nil {
+			return nil, errors.HandleTxDBError(err)
+		}
+		if len(transfers) != 1 || (transfers[0].DebitAccountID != walletID && transfers[0].CreditAccountID != walletID) {
+			return nil, errors.NewNotFoundError("transaction not found")
+		}
+		timestamp = transfers[0].Timestamp - 1
+	}
+
+	balance, err := w.balanceAt(wallet, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responses.Response[*responses.WalletBalanceResponseData]{
+		Status: "successful",
+		Data:   walletBalanceData(wallet, balance),
+	}, nil
+}
+
+// FetchWalletBalanceHistory returns the wallet's balances after every transfer within the period, newest first
+func (w *walletService) FetchWalletBalanceHistory(ctx context.Context, req *requests.FetchWalletBalanceHistoryRequest) (*responses.Response[[]*responses.WalletBalanceResponseData], error) {
+	user, err := w.authService.AuthorizeUser(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := w.findWallet(ctx, user.ID, req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	walletID, err := tdb_types.HexStringToUint128(wallet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := tdb_types.AccountFilter{
+		AccountID: walletID,
+		Flags: tdb_types.AccountFilterFlags{
+			Debits:  true,
+			Credits: true,
+		}.ToUint32(),
+	}
+	if req.From != "" {
+		from, _ := time.Parse(time.RFC3339, req.From)
+		filter.TimestampMin = uint64(max(from.UnixNano(), 0))
+	}
+	if req.To != "" {
+		to, _ := time.Parse(time.RFC3339, req.To)
+		filter.TimestampMax = uint64(max(to.UnixNano(), 0))
+	}
+
+	page, pagination, err := ledgerPage(req.Pagination, []ledgerSource[tdb_types.AccountBalance]{{filter: filter}}, w.transactionDB.GetAccountBalances, balanceTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]*responses.WalletBalanceResponseData, 0, len(page))
+	for i := range page {
+		data = append(data, walletBalanceData(wallet, &page[i].item))
+	}
+
+	return &responses.Response[[]*responses.WalletBalanceResponseData]{
+		Status:     "successful",
+		Data:       data,
+		Pagination: pagination,
+	}, nil
+}
+
+func walletBalanceData(wallet *models.Wallet, balance *tdb_types.AccountBalance) *responses.WalletBalanceResponseData {
+	data := &responses.WalletBalanceResponseData{
+		WalletID: wallet.ID,
+		Currency: wallet.Token,
+	}
+	if balance == nil {
+		return data
+	}
+
+	updatedAt := time.Unix(0, int64(balance.Timestamp))
+	data.DebitsPending = utils.ApproximateAmount(wallet.Token, utils.FromAmount(balance.DebitsPending))
+	data.DebitsPosted = utils.ApproximateAmount(wallet.Token, utils.FromAmount(balance.DebitsPosted))
+	data.CreditsPending = utils.ApproximateAmount(wallet.Token, utils.FromAmount(balance.CreditsPending))
+	data.CreditsPosted = utils.ApproximateAmount(wallet.Token, utils.FromAmount(balance.CreditsPosted))
+	data.Balance, data.LockedBalance = walletBalance(wallet.Token, balance.CreditsPosted, balance.DebitsPosted, balance.DebitsPending)
+	data.UpdatedAt = &updatedAt
+
+	return data
+}
@@ -12,6 +12,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/2HgO/quidax-go/config"
+	"github.com/2HgO/quidax-go/errors"
 	"github.com/2HgO/quidax-go/models"
 	"github.com/2HgO/quidax-go/types/responses"
 	"go.uber.org/zap"
@@ -25,23 +27,29 @@ type WebhookService interface {
 	SendWithdrawalSuccessfulEvent(models.WebhookDetails, *responses.WithdrawalResponseData) (self WebhookService)
 	SendWithdrawalRejectedEvent(models.WebhookDetails, *responses.WithdrawalResponseData) (self WebhookService)
 	SendDepositSuccessfulEvent(models.WebhookDetails, *responses.DepositResponseData) (self WebhookService)
+	SendUserFrozenEvent(models.WebhookDetails, *models.Account) (self WebhookService)
+	SendUserUnfrozenEvent(models.WebhookDetails, *models.Account) (self WebhookService)
+	SendWalletFrozenEvent(models.WebhookDetails, *responses.UserWalletResponseData) (self WebhookService)
+	SendWalletUnfrozenEvent(models.WebhookDetails, *responses.UserWalletResponseData) (self WebhookService)
+	SendKYCUpdatedEvent(models.WebhookDetails, *models.KYCSubmission) (self WebhookService)
+	// Deliver sends the event right away and fails when the callback does not accept it
+	Deliver(models.WebhookDetails, models.WebhookEvent, any) error
 }
 
 type webhookService struct {
 	service
 }
 
-func NewWebhookService(accountService AccountService, log *zap.Logger) WebhookService {
+func NewWebhookService(cfg *config.Config, log *zap.Logger) WebhookService {
 	return &webhookService{
 		service{
-			accountService: accountService,
-			log:            log,
+			config: cfg,
+			log:    log,
 		},
 	}
 }
 
 func (w *webhookService) doRequest(url string, body *bytes.Buffer, key *string) (error, bool) {
-	time.Sleep(time.Second * 5)
 	req, err := http.NewRequest(http.MethodPost, url, body)
 	if err != nil {
 		return err, false
@@ -76,6 +84,19 @@ func (w *webhookService) sendEvent(whDetails models.WebhookDetails, eventType mo
 		return w
 	}
 	w.log.Info("dispatching event...", zap.String("Event Type", eventType.String()))
+	time.Sleep(w.config.Webhooks.Delay)
+
+	if err := w.Deliver(whDetails, eventType, eventData); err != nil {
+		// todo: schedule event for single retry
+		w.log.Error("dispatching event", zap.Error(err))
+	}
+	return w
+}
+
+func (w *webhookService) Deliver(whDetails models.WebhookDetails, eventType models.WebhookEvent, eventData any) error {
+	if whDetails.CallbackURL == nil {
+		return errors.NewValidationError("account has no webhook url")
+	}
 
 	event := &models.Webhook{
 		Event: eventType,
@@ -84,24 +105,17 @@ func (w *webhookService) sendEvent(whDetails models.WebhookDetails, eventType mo
 
 	data, err := json.Marshal(event)
 	if err != nil {
-		// todo
-		w.log.Error("encoding request body", zap.Error(err))
-		return w
+		return err
 	}
 
 	err, ok := w.doRequest(*whDetails.CallbackURL, bytes.NewBuffer(data), whDetails.WebhookKey)
 	if err != nil {
-		//todo
-		w.log.Error("dispatching request", zap.Error(err))
-		return w
+		return err
 	}
-
-	if ok {
-		return w
+	if !ok {
+		return errors.NewFailedDependencyError("callback did not accept the " + eventType.String() + " event")
 	}
-
-	// todo: schedule event for single retry
-	return w
+	return nil
 }
 
 func (w *webhookService) SendWalletUpdatedEvent(whDetails models.WebhookDetails, wallet *responses.UserWalletResponseData) (self WebhookService) {
@@ -130,6 +144,26 @@ func (w *webhookService) SendWithdrawalRejectedEvent(whDetails models.WebhookDet
 
 func (w *webhookService) SendDepositSuccessfulEvent(whDetails models.WebhookDetails, data *responses.DepositResponseData) (self WebhookService) {
 	w.sendEvent(whDetails, models.DepositConfirmation_WebhookEvent, data)
-	time.Sleep(time.Second * 5)
+	time.Sleep(w.config.Webhooks.Delay)
 	return w.sendEvent(whDetails, models.DepositSuccessful_WebhookEvent, data)
 }
+
+func (w *webhookService) SendUserFrozenEvent(whDetails models.WebhookDetails, user *models.Account) (self WebhookService) {
+	return w.sendEvent(whDetails, models.UserFrozen_WebhookEvent, user)
+}
+
+func (w *webhookService) SendUserUnfrozenEvent(whDetails models.WebhookDetails, user *models.Account) (self WebhookService) {
+	return w.sendEvent(whDetails, models.UserUnfrozen_WebhookEvent, user)
+}
+
+func (w *webhookService) SendWalletFrozenEvent(whDetails models.WebhookDetails, wallet *responses.UserWalletResponseData) (self WebhookService) {
+	return w.sendEvent(whDetails, models.WalletFrozen_WebhookEvent, wallet)
+}
+
+func (w *webhookService) SendWalletUnfrozenEvent(whDetails models.WebhookDetails, wallet *responses.UserWalletResponseData) (self WebhookService) {
+	return w.sendEvent(whDetails, models.WalletUnfrozen_WebhookEvent, wallet)
+}
+
+func (w *webhookService) SendKYCUpdatedEvent(whDetails models.WebhookDetails, submission *models.KYCSubmission) (self WebhookService) {
+	return w.sendEvent(whDetails, models.KYCUpdated_WebhookEvent, submission)
+}
@@ -2,15 +2,15 @@ package services
 
 import (
 	"context"
-	"database/sql"
+	"slices"
 	"time"
 
 	"github.com/2HgO/quidax-go/errors"
 	"github.com/2HgO/quidax-go/models"
+	"github.com/2HgO/quidax-go/repositories"
 	"github.com/2HgO/quidax-go/types/requests"
 	"github.com/2HgO/quidax-go/types/responses"
 	"github.com/2HgO/quidax-go/utils"
-	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
 	tdb "github.com/tigerbeetle/tigerbeetle-go"
 	tdb_types "github.com/tigerbeetle/tigerbeetle-go/pkg/types"
@@ -23,23 +23,78 @@ type WithdrawalService interface {
 	FetchWithdrawals(context.Context, *requests.FetchWithdrawalsRequest) (*responses.Response[[]*responses.WithdrawalResponseData], error)
 }
 
-func NewWithdrawalService(txDatabase tdb.Client, dataDatabase *sql.DB, accountService AccountService, walletService WalletService, webhookService WebhookService, log *zap.Logger) WithdrawalService {
-	return &withdrawalService{
+func NewWithdrawalService(
+	txDatabase tdb.Client,
+	walletRepository repositories.WalletRepository,
+	withdrawalRepository repositories.WithdrawalRepository,
+	authService AuthorizationService,
+	accountService AccountService,
+	walletService WalletService,
+	webhookService WebhookService,
+	limitService LimitService,
+	sagaService SagaService,
+	log *zap.Logger,
+) WithdrawalService {
+	w := &withdrawalService{
 		service{
-			transactionDB:  txDatabase,
-			dataDB:         dataDatabase,
-			accountService: accountService,
-			walletService:  walletService,
-			webhookService: webhookService,
-			log:            log,
+			transactionDB:        txDatabase,
+			authService:          authService,
+			accountService:       accountService,
+			walletService:        walletService,
+			webhookService:       webhookService,
+			limitService:         limitService,
+			sagaService:          sagaService,
+			log:                  log,
+			walletRepository:     walletRepository,
+			withdrawalRepository: withdrawalRepository,
 		},
 	}
+	sagaService.Register(models.Withdrawal_SagaKind, newSagaBuilder(w.withdrawalSagaDefinition))
+
+	return w
 }
 
 type withdrawalService struct {
 	service
 }
 
+// withdrawalSaga is the payload of withdrawal sagas
+type withdrawalSaga struct {
+	WithdrawalID string             `json:"withdrawal_id"`
+	Transfer     tdb_types.Transfer `json:"transfer"`
+	// the wallet's limit usage reserved for the withdrawal, given back when it is undone
+	WalletID   string    `json:"wallet_id"`
+	Amount     float64   `json:"amount"`
+	ReservedAt time.Time `json:"reserved_at"`
+}
+
+// withdrawalSagaDefinition records a pending withdrawal, makes its transfer and then completes it. Pending
+// withdrawals whose transfer cannot be made are deleted and their limit usage given back
+func (w *withdrawalService) withdrawalSagaDefinition(payload withdrawalSaga) *sagaDefinition {
+	return &sagaDefinition{
+		steps: []sagaStep{
+			{
+				name: "insert pending withdrawal",
+				compensate: func(ctx context.Context) error {
+					err := w.limitService.ReleaseLimit(ctx, payload.WalletID, models.Withdrawal_LimitOperation, payload.Amount, payload.ReservedAt)
+					if err != nil {
+						return err
+					}
+					return w.withdrawalRepository.Delete(ctx, payload.WithdrawalID)
+				},
+			},
+			w.createTransfersStep([]tdb_types.Transfer{payload.Transfer}),
+			{
+				name: "complete withdrawal",
+				forward: func(ctx context.Context) error {
+					return w.withdrawalRepository.SetStatus(ctx, payload.WithdrawalID, models.Completed_WithdrawalStatus)
+				},
+			},
+		},
+		pivot: 1,
+	}
+}
+
 func (w *withdrawalService) CreateUserWithdrawal(ctx context.Context, req *requests.CreateWithdrawalRequest) (*responses.Response[*responses.WithdrawalResponseData], error) {
 	amount := utils.ApproximateAmount(req.Currency, float64(req.Amount))
 	wallet, err := w.walletService.FetchUserWallet(ctx, &requests.FetchUserWalletRequest{UserID: req.UserID, Currency: req.Currency})
@@ -50,17 +105,33 @@ func (w *withdrawalService) CreateUserWithdrawal(ctx context.Context, req *reque
 	if err != nil {
 		return nil, err
 	}
-	destination, err := w.walletService.FetchUserWallet(context.WithValue(ctx, "skip_check", true), &requests.FetchUserWalletRequest{UserID: req.FundUid, Currency: req.Currency})
+	recipient, err := w.authService.AuthorizeRecipient(ctx, req.FundUid)
 	if err != nil {
 		return nil, err
 	}
-	destinationID, err := tdb_types.HexStringToUint128(destination.Data.ID)
+	destination, err := w.findWallet(ctx, recipient.ID, req.Currency)
 	if err != nil {
 		return nil, err
 	}
-
+	destinationID, err := tdb_types.HexStringToUint128(destination.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkFrozen(wallet.Data.User, req.Currency, wallet.Data.Frozen); err != nil {
+		return nil, err
+	}
+	if err = checkFrozen(recipient, req.Currency, destination.Frozen); err != nil {
+		return nil, errors.NewFrozenError("recipient cannot receive funds")
+	}
+	if err = checkKYC(wallet.Data.User, withdraw_kycOperation, req.Currency); err != nil {
+		return nil, err
+	}
+	if err = checkKYC(recipient, deposit_kycOperation, req.Currency); err != nil {
+		return nil, errors.NewKYCRequiredError("recipient cannot receive funds")
+	}
 	txID := tdb_types.ID()
 	id := uuid.New()
+	now := time.Now()
 	withdrawal := &models.Withdrawal{
 		ID:              id.String(),
 		WalletID:        wallet.Data.ID,
@@ -73,66 +144,41 @@ func (w *withdrawalService) CreateUserWithdrawal(ctx context.Context, req *reque
 		Recipient: &models.Recipient{
 			Type: models.Internal_RecipientType,
 			Details: &models.RecipientDetails{
-				Name:           utils.String(destination.Data.User.FirstName),
-				DestinationTag: utils.String(destination.Data.User.ID),
+				Name:           utils.String(recipient.FirstName),
+				DestinationTag: utils.String(recipient.ID),
 			},
 		},
+		Amount:    amount,
+		CreatedAt: now,
 	}
 
-	tx, err := w.dataDB.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-	// Defer a rollback in case anything fails.
-	defer tx.Rollback()
-
-	_, err = sq.
-		Insert("withdrawals").
-		Columns(
-			"id", "wallet_id", "ref", "tx_id", "transaction_note", "narration",
-			"status", "recipient_type", "recipient_details_name",
-			"recipient_details_destination_tag", "recipient_details_address",
-		).
-		Values(
-			withdrawal.ID, withdrawal.WalletID, withdrawal.Ref, withdrawal.TxID, withdrawal.TransactionNote, withdrawal.Narration,
-			withdrawal.Status, withdrawal.Recipient.Type, withdrawal.Recipient.Details.Name,
-			withdrawal.Recipient.Details.DestinationTag, withdrawal.Recipient.Details.Address,
-		).
-		RunWith(tx).
-		ExecContext(ctx)
-
-		// TODO: create corresponding `internal` deposit for recipient wallet
-
-	if err != nil {
-		return nil, err
-	}
-
-	now := time.Now()
 	trf := tdb_types.Transfer{
 		ID:              txID,
 		DebitAccountID:  walletID,
 		CreditAccountID: destinationID,
 		Amount:          utils.ToAmount(amount),
-		Ledger:          LedgerIDs[req.Currency],
+		Ledger:          LedgerIDs[environment(ctx)][req.Currency],
 		UserData128:     tdb_types.BytesToUint128(uuid.MustParse(wallet.Data.User.ID)),
-		Code:            2,
-	}
-	res, err := w.transactionDB.CreateTransfers([]tdb_types.Transfer{trf})
-	if err != nil {
-		return nil, errors.HandleTxDBError(err)
+		Code:            withdrawal_TransferCode,
 	}
-	if len(res) > 0 {
-		for _, r := range res {
-			if r.Result == tdb_types.TransferExceedsCredits {
-				return nil, errors.NewFailedDependencyError("Insufficient Balance")
-			}
+
+	// * the withdrawal is recorded as pending until its transfer has been made, its limit usage is reserved
+	// * in the same transaction so withdrawals made together can not exceed the limits between them
+	saga := withdrawalSaga{WithdrawalID: withdrawal.ID, Transfer: trf, WalletID: wallet.Data.ID, Amount: amount, ReservedAt: now}
+	err = w.sagaService.Run(ctx, models.Withdrawal_SagaKind, saga, func(ctx context.Context) error {
+		err := w.limitService.ReserveLimit(ctx, wallet.Data.User, wallet.Data.ID, req.Currency, models.Withdrawal_LimitOperation, amount, now)
+		if err != nil {
+			return err
 		}
-		return nil, errors.NewUnknownError(res[0].Result.String())
+		pending := *withdrawal
+		pending.Status = models.Pending_WithdrawalStatus
+		return w.withdrawalRepository.Create(ctx, &pending)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if err = tx.Commit(); err != nil {
-		return nil, errors.HandleDataDBError(err)
-	}
+	// TODO: create corresponding `internal` deposit for recipient wallet
 
 	// ?todo make asynchronous when third party payment processor implemented
 	data := &responses.WithdrawalResponseData{
@@ -153,7 +199,7 @@ func (w *withdrawalService) CreateUserWithdrawal(ctx context.Context, req *reque
 		Wallet:          wallet.Data,
 		User:            wallet.Data.User,
 	}
-	go w.webhookService.SendWithdrawalSuccessfulEvent(ctx.Value("user").(*models.Account).WebhookDetails, data)
+	go w.webhookService.SendWithdrawalSuccessfulEvent(wallet.Data.User.WebhookDetails, data)
 
 	return &responses.Response[*responses.WithdrawalResponseData]{
 		Data: data,
@@ -166,46 +212,17 @@ func (w *withdrawalService) FetchWithdrawal(ctx context.Context, req *requests.F
 		return nil, err
 	}
 
-	stmt := sq.
-		Select(
-			"withdrawals.id", "withdrawals.ref", "withdrawals.tx_id", "withdrawals.transaction_note",
-			"withdrawals.narration", "withdrawals.status", "withdrawals.recipient_type", "withdrawals.recipient_details_name",
-			"withdrawals.recipient_details_destination_tag", "withdrawals.recipient_details_address",
-
-			"wallets.id",
-		).
-		From("withdrawals").
-		Join("wallets on withdrawals.wallet_id = wallets.id").
-		Where(sq.Or{sq.Eq{"wallets.account_id": user.Data.ID}, sq.Eq{"withdrawals.recipient_details_destination_tag": user.Data.ID}})
-
-	switch "" {
-	case req.Reference:
-		stmt = stmt.Where(sq.Eq{"withdrawals.id": req.WithdrawalID})
-	case req.WithdrawalID:
-		stmt = stmt.Where(sq.Eq{"withdrawals.ref": req.Reference})
-	default:
-		panic("unreachable")
-	}
-
-	row := stmt.RunWith(w.dataDB).QueryRowContext(ctx)
-
-	withdrawal := &responses.WithdrawalResponseData{
-		Recipient: &models.Recipient{
-			Details: &models.RecipientDetails{},
-		},
-		Wallet: &responses.UserWalletResponseData{},
-		User:   &models.Account{},
-	}
-	err = row.Scan(
-		&withdrawal.ID, &withdrawal.Reference, &withdrawal.TransactionID, &withdrawal.TransactionNote,
-		&withdrawal.Narration, &withdrawal.Status, &withdrawal.Recipient.Type, &withdrawal.Recipient.Details.Name,
-		&withdrawal.Recipient.Details.DestinationTag, &withdrawal.Recipient.Details.Address,
-
-		&withdrawal.Wallet.ID,
-	)
+	env := environment(ctx)
+	found, err := w.withdrawalRepository.Find(ctx, repositories.WithdrawalFilter{
+		ID:          req.WithdrawalID,
+		Reference:   req.Reference,
+		AccountID:   user.Data.ID,
+		Environment: &env,
+	})
 	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
 	}
+	withdrawal := withdrawalData(found)
 
 	data, err := w.populateWithdrawals(ctx, map[string]*responses.WithdrawalResponseData{withdrawal.TransactionID: withdrawal}, user.Data)
 	if err != nil {
@@ -228,62 +245,65 @@ func (w *withdrawalService) FetchWithdrawals(ctx context.Context, req *requests.
 		return nil, err
 	}
 
-	stmt := sq.
-		Select(
-			"withdrawals.id", "withdrawals.ref", "withdrawals.tx_id", "withdrawals.transaction_note",
-			"withdrawals.narration", "withdrawals.status", "withdrawals.recipient_type", "withdrawals.recipient_details_name",
-			"withdrawals.recipient_details_destination_tag", "withdrawals.recipient_details_address",
-
-			"wallets.id",
-		).
-		From("withdrawals").
-		Join("wallets on withdrawals.wallet_id = wallets.id").
-		Where(sq.Or{sq.Eq{"wallets.account_id": user.Data.ID}, sq.Eq{"withdrawals.recipient_details_destination_tag": user.Data.ID}})
-
-	if req.State != nil {
-		stmt = stmt.Where(sq.Eq{"withdrawals.state": *req.State})
-	}
-	if req.Currency != nil {
-		stmt = stmt.Where(sq.Eq{"wallets.token": *req.Currency})
-	}
-
-	rows, err := stmt.RunWith(w.dataDB).QueryContext(ctx)
+	env := environment(ctx)
+	from, to := req.Period()
+	withdrawals, pagination, err := w.withdrawalRepository.List(ctx, repositories.WithdrawalFilter{
+		Reference:   req.Reference,
+		AccountID:   user.Data.ID,
+		Environment: &env,
+		Status:      req.State,
+		Currency:    req.Currency,
+		From:        from,
+		To:          to,
+		MinAmount:   req.MinAmount,
+		MaxAmount:   req.MaxAmount,
+	}, &req.Pagination)
 	if err != nil {
-		return nil, errors.HandleDataDBError(err)
+		return nil, err
 	}
-
-	withdrawals := map[string]*responses.WithdrawalResponseData{}
-	for rows.Next() {
-		withdrawal := &responses.WithdrawalResponseData{
-			Recipient: &models.Recipient{
-				Details: &models.RecipientDetails{},
-			},
-			Wallet: &responses.UserWalletResponseData{},
-			User:   &models.Account{},
-		}
-		err = rows.Scan(
-			&withdrawal.ID, &withdrawal.Reference, &withdrawal.TransactionID, &withdrawal.TransactionNote,
-			&withdrawal.Narration, &withdrawal.Status, &withdrawal.Recipient.Type, &withdrawal.Recipient.Details.Name,
-			&withdrawal.Recipient.Details.DestinationTag, &withdrawal.Recipient.Details.Address,
-
-			&withdrawal.Wallet.ID,
-		)
-		if err != nil {
-			return nil, errors.HandleDataDBError(err)
-		}
-		withdrawals[withdrawal.TransactionID] = withdrawal
+	page := make([]*responses.WithdrawalResponseData, 0, len(withdrawals))
+	for _, withdrawal := range withdrawals {
+		page = append(page, withdrawalData(withdrawal))
 	}
 
-	data, err := w.populateWithdrawals(ctx, withdrawals, user.Data)
+	order := make(map[string]int, len(page))
+	byTransaction := make(map[string]*responses.WithdrawalResponseData, len(page))
+	for i, withdrawal := range page {
+		order[withdrawal.ID] = i
+		byTransaction[withdrawal.TransactionID] = withdrawal
+	}
+	// the ledger returns transfers in its own order, keep the order of the page
+	data, err := w.populateWithdrawals(ctx, byTransaction, user.Data)
 	if err != nil {
 		return nil, err
 	}
+	slices.SortFunc(data, func(a, b *responses.WithdrawalResponseData) int {
+		return order[a.ID] - order[b.ID]
+	})
 
 	return &responses.Response[[]*responses.WithdrawalResponseData]{
-		Data: data,
+		Data:       data,
+		Pagination: pagination,
 	}, nil
 }
 
+// withdrawalData returns the stored fields of the withdrawal, the rest are populated from its transfer and wallet
+func withdrawalData(withdrawal *models.Withdrawal) *responses.WithdrawalResponseData {
+	return &responses.WithdrawalResponseData{
+		ID:              withdrawal.ID,
+		Reference:       withdrawal.Ref,
+		TransactionID:   withdrawal.TxID,
+		TransactionNote: withdrawal.TransactionNote,
+		Narration:       withdrawal.Narration,
+		Status:          withdrawal.Status,
+		Reason:          withdrawal.Reason,
+		CreatedAt:       withdrawal.CreatedAt,
+		Recipient:       withdrawal.Recipient,
+		Wallet:          &responses.UserWalletResponseData{ID: withdrawal.WalletID},
+		User:            &models.Account{},
+	}
+}
+
 func (w *withdrawalService) populateWithdrawals(ctx context.Context, withdrawals map[string]*responses.WithdrawalResponseData, user *models.Account) ([]*responses.WithdrawalResponseData, error) {
 	walletIds := make([]string, 0)
 	transferIds := make([]tdb_types.Uint128, 0)
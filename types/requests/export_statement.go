@@ -0,0 +1,13 @@
+package requests
+
+import "github.com/2HgO/quidax-go/models"
+
+type ExportStatementRequest struct {
+	UserID   string                 `uri:"user_id" validate:"required"`
+	From     string                 `query:"from" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	To       string                 `query:"to" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	Currency string                 `query:"currency" validate:"omitempty,oneof=ngn usdt usdc eth bnb sol btc"`
+	Format   models.StatementFormat `query:"format"`
+	// exports in the background even when the statement is small enough to stream
+	Async bool `query:"async"`
+}
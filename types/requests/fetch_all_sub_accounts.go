@@ -1,4 +1,5 @@
 package requests
 
 type FetchAllSubAccountsRequest struct {
+	Pagination
 }
@@ -2,5 +2,10 @@ package requests
 
 type FetchDepositsRequest struct {
 	UserID   string `uri:"user_id"`
-	Currency string `uri:"currency" validate:"omitempty,oneof=ngn usdt usdc eth bnb sol btc"`
+	Currency string `uri:"currency" query:"currency" validate:"omitempty,oneof=ngn usdt usdc eth bnb sol btc"`
+	// deposits are settled when they are recorded so they are always completed
+	State string `query:"state" validate:"omitempty,oneof=completed"`
+
+	TransactionFilter
+	Pagination
 }
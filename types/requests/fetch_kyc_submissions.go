@@ -0,0 +1,7 @@
+package requests
+
+type FetchKYCSubmissionsRequest struct {
+	UserID string `uri:"user_id" validate:"required"`
+
+	Pagination
+}
@@ -0,0 +1,6 @@
+package requests
+
+type FetchLedgerPositionsRequest struct {
+	Environment string `query:"environment" validate:"omitempty,oneof=test live"`
+	Currency    string `query:"currency" validate:"omitempty,oneof=ngn usdt usdc eth bnb sol btc"`
+}
@@ -0,0 +1,6 @@
+package requests
+
+type FetchLiabilityProofRequest struct {
+	UserID     string `uri:"user_id" validate:"required"`
+	SnapshotID string `uri:"snapshot_id" validate:"required"`
+}
@@ -0,0 +1,5 @@
+package requests
+
+type FetchLiabilitySnapshotRequest struct {
+	SnapshotID string `uri:"snapshot_id" validate:"required"`
+}
@@ -0,0 +1,6 @@
+package requests
+
+type FetchLiabilitySnapshotsRequest struct {
+	Environment string `query:"environment" validate:"omitempty,oneof=test live"`
+	Pagination
+}
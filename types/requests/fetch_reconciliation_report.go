@@ -0,0 +1,5 @@
+package requests
+
+type FetchReconciliationReportRequest struct {
+	ReconciliationID string `uri:"reconciliation_id" validate:"required"`
+}
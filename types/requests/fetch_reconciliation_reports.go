@@ -0,0 +1,5 @@
+package requests
+
+type FetchReconciliationReportsRequest struct {
+	Pagination
+}
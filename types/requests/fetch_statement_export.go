@@ -0,0 +1,6 @@
+package requests
+
+type FetchStatementExportRequest struct {
+	UserID   string `uri:"user_id" validate:"required"`
+	ExportID string `uri:"export_id" validate:"required"`
+}
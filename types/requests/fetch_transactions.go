@@ -0,0 +1,12 @@
+package requests
+
+import "github.com/2HgO/quidax-go/models"
+
+type FetchTransactionsRequest struct {
+	UserID   string                  `uri:"user_id" validate:"required"`
+	Currency string                  `query:"currency" validate:"omitempty,oneof=ngn usdt usdc eth bnb sol btc"`
+	Type     *models.TransactionType `query:"type"`
+
+	TransactionFilter
+	Pagination
+}
@@ -0,0 +1,5 @@
+package requests
+
+type FetchUserLimitsRequest struct {
+	UserID string `uri:"user_id" validate:"required"`
+}
@@ -0,0 +1,10 @@
+package requests
+
+type FetchWalletBalanceRequest struct {
+	UserID   string `uri:"user_id" validate:"required"`
+	Currency string `uri:"currency" validate:"required,oneof=ngn usdt usdc eth bnb sol btc"`
+	// balance as of the time given
+	At string `query:"at" validate:"required_without=Before,excluded_with=Before,omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	// balance right before the transaction with the id given was applied
+	Before string `query:"before"`
+}
@@ -0,0 +1,10 @@
+package requests
+
+type FetchWalletBalanceHistoryRequest struct {
+	UserID   string `uri:"user_id" validate:"required"`
+	Currency string `uri:"currency" validate:"required,oneof=ngn usdt usdc eth bnb sol btc"`
+	From     string `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To       string `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+
+	Pagination
+}
@@ -6,4 +6,7 @@ type FetchWithdrawalsRequest struct {
 	UserID   string                   `uri:"user_id" validate:"required"`
 	Currency *string                  `query:"currency" validate:"omitempty,oneof=ngn usdt usdc eth bnb sol btc"`
 	State    *models.WithdrawalStatus `query:"state"`
+
+	TransactionFilter
+	Pagination
 }
@@ -1,5 +1,10 @@
 package requests
 
 type GetInstantSwapTransactionsRequest struct {
-	UserID string `uri:"user_id" validate:"required"`
+	UserID   string `uri:"user_id" validate:"required"`
+	Currency string `query:"currency" validate:"omitempty,oneof=ngn usdt usdc eth bnb sol btc"`
+	State    string `query:"state" validate:"omitempty,oneof=pending confirmed reversed failed"`
+
+	TransactionFilter
+	Pagination
 }
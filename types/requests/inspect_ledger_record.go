@@ -0,0 +1,7 @@
+package requests
+
+type InspectLedgerRecordRequest struct {
+	ID string `uri:"ledger_record_id" validate:"required"`
+	// number of an account's latest transfers to include
+	Transfers uint32 `query:"transfers" default:"10" validate:"max=8190"`
+}
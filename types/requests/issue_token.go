@@ -0,0 +1,19 @@
+package requests
+
+import "github.com/2HgO/quidax-go/models"
+
+type IssueTokenRequest struct {
+	UserID      string              `json:"user_id" validate:"required"`
+	Environment *models.Environment `json:"environment" validate:"required"`
+	Name        string              `json:"name" validate:"required"`
+	Description string              `json:"description"`
+	// limits of the token in place of the route groups' defaults
+	RateLimits []TokenRateLimit `json:"rate_limits" validate:"dive"`
+}
+
+// TokenRateLimit is a token's limit for a route group, `*` applies to every group without its own limit
+type TokenRateLimit struct {
+	RouteGroup string  `json:"route_group" validate:"required,oneof=* accounts wallets swaps withdrawals deposits markets transactions proofs"`
+	Rate       float64 `json:"rate" validate:"gt=0"`
+	Burst      int     `json:"burst" validate:"gt=0"`
+}
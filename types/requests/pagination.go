@@ -0,0 +1,12 @@
+package requests
+
+// Pagination is embedded in list requests, a cursor from a previous page takes precedence over the page number
+type Pagination struct {
+	Page    int    `query:"page" default:"1" validate:"min=1"`
+	PerPage int    `query:"per_page" default:"20" validate:"min=1,max=100"`
+	Cursor  string `query:"cursor"`
+}
+
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PerPage
+}
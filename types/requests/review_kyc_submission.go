@@ -0,0 +1,10 @@
+package requests
+
+import "github.com/2HgO/quidax-go/models"
+
+type ReviewKYCSubmissionRequest struct {
+	UserID       string           `uri:"user_id" validate:"required"`
+	SubmissionID string           `uri:"submission_id" validate:"required"`
+	Status       models.KYCStatus `json:"status" validate:"oneof=1 2"`
+	Reason       *string          `json:"reason"`
+}
@@ -0,0 +1,5 @@
+package requests
+
+type RevokeTokenRequest struct {
+	TokenID string `uri:"token_id" validate:"required"`
+}
@@ -0,0 +1,15 @@
+package requests
+
+import "github.com/2HgO/quidax-go/models"
+
+type SetSubAccountLimitRequest struct {
+	// UserID is the main account whose sub accounts the limit applies to
+	UserID    string                 `uri:"user_id" validate:"required"`
+	Tier      models.KYCTier         `json:"tier" validate:"required,oneof=1 2"`
+	Currency  string                 `json:"currency" validate:"required,oneof=ngn usdt usdc eth bnb sol btc"`
+	Operation *models.LimitOperation `json:"operation" validate:"required"`
+	// caps left out fall back to the tier's default
+	Single  *float64 `json:"single" validate:"omitnil,gt=0"`
+	Daily   *float64 `json:"daily" validate:"omitnil,gt=0"`
+	Monthly *float64 `json:"monthly" validate:"omitnil,gt=0"`
+}
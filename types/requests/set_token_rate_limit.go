@@ -0,0 +1,8 @@
+package requests
+
+type SetTokenRateLimitRequest struct {
+	TokenID    string  `uri:"token_id" validate:"required"`
+	RouteGroup string  `json:"route_group" validate:"required,oneof=* accounts wallets swaps withdrawals deposits markets transactions proofs"`
+	Rate       float64 `json:"rate" validate:"gt=0"`
+	Burst      int     `json:"burst" validate:"gt=0"`
+}
@@ -0,0 +1,7 @@
+package requests
+
+import "github.com/2HgO/quidax-go/models"
+
+type StartLiabilitySnapshotRequest struct {
+	Environment *models.Environment `json:"environment" validate:"required"`
+}
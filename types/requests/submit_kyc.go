@@ -0,0 +1,13 @@
+package requests
+
+import "github.com/2HgO/quidax-go/models"
+
+type SubmitKYCRequest struct {
+	UserID      string         `uri:"user_id" validate:"required"`
+	Tier        models.KYCTier `json:"tier" validate:"required,oneof=1 2"`
+	PhoneNumber string         `json:"phone_number" validate:"required,e164"`
+	DateOfBirth string         `json:"date_of_birth" validate:"required,datetime=2006-01-02"`
+	Country     string         `json:"country" validate:"required,iso3166_1_alpha2"`
+	IDType      string         `json:"id_type" validate:"required_if=Tier 2,omitempty,oneof=passport national_id drivers_license"`
+	IDNumber    string         `json:"id_number" validate:"required_if=Tier 2"`
+}
@@ -0,0 +1,29 @@
+package requests
+
+import "time"
+
+// TransactionFilter is embedded in requests listing deposits, withdrawals and swaps
+type TransactionFilter struct {
+	From      string  `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To        string  `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	MinAmount float64 `query:"min_amount" validate:"gte=0"`
+	MaxAmount float64 `query:"max_amount" validate:"omitempty,gtefield=MinAmount"`
+	Reference string  `query:"reference"`
+}
+
+// Period returns the creation time range to filter on, bounds that were not set are nil
+func (t TransactionFilter) Period() (from *time.Time, to *time.Time) {
+	if t.From != "" {
+		ts, _ := time.Parse(time.RFC3339, t.From)
+		from = &ts
+	}
+	if t.To != "" {
+		ts, _ := time.Parse(time.RFC3339, t.To)
+		to = &ts
+	}
+	return from, to
+}
+
+func (t TransactionFilter) MatchesAmount(amount float64) bool {
+	return amount >= t.MinAmount && (t.MaxAmount == 0 || amount <= t.MaxAmount)
+}
@@ -3,6 +3,7 @@ package responses
 import "github.com/2HgO/quidax-go/models"
 
 type CreateAccountResponseData struct {
-	User  *models.Account     `json:"user"`
-	Token *models.AccessToken `json:"token"`
+	User      *models.Account     `json:"user"`
+	Token     *models.AccessToken `json:"token"`
+	LiveToken *models.AccessToken `json:"live_token"`
 }
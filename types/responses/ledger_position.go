@@ -0,0 +1,29 @@
+package responses
+
+import (
+	"time"
+
+	"github.com/2HgO/quidax-go/models"
+)
+
+type LedgerPositionResponseData struct {
+	Ledger          uint32             `json:"ledger"`
+	Environment     models.Environment `json:"environment"`
+	Currency        string             `json:"currency"`
+	SystemAccountID string             `json:"system_account_id"`
+	// posted credits less posted debits of the system account, negative while the house owes funds it has credited to wallets
+	SystemPosition       float64 `json:"system_position,string"`
+	SystemDebitsPending  float64 `json:"system_debits_pending,string"`
+	SystemCreditsPending float64 `json:"system_credits_pending,string"`
+	Wallets              int     `json:"wallets"`
+	// posted balances of every wallet on the ledger
+	Liabilities float64 `json:"liabilities,string"`
+	// funds held on wallets by pending transfers
+	PendingDebits float64 `json:"pending_debits,string"`
+	// funds pending to be credited to wallets
+	PendingCredits float64 `json:"pending_credits,string"`
+	// liabilities once every pending transfer is posted
+	NetExposure     float64   `json:"net_exposure,string"`
+	NetExposureUSDT float64   `json:"net_exposure_usdt,string"`
+	AsOf            time.Time `json:"as_of"`
+}
@@ -0,0 +1,50 @@
+package responses
+
+import (
+	"time"
+
+	"github.com/2HgO/quidax-go/models"
+)
+
+// LedgerRecordResponseData is either a ledger account with its latest transfers, newest first, or a transfer
+type LedgerRecordResponseData struct {
+	Account   *LedgerAccountResponseData    `json:"account,omitempty"`
+	Transfers []*LedgerTransferResponseData `json:"transfers,omitempty"`
+	Transfer  *LedgerTransferResponseData   `json:"transfer,omitempty"`
+}
+
+type LedgerAccountResponseData struct {
+	ID             string             `json:"id"`
+	Ledger         uint32             `json:"ledger"`
+	Environment    models.Environment `json:"environment"`
+	Currency       string             `json:"currency"`
+	Code           uint16             `json:"code"`
+	Flags          []string           `json:"flags"`
+	DebitsPending  float64            `json:"debits_pending,string"`
+	DebitsPosted   float64            `json:"debits_posted,string"`
+	CreditsPending float64            `json:"credits_pending,string"`
+	CreditsPosted  float64            `json:"credits_posted,string"`
+	UserData128    string             `json:"user_data_128"`
+	UserData64     uint64             `json:"user_data_64"`
+	UserData32     uint32             `json:"user_data_32"`
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+type LedgerTransferResponseData struct {
+	ID              string             `json:"id"`
+	DebitAccountID  string             `json:"debit_account_id"`
+	CreditAccountID string             `json:"credit_account_id"`
+	Amount          float64            `json:"amount,string"`
+	PendingID       string             `json:"pending_id,omitempty"`
+	Ledger          uint32             `json:"ledger"`
+	Environment     models.Environment `json:"environment"`
+	Currency        string             `json:"currency"`
+	Code            uint16             `json:"code"`
+	Flags           []string           `json:"flags"`
+	// seconds a pending transfer holds its funds for, 0 holds them until it is posted or voided
+	Timeout     uint32    `json:"timeout"`
+	UserData128 string    `json:"user_data_128"`
+	UserData64  uint64    `json:"user_data_64"`
+	UserData32  uint32    `json:"user_data_32"`
+	CreatedAt   time.Time `json:"created_at"`
+}
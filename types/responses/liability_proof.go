@@ -0,0 +1,19 @@
+package responses
+
+import (
+	"github.com/2HgO/quidax-go/merkle"
+	"github.com/2HgO/quidax-go/models"
+)
+
+type LiabilityProof struct {
+	Currency string        `json:"currency"`
+	Proof    *merkle.Proof `json:"proof"`
+}
+
+type LiabilityProofResponseData struct {
+	SnapshotID      string             `json:"snapshot_id"`
+	Environment     models.Environment `json:"environment"`
+	LedgerTimestamp uint64             `json:"ledger_timestamp"`
+	// one proof for every currency the user held a balance in at the snapshot
+	Proofs []*LiabilityProof `json:"proofs"`
+}
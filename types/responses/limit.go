@@ -0,0 +1,28 @@
+package responses
+
+import "github.com/2HgO/quidax-go/models"
+
+type LimitUsage struct {
+	// nil when the period is not limited
+	Limit     *float64 `json:"limit"`
+	Used      float64  `json:"used"`
+	Remaining *float64 `json:"remaining"`
+}
+
+type UserLimitResponseData struct {
+	Currency  string                `json:"currency"`
+	Operation models.LimitOperation `json:"operation"`
+	Single    *float64              `json:"single"`
+	Daily     LimitUsage            `json:"daily"`
+	Monthly   LimitUsage            `json:"monthly"`
+}
+
+// SubAccountLimitResponseData is the limit sub accounts of the tier get, nil caps are not limited
+type SubAccountLimitResponseData struct {
+	Tier      models.KYCTier        `json:"tier"`
+	Currency  string                `json:"currency"`
+	Operation models.LimitOperation `json:"operation"`
+	Single    *float64              `json:"single"`
+	Daily     *float64              `json:"daily"`
+	Monthly   *float64              `json:"monthly"`
+}
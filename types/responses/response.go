@@ -1,7 +1,15 @@
 package responses
 
 type Response[T any] struct {
-	Status  string `json:"status"`
-	Message string `json:"message,omitempty"`
-	Data    T      `json:"data"`
+	Status     string      `json:"status"`
+	Message    string      `json:"message,omitempty"`
+	Data       T           `json:"data"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+type Pagination struct {
+	Page       int    `json:"page"`
+	PerPage    int    `json:"per_page"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
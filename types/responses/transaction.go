@@ -0,0 +1,26 @@
+package responses
+
+import (
+	"time"
+
+	"github.com/2HgO/quidax-go/models"
+)
+
+type TransactionResponseData struct {
+	// id of the ledger transfer
+	ID       string                 `json:"id"`
+	Type     models.TransactionType `json:"type"`
+	WalletID string                 `json:"wallet_id"`
+	Currency string                 `json:"currency"`
+	// credit or debit, from the wallet's point of view
+	Direction string  `json:"direction"`
+	Amount    float64 `json:"amount,string"`
+	// pending, completed or reversed
+	Status string `json:"status"`
+	// id of the deposit, withdrawal or swap the transfer belongs to
+	Reference string `json:"reference"`
+	// wallet balances once the transfer was applied
+	Balance       float64   `json:"balance,string"`
+	LockedBalance float64   `json:"locked,string"`
+	CreatedAt     time.Time `json:"created_at"`
+}
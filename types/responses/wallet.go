@@ -21,4 +21,5 @@ type UserWalletResponseData struct {
 	UpdatedAt         time.Time       `json:"updated_at"`
 	ReferenceCurrency string          `json:"reference_currency"`
 	IsCrypto          bool            `json:"is_crypto"`
+	Frozen            bool            `json:"frozen"`
 }
@@ -0,0 +1,16 @@
+package responses
+
+import "time"
+
+type WalletBalanceResponseData struct {
+	WalletID       string  `json:"wallet_id"`
+	Currency       string  `json:"currency"`
+	DebitsPending  float64 `json:"debits_pending,string"`
+	DebitsPosted   float64 `json:"debits_posted,string"`
+	CreditsPending float64 `json:"credits_pending,string"`
+	CreditsPosted  float64 `json:"credits_posted,string"`
+	Balance        float64 `json:"balance,string"`
+	LockedBalance  float64 `json:"locked,string"`
+	// time of the last transfer applied to the balance, nil when no transfer was applied yet
+	UpdatedAt *time.Time `json:"updated_at"`
+}
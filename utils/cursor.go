@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/2HgO/quidax-go/errors"
+)
+
+// EncodeCursor serializes the position of the last item of a page into an opaque token clients pass back for the next page
+func EncodeCursor(position any) string {
+	data, err := json.Marshal(position)
+	if err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(cursor string, position any) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return errors.NewValidationError("invalid cursor")
+	}
+	if err = json.Unmarshal(data, position); err != nil {
+		return errors.NewValidationError("invalid cursor")
+	}
+	return nil
+}
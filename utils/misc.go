@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"time"
+
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
 )
@@ -10,3 +12,11 @@ var Formatter = message.NewPrinter(language.English)
 func String(s string) *string {
 	return &s
 }
+
+func Float64(f float64) *float64 {
+	return &f
+}
+
+func Time(t time.Time) *time.Time {
+	return &t
+}
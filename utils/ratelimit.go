@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TokenBucket is a token bucket refilled continuously at Rate tokens per second up to Burst tokens
+type TokenBucket struct {
+	Rate  float64
+	Burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+type TokenBucketState struct {
+	Limit     int
+	Remaining int
+	// time until the bucket is full again
+	Reset time.Duration
+	// time until the next token is available, zero when a token was taken
+	RetryAfter time.Duration
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{Rate: rate, Burst: burst, tokens: float64(burst)}
+}
+
+func (t *TokenBucket) refill(now time.Time) {
+	if !t.last.IsZero() {
+		t.tokens = math.Min(float64(t.Burst), t.tokens+now.Sub(t.last).Seconds()*t.Rate)
+	}
+	t.last = now
+}
+
+// Take removes a token from the bucket, reporting false when the bucket is empty
+func (t *TokenBucket) Take(now time.Time) (bool, TokenBucketState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.refill(now)
+	ok := t.tokens >= 1
+	if ok {
+		t.tokens--
+	}
+
+	state := TokenBucketState{
+		Limit:     t.Burst,
+		Remaining: int(math.Floor(t.tokens)),
+		Reset:     t.fillTime(float64(t.Burst) - t.tokens),
+	}
+	if !ok {
+		state.RetryAfter = t.fillTime(1 - t.tokens)
+	}
+	return ok, state
+}
+
+// Idle reports whether the bucket has refilled completely and can be discarded
+func (t *TokenBucket) Idle(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.refill(now)
+	return t.tokens >= float64(t.Burst)
+}
+
+func (t *TokenBucket) fillTime(tokens float64) time.Duration {
+	if tokens <= 0 || t.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens / t.Rate * float64(time.Second)))
+}